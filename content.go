@@ -30,6 +30,7 @@ import (
 	"oras.land/oras-go/v2/internal/interfaces"
 	"oras.land/oras-go/v2/internal/platform"
 	"oras.land/oras-go/v2/internal/syncutil"
+	"oras.land/oras-go/v2/platforms"
 	"oras.land/oras-go/v2/registry"
 	"oras.land/oras-go/v2/registry/remote/auth"
 )
@@ -181,6 +182,12 @@ type ResolveOptions struct {
 	// matches the target platform if the node is a manifest list.
 	TargetPlatform *ocispec.Platform
 
+	// PlatformMatcher selects the resolved content best matching the
+	// platforms accepted by the matcher, such as [platforms.Only] which
+	// accepts the compatible platforms of the target platform as fallbacks.
+	// If PlatformMatcher is provided, TargetPlatform is ignored.
+	PlatformMatcher platforms.MatchComparer
+
 	// MaxMetadataBytes limits the maximum size of metadata that can be cached
 	// in the memory.
 	// If less than or equal to 0, a default (currently 4 MiB) is used.
@@ -189,7 +196,7 @@ type ResolveOptions struct {
 
 // Resolve resolves a descriptor with provided reference from the target.
 func Resolve(ctx context.Context, target ReadOnlyTarget, reference string, opts ResolveOptions) (ocispec.Descriptor, error) {
-	if opts.TargetPlatform == nil {
+	if opts.TargetPlatform == nil && opts.PlatformMatcher == nil {
 		return target.Resolve(ctx, reference)
 	}
 	return resolve(ctx, target, nil, reference, opts)
@@ -229,7 +236,7 @@ func resolve(ctx context.Context, target ReadOnlyTarget, proxy *cas.Proxy, refer
 			}
 			// stop caching as SelectManifest may fetch a config blob
 			proxy.StopCaching = true
-			return platform.SelectManifest(ctx, proxy, desc, opts.TargetPlatform)
+			return opts.selectManifest(ctx, proxy, desc)
 		default:
 			return ocispec.Descriptor{}, fmt.Errorf("%s: %s: %w", desc.Digest, desc.MediaType, errdef.ErrUnsupported)
 		}
@@ -239,7 +246,16 @@ func resolve(ctx context.Context, target ReadOnlyTarget, proxy *cas.Proxy, refer
 	if err != nil {
 		return ocispec.Descriptor{}, err
 	}
-	return platform.SelectManifest(ctx, target, desc, opts.TargetPlatform)
+	return opts.selectManifest(ctx, target, desc)
+}
+
+// selectManifest selects the manifest matching the target platform or the
+// platform matcher.
+func (opts *ResolveOptions) selectManifest(ctx context.Context, src content.ReadOnlyStorage, root ocispec.Descriptor) (ocispec.Descriptor, error) {
+	if opts.PlatformMatcher != nil {
+		return platforms.SelectManifest(ctx, src, root, opts.PlatformMatcher)
+	}
+	return platform.SelectManifest(ctx, src, root, opts.TargetPlatform)
 }
 
 // DefaultFetchOptions provides the default FetchOptions.
@@ -253,7 +269,7 @@ type FetchOptions struct {
 
 // Fetch fetches the content identified by the reference.
 func Fetch(ctx context.Context, target ReadOnlyTarget, reference string, opts FetchOptions) (ocispec.Descriptor, io.ReadCloser, error) {
-	if opts.TargetPlatform == nil {
+	if opts.TargetPlatform == nil && opts.PlatformMatcher == nil {
 		if refFetcher, ok := target.(registry.ReferenceFetcher); ok {
 			return refFetcher.FetchReference(ctx, reference)
 		}
@@ -0,0 +1,244 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cache provides a pull-through caching target, which fronts a
+// read-only target, such as a remote repository, with a local storage, such
+// as an OCI image layout store.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/resolver"
+)
+
+// errPartialRead is the error for aborting caching a content not fully read.
+var errPartialRead = errors.New("content is not fully read")
+
+// ReadOnlyTarget represents a read-only target fronted by the cache, such as
+// a remote repository.
+type ReadOnlyTarget interface {
+	content.ReadOnlyStorage
+	content.Resolver
+}
+
+// Options contains parameters for [New].
+type Options struct {
+	// TagTTL is the duration for which a reference resolved from the base
+	// target is served from the cache without being revalidated against the
+	// base target.
+	//   - If zero, references are revalidated on every Resolve, while the
+	//     content is still served from the cache.
+	//   - If negative, cached references are never revalidated.
+	//
+	// The revalidation times are kept in the memory, and therefore the
+	// references cached by a previous process are revalidated on their first
+	// Resolve.
+	// References by digests are never revalidated as they are immutable.
+	TagTTL time.Duration
+
+	// ServeStale, if true, serves the cached references when the base target
+	// fails to revalidate them for reasons other than the references not
+	// being found, such as network failures.
+	ServeStale bool
+}
+
+// Target is a pull-through caching target, which serves Fetch, Exists and
+// Resolve from the cache storage when possible, and fills the cache storage
+// with the content fetched from the base target on miss.
+//
+// Failures in filling the cache, such as running out of disk space, do not
+// fail the requests, which are served by the base target instead.
+type Target struct {
+	base      ReadOnlyTarget
+	cache     content.Storage
+	tagCache  content.TagResolver
+	opts      Options
+	lock      sync.Mutex
+	validated map[string]time.Time
+}
+
+// New creates a pull-through caching target fronting base with the cache
+// storage, such as an oci.Store.
+// If the cache storage implements content.TagResolver, the resolved references
+// are cached in it as well. Otherwise, the references are cached in the
+// memory.
+func New(base ReadOnlyTarget, cache content.Storage, opts Options) *Target {
+	tagCache, ok := cache.(content.TagResolver)
+	if !ok {
+		tagCache = resolver.NewMemory()
+	}
+	return &Target{
+		base:      base,
+		cache:     cache,
+		tagCache:  tagCache,
+		opts:      opts,
+		validated: make(map[string]time.Time),
+	}
+}
+
+// Fetch fetches the content identified by the descriptor from the cache
+// storage, or from the base target if the content is not cached.
+// The content fetched from the base target is cached while being read, where
+// the content is cached only if it is read to the end.
+func (t *Target) Fetch(ctx context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
+	if rc, err := t.cache.Fetch(ctx, target); err == nil {
+		return rc, nil
+	}
+	rc, err := t.base.Fetch(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	cr := &cachingReader{
+		rc:   rc,
+		pw:   pw,
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(cr.done)
+		if err := t.cache.Push(ctx, target, pr); err != nil {
+			pr.CloseWithError(err)
+		}
+	}()
+	return cr, nil
+}
+
+// Exists returns true if the described content exists in either the cache
+// storage or the base target.
+func (t *Target) Exists(ctx context.Context, target ocispec.Descriptor) (bool, error) {
+	if exists, err := t.cache.Exists(ctx, target); err == nil && exists {
+		return true, nil
+	}
+	return t.base.Exists(ctx, target)
+}
+
+// Resolve resolves a reference to a descriptor.
+// The reference is resolved from the cache if it is cached and has been
+// validated within TagTTL, or from the base target otherwise, where the
+// resolved manifest is cached.
+func (t *Target) Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error) {
+	cached, cacheErr := t.tagCache.Resolve(ctx, reference)
+	if cacheErr == nil && t.fresh(reference) {
+		return cached, nil
+	}
+
+	desc, err := t.base.Resolve(ctx, reference)
+	if err != nil {
+		if cacheErr == nil && t.opts.ServeStale && !errors.Is(err, errdef.ErrNotFound) {
+			return cached, nil
+		}
+		return ocispec.Descriptor{}, err
+	}
+	if err := t.cacheReference(ctx, desc, reference); err == nil {
+		t.lock.Lock()
+		t.validated[reference] = time.Now()
+		t.lock.Unlock()
+	}
+	return desc, nil
+}
+
+// fresh returns true if the cached reference does not need revalidation.
+func (t *Target) fresh(reference string) bool {
+	if t.opts.TagTTL < 0 || isDigestReference(reference) {
+		return true
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	validatedAt, ok := t.validated[reference]
+	return ok && time.Since(validatedAt) < t.opts.TagTTL
+}
+
+// cacheReference caches the content described by desc and tags it with the
+// reference in the cache.
+func (t *Target) cacheReference(ctx context.Context, desc ocispec.Descriptor, reference string) error {
+	exists, err := t.cache.Exists(ctx, desc)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		manifestJSON, err := content.FetchAll(ctx, t.base, desc)
+		if err != nil {
+			return err
+		}
+		if err := t.cache.Push(ctx, desc, bytes.NewReader(manifestJSON)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+			return err
+		}
+	}
+	return t.tagCache.Tag(ctx, desc, reference)
+}
+
+// isDigestReference returns true if the reference is a digest, or a full
+// reference by digest.
+func isDigestReference(reference string) bool {
+	if i := strings.LastIndex(reference, "@"); i != -1 {
+		reference = reference[i+1:]
+	}
+	_, err := digest.Parse(reference)
+	return err == nil
+}
+
+// cachingReader reads the content from the base target while writing it to
+// the cache storage.
+type cachingReader struct {
+	rc io.ReadCloser
+	// pw is the writer to the cache storage, which is nil once caching is
+	// aborted.
+	pw   *io.PipeWriter
+	eof  bool
+	done chan struct{}
+}
+
+// Read reads the content from the base target, and writes the read content
+// to the cache storage, where caching is aborted on write failures without
+// failing the read.
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	if n > 0 && r.pw != nil {
+		if _, err := r.pw.Write(p[:n]); err != nil {
+			r.pw = nil
+		}
+	}
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+// Close closes the content from the base target, and waits for caching to
+// complete.
+func (r *cachingReader) Close() error {
+	err := r.rc.Close()
+	if r.pw != nil {
+		if r.eof {
+			r.pw.Close()
+		} else {
+			r.pw.CloseWithError(errPartialRead)
+		}
+	}
+	<-r.done
+	return err
+}
@@ -0,0 +1,285 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+)
+
+var errTestNetwork = errors.New("network failure")
+
+// countingTarget counts the requests to the wrapped target, and fails the
+// requests when failing or untagged is set.
+type countingTarget struct {
+	*memory.Store
+	fetches  atomic.Int64
+	resolves atomic.Int64
+	failing  atomic.Bool
+	untagged atomic.Bool
+}
+
+func (t *countingTarget) Fetch(ctx context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
+	t.fetches.Add(1)
+	if t.failing.Load() {
+		return nil, errTestNetwork
+	}
+	return t.Store.Fetch(ctx, target)
+}
+
+func (t *countingTarget) Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error) {
+	t.resolves.Add(1)
+	if t.failing.Load() {
+		return ocispec.Descriptor{}, errTestNetwork
+	}
+	if t.untagged.Load() {
+		return ocispec.Descriptor{}, errdef.ErrNotFound
+	}
+	return t.Store.Resolve(ctx, reference)
+}
+
+// failingStorage is a storage failing all pushes.
+type failingStorage struct {
+	content.Storage
+}
+
+func (failingStorage) Push(ctx context.Context, expected ocispec.Descriptor, r io.Reader) error {
+	return errors.New("disk full")
+}
+
+// pushTagged pushes a manifest to the storage and tags it.
+func pushTagged(t *testing.T, s *memory.Store, manifestJSON []byte, reference string) ocispec.Descriptor {
+	t.Helper()
+	ctx := context.Background()
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestJSON)
+	if err := s.Push(ctx, desc, bytes.NewReader(manifestJSON)); err != nil {
+		t.Fatalf("failed to push test content: %v", err)
+	}
+	if err := s.Tag(ctx, desc, reference); err != nil {
+		t.Fatalf("failed to tag test content: %v", err)
+	}
+	return desc
+}
+
+func TestTarget_Fetch(t *testing.T) {
+	ctx := context.Background()
+	base := &countingTarget{Store: memory.New()}
+	blob := []byte("hello world")
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, blob)
+	if err := base.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+		t.Fatal(err)
+	}
+	store, err := oci.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := New(base, store, Options{})
+
+	// partial reads are not cached
+	rc, err := target.Fetch(ctx, desc)
+	if err != nil {
+		t.Fatalf("Target.Fetch() error = %v", err)
+	}
+	if _, err := rc.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if exists, err := store.Exists(ctx, desc); err != nil || exists {
+		t.Fatalf("cache Exists() = %v, %v, want false", exists, err)
+	}
+
+	// full reads are cached
+	for i := 0; i < 3; i++ {
+		got, err := content.FetchAll(ctx, target, desc)
+		if err != nil {
+			t.Fatalf("Target.Fetch() error = %v", err)
+		}
+		if !bytes.Equal(got, blob) {
+			t.Errorf("Target.Fetch() = %s, want %s", got, blob)
+		}
+	}
+	if got, want := base.fetches.Load(), int64(2); got != want {
+		t.Errorf("base fetches = %d, want %d", got, want)
+	}
+	if exists, err := store.Exists(ctx, desc); err != nil || !exists {
+		t.Errorf("cache Exists() = %v, %v, want true", exists, err)
+	}
+
+	// cached content is served when the base is unavailable
+	base.failing.Store(true)
+	if _, err := content.FetchAll(ctx, target, desc); err != nil {
+		t.Errorf("Target.Fetch() error = %v, wantErr %v", err, false)
+	}
+	exists, err := target.Exists(ctx, desc)
+	if err != nil || !exists {
+		t.Errorf("Target.Exists() = %v, %v, want true", exists, err)
+	}
+}
+
+func TestTarget_Fetch_CacheFailure(t *testing.T) {
+	ctx := context.Background()
+	base := &countingTarget{Store: memory.New()}
+	blob := bytes.Repeat([]byte("x"), 64*1024)
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, blob)
+	if err := base.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+		t.Fatal(err)
+	}
+	target := New(base, failingStorage{memory.New()}, Options{})
+
+	got, err := content.FetchAll(ctx, target, desc)
+	if err != nil {
+		t.Fatalf("Target.Fetch() error = %v, wantErr %v", err, false)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Errorf("Target.Fetch() got %d bytes, want %d", len(got), len(blob))
+	}
+}
+
+func TestTarget_Resolve(t *testing.T) {
+	ctx := context.Background()
+	base := &countingTarget{Store: memory.New()}
+	ref := "latest"
+	v1 := pushTagged(t, base.Store, []byte(`{"layers":[],"v":1}`), ref)
+	store, err := oci.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := New(base, store, Options{TagTTL: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		got, err := target.Resolve(ctx, ref)
+		if err != nil {
+			t.Fatalf("Target.Resolve() error = %v", err)
+		}
+		if !content.Equal(got, v1) {
+			t.Errorf("Target.Resolve() = %v, want %v", got, v1)
+		}
+	}
+	if got, want := base.resolves.Load(), int64(1); got != want {
+		t.Errorf("base resolves = %d, want %d", got, want)
+	}
+	// the manifest and the tag are cached
+	if got, err := store.Resolve(ctx, ref); err != nil || !content.Equal(got, v1) {
+		t.Errorf("cache Resolve() = %v, %v, want %v", got, err, v1)
+	}
+	if _, err := content.FetchAll(ctx, target, v1); err != nil {
+		t.Fatalf("Target.Fetch() error = %v", err)
+	}
+	if got := base.fetches.Load(); got != 1 {
+		t.Errorf("base fetches = %d, want %d", got, 1)
+	}
+
+	// digests are never revalidated
+	if _, err := target.Resolve(ctx, v1.Digest.String()); err != nil {
+		t.Fatalf("Target.Resolve() error = %v", err)
+	}
+	if got, want := base.resolves.Load(), int64(1); got != want {
+		t.Errorf("base resolves = %d, want %d", got, want)
+	}
+}
+
+func TestTarget_Resolve_TagTTL(t *testing.T) {
+	ctx := context.Background()
+	base := &countingTarget{Store: memory.New()}
+	ref := "latest"
+	pushTagged(t, base.Store, []byte(`{"layers":[],"v":1}`), ref)
+	target := New(base, memory.New(), Options{})
+
+	if _, err := target.Resolve(ctx, ref); err != nil {
+		t.Fatalf("Target.Resolve() error = %v", err)
+	}
+	// the tag is moved in the base
+	v2 := pushTagged(t, base.Store, []byte(`{"layers":[],"v":2}`), ref)
+	got, err := target.Resolve(ctx, ref)
+	if err != nil {
+		t.Fatalf("Target.Resolve() error = %v", err)
+	}
+	if !content.Equal(got, v2) {
+		t.Errorf("Target.Resolve() = %v, want %v", got, v2)
+	}
+	if got, want := base.resolves.Load(), int64(2); got != want {
+		t.Errorf("base resolves = %d, want %d", got, want)
+	}
+
+	// negative TTL never revalidates
+	base.resolves.Store(0)
+	target = New(base, memory.New(), Options{TagTTL: -1})
+	for i := 0; i < 3; i++ {
+		if _, err := target.Resolve(ctx, ref); err != nil {
+			t.Fatalf("Target.Resolve() error = %v", err)
+		}
+	}
+	if got, want := base.resolves.Load(), int64(1); got != want {
+		t.Errorf("base resolves = %d, want %d", got, want)
+	}
+}
+
+func TestTarget_Resolve_ServeStale(t *testing.T) {
+	ctx := context.Background()
+	base := &countingTarget{Store: memory.New()}
+	ref := "latest"
+	v1 := pushTagged(t, base.Store, []byte(`{"layers":[],"v":1}`), ref)
+
+	tests := []struct {
+		name       string
+		serveStale bool
+		wantErr    error
+	}{
+		{name: "serve stale", serveStale: true},
+		{name: "no stale", serveStale: false, wantErr: errTestNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base.failing.Store(false)
+			target := New(base, memory.New(), Options{ServeStale: tt.serveStale})
+			if _, err := target.Resolve(ctx, ref); err != nil {
+				t.Fatalf("Target.Resolve() error = %v", err)
+			}
+			base.failing.Store(true)
+			got, err := target.Resolve(ctx, ref)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Target.Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !content.Equal(got, v1) {
+				t.Errorf("Target.Resolve() = %v, want %v", got, v1)
+			}
+		})
+	}
+
+	// tags not found in the base are not served stale
+	base.failing.Store(false)
+	target := New(base, memory.New(), Options{ServeStale: true})
+	if _, err := target.Resolve(ctx, ref); err != nil {
+		t.Fatalf("Target.Resolve() error = %v", err)
+	}
+	base.untagged.Store(true)
+	if _, err := target.Resolve(ctx, ref); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Target.Resolve() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+}
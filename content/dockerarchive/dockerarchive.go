@@ -0,0 +1,329 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dockerarchive provides access to image archives produced by
+// `docker save` and loadable by `docker load`.
+//
+// An archive contains a `manifest.json` file listing the images, where each
+// image refers to its config and layers by their paths in the archive.
+// Reference: https://github.com/moby/moby/blob/v27.0.0/image/spec/v1.2.md
+package dockerarchive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/cas"
+	"oras.land/oras-go/v2/internal/descriptor"
+	"oras.land/oras-go/v2/internal/docker"
+	"oras.land/oras-go/v2/internal/fs/tarfs"
+	"oras.land/oras-go/v2/internal/graph"
+	"oras.land/oras-go/v2/internal/resolver"
+)
+
+// manifestFile is the name of the file listing the images in an archive.
+const manifestFile = "manifest.json"
+
+// manifestEntry describes an image in the `manifest.json` file.
+type manifestEntry struct {
+	// Config is the path of the image config.
+	Config string `json:"Config"`
+	// RepoTags are the references of the image, in the form of
+	// `<repository>:<tag>`.
+	RepoTags []string `json:"RepoTags"`
+	// Layers are the paths of the layers, in the same order as the diff IDs in
+	// the image config.
+	Layers []string `json:"Layers"`
+	// LayerSources maps the diff IDs of the foreign layers to their
+	// descriptors.
+	LayerSources map[digest.Digest]ocispec.Descriptor `json:"LayerSources,omitempty"`
+}
+
+// ReadOnlyStore implements `oras.ReadOnlyGraphTarget`, and represents a
+// read-only content store based on a Docker image archive.
+//
+// As the archive does not contain image manifests, a Docker image manifest
+// (schema 2) is synthesized for each image, where the layers are described as
+// gzip-compressed or uncompressed tarballs according to their content.
+// The synthesized manifests can be resolved by the `RepoTags` of the images
+// or by their digests.
+type ReadOnlyStore struct {
+	fsys        fs.FS
+	blobs       map[digest.Digest]string
+	manifests   *cas.Memory
+	tagResolver *resolver.Memory
+	graph       *graph.Memory
+}
+
+// NewFromFS creates a new read-only store from fsys, which holds the
+// extracted content of a Docker image archive.
+func NewFromFS(ctx context.Context, fsys fs.FS) (*ReadOnlyStore, error) {
+	store := &ReadOnlyStore{
+		fsys:        fsys,
+		blobs:       make(map[digest.Digest]string),
+		manifests:   cas.NewMemory(),
+		tagResolver: resolver.NewMemory(),
+		graph:       graph.NewMemory(),
+	}
+	if err := store.loadManifestFile(ctx); err != nil {
+		return nil, fmt.Errorf("invalid Docker image archive: %w", err)
+	}
+	return store, nil
+}
+
+// NewFromTar creates a new read-only store from a Docker image archive
+// located at path.
+func NewFromTar(ctx context.Context, path string) (*ReadOnlyStore, error) {
+	tfs, err := tarfs.New(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromFS(ctx, tfs)
+}
+
+// Fetch fetches the content identified by the descriptor.
+func (s *ReadOnlyStore) Fetch(ctx context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
+	if exists, err := s.manifests.Exists(ctx, target); err == nil && exists {
+		return s.manifests.Fetch(ctx, target)
+	}
+	blobPath, ok := s.blobs[target.Digest]
+	if !ok {
+		return nil, fmt.Errorf("%s: %s: %w", target.Digest, target.MediaType, errdef.ErrNotFound)
+	}
+	return s.fsys.Open(blobPath)
+}
+
+// Exists returns true if the described content exists.
+func (s *ReadOnlyStore) Exists(ctx context.Context, target ocispec.Descriptor) (bool, error) {
+	if _, ok := s.blobs[target.Digest]; ok {
+		return true, nil
+	}
+	return s.manifests.Exists(ctx, target)
+}
+
+// Resolve resolves a reference to a descriptor.
+//   - If the reference to be resolved is a tag, the returned descriptor will be
+//     a full descriptor declared by github.com/opencontainers/image-spec/specs-go/v1.
+//   - If the reference is a digest, the returned descriptor will be a
+//     plain descriptor (containing only the digest, media type and size).
+func (s *ReadOnlyStore) Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error) {
+	if reference == "" {
+		return ocispec.Descriptor{}, errdef.ErrMissingReference
+	}
+	desc, err := s.tagResolver.Resolve(ctx, reference)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if reference == desc.Digest.String() {
+		return descriptor.Plain(desc), nil
+	}
+	return desc, nil
+}
+
+// Predecessors returns the nodes directly pointing to the current node.
+// Predecessors returns nil without error if the node does not exists in the
+// store.
+func (s *ReadOnlyStore) Predecessors(ctx context.Context, node ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	return s.graph.Predecessors(ctx, node)
+}
+
+// Tags lists the `RepoTags` presented in the `manifest.json` file of the
+// archive, returned in ascending order.
+// If `last` is NOT empty, the entries in the response start after the tag
+// specified by `last`. Otherwise, the response starts from the top of the tags
+// list.
+//
+// See also `Tags()` in the package `registry`.
+func (s *ReadOnlyStore) Tags(ctx context.Context, last string, fn func(tags []string) error) error {
+	var tags []string
+	for tag, desc := range s.tagResolver.Map() {
+		if tag == desc.Digest.String() {
+			continue
+		}
+		if last != "" && tag <= last {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	return fn(tags)
+}
+
+// loadManifestFile reads manifest.json from s.fsys, and synthesizes a
+// manifest for each image listed.
+func (s *ReadOnlyStore) loadManifestFile(ctx context.Context) error {
+	data, err := fs.ReadFile(s.fsys, manifestFile)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest file: %w", err)
+	}
+	var entries []manifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to decode manifest file: %w", err)
+	}
+	for _, entry := range entries {
+		if err := s.loadImage(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadImage synthesizes the manifest of the image described by entry, and
+// tags it by the `RepoTags` of the image and by its digest.
+func (s *ReadOnlyStore) loadImage(ctx context.Context, entry manifestEntry) error {
+	configPath, err := cleanPath(entry.Config)
+	if err != nil {
+		return err
+	}
+	configJSON, err := fs.ReadFile(s.fsys, configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config %s: %w", entry.Config, err)
+	}
+	config := content.NewDescriptorFromBytes(docker.MediaTypeConfig, configJSON)
+	s.blobs[config.Digest] = configPath
+	var image ocispec.Image
+	if err := json.Unmarshal(configJSON, &image); err != nil {
+		return fmt.Errorf("failed to decode config %s: %w", entry.Config, err)
+	}
+	diffIDs := image.RootFS.DiffIDs
+	if len(diffIDs) != len(entry.Layers) {
+		return fmt.Errorf("config %s: found %d diff IDs for %d layers", entry.Config, len(diffIDs), len(entry.Layers))
+	}
+
+	layers := make([]ocispec.Descriptor, 0, len(entry.Layers))
+	for i, layerPath := range entry.Layers {
+		if source, ok := entry.LayerSources[diffIDs[i]]; ok {
+			// foreign layers are not included in the archive
+			layers = append(layers, source)
+			continue
+		}
+		layer, err := s.loadLayer(layerPath)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, layer)
+	}
+
+	manifestJSON, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2,
+		},
+		MediaType: docker.MediaTypeManifest,
+		Config:    config,
+		Layers:    layers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	manifest := content.NewDescriptorFromBytes(docker.MediaTypeManifest, manifestJSON)
+	if err := s.manifests.Push(ctx, manifest, bytes.NewReader(manifestJSON)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return err
+	}
+	if err := s.graph.Index(ctx, s.manifests, manifest); err != nil {
+		return err
+	}
+	if err := s.tagResolver.Tag(ctx, manifest, manifest.Digest.String()); err != nil {
+		return err
+	}
+	for _, repoTag := range entry.RepoTags {
+		if err := s.tagResolver.Tag(ctx, manifest, repoTag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadLayer returns a descriptor describing the layer located at layerPath.
+// The digest of the layer is taken from the path if the layer is stored in
+// the form of `blobs/<algorithm>/<encoded>`, or computed from the content
+// otherwise.
+func (s *ReadOnlyStore) loadLayer(layerPath string) (ocispec.Descriptor, error) {
+	layerPath, err := cleanPath(layerPath)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	fp, err := s.fsys.Open(layerPath)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to open layer %s: %w", layerPath, err)
+	}
+	defer fp.Close()
+
+	br := bufio.NewReader(fp)
+	mediaType := docker.MediaTypeUncompressedLayer
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		mediaType = docker.MediaTypeLayer
+	}
+
+	dgst, ok := digestFromPath(layerPath)
+	var size int64
+	if ok {
+		fi, err := fp.Stat()
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to stat layer %s: %w", layerPath, err)
+		}
+		size = fi.Size()
+	} else {
+		digester := digest.Canonical.Digester()
+		size, err = io.Copy(digester.Hash(), br)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to read layer %s: %w", layerPath, err)
+		}
+		dgst = digester.Digest()
+	}
+	s.blobs[dgst] = layerPath
+
+	return ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    dgst,
+		Size:      size,
+	}, nil
+}
+
+// cleanPath validates and cleans a path listed in `manifest.json`.
+func cleanPath(name string) (string, error) {
+	cleaned := path.Clean(name)
+	if !fs.ValidPath(cleaned) || cleaned == "." {
+		return "", fmt.Errorf("%s: %w", name, fs.ErrInvalid)
+	}
+	return cleaned, nil
+}
+
+// digestFromPath returns the digest of a blob stored in the form of
+// `blobs/<algorithm>/<encoded>`.
+func digestFromPath(name string) (digest.Digest, bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 || parts[0] != "blobs" {
+		return "", false
+	}
+	dgst := digest.NewDigestFromEncoded(digest.Algorithm(parts[1]), parts[2])
+	if err := dgst.Validate(); err != nil {
+		return "", false
+	}
+	return dgst, true
+}
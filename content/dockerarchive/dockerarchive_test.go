@@ -0,0 +1,365 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockerarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/docker"
+)
+
+// testImage describes the content of an image pushed for testing.
+type testImage struct {
+	config       ocispec.Descriptor
+	layer        ocispec.Descriptor
+	gzipLayer    ocispec.Descriptor
+	foreignLayer ocispec.Descriptor
+	manifest     ocispec.Descriptor
+}
+
+// pushTestImage pushes an image with an uncompressed layer, a gzip-compressed
+// layer and a foreign layer to the storage.
+func pushTestImage(t *testing.T, ctx context.Context, s content.Storage, platform ocispec.Platform) testImage {
+	t.Helper()
+	push := func(mediaType string, blob []byte) ocispec.Descriptor {
+		desc := content.NewDescriptorFromBytes(mediaType, blob)
+		if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+			t.Fatalf("failed to push test content: %v", err)
+		}
+		return desc
+	}
+
+	var img testImage
+	layerBlob := []byte("hello " + platform.Architecture)
+	img.layer = push(ocispec.MediaTypeImageLayer, layerBlob)
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte("world " + platform.Architecture)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	img.gzipLayer = push(ocispec.MediaTypeImageLayerGzip, buf.Bytes())
+	img.foreignLayer = content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayerNonDistributableGzip, []byte("foreign"))
+	img.foreignLayer.URLs = []string{"https://example.com/foreign"}
+
+	configJSON, err := json.Marshal(ocispec.Image{
+		Platform: platform,
+		RootFS: ocispec.RootFS{
+			Type: "layers",
+			DiffIDs: []digest.Digest{
+				img.layer.Digest,
+				digest.FromString("world " + platform.Architecture),
+				digest.FromString("foreign diff"),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img.config = push(ocispec.MediaTypeImageConfig, configJSON)
+	manifestJSON, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    img.config,
+		Layers:    []ocispec.Descriptor{img.layer, img.gzipLayer, img.foreignLayer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img.manifest = push(ocispec.MediaTypeImageManifest, manifestJSON)
+	img.manifest.Platform = &platform
+	return img
+}
+
+// exportToFile exports the images to a tarball and returns its path.
+func exportToFile(t *testing.T, ctx context.Context, src content.ReadOnlyStorage, images []Image, opts ExportOptions) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "archive.tar")
+	fp, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	if err := Export(ctx, fp, src, images, opts); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	return path
+}
+
+func TestExportAndLoad(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	img := pushTestImage(t, ctx, src, ocispec.Platform{OS: "linux", Architecture: "amd64"})
+	repoTags := []string{"example.com/hello:v1", "hello:latest"}
+
+	path := exportToFile(t, ctx, src, []Image{{Manifest: img.manifest, RepoTags: repoTags}}, ExportOptions{})
+	s, err := NewFromTar(ctx, path)
+	if err != nil {
+		t.Fatalf("NewFromTar() error = %v", err)
+	}
+
+	var tags []string
+	if err := s.Tags(ctx, "", func(got []string) error {
+		tags = got
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadOnlyStore.Tags() error = %v", err)
+	}
+	if !slices.Equal(tags, repoTags) {
+		t.Errorf("ReadOnlyStore.Tags() = %v, want %v", tags, repoTags)
+	}
+
+	manifestDesc, err := s.Resolve(ctx, "hello:latest")
+	if err != nil {
+		t.Fatalf("ReadOnlyStore.Resolve() error = %v", err)
+	}
+	if manifestDesc.MediaType != docker.MediaTypeManifest {
+		t.Errorf("ReadOnlyStore.Resolve() media type = %s, want %s", manifestDesc.MediaType, docker.MediaTypeManifest)
+	}
+	got, err := s.Resolve(ctx, manifestDesc.Digest.String())
+	if err != nil {
+		t.Fatalf("ReadOnlyStore.Resolve() error = %v", err)
+	}
+	if !content.Equal(got, manifestDesc) {
+		t.Errorf("ReadOnlyStore.Resolve() = %v, want %v", got, manifestDesc)
+	}
+
+	manifestJSON, err := content.FetchAll(ctx, s, manifestDesc)
+	if err != nil {
+		t.Fatalf("ReadOnlyStore.Fetch() error = %v", err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.SchemaVersion != 2 || manifest.MediaType != docker.MediaTypeManifest {
+		t.Errorf("synthesized manifest = %s, want a Docker image manifest", manifestJSON)
+	}
+	if manifest.Config.Digest != img.config.Digest || manifest.Config.MediaType != docker.MediaTypeConfig {
+		t.Errorf("synthesized manifest config = %v, want digest %s", manifest.Config, img.config.Digest)
+	}
+	wantLayers := []ocispec.Descriptor{
+		{MediaType: docker.MediaTypeUncompressedLayer, Digest: img.layer.Digest, Size: img.layer.Size},
+		{MediaType: docker.MediaTypeLayer, Digest: img.gzipLayer.Digest, Size: img.gzipLayer.Size},
+		img.foreignLayer,
+	}
+	if !slices.EqualFunc(manifest.Layers, wantLayers, func(a, b ocispec.Descriptor) bool {
+		return content.Equal(a, b) && slices.Equal(a.URLs, b.URLs)
+	}) {
+		t.Errorf("synthesized manifest layers = %v, want %v", manifest.Layers, wantLayers)
+	}
+
+	// the blobs are exported except the foreign layer
+	for i, srcDesc := range []ocispec.Descriptor{img.layer, img.gzipLayer} {
+		want, err := content.FetchAll(ctx, src, srcDesc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := content.FetchAll(ctx, s, manifest.Layers[i])
+		if err != nil {
+			t.Fatalf("ReadOnlyStore.Fetch() error = %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("ReadOnlyStore.Fetch() = %v, want %v", got, want)
+		}
+	}
+	exists, err := s.Exists(ctx, img.foreignLayer)
+	if err != nil {
+		t.Fatalf("ReadOnlyStore.Exists() error = %v", err)
+	}
+	if exists {
+		t.Errorf("ReadOnlyStore.Exists() = %v, want %v", exists, false)
+	}
+
+	predecessors, err := s.Predecessors(ctx, manifest.Config)
+	if err != nil {
+		t.Fatalf("ReadOnlyStore.Predecessors() error = %v", err)
+	}
+	if want := []ocispec.Descriptor{manifestDesc}; !slices.EqualFunc(predecessors, want, content.Equal) {
+		t.Errorf("ReadOnlyStore.Predecessors() = %v, want %v", predecessors, want)
+	}
+
+	// the image can be copied, skipping the foreign layer
+	dst := memory.New()
+	if _, err := oras.Copy(ctx, s, "hello:latest", dst, "latest", oras.DefaultCopyOptions); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+
+	// the archive is reproducible
+	path2 := exportToFile(t, ctx, src, []Image{{Manifest: img.manifest, RepoTags: repoTags}}, ExportOptions{})
+	archive, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive2, err := os.ReadFile(path2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(archive, archive2) {
+		t.Error("Export() is not reproducible")
+	}
+}
+
+func TestExport_Index(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	amd64 := pushTestImage(t, ctx, src, ocispec.Platform{OS: "linux", Architecture: "amd64"})
+	arm64 := pushTestImage(t, ctx, src, ocispec.Platform{OS: "linux", Architecture: "arm64"})
+	indexJSON, err := json.Marshal(ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{amd64.manifest, arm64.manifest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := content.NewDescriptorFromBytes(ocispec.MediaTypeImageIndex, indexJSON)
+	if err := src.Push(ctx, index, bytes.NewReader(indexJSON)); err != nil {
+		t.Fatal(err)
+	}
+	images := []Image{{Manifest: index, RepoTags: []string{"hello:latest"}}}
+
+	// missing target platform
+	if err := Export(ctx, io.Discard, src, images, ExportOptions{}); !errors.Is(err, errdef.ErrUnsupported) {
+		t.Fatalf("Export() error = %v, wantErr %v", err, errdef.ErrUnsupported)
+	}
+
+	path := exportToFile(t, ctx, src, images, ExportOptions{
+		TargetPlatform: &ocispec.Platform{OS: "linux", Architecture: "arm64"},
+	})
+	s, err := NewFromTar(ctx, path)
+	if err != nil {
+		t.Fatalf("NewFromTar() error = %v", err)
+	}
+	exists, err := s.Exists(ctx, arm64.config)
+	if err != nil {
+		t.Fatalf("ReadOnlyStore.Exists() error = %v", err)
+	}
+	if !exists {
+		t.Errorf("ReadOnlyStore.Exists(arm64 config) = %v, want %v", exists, true)
+	}
+	exists, err = s.Exists(ctx, amd64.config)
+	if err != nil {
+		t.Fatalf("ReadOnlyStore.Exists() error = %v", err)
+	}
+	if exists {
+		t.Errorf("ReadOnlyStore.Exists(amd64 config) = %v, want %v", exists, false)
+	}
+}
+
+func TestExport_Unsupported(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	manifestJSON, err := json.Marshal(ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.test",
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{ocispec.DescriptorEmptyJSON},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestJSON)
+	if err := src.Push(ctx, manifest, bytes.NewReader(manifestJSON)); err != nil {
+		t.Fatal(err)
+	}
+
+	err = Export(ctx, io.Discard, src, []Image{{Manifest: manifest}}, ExportOptions{})
+	if !errors.Is(err, errdef.ErrUnsupported) {
+		t.Errorf("Export() error = %v, wantErr %v", err, errdef.ErrUnsupported)
+	}
+}
+
+func TestNewFromFS_LegacyLayout(t *testing.T) {
+	ctx := context.Background()
+	layer := []byte("legacy layer")
+	configJSON, err := json.Marshal(ocispec.Image{
+		RootFS: ocispec.RootFS{
+			Type:    "layers",
+			DiffIDs: []digest.Digest{digest.FromBytes(layer)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configDigest := digest.FromBytes(configJSON)
+	manifestJSON, err := json.Marshal([]manifestEntry{{
+		Config:   configDigest.Encoded() + ".json",
+		RepoTags: []string{"legacy:v1"},
+		Layers:   []string{"0123456789abcdef/layer.tar"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		manifestFile:                     {Data: manifestJSON},
+		configDigest.Encoded() + ".json": {Data: configJSON},
+		"0123456789abcdef/layer.tar":     {Data: layer},
+		"repositories":                   {Data: []byte(`{"legacy":{"v1":"0123456789abcdef"}}`)},
+	}
+
+	s, err := NewFromFS(ctx, fsys)
+	if err != nil {
+		t.Fatalf("NewFromFS() error = %v", err)
+	}
+	desc, err := s.Resolve(ctx, "legacy:v1")
+	if err != nil {
+		t.Fatalf("ReadOnlyStore.Resolve() error = %v", err)
+	}
+	successors, err := content.Successors(ctx, s, desc)
+	if err != nil {
+		t.Fatalf("content.Successors() error = %v", err)
+	}
+	want := []ocispec.Descriptor{
+		{MediaType: docker.MediaTypeConfig, Digest: configDigest, Size: int64(len(configJSON))},
+		{MediaType: docker.MediaTypeUncompressedLayer, Digest: digest.FromBytes(layer), Size: int64(len(layer))},
+	}
+	if !slices.EqualFunc(successors, want, content.Equal) {
+		t.Fatalf("content.Successors() = %v, want %v", successors, want)
+	}
+	got, err := content.FetchAll(ctx, s, want[1])
+	if err != nil {
+		t.Fatalf("ReadOnlyStore.Fetch() error = %v", err)
+	}
+	if !bytes.Equal(got, layer) {
+		t.Errorf("ReadOnlyStore.Fetch() = %s, want %s", got, layer)
+	}
+
+	// invalid archives
+	delete(fsys, "0123456789abcdef/layer.tar")
+	if _, err := NewFromFS(ctx, fsys); err == nil {
+		t.Errorf("NewFromFS() error = %v, wantErr %v", err, true)
+	}
+	if _, err := NewFromFS(ctx, fstest.MapFS{}); err == nil {
+		t.Errorf("NewFromFS() error = %v, wantErr %v", err, true)
+	}
+}
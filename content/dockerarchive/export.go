@@ -0,0 +1,208 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockerarchive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/descriptor"
+	"oras.land/oras-go/v2/internal/docker"
+	"oras.land/oras-go/v2/internal/fs/tarfs"
+	"oras.land/oras-go/v2/internal/platform"
+)
+
+// Image describes an image to be exported.
+type Image struct {
+	// Manifest is the descriptor of the image manifest, which can be either a
+	// Docker image manifest or an OCI image manifest.
+	// If Manifest describes an index or a manifest list, the manifest matching
+	// ExportOptions.TargetPlatform is selected.
+	Manifest ocispec.Descriptor
+	// RepoTags are the references of the image to be loaded, in the form of
+	// `<repository>:<tag>`.
+	RepoTags []string
+}
+
+// ExportOptions contains parameters for [dockerarchive.Export].
+type ExportOptions struct {
+	// TargetPlatform selects the image manifest from an index or a manifest
+	// list.
+	// Exporting an index or a manifest list fails with errdef.ErrUnsupported
+	// if TargetPlatform is nil.
+	TargetPlatform *ocispec.Platform
+}
+
+// Export writes the given images in src to w as a Docker image archive, which
+// is loadable by `docker load`.
+//
+// The archive contains the `manifest.json` file followed by the configs and
+// the layers, stored in the form of `blobs/<algorithm>/<encoded>`.
+// Foreign layers are not exported, but recorded in the `LayerSources` of the
+// images instead.
+// Only container images can be exported. Artifacts with other config media
+// types are rejected with errdef.ErrUnsupported.
+//
+// The archive is reproducible: exporting the same content produces the same
+// bytes, as the blobs are written in the lexical order of their paths with
+// fixed metadata.
+func Export(ctx context.Context, w io.Writer, src content.ReadOnlyStorage, images []Image, opts ExportOptions) error {
+	if src == nil {
+		return errors.New("nil source storage")
+	}
+
+	entries := make([]manifestEntry, 0, len(images))
+	blobs := make(map[string]ocispec.Descriptor)
+	for _, image := range images {
+		entry, err := exportEntry(ctx, src, image, opts, blobs)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	manifestJSON, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest file: %w", err)
+	}
+	sortedPaths := make([]string, 0, len(blobs))
+	for blobPath := range blobs {
+		sortedPaths = append(sortedPaths, blobPath)
+	}
+	slices.Sort(sortedPaths)
+
+	tw := tarfs.NewWriter(w)
+	if err := tw.WriteFile(manifestFile, int64(len(manifestJSON)), bytes.NewReader(manifestJSON)); err != nil {
+		return err
+	}
+	for _, blobPath := range sortedPaths {
+		if err := exportBlob(ctx, tw, src, blobPath, blobs[blobPath]); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// exportEntry generates the `manifest.json` entry of image, and records the
+// blobs to be exported in blobs, keyed by their paths.
+func exportEntry(ctx context.Context, src content.ReadOnlyStorage, image Image, opts ExportOptions, blobs map[string]ocispec.Descriptor) (manifestEntry, error) {
+	root := image.Manifest
+	switch root.MediaType {
+	case docker.MediaTypeManifest, ocispec.MediaTypeImageManifest:
+	case docker.MediaTypeManifestList, ocispec.MediaTypeImageIndex:
+		if opts.TargetPlatform == nil {
+			return manifestEntry{}, fmt.Errorf("%s: %s: missing target platform: %w", root.Digest, root.MediaType, errdef.ErrUnsupported)
+		}
+		var err error
+		root, err = platform.SelectManifest(ctx, src, root, opts.TargetPlatform)
+		if err != nil {
+			return manifestEntry{}, err
+		}
+	default:
+		return manifestEntry{}, fmt.Errorf("%s: %s: %w", root.Digest, root.MediaType, errdef.ErrUnsupported)
+	}
+
+	manifestJSON, err := content.FetchAll(ctx, src, root)
+	if err != nil {
+		return manifestEntry{}, fmt.Errorf("failed to fetch %s: %w", root.Digest, err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return manifestEntry{}, fmt.Errorf("failed to decode manifest %s: %w", root.Digest, err)
+	}
+	switch manifest.Config.MediaType {
+	case docker.MediaTypeConfig, ocispec.MediaTypeImageConfig:
+	default:
+		return manifestEntry{}, fmt.Errorf("%s: %s: %w", manifest.Config.Digest, manifest.Config.MediaType, errdef.ErrUnsupported)
+	}
+	configJSON, err := content.FetchAll(ctx, src, manifest.Config)
+	if err != nil {
+		return manifestEntry{}, fmt.Errorf("failed to fetch %s: %w", manifest.Config.Digest, err)
+	}
+	var config ocispec.Image
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return manifestEntry{}, fmt.Errorf("failed to decode config %s: %w", manifest.Config.Digest, err)
+	}
+	diffIDs := config.RootFS.DiffIDs
+	if len(diffIDs) != len(manifest.Layers) {
+		return manifestEntry{}, fmt.Errorf("config %s: found %d diff IDs for %d layers", manifest.Config.Digest, len(diffIDs), len(manifest.Layers))
+	}
+
+	configPath, err := blobPath(manifest.Config)
+	if err != nil {
+		return manifestEntry{}, err
+	}
+	entry := manifestEntry{
+		Config:   configPath,
+		RepoTags: image.RepoTags,
+		Layers:   make([]string, 0, len(manifest.Layers)),
+	}
+	if entry.RepoTags == nil {
+		entry.RepoTags = []string{}
+	}
+	blobs[entry.Config] = manifest.Config
+	for i, layer := range manifest.Layers {
+		layerPath, err := blobPath(layer)
+		if err != nil {
+			return manifestEntry{}, err
+		}
+		entry.Layers = append(entry.Layers, layerPath)
+		if descriptor.IsForeignLayer(layer) {
+			if entry.LayerSources == nil {
+				entry.LayerSources = make(map[digest.Digest]ocispec.Descriptor)
+			}
+			entry.LayerSources[diffIDs[i]] = layer
+			continue
+		}
+		blobs[layerPath] = layer
+	}
+	return entry, nil
+}
+
+// exportBlob writes the blob described by desc to the archive.
+func exportBlob(ctx context.Context, tw *tarfs.Writer, src content.ReadOnlyStorage, blobPath string, desc ocispec.Descriptor) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rc, err := src.Fetch(ctx, desc)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", desc.Digest, err)
+	}
+	defer rc.Close()
+
+	vr := content.NewVerifyReader(rc, desc)
+	if err := tw.WriteFile(blobPath, desc.Size, vr); err != nil {
+		return err
+	}
+	return vr.Verify()
+}
+
+// blobPath returns the path of the blob described by desc in the archive.
+func blobPath(desc ocispec.Descriptor) (string, error) {
+	if err := desc.Digest.Validate(); err != nil {
+		return "", fmt.Errorf("cannot export %s: %s: %w", desc.Digest, err, errdef.ErrInvalidDigest)
+	}
+	return path.Join("blobs", desc.Digest.Algorithm().String(), desc.Digest.Encoded()), nil
+}
@@ -0,0 +1,192 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/internal/cas"
+	"oras.land/oras-go/v2/internal/container/set"
+	"oras.land/oras-go/v2/internal/descriptor"
+	"oras.land/oras-go/v2/internal/fs/tarfs"
+)
+
+// ExportOptions contains parameters for [oci.Export].
+type ExportOptions struct {
+	// Gzip compresses the tarball with gzip if true.
+	Gzip bool
+	// FindSuccessors finds the successors of the current node.
+	// It can be used to export a subgraph, such as the manifests of specific
+	// platforms of an image index.
+	// If FindSuccessors is nil, content.Successors will be used.
+	FindSuccessors func(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) ([]ocispec.Descriptor, error)
+}
+
+// Export writes the graphs rooted by the given manifests in src to w as a
+// tarball of an OCI image layout, containing the `oci-layout` file, the
+// `index.json` file and the blobs.
+//
+// The manifests are listed in `index.json` as given, so a manifest can be
+// tagged by the annotation "org.opencontainers.image.ref.name".
+// Foreign layers are not exported.
+//
+// The tarball is reproducible: exporting the same content produces the same
+// bytes, as the blobs are written in the lexical order of their paths with
+// fixed metadata.
+//
+// Reference: https://github.com/opencontainers/image-spec/blob/v1.1.1/image-layout.md
+func Export(ctx context.Context, w io.Writer, src content.ReadOnlyStorage, manifests []ocispec.Descriptor, opts ExportOptions) error {
+	if src == nil {
+		return errors.New("nil source storage")
+	}
+	if opts.FindSuccessors == nil {
+		opts.FindSuccessors = content.Successors
+	}
+
+	// find all the nodes, caching the non-leaf nodes
+	proxy := cas.NewProxy(src, cas.NewMemory())
+	visited := set.New[descriptor.Descriptor]()
+	var nodes []ocispec.Descriptor
+	var walk func(desc ocispec.Descriptor) error
+	walk = func(desc ocispec.Descriptor) error {
+		if err := isContextDone(ctx); err != nil {
+			return err
+		}
+		key := descriptor.FromOCI(desc)
+		if visited.Contains(key) || descriptor.IsForeignLayer(desc) {
+			return nil
+		}
+		visited.Add(key)
+		nodes = append(nodes, desc)
+		successors, err := opts.FindSuccessors(ctx, proxy, desc)
+		if err != nil {
+			return fmt.Errorf("failed to find successors of %s: %w", desc.Digest, err)
+		}
+		for _, successor := range successors {
+			if err := walk(successor); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, manifest := range manifests {
+		if err := walk(manifest); err != nil {
+			return err
+		}
+	}
+
+	// sort the blobs by path, where the same blob may be described by
+	// different descriptors
+	paths := make(map[string]ocispec.Descriptor, len(nodes))
+	for _, desc := range nodes {
+		blobPath, err := blobPath(desc.Digest)
+		if err != nil {
+			return err
+		}
+		if _, ok := paths[blobPath]; !ok {
+			paths[blobPath] = desc
+		}
+	}
+	sortedPaths := make([]string, 0, len(paths))
+	for blobPath := range paths {
+		sortedPaths = append(sortedPaths, blobPath)
+	}
+	slices.Sort(sortedPaths)
+
+	layoutJSON, err := json.Marshal(ocispec.ImageLayout{
+		Version: ocispec.ImageLayoutVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal OCI layout file: %w", err)
+	}
+	if manifests == nil {
+		manifests = []ocispec.Descriptor{}
+	}
+	indexJSON, err := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2, // historical value
+		},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: manifests,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal index file: %w", err)
+	}
+
+	var gw *gzip.Writer
+	if opts.Gzip {
+		// the header of the gzip stream is left empty for reproducibility
+		gw = gzip.NewWriter(w)
+		w = gw
+	}
+	tw := tarfs.NewWriter(w)
+	if err := tw.WriteFile(ocispec.ImageLayoutFile, int64(len(layoutJSON)), bytes.NewReader(layoutJSON)); err != nil {
+		return err
+	}
+	if err := tw.WriteFile(ocispec.ImageIndexFile, int64(len(indexJSON)), bytes.NewReader(indexJSON)); err != nil {
+		return err
+	}
+	for _, blobPath := range sortedPaths {
+		if err := exportBlob(ctx, tw, proxy, blobPath, paths[blobPath]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gw != nil {
+		return gw.Close()
+	}
+	return nil
+}
+
+// exportBlob writes the blob described by desc to the tarball.
+func exportBlob(ctx context.Context, tw *tarfs.Writer, proxy *cas.Proxy, blobPath string, desc ocispec.Descriptor) error {
+	if err := isContextDone(ctx); err != nil {
+		return err
+	}
+	// fetch large blobs from the source directly without caching
+	var rc io.ReadCloser
+	exists, err := proxy.Cache.Exists(ctx, desc)
+	if err != nil {
+		return err
+	}
+	if exists {
+		rc, err = proxy.Cache.Fetch(ctx, desc)
+	} else {
+		rc, err = proxy.ReadOnlyStorage.Fetch(ctx, desc)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", desc.Digest, err)
+	}
+	defer rc.Close()
+
+	vr := content.NewVerifyReader(rc, desc)
+	if err := tw.WriteFile(blobPath, desc.Size, vr); err != nil {
+		return err
+	}
+	return vr.Verify()
+}
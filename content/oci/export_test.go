@@ -0,0 +1,199 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// pushExportTestImage pushes an image with a foreign layer to the storage,
+// and returns the descriptors of the config, the layer, the foreign layer
+// and the manifest.
+func pushExportTestImage(t *testing.T, ctx context.Context, s content.Storage) []ocispec.Descriptor {
+	t.Helper()
+	var descs []ocispec.Descriptor
+	push := func(mediaType string, blob []byte) ocispec.Descriptor {
+		desc := content.NewDescriptorFromBytes(mediaType, blob)
+		if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+			t.Fatalf("failed to push test content: %v", err)
+		}
+		descs = append(descs, desc)
+		return desc
+	}
+	config := push(ocispec.MediaTypeImageConfig, []byte("{}"))
+	layer := push(ocispec.MediaTypeImageLayer, []byte("hello world"))
+	foreignLayer := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayerNonDistributableGzip, []byte("foreign"))
+	foreignLayer.URLs = []string{"https://example.com/foreign"}
+	descs = append(descs, foreignLayer)
+	manifestJSON, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layer, foreignLayer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	push(ocispec.MediaTypeImageManifest, manifestJSON)
+	return descs
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	descs := pushExportTestImage(t, ctx, s)
+	manifest := descs[3]
+	if err := s.Tag(ctx, manifest, "latest"); err != nil {
+		t.Fatal("Store.Tag() error =", err)
+	}
+	tagged := manifest
+	tagged.Annotations = map[string]string{
+		ocispec.AnnotationRefName: "v1",
+	}
+
+	path := filepath.Join(t.TempDir(), "layout.tar")
+	var buf bytes.Buffer
+	if err := Export(ctx, &buf, s, []ocispec.Descriptor{tagged}, ExportOptions{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := NewFromTar(ctx, path)
+	if err != nil {
+		t.Fatalf("NewFromTar() error = %v", err)
+	}
+	got, err := exported.Resolve(ctx, "v1")
+	if err != nil {
+		t.Fatalf("ReadOnlyStore.Resolve() error = %v", err)
+	}
+	if !content.Equal(got, manifest) {
+		t.Errorf("ReadOnlyStore.Resolve() = %v, want %v", got, manifest)
+	}
+	for _, desc := range []ocispec.Descriptor{descs[0], descs[1], descs[3]} {
+		want, err := content.FetchAll(ctx, s, desc)
+		if err != nil {
+			t.Fatal("Store.Fetch() error =", err)
+		}
+		got, err := content.FetchAll(ctx, exported, desc)
+		if err != nil {
+			t.Fatalf("ReadOnlyStore.Fetch() error = %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("ReadOnlyStore.Fetch() = %s, want %s", got, want)
+		}
+	}
+	// foreign layers are not exported
+	exists, err := exported.Exists(ctx, descs[2])
+	if err != nil {
+		t.Fatalf("ReadOnlyStore.Exists() error = %v", err)
+	}
+	if exists {
+		t.Errorf("ReadOnlyStore.Exists() = %v, want %v", exists, false)
+	}
+
+	// the tarball is reproducible
+	var buf2 bytes.Buffer
+	if err := Export(ctx, &buf2, s, []ocispec.Descriptor{tagged}, ExportOptions{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), buf2.Bytes()) {
+		t.Error("Export() is not reproducible")
+	}
+}
+
+func TestExport_Gzip(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+	descs := pushExportTestImage(t, ctx, s)
+	manifest := descs[3]
+
+	var buf bytes.Buffer
+	if err := Export(ctx, &buf, s, []ocispec.Descriptor{manifest}, ExportOptions{Gzip: true}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	var buf2 bytes.Buffer
+	if err := Export(ctx, &buf2, s, []ocispec.Descriptor{manifest}, ExportOptions{Gzip: true}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), buf2.Bytes()) {
+		t.Error("Export() is not reproducible")
+	}
+
+	gr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	tarball, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatalf("failed to decompress: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "layout.tar")
+	if err := os.WriteFile(path, tarball, 0666); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := NewFromTar(ctx, path)
+	if err != nil {
+		t.Fatalf("NewFromTar() error = %v", err)
+	}
+	got, err := exported.Resolve(ctx, manifest.Digest.String())
+	if err != nil {
+		t.Fatalf("ReadOnlyStore.Resolve() error = %v", err)
+	}
+	if !content.Equal(got, manifest) {
+		t.Errorf("ReadOnlyStore.Resolve() = %v, want %v", got, manifest)
+	}
+}
+
+func TestExport_MissingBlob(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+	blob := []byte("hello world")
+	layer := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, blob)
+	manifestJSON, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    ocispec.DescriptorEmptyJSON,
+		Layers:    []ocispec.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestJSON)
+	if err := s.Push(ctx, manifest, bytes.NewReader(manifestJSON)); err != nil {
+		t.Fatal(err)
+	}
+
+	err = Export(ctx, io.Discard, s, []ocispec.Descriptor{manifest}, ExportOptions{})
+	if !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Export() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+}
@@ -27,7 +27,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/opencontainers/go-digest"
 	specs "github.com/opencontainers/image-spec/specs-go"
@@ -36,9 +38,11 @@ import (
 	"oras.land/oras-go/v2/errdef"
 	"oras.land/oras-go/v2/internal/container/set"
 	"oras.land/oras-go/v2/internal/descriptor"
+	"oras.land/oras-go/v2/internal/fs/filelock"
 	"oras.land/oras-go/v2/internal/graph"
 	"oras.land/oras-go/v2/internal/manifestutil"
 	"oras.land/oras-go/v2/internal/resolver"
+	"oras.land/oras-go/v2/platforms"
 	"oras.land/oras-go/v2/registry"
 )
 
@@ -64,12 +68,36 @@ type Store struct {
 	//   - Default value: true.
 	AutoGC bool
 
-	root        string
-	indexPath   string
+	// GCGracePeriod is the duration for which blobs are protected from GC(),
+	// and from being repaired as orphans by Verify(), since they are last
+	// pushed to the store. It prevents the blobs being pushed by other
+	// processes sharing the same OCI layout, whose manifests are not yet saved
+	// to `index.json`, from being removed.
+	//   - Default value: 0, where blobs are not protected.
+	GCGracePeriod time.Duration
+
+	// TrackUsage controls if the OCI store records the last use times of the
+	// tags on tagging and resolving, which are used by Prune() to remove the
+	// least recently used tags with PrunePolicy.MaxSize. The records are
+	// stored in the `usage` directory under the root, which is not a part of
+	// the OCI image layout.
+	//   - Default value: false.
+	TrackUsage bool
+
+	root          string
+	indexPath     string
+	indexLockPath string
+	// usageRoot is the directory recording the last use times of the tags.
+	usageRoot   string
 	index       *ocispec.Index
 	storage     *Storage
 	tagResolver *resolver.Memory
+	nestedTags  *nestedTags
 	graph       *graph.Memory
+	// savedRefs is the references in `index.json` as of the last time it was
+	// read or written, which is the base of merging the changes made by
+	// other processes sharing the same OCI layout.
+	savedRefs map[string][]ocispec.Descriptor
 
 	// sync ensures that most operations can be done concurrently, while Delete
 	// has the exclusive access to Store if a delete operation is underway.
@@ -77,6 +105,8 @@ type Store struct {
 	// sync.Lock().
 	sync sync.RWMutex
 	// indexLock ensures that only one go-routine is writing to the index.
+	// Across processes, `index.json` is guarded by an advisory lock on the
+	// `index.json.lock` file.
 	indexLock sync.Mutex
 }
 
@@ -87,11 +117,22 @@ func New(root string) (*Store, error) {
 
 // NewWithContext creates a new OCI store.
 func NewWithContext(ctx context.Context, root string) (*Store, error) {
+	return newStore(ctx, root, nil)
+}
+
+// newStore creates a new OCI store, where the blobs are stored in pool if
+// pool is not nil.
+func newStore(ctx context.Context, root string, pool *BlobPool) (*Store, error) {
 	rootAbs, err := filepath.Abs(root)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve absolute path for %s: %w", root, err)
 	}
-	storage, err := NewStorage(rootAbs)
+	var storage *Storage
+	if pool != nil {
+		storage, err = NewStorageWithPool(rootAbs, pool)
+	} else {
+		storage, err = NewStorage(rootAbs)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create storage: %w", err)
 	}
@@ -101,8 +142,11 @@ func NewWithContext(ctx context.Context, root string) (*Store, error) {
 		AutoGC:        true,
 		root:          rootAbs,
 		indexPath:     filepath.Join(rootAbs, ocispec.ImageIndexFile),
+		indexLockPath: filepath.Join(rootAbs, ocispec.ImageIndexFile+".lock"),
+		usageRoot:     filepath.Join(rootAbs, "usage"),
 		storage:       storage,
 		tagResolver:   resolver.NewMemory(),
+		nestedTags:    newNestedTags(storage),
 		graph:         graph.NewMemory(),
 	}
 
@@ -135,6 +179,10 @@ func (s *Store) Push(ctx context.Context, expected ocispec.Descriptor, reader io
 	defer s.sync.RUnlock()
 
 	if err := s.storage.Push(ctx, expected, reader); err != nil {
+		if errors.Is(err, errdef.ErrAlreadyExists) {
+			// the existing blob is reused by the pusher
+			s.touch(expected)
+		}
 		return err
 	}
 	if err := s.graph.Index(ctx, s.storage, expected); err != nil {
@@ -147,6 +195,18 @@ func (s *Store) Push(ctx context.Context, expected ocispec.Descriptor, reader io
 	return nil
 }
 
+// touch updates the modification time of the blob described by desc, which
+// protects the blob from GC() within GCGracePeriod. Errors are ignored as the
+// store may be on a read-only file system.
+func (s *Store) touch(desc ocispec.Descriptor) {
+	path, err := s.blobFilePath(desc.Digest)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+}
+
 // Exists returns true if the described content exists.
 func (s *Store) Exists(ctx context.Context, target ocispec.Descriptor) (bool, error) {
 	s.sync.RLock()
@@ -162,10 +222,29 @@ func (s *Store) Exists(ctx context.Context, target ocispec.Descriptor) (bool, er
 //     remove the dangling blobs caused by the current delete.
 //   - If s.AutoDeleteReferrers is set to true, Delete will recursively remove
 //     the referrers of the manifests being deleted.
-func (s *Store) Delete(ctx context.Context, target ocispec.Descriptor) error {
+func (s *Store) Delete(ctx context.Context, target ocispec.Descriptor) (err error) {
 	s.sync.Lock()
 	defer s.sync.Unlock()
 
+	// hold the lock on index.json until the blobs are deleted, so that other
+	// processes sharing the same OCI layout cannot reference the blobs being
+	// deleted
+	s.indexLock.Lock()
+	defer s.indexLock.Unlock()
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+	// find the dangling blobs against the latest index.json
+	if err := s.mergeIndexFile(ctx); err != nil {
+		return fmt.Errorf("unable to reload index: %w", err)
+	}
+
 	deleteQueue := []ocispec.Descriptor{target}
 	for len(deleteQueue) > 0 {
 		head := deleteQueue[0]
@@ -178,6 +257,16 @@ func (s *Store) Delete(ctx context.Context, target ocispec.Descriptor) error {
 				return err
 			}
 			deleteQueue = append(deleteQueue, referrers...)
+			// also delete the referrers indexes by the referrers tag schema
+			indexes, err := referrersIndexes(ctx, s.tagResolver, head)
+			if err != nil {
+				return err
+			}
+			for _, index := range indexes {
+				if s.graph.Exists(index) {
+					deleteQueue = append(deleteQueue, index)
+				}
+			}
 		}
 
 		// delete the head of queue
@@ -199,18 +288,27 @@ func (s *Store) Delete(ctx context.Context, target ocispec.Descriptor) error {
 }
 
 // delete deletes one node and returns the dangling nodes caused by the delete.
+// The caller must hold the lock on index.json.
 func (s *Store) delete(ctx context.Context, target ocispec.Descriptor) ([]ocispec.Descriptor, error) {
-	resolvers := s.tagResolver.Map()
+	resolvers := s.tagResolver.MapAll()
 	untagged := false
-	for reference, desc := range resolvers {
-		if content.Equal(desc, target) {
+	for reference, descs := range resolvers {
+		remaining := slices.DeleteFunc(slices.Clone(descs), func(desc ocispec.Descriptor) bool {
+			return content.Equal(desc, target)
+		})
+		if len(remaining) == len(descs) {
+			continue
+		}
+		if len(remaining) == 0 {
 			s.tagResolver.Untag(reference)
-			untagged = true
+		} else if err := s.tagResolver.TagAll(ctx, remaining, reference); err != nil {
+			return nil, err
 		}
+		untagged = true
 	}
 	danglings := s.graph.Remove(target)
 	if untagged && s.AutoSaveIndex {
-		err := s.saveIndex()
+		err := s.saveIndexFile(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -232,6 +330,9 @@ func (s *Store) delete(ctx context.Context, target ocispec.Descriptor) ([]ocispe
 //     strings, multiple copies of the descriptor with different reference tags
 //     will be stored in the `index.json` file.
 //
+// To associate a reference string with the manifests of multiple platforms,
+// use TagAll().
+//
 // Reference: https://github.com/opencontainers/image-spec/blob/v1.1.1/image-layout.md#indexjson-file
 func (s *Store) Tag(ctx context.Context, desc ocispec.Descriptor, reference string) error {
 	s.sync.RLock()
@@ -252,20 +353,77 @@ func (s *Store) Tag(ctx context.Context, desc ocispec.Descriptor, reference stri
 	return s.tag(ctx, desc, reference)
 }
 
+// TagAll associates a reference string with multiple descriptors, such as
+// the manifests of different platforms, which are distinguished by the
+// platforms of the descriptors. When saved, an entry is persisted in the
+// `index.json` file for each descriptor, with the reference string recorded
+// in the "org.opencontainers.image.ref.name" annotation.
+//
+// The descriptors previously associated with the reference string are
+// replaced. If there are multiple descriptors, each of them must have a
+// distinct platform.
+//
+// Reference: https://github.com/opencontainers/image-spec/blob/v1.1.1/image-layout.md#indexjson-file
+func (s *Store) TagAll(ctx context.Context, descs []ocispec.Descriptor, reference string) error {
+	s.sync.RLock()
+	defer s.sync.RUnlock()
+
+	if err := validateReference(reference); err != nil {
+		return err
+	}
+	if len(descs) == 0 {
+		return fmt.Errorf("%s: no descriptors to tag", reference)
+	}
+	if len(descs) > 1 {
+		platformSet := set.New[string]()
+		for _, desc := range descs {
+			if desc.Platform == nil {
+				return fmt.Errorf("%s: %s: missing platform: %w", desc.Digest, desc.MediaType, errdef.ErrInvalidPlatform)
+			}
+			platform := platforms.Format(platforms.Normalize(*desc.Platform))
+			if platformSet.Contains(platform) {
+				return fmt.Errorf("%s: %s: duplicate platform %s: %w", desc.Digest, desc.MediaType, platform, errdef.ErrInvalidPlatform)
+			}
+			platformSet.Add(platform)
+		}
+	}
+	for _, desc := range descs {
+		exists, err := s.storage.Exists(ctx, desc)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%s: %s: %w", desc.Digest, desc.MediaType, errdef.ErrNotFound)
+		}
+	}
+
+	return s.tagAll(ctx, descs, reference)
+}
+
 // tag tags a descriptor with a reference string.
 func (s *Store) tag(ctx context.Context, desc ocispec.Descriptor, reference string) error {
-	dgst := desc.Digest.String()
-	if reference != dgst {
-		// also tag desc by its digest
-		if err := s.tagResolver.Tag(ctx, desc, dgst); err != nil {
-			return err
+	return s.tagAll(ctx, []ocispec.Descriptor{desc}, reference)
+}
+
+// tagAll tags the non-empty descs with a reference string.
+func (s *Store) tagAll(ctx context.Context, descs []ocispec.Descriptor, reference string) error {
+	for _, desc := range descs {
+		dgst := desc.Digest.String()
+		if reference != dgst {
+			// also tag desc by its digest
+			if err := s.tagResolver.Tag(ctx, desc, dgst); err != nil {
+				return err
+			}
 		}
 	}
-	if err := s.tagResolver.Tag(ctx, desc, reference); err != nil {
+	if err := s.tagResolver.TagAll(ctx, descs, reference); err != nil {
 		return err
 	}
+	if len(descs) > 1 || reference != descs[0].Digest.String() {
+		s.recordUse(reference)
+	}
 	if s.AutoSaveIndex {
-		return s.saveIndex()
+		return s.saveIndex(ctx)
 	}
 	return nil
 }
@@ -275,6 +433,12 @@ func (s *Store) tag(ctx context.Context, desc ocispec.Descriptor, reference stri
 //     a full descriptor declared by github.com/opencontainers/image-spec/specs-go/v1.
 //   - If the reference is a digest, the returned descriptor will be a
 //     plain descriptor (containing only the digest, media type and size).
+//
+// If the tag is associated with multiple descriptors, such as the manifests
+// of different platforms, the one best matching the platform of the host is
+// returned, or the first one if none of them matches. See also ResolveAll().
+//
+// Resolving a tag records its use for the MaxSize policy of [Store.Prune].
 func (s *Store) Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error) {
 	s.sync.RLock()
 	defer s.sync.RUnlock()
@@ -284,7 +448,7 @@ func (s *Store) Resolve(ctx context.Context, reference string) (ocispec.Descript
 	}
 
 	// attempt resolving manifest
-	desc, err := s.tagResolver.Resolve(ctx, reference)
+	descs, err := resolveAll(ctx, s.tagResolver, s.nestedTags, reference)
 	if err != nil {
 		if errors.Is(err, errdef.ErrNotFound) {
 			// attempt resolving blob
@@ -293,18 +457,55 @@ func (s *Store) Resolve(ctx context.Context, reference string) (ocispec.Descript
 		return ocispec.Descriptor{}, err
 	}
 
+	desc := selectDescriptor(descs)
 	if reference == desc.Digest.String() {
 		return descriptor.Plain(desc), nil
 	}
 
+	// record the use of the tag for Prune
+	s.recordUse(reference)
 	return desc, nil
 }
 
+// ResolveAll resolves a reference to all the descriptors associated with it.
+//   - If the reference is a tag, the returned descriptors are the entries of
+//     `index.json` annotated with the tag, such as the manifests of different
+//     platforms. If none of the entries is annotated with the tag, the
+//     manifests annotated with the tag in the nested indexes are returned.
+//   - If the reference is a digest, a single plain descriptor is returned.
+func (s *Store) ResolveAll(ctx context.Context, reference string) ([]ocispec.Descriptor, error) {
+	s.sync.RLock()
+	defer s.sync.RUnlock()
+
+	if reference == "" {
+		return nil, errdef.ErrMissingReference
+	}
+
+	descs, err := resolveAll(ctx, s.tagResolver, s.nestedTags, reference)
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			desc, err := resolveBlob(os.DirFS(s.root), reference)
+			if err != nil {
+				return nil, err
+			}
+			return []ocispec.Descriptor{desc}, nil
+		}
+		return nil, err
+	}
+	if reference == descs[0].Digest.String() {
+		return []ocispec.Descriptor{descriptor.Plain(descs[0])}, nil
+	}
+	return descs, nil
+}
+
 // Untag disassociates a reference string from its descriptor.
 // When saved, the descriptor entry cotanining the reference in the
 // "org.opencontainers.image.ref.name" annotation is removed from the
 // `index.json` file.
+// If the reference string is associated with multiple descriptors, all the
+// entries are removed.
 // The actual content identified by the descriptor is NOT deleted.
+// Tags in the indexes nested in `index.json` cannot be removed.
 //
 // Reference: https://github.com/opencontainers/image-spec/blob/v1.1.1/image-layout.md#indexjson-file
 func (s *Store) Untag(ctx context.Context, reference string) error {
@@ -324,8 +525,9 @@ func (s *Store) Untag(ctx context.Context, reference string) error {
 	}
 
 	s.tagResolver.Untag(reference)
+	s.removeUse(reference)
 	if s.AutoSaveIndex {
-		return s.saveIndex()
+		return s.saveIndex(ctx)
 	}
 	return nil
 }
@@ -340,8 +542,8 @@ func (s *Store) Predecessors(ctx context.Context, node ocispec.Descriptor) ([]oc
 	return s.graph.Predecessors(ctx, node)
 }
 
-// Tags lists the tags presented in the `index.json` file of the OCI layout,
-// returned in ascending order.
+// Tags lists the tags presented in the `index.json` file of the OCI layout
+// and in the indexes nested in it, returned in ascending order.
 // If `last` is NOT empty, the entries in the response start after the tag
 // specified by `last`. Otherwise, the response starts from the top of the tags
 // list.
@@ -351,7 +553,7 @@ func (s *Store) Tags(ctx context.Context, last string, fn func(tags []string) er
 	s.sync.RLock()
 	defer s.sync.RUnlock()
 
-	return listTags(s.tagResolver, last, fn)
+	return listTags(ctx, s.tagResolver, s.nestedTags, last, fn)
 }
 
 // ensureOCILayoutFile ensures the `oci-layout` file.
@@ -384,13 +586,24 @@ func (s *Store) ensureOCILayoutFile() error {
 
 // loadIndexFile reads index.json from the file system.
 // Create index.json if it does not exist.
-func (s *Store) loadIndexFile(ctx context.Context) error {
-	indexFile, err := os.Open(s.indexPath)
+func (s *Store) loadIndexFile(ctx context.Context) (err error) {
+	unlock, err := s.lockIndex()
 	if err != nil {
-		if !os.IsNotExist(err) {
-			return fmt.Errorf("failed to open index file: %w", err)
+		// the OCI layout may be on a read-only file system, where index.json
+		// cannot be changed by other processes either
+		unlock = func() error { return nil }
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
 		}
+	}()
 
+	index, err := s.readIndexFile()
+	if err != nil {
+		return err
+	}
+	if index == nil {
 		// write index.json if it does not exist
 		s.index = &ocispec.Index{
 			Versioned: specs.Versioned{
@@ -399,16 +612,51 @@ func (s *Store) loadIndexFile(ctx context.Context) error {
 			MediaType: ocispec.MediaTypeImageIndex,
 			Manifests: []ocispec.Descriptor{},
 		}
+		s.savedRefs = make(map[string][]ocispec.Descriptor)
 		return s.writeIndexFile()
 	}
+	s.index = index
+	if err := loadIndex(ctx, s.index, s.storage, s.tagResolver, s.nestedTags, s.graph); err != nil {
+		return err
+	}
+	s.savedRefs = indexRefs(index)
+	return nil
+}
+
+// readIndexFile reads and decodes index.json from the file system.
+// Returns nil without error if index.json does not exist.
+func (s *Store) readIndexFile() (*ocispec.Index, error) {
+	indexFile, err := os.Open(s.indexPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open index file: %w", err)
+	}
 	defer indexFile.Close()
 
 	var index ocispec.Index
 	if err := json.NewDecoder(indexFile).Decode(&index); err != nil {
-		return fmt.Errorf("failed to decode index file: %w", err)
+		return nil, fmt.Errorf("failed to decode index file: %w", err)
+	}
+	return &index, nil
+}
+
+// lockIndex acquires the advisory lock on index.json shared across
+// processes, and returns the function releasing the lock.
+// If file locking is not supported on the platform, index.json is guarded
+// within the process only.
+func (s *Store) lockIndex() (func() error, error) {
+	lockFile, err := filelock.Lock(s.indexLockPath)
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			return func() error { return nil }, nil
+		}
+		return nil, fmt.Errorf("failed to lock index file: %w", err)
 	}
-	s.index = &index
-	return loadIndex(ctx, s.index, s.storage, s.tagResolver, s.graph)
+	return func() error {
+		return filelock.Unlock(lockFile)
+	}, nil
 }
 
 // SaveIndex writes the `index.json` file to the file system.
@@ -417,53 +665,154 @@ func (s *Store) loadIndexFile(ctx context.Context) error {
 //     on Tag() and Delete() calls, and when pushing a manifest.
 //   - If AutoSaveIndex is set to false, it's the caller's responsibility
 //     to manually call this method when needed.
+//
+// The changes made to `index.json` by other processes sharing the same OCI
+// layout since it was last read or written are merged before saving, where
+// the changes made by this store take precedence on conflicts.
 func (s *Store) SaveIndex() error {
 	s.sync.RLock()
 	defer s.sync.RUnlock()
 
-	return s.saveIndex()
+	return s.saveIndex(context.Background())
 }
 
-func (s *Store) saveIndex() error {
+func (s *Store) saveIndex(ctx context.Context) (err error) {
 	s.indexLock.Lock()
 	defer s.indexLock.Unlock()
 
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+	return s.saveIndexFile(ctx)
+}
+
+// saveIndexFile merges the changes made to index.json by other processes and
+// writes the index of the store to index.json.
+// The caller must hold the lock on index.json.
+func (s *Store) saveIndexFile(ctx context.Context) error {
+	if err := s.mergeIndexFile(ctx); err != nil {
+		return err
+	}
+
 	var manifests []ocispec.Descriptor
-	tagged := set.New[digest.Digest]()
-	refMap := s.tagResolver.Map()
+	tagged := make(map[digest.Digest][]ocispec.Descriptor)
+	refMap := s.tagResolver.MapAll()
+	refs := slices.Sorted(maps.Keys(refMap))
 
 	// 1. Add descriptors that are associated with tags
-	// Note: One descriptor can be associated with multiple tags.
-	for ref, desc := range refMap {
-		if ref != desc.Digest.String() {
-			annotations := make(map[string]string, len(desc.Annotations)+1)
-			maps.Copy(annotations, desc.Annotations)
-			annotations[ocispec.AnnotationRefName] = ref
-			desc.Annotations = annotations
-			manifests = append(manifests, desc)
-			// mark the digest as tagged for deduplication in step 2
-			tagged.Add(desc.Digest)
+	// Note: One descriptor can be associated with multiple tags, and one tag
+	// can be associated with multiple descriptors of different platforms.
+	for _, ref := range refs {
+		for _, desc := range refMap[ref] {
+			if ref != desc.Digest.String() {
+				annotations := make(map[string]string, len(desc.Annotations)+1)
+				maps.Copy(annotations, desc.Annotations)
+				annotations[ocispec.AnnotationRefName] = ref
+				desc.Annotations = annotations
+				manifests = append(manifests, desc)
+				// mark the entry as tagged for deduplication in step 2
+				tagged[desc.Digest] = append(tagged[desc.Digest], desc)
+			}
 		}
 	}
 	// 2. Add descriptors that are not associated with any tag
-	for ref, desc := range refMap {
-		if ref == desc.Digest.String() && !tagged.Contains(desc.Digest) {
-			// skip tagged ones since they have been added in step 1
+	// Note: One digest can be associated with multiple entries differing in
+	// platforms or annotations.
+	for _, ref := range refs {
+		descs := refMap[ref]
+		if ref != descs[0].Digest.String() {
+			continue
+		}
+		for _, desc := range descs {
+			if slices.ContainsFunc(tagged[desc.Digest], func(d ocispec.Descriptor) bool {
+				return sameIndexEntry(d, desc)
+			}) {
+				// skip tagged ones since they have been added in step 1
+				continue
+			}
 			manifests = append(manifests, deleteAnnotationRefName(desc))
 		}
 	}
 
 	s.index.Manifests = manifests
-	return s.writeIndexFile()
+	if err := s.writeIndexFile(); err != nil {
+		return err
+	}
+	s.savedRefs = refMap
+	return nil
+}
+
+// mergeIndexFile merges the changes made to index.json by other processes
+// since it was last read or written into the store, where the changes made by
+// the store take precedence on conflicts.
+// The caller must hold the lock on index.json.
+func (s *Store) mergeIndexFile(ctx context.Context) error {
+	index, err := s.readIndexFile()
+	if err != nil {
+		return err
+	}
+	if index == nil {
+		// index.json is removed by others
+		return nil
+	}
+	theirs := indexRefs(index)
+	ours := s.tagResolver.MapAll()
+	base := s.savedRefs
+
+	refs := set.New[string]()
+	for _, m := range []map[string][]ocispec.Descriptor{base, ours, theirs} {
+		for ref := range m {
+			refs.Add(ref)
+		}
+	}
+	for ref := range refs {
+		if refChanged(base, ours, ref) || !refChanged(ours, theirs, ref) {
+			// keep our changes, or nothing to merge
+			continue
+		}
+		descs, ok := theirs[ref]
+		if !ok {
+			s.tagResolver.Untag(ref)
+			continue
+		}
+		if err := s.tagResolver.TagAll(ctx, descs, ref); err != nil {
+			return err
+		}
+		for _, desc := range descs {
+			if !s.graph.Exists(desc) {
+				if err := s.graph.IndexAll(ctx, s.storage, descriptor.Plain(desc)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	s.savedRefs = theirs
+	return nil
 }
 
 // writeIndexFile writes the `index.json` file.
+// The file is replaced atomically so that readers never see a partially
+// written index.
 func (s *Store) writeIndexFile() error {
 	indexJSON, err := json.Marshal(s.index)
 	if err != nil {
 		return fmt.Errorf("failed to marshal index file: %w", err)
 	}
-	return os.WriteFile(s.indexPath, indexJSON, 0666)
+	tempPath := s.indexPath + ".tmp"
+	if err := os.WriteFile(tempPath, indexJSON, 0666); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, s.indexPath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
 }
 
 // GC removes garbage from Store. Unsaved index will be lost. To prevent unexpected
@@ -471,18 +820,53 @@ func (s *Store) writeIndexFile() error {
 // The garbage to be cleaned are:
 //   - unreferenced (dangling) blobs in Store which have no predecessors
 //   - garbage blobs in the storage whose metadata is not stored in Store
-func (s *Store) GC(ctx context.Context) error {
+//
+// If Store uses a shared blob pool, only the links to the garbage blobs are
+// removed from Store, and the space is reclaimed by BlobPool.GC().
+func (s *Store) GC(ctx context.Context) (err error) {
 	s.sync.Lock()
 	defer s.sync.Unlock()
 
+	// hold the lock on index.json so that other processes sharing the same
+	// OCI layout cannot tag the blobs being removed
+	s.indexLock.Lock()
+	defer s.indexLock.Unlock()
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+	if err := s.mergeIndexFile(ctx); err != nil {
+		return fmt.Errorf("unable to reload index: %w", err)
+	}
+
 	// get reachable nodes by reloading the index
-	err := s.gcIndex(ctx)
+	err = s.gcIndex(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to reload index: %w", err)
 	}
 	reachableNodes := s.graph.DigestSet()
 
+	// clean up the records of the use of the removed tags
+	if err := s.removeStaleUses(); err != nil {
+		return fmt.Errorf("unable to clean up usage records: %w", err)
+	}
+
 	// clean up garbage blobs in the storage
+	return s.walkGarbage(ctx, reachableNodes, func(_ digest.Digest, blobPath string) error {
+		// remove the blob from storage if it does not exist in Store
+		return os.Remove(blobPath)
+	})
+}
+
+// walkGarbage walks through the blobs in the storage which are not in
+// reachable, and calls fn with the ones which are not protected by
+// GCGracePeriod.
+func (s *Store) walkGarbage(ctx context.Context, reachable set.Set[digest.Digest], fn func(dgst digest.Digest, blobPath string) error) error {
 	rootpath := filepath.Join(s.root, ocispec.ImageBlobsDir)
 	algDirs, err := os.ReadDir(rootpath)
 	if err != nil {
@@ -512,12 +896,21 @@ func (s *Store) GC(ctx context.Context) error {
 				// skip irrelevant content
 				continue
 			}
-			if !reachableNodes.Contains(blobDigest) {
-				// remove the blob from storage if it does not exist in Store
-				err = os.Remove(path.Join(algPath, dgst))
+			if reachable.Contains(blobDigest) {
+				continue
+			}
+			if s.GCGracePeriod > 0 {
+				info, err := digestEntry.Info()
 				if err != nil {
 					return err
 				}
+				if time.Since(info.ModTime()) < s.GCGracePeriod {
+					// skip the blob being pushed
+					continue
+				}
+			}
+			if err := fn(blobDigest, path.Join(algPath, dgst)); err != nil {
+				return err
 			}
 		}
 	}
@@ -532,56 +925,99 @@ func (s *Store) gcIndex(ctx context.Context) error {
 	tagged := set.New[digest.Digest]()
 
 	// index tagged manifests
-	refMap := s.tagResolver.Map()
-	for ref, desc := range refMap {
-		if ref == desc.Digest.String() {
+	refMap := s.tagResolver.MapAll()
+	for ref, descs := range refMap {
+		if ref == descs[0].Digest.String() {
 			continue
 		}
-		if err := tagResolver.Tag(ctx, deleteAnnotationRefName(desc), desc.Digest.String()); err != nil {
-			return err
+		for _, desc := range descs {
+			if err := tagResolver.Tag(ctx, deleteAnnotationRefName(desc), desc.Digest.String()); err != nil {
+				return err
+			}
+			plain := descriptor.Plain(desc)
+			if err := graph.IndexAll(ctx, s.storage, plain); err != nil {
+				return err
+			}
+			tagged.Add(desc.Digest)
 		}
-		if err := tagResolver.Tag(ctx, desc, ref); err != nil {
+		if err := tagResolver.TagAll(ctx, descs, ref); err != nil {
 			return err
 		}
-		plain := descriptor.Plain(desc)
-		if err := graph.IndexAll(ctx, s.storage, plain); err != nil {
-			return err
+	}
+	// keep all the entries of the tagged digests
+	for dgst := range tagged {
+		if descs, ok := refMap[dgst.String()]; ok {
+			if err := tagResolver.TagAll(ctx, descs, dgst.String()); err != nil {
+				return err
+			}
 		}
-		tagged.Add(desc.Digest)
 	}
 
 	// index referrer manifests
-	for ref, desc := range refMap {
+	for ref, descs := range refMap {
+		desc := descs[0]
 		if ref != desc.Digest.String() || tagged.Contains(desc.Digest) {
 			continue
 		}
-		// check if the referrers manifest can traverse to the existing graph
+		// check if the referrers manifest can traverse to the existing graph,
+		// or to an absent subject which may be pushed later
+		keep := false
 		subject := &desc
 		for {
-			subject, err := manifestutil.Subject(ctx, s.storage, *subject)
+			var err error
+			subject, err = manifestutil.Subject(ctx, s.storage, *subject)
 			if err != nil {
+				if errors.Is(err, errdef.ErrNotFound) {
+					// the referrers manifest is absent
+					break
+				}
 				return err
 			}
 			if subject == nil {
 				break
 			}
 			if graph.Exists(*subject) {
-				if err := tagResolver.Tag(ctx, deleteAnnotationRefName(desc), desc.Digest.String()); err != nil {
-					return err
-				}
-				plain := descriptor.Plain(desc)
-				if err := graph.IndexAll(ctx, s.storage, plain); err != nil {
-					return err
-				}
+				keep = true
+				break
+			}
+			exists, err := s.storage.Exists(ctx, *subject)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				keep = true
 				break
 			}
 		}
+		if !keep {
+			continue
+		}
+		if err := tagResolver.TagAll(ctx, descs, ref); err != nil {
+			return err
+		}
+		for _, desc := range descs {
+			plain := descriptor.Plain(desc)
+			if err := graph.IndexAll(ctx, s.storage, plain); err != nil {
+				return err
+			}
+		}
 	}
 	s.tagResolver = tagResolver
 	s.graph = graph
 	return nil
 }
 
+// refChanged returns true if the reference is added, removed or retargeted
+// from the references a to the references b.
+func refChanged(a, b map[string][]ocispec.Descriptor, ref string) bool {
+	descsA, okA := a[ref]
+	descsB, okB := b[ref]
+	if okA != okB {
+		return true
+	}
+	return okA && !slices.EqualFunc(descsA, descsB, content.Equal)
+}
+
 // isTagged checks if the blob given by the descriptor is tagged.
 func (s *Store) isTagged(desc ocispec.Descriptor) bool {
 	tagSet := s.tagResolver.TagSet(desc)
@@ -604,6 +1040,10 @@ func (s *unsafeStore) Predecessors(ctx context.Context, node ocispec.Descriptor)
 	return s.graph.Predecessors(ctx, node)
 }
 
+func (s *unsafeStore) Referrers(ctx context.Context, desc ocispec.Descriptor, artifactType string, fn func(referrers []ocispec.Descriptor) error) error {
+	return listReferrers(ctx, s.storage, s.graph, s.tagResolver, desc, artifactType, fn)
+}
+
 // isContextDone returns an error if the context is done.
 // Reference: https://pkg.go.dev/context#Context
 func isContextDone(ctx context.Context) error {
@@ -32,8 +32,10 @@ import (
 	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"golang.org/x/sync/errgroup"
 	"oras.land/oras-go/v2"
@@ -43,6 +45,7 @@ import (
 	"oras.land/oras-go/v2/internal/cas"
 	"oras.land/oras-go/v2/internal/descriptor"
 	"oras.land/oras-go/v2/internal/spec"
+	"oras.land/oras-go/v2/platforms"
 	"oras.land/oras-go/v2/registry"
 )
 
@@ -77,6 +80,9 @@ func TestStoreInterface(t *testing.T) {
 	if _, ok := store.(registry.TagLister); !ok {
 		t.Error("&Store{} does not conform registry.TagLister")
 	}
+	if _, ok := store.(registry.ReferrerLister); !ok {
+		t.Error("&Store{} does not conform registry.ReferrerLister")
+	}
 }
 
 func TestStore_Success(t *testing.T) {
@@ -654,7 +660,7 @@ func TestStore_DisableAutoSaveIndex(t *testing.T) {
 	if got, want := len(s.index.Manifests), 0; got != want {
 		t.Errorf("len(index.Manifests) = %v, want %v", got, want)
 	}
-	if err := s.saveIndex(); err != nil {
+	if err := s.saveIndex(ctx); err != nil {
 		t.Fatal("Store.SaveIndex() error =", err)
 	}
 	// test index file again
@@ -676,7 +682,7 @@ func TestStore_DisableAutoSaveIndex(t *testing.T) {
 	if got, want := len(s.index.Manifests), 2; got != want {
 		t.Errorf("len(index.Manifests) = %v, want %v", got, want)
 	}
-	if err := s.saveIndex(); err != nil {
+	if err := s.saveIndex(ctx); err != nil {
 		t.Fatal("Store.SaveIndex() error =", err)
 	}
 	// test index file again
@@ -2769,6 +2775,104 @@ func TestStore_GCAndDeleteOnIndex(t *testing.T) {
 	}
 }
 
+func TestStore_GC_Referrers(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	ctx := context.Background()
+
+	// generate test content
+	var blobs [][]byte
+	var descs []ocispec.Descriptor
+	appendBlob := func(mediaType string, blob []byte) {
+		blobs = append(blobs, blob)
+		descs = append(descs, ocispec.Descriptor{
+			MediaType: mediaType,
+			Digest:    digest.FromBytes(blob),
+			Size:      int64(len(blob)),
+		})
+	}
+	generateManifest := func(config ocispec.Descriptor, subject *ocispec.Descriptor, layers ...ocispec.Descriptor) {
+		manifest := ocispec.Manifest{
+			Config:  config,
+			Layers:  layers,
+			Subject: subject,
+		}
+		manifestJSON, err := json.Marshal(manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		appendBlob(ocispec.MediaTypeImageManifest, manifestJSON)
+	}
+
+	appendBlob(ocispec.MediaTypeImageConfig, []byte("config"))       // Blob 0
+	appendBlob(ocispec.MediaTypeImageLayer, []byte("blob"))          // Blob 1
+	generateManifest(descs[0], nil, descs[1])                        // Blob 2, tagged manifest
+	generateManifest(descs[0], &descs[2], descs[1])                  // Blob 3, referrer of a tagged manifest
+	generateManifest(descs[0], &descs[3], descs[1])                  // Blob 4, referrer of a referrer
+	appendBlob(ocispec.MediaTypeImageLayer, []byte("absent"))        // Blob 5, absent layer
+	generateManifest(descs[0], nil, descs[5])                        // Blob 6, absent manifest
+	generateManifest(descs[0], &descs[6], descs[1])                  // Blob 7, referrer of an absent manifest
+	appendBlob(ocispec.MediaTypeImageLayer, []byte("untagged"))      // Blob 8, untagged layer
+	generateManifest(descs[0], nil, descs[8])                        // Blob 9, untagged manifest
+	generateManifest(descs[0], &descs[9], descs[1])                  // Blob 10, referrer of an untagged manifest
+	generateManifest(descs[0], &descs[10], descs[1])                 // Blob 11, referrer of a garbage referrer
+	appendBlob(ocispec.MediaTypeImageLayer, []byte("garbage layer")) // Blob 12, garbage layer
+
+	for i, desc := range descs {
+		if i == 5 || i == 6 {
+			continue
+		}
+		if err := s.Push(ctx, desc, bytes.NewReader(blobs[i])); err != nil {
+			t.Fatalf("failed to push test content to src: %d: %v", i, err)
+		}
+	}
+	if err := s.Tag(ctx, descs[2], "latest"); err != nil {
+		t.Fatal("Store.Tag() error =", err)
+	}
+
+	if err := s.GC(ctx); err != nil {
+		t.Fatal("Store.GC() error =", err)
+	}
+
+	// referrers reaching the tagged manifest or an absent subject are kept
+	wantExistence := []bool{true, true, true, true, true, false, false, true,
+		false, false, false, false, false}
+	for i, wantValue := range wantExistence {
+		if i == 5 || i == 6 {
+			continue
+		}
+		exists, err := s.Exists(ctx, descs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != wantValue {
+			t.Errorf("Store.Exists(descs[%d]) = %v, want %v", i, exists, wantValue)
+		}
+	}
+	for _, i := range []int{3, 4, 7} {
+		if got, err := s.Resolve(ctx, descs[i].Digest.String()); err != nil || !content.Equal(got, descs[i]) {
+			t.Errorf("Store.Resolve(descs[%d]) = %v, %v, want %v", i, got, err, descs[i])
+		}
+	}
+
+	// the referrer is found once the subject is pushed
+	for _, i := range []int{5, 6} {
+		if err := s.Push(ctx, descs[i], bytes.NewReader(blobs[i])); err != nil {
+			t.Fatalf("failed to push test content to src: %d: %v", i, err)
+		}
+	}
+	got, err := s.Predecessors(ctx, descs[6])
+	if err != nil {
+		t.Fatal("Store.Predecessors() error =", err)
+	}
+	if want := []ocispec.Descriptor{descs[7]}; !equalDescriptorSet(got, want) {
+		t.Errorf("Store.Predecessors() = %v, want %v", got, want)
+	}
+}
+
 func TestStore_GCErrorPath(t *testing.T) {
 	tempDir := t.TempDir()
 	s, err := New(tempDir)
@@ -2947,3 +3051,519 @@ func TestStore_BadDigest(t *testing.T) {
 		}
 	})
 }
+
+// pushTestManifest pushes a manifest with a layer of the given content to the
+// store and tags it.
+func pushTestManifest(t *testing.T, s *Store, layerContent string, reference string) ocispec.Descriptor {
+	t.Helper()
+	ctx := context.Background()
+	layer := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, []byte(layerContent))
+	if err := s.Push(ctx, layer, bytes.NewReader([]byte(layerContent))); err != nil {
+		t.Fatalf("failed to push test content: %v", err)
+	}
+	manifestJSON, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    layer,
+		Layers:    []ocispec.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestJSON)
+	if err := s.Push(ctx, manifest, bytes.NewReader(manifestJSON)); err != nil {
+		t.Fatalf("failed to push test content: %v", err)
+	}
+	if err := s.Tag(ctx, manifest, reference); err != nil {
+		t.Fatalf("failed to tag test content: %v", err)
+	}
+	return manifest
+}
+
+func TestStore_SaveIndex_MergeChanges(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+	// the stores simulate the processes sharing the same OCI layout
+	s1, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	s2, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+
+	foo := pushTestManifest(t, s1, "foo", "foo")
+	bar := pushTestManifest(t, s2, "bar", "bar")
+	// the changes of s1 are merged into s2
+	if got, err := s2.Resolve(ctx, "foo"); err != nil || !content.Equal(got, foo) {
+		t.Errorf("Store.Resolve() = %v, %v, want %v", got, err, foo)
+	}
+
+	// untagging in s1 is kept while s2 saves
+	if err := s1.Untag(ctx, "foo"); err != nil {
+		t.Fatal("Store.Untag() error =", err)
+	}
+	if err := s2.Tag(ctx, bar, "latest"); err != nil {
+		t.Fatal("Store.Tag() error =", err)
+	}
+	// retagging in s1 wins over the stale tag in s2
+	if err := s1.Tag(ctx, foo, "bar"); err != nil {
+		t.Fatal("Store.Tag() error =", err)
+	}
+
+	s3, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	if _, err := s3.Resolve(ctx, "foo"); err == nil {
+		t.Errorf("Store.Resolve() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+	want := map[string]ocispec.Descriptor{
+		"bar":    foo,
+		"latest": bar,
+	}
+	for ref, desc := range want {
+		if got, err := s3.Resolve(ctx, ref); err != nil || !content.Equal(got, desc) {
+			t.Errorf("Store.Resolve(%q) = %v, %v, want %v", ref, got, err, desc)
+		}
+	}
+}
+
+func TestStore_SaveIndex_UntaggedEntries(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	foo := pushTestManifest(t, s, "foo", "foo")
+
+	// entries of the same digest differing in platforms or annotations
+	tagged := foo
+	tagged.Annotations = map[string]string{ocispec.AnnotationRefName: "foo"}
+	amd64 := foo
+	amd64.Platform = &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := foo
+	arm64.Platform = &ocispec.Platform{OS: "linux", Architecture: "arm64"}
+	annotated := foo
+	annotated.Annotations = map[string]string{"key": "value"}
+	index := ocispec.Index{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2,
+		},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{tagged, amd64, arm64, annotated, amd64},
+	}
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexPath := filepath.Join(tempDir, ocispec.ImageIndexFile)
+	if err := os.WriteFile(indexPath, indexJSON, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	if err := s.SaveIndex(); err != nil {
+		t.Fatal("Store.SaveIndex() error =", err)
+	}
+	if err := s.GC(ctx); err != nil {
+		t.Fatal("Store.GC() error =", err)
+	}
+
+	// the untagged entries are kept without duplicates
+	want := []ocispec.Descriptor{tagged, amd64, arm64, annotated}
+	got, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotIndex ocispec.Index
+	if err := json.Unmarshal(got, &gotIndex); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotIndex.Manifests, want) {
+		t.Errorf("index.json manifests = %v, want %v", gotIndex.Manifests, want)
+	}
+	if got, err := s.Resolve(ctx, foo.Digest.String()); err != nil || !content.Equal(got, foo) {
+		t.Errorf("Store.Resolve() = %v, %v, want %v", got, err, foo)
+	}
+}
+
+func TestStore_SaveIndex_Concurrent(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+	const n = 8
+	stores := make([]*Store, n)
+	for i := range stores {
+		s, err := New(tempDir)
+		if err != nil {
+			t.Fatal("New() error =", err)
+		}
+		stores[i] = s
+	}
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	for i, s := range stores {
+		eg.Go(func() error {
+			blob := []byte(fmt.Sprintf(`{"layers":[],"annotations":{"i":"%d"}}`, i))
+			desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, blob)
+			if err := s.Push(egCtx, desc, bytes.NewReader(blob)); err != nil {
+				return err
+			}
+			return s.Tag(egCtx, desc, fmt.Sprintf("tag%d", i))
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := s.Resolve(ctx, fmt.Sprintf("tag%d", i)); err != nil {
+			t.Errorf("Store.Resolve() error = %v", err)
+		}
+	}
+}
+
+func TestStore_GC_SharedLayout(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+	s1, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	s2, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	s2.GCGracePeriod = time.Hour
+
+	// s1 tags a manifest and pushes a blob whose manifest is not yet pushed
+	foo := pushTestManifest(t, s1, "foo", "foo")
+	pending := []byte("pending")
+	pendingDesc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, pending)
+	if err := s1.Push(ctx, pendingDesc, bytes.NewReader(pending)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s2.GC(ctx); err != nil {
+		t.Fatal("Store.GC() error =", err)
+	}
+	for _, desc := range []ocispec.Descriptor{foo, pendingDesc} {
+		if exists, err := s1.Exists(ctx, desc); err != nil || !exists {
+			t.Errorf("Store.Exists(%v) = %v, %v, want true", desc, exists, err)
+		}
+	}
+
+	// the blobs reused by the pushers are protected as well
+	old := time.Now().Add(-2 * s2.GCGracePeriod)
+	path, err := s1.blobFilePath(pendingDesc.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := s1.Push(ctx, pendingDesc, bytes.NewReader(pending)); !errors.Is(err, errdef.ErrAlreadyExists) {
+		t.Fatalf("Store.Push() error = %v, wantErr %v", err, errdef.ErrAlreadyExists)
+	}
+	if err := s2.GC(ctx); err != nil {
+		t.Fatal("Store.GC() error =", err)
+	}
+	if exists, err := s1.Exists(ctx, pendingDesc); err != nil || !exists {
+		t.Errorf("Store.Exists(%v) = %v, %v, want true", pendingDesc, exists, err)
+	}
+
+	// blobs outside the grace period are removed
+	s2.GCGracePeriod = 0
+	if err := s2.GC(ctx); err != nil {
+		t.Fatal("Store.GC() error =", err)
+	}
+	if exists, err := s1.Exists(ctx, pendingDesc); err != nil || exists {
+		t.Errorf("Store.Exists(%v) = %v, %v, want false", pendingDesc, exists, err)
+	}
+	if exists, err := s1.Exists(ctx, foo); err != nil || !exists {
+		t.Errorf("Store.Exists(%v) = %v, %v, want true", foo, exists, err)
+	}
+}
+
+func TestStore_Delete_SharedLayout(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+	s1, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	s2, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	push := func(s *Store, mediaType string, blob []byte) ocispec.Descriptor {
+		desc := content.NewDescriptorFromBytes(mediaType, blob)
+		if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+			t.Fatalf("failed to push test content: %v", err)
+		}
+		return desc
+	}
+	pushManifest := func(s *Store, config, layer ocispec.Descriptor, reference string) ocispec.Descriptor {
+		manifestJSON, err := json.Marshal(ocispec.Manifest{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config:    config,
+			Layers:    []ocispec.Descriptor{layer},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest := push(s, ocispec.MediaTypeImageManifest, manifestJSON)
+		if err := s.Tag(ctx, manifest, reference); err != nil {
+			t.Fatalf("failed to tag test content: %v", err)
+		}
+		return manifest
+	}
+
+	// s1 and s2 tag the manifests sharing the same layer
+	layer := push(s1, ocispec.MediaTypeImageLayer, []byte("shared layer"))
+	foo := pushManifest(s1, push(s1, ocispec.MediaTypeImageConfig, []byte("foo")), layer, "foo")
+	push(s2, ocispec.MediaTypeImageLayer, []byte("shared layer"))
+	bar := pushManifest(s2, push(s2, ocispec.MediaTypeImageConfig, []byte("bar")), layer, "bar")
+
+	// the layer referenced by s2 is kept when s1 deletes its manifest
+	if err := s1.Delete(ctx, foo); err != nil {
+		t.Fatal("Store.Delete() error =", err)
+	}
+	s3, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	if got, err := s3.Resolve(ctx, "bar"); err != nil || !content.Equal(got, bar) {
+		t.Errorf("Store.Resolve() = %v, %v, want %v", got, err, bar)
+	}
+	if _, err := s3.Resolve(ctx, "foo"); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Store.Resolve() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+	if exists, err := s3.Exists(ctx, layer); err != nil || !exists {
+		t.Errorf("Store.Exists(%v) = %v, %v, want true", layer, exists, err)
+	}
+	if exists, err := s3.Exists(ctx, foo); err != nil || exists {
+		t.Errorf("Store.Exists(%v) = %v, %v, want false", foo, exists, err)
+	}
+}
+
+func TestStore_TagAll(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	ctx := context.Background()
+
+	host := platforms.DefaultSpec()
+	other := ocispec.Platform{OS: "plan9", Architecture: "unknown"}
+	foo := pushTestManifest(t, s, "foo", "foo")
+	foo.Platform = &other
+	bar := pushTestManifest(t, s, "bar", "bar")
+	bar.Platform = &host
+	ref := "latest"
+
+	// invalid platforms
+	if err := s.TagAll(ctx, []ocispec.Descriptor{foo, bar, bar}, ref); !errors.Is(err, errdef.ErrInvalidPlatform) {
+		t.Errorf("Store.TagAll() error = %v, wantErr %v", err, errdef.ErrInvalidPlatform)
+	}
+	noPlatform := foo
+	noPlatform.Platform = nil
+	if err := s.TagAll(ctx, []ocispec.Descriptor{noPlatform, bar}, ref); !errors.Is(err, errdef.ErrInvalidPlatform) {
+		t.Errorf("Store.TagAll() error = %v, wantErr %v", err, errdef.ErrInvalidPlatform)
+	}
+
+	if err := s.TagAll(ctx, []ocispec.Descriptor{foo, bar}, ref); err != nil {
+		t.Fatal("Store.TagAll() error =", err)
+	}
+	want := []ocispec.Descriptor{foo, bar}
+	got, err := s.ResolveAll(ctx, ref)
+	if err != nil {
+		t.Fatal("Store.ResolveAll() error =", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Store.ResolveAll() = %v, want %v", got, want)
+	}
+	// the manifest of the host platform is preferred
+	desc, err := s.Resolve(ctx, ref)
+	if err != nil {
+		t.Fatal("Store.Resolve() error =", err)
+	}
+	if !reflect.DeepEqual(desc, bar) {
+		t.Errorf("Store.Resolve() = %v, want %v", desc, bar)
+	}
+
+	// all the entries are saved to and loaded from index.json
+	var index ocispec.Index
+	indexJSON, err := os.ReadFile(filepath.Join(tempDir, ocispec.ImageIndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(indexJSON, &index); err != nil {
+		t.Fatal(err)
+	}
+	var refEntries int
+	for _, desc := range index.Manifests {
+		if desc.Annotations[ocispec.AnnotationRefName] == ref {
+			refEntries++
+		}
+	}
+	if refEntries != 2 {
+		t.Errorf("index.json = %s, want 2 entries of %s", indexJSON, ref)
+	}
+	reloaded, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	got, err = reloaded.ResolveAll(ctx, ref)
+	if err != nil {
+		t.Fatal("Store.ResolveAll() error =", err)
+	}
+	if len(got) != 2 || !content.Equal(got[0], foo) || !content.Equal(got[1], bar) {
+		t.Errorf("Store.ResolveAll() = %v, want %v", got, want)
+	}
+
+	// deleting a manifest keeps the other one tagged
+	if err := s.Delete(ctx, foo); err != nil {
+		t.Fatal("Store.Delete() error =", err)
+	}
+	got, err = s.ResolveAll(ctx, ref)
+	if err != nil {
+		t.Fatal("Store.ResolveAll() error =", err)
+	}
+	if want := []ocispec.Descriptor{bar}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Store.ResolveAll() = %v, want %v", got, want)
+	}
+
+	// untagging removes all the entries
+	if err := s.Untag(ctx, ref); err != nil {
+		t.Fatal("Store.Untag() error =", err)
+	}
+	if _, err := s.ResolveAll(ctx, ref); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Store.ResolveAll() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+}
+
+func TestStore_NestedIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	ctx := context.Background()
+
+	foo := pushTestManifest(t, s, "foo", "foo")
+	bar := pushTestManifest(t, s, "bar", "bar")
+	for _, ref := range []string{"foo", "bar"} {
+		if err := s.Untag(ctx, ref); err != nil {
+			t.Fatal("Store.Untag() error =", err)
+		}
+	}
+	foo.Annotations = map[string]string{ocispec.AnnotationRefName: "nested"}
+	bar.Annotations = map[string]string{ocispec.AnnotationRefName: "nested"}
+	nestedJSON, err := json.Marshal(ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{foo, bar},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested := content.NewDescriptorFromBytes(ocispec.MediaTypeImageIndex, nestedJSON)
+	if err := s.Push(ctx, nested, bytes.NewReader(nestedJSON)); err != nil {
+		t.Fatal("Store.Push() error =", err)
+	}
+	if err := s.Tag(ctx, nested, "top"); err != nil {
+		t.Fatal("Store.Tag() error =", err)
+	}
+
+	reloaded, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	got, err := reloaded.ResolveAll(ctx, "nested")
+	if err != nil {
+		t.Fatal("Store.ResolveAll() error =", err)
+	}
+	if want := []ocispec.Descriptor{foo, bar}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Store.ResolveAll() = %v, want %v", got, want)
+	}
+	if err := reloaded.Tags(ctx, "", func(tags []string) error {
+		if want := []string{"nested", "top"}; !reflect.DeepEqual(tags, want) {
+			t.Errorf("Store.Tags() = %v, want %v", tags, want)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal("Store.Tags() error =", err)
+	}
+
+	// nested tags are not flattened into index.json
+	if err := reloaded.SaveIndex(); err != nil {
+		t.Fatal("Store.SaveIndex() error =", err)
+	}
+	indexJSON, err := os.ReadFile(filepath.Join(tempDir, ocispec.ImageIndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(indexJSON, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 3 || strings.Contains(string(indexJSON), `"nested"`) {
+		t.Errorf("index.json = %s, want the nested index and its manifests without nested tags", indexJSON)
+	}
+
+	// top-level tags take precedence over nested tags
+	if err := reloaded.Tag(ctx, nested, "nested"); err != nil {
+		t.Fatal("Store.Tag() error =", err)
+	}
+	desc, err := reloaded.Resolve(ctx, "nested")
+	if err != nil {
+		t.Fatal("Store.Resolve() error =", err)
+	}
+	if !content.Equal(desc, nested) {
+		t.Errorf("Store.Resolve() = %v, want %v", desc, nested)
+	}
+}
+
+func TestStore_NestedIndex_Malformed(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	ctx := context.Background()
+
+	foo := pushTestManifest(t, s, "foo", "foo")
+	malformedJSON := []byte(`{"manifests":"malformed"}`)
+	malformed := content.NewDescriptorFromBytes(ocispec.MediaTypeImageIndex, malformedJSON)
+	if err := s.storage.Push(ctx, malformed, bytes.NewReader(malformedJSON)); err != nil {
+		t.Fatal("Storage.Push() error =", err)
+	}
+	if err := s.Tag(ctx, malformed, "malformed"); err != nil {
+		t.Fatal("Store.Tag() error =", err)
+	}
+
+	// malformed indexes are skipped on looking up the nested tags
+	if _, err := s.Resolve(ctx, "missing"); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Store.Resolve() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+	if got, err := s.Resolve(ctx, "foo"); err != nil || !content.Equal(got, foo) {
+		t.Errorf("Store.Resolve() = %v, %v, want %v", got, err, foo)
+	}
+	if err := s.Tags(ctx, "", func(tags []string) error {
+		if want := []string{"foo", "malformed"}; !reflect.DeepEqual(tags, want) {
+			t.Errorf("Store.Tags() = %v, want %v", tags, want)
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("Store.Tags() error = %v", err)
+	}
+}
@@ -0,0 +1,522 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/fs/filelock"
+	"oras.land/oras-go/v2/internal/fs/reflink"
+	"oras.land/oras-go/v2/internal/ioutil"
+)
+
+// BlobPool is a content-addressable directory of blobs shared by multiple OCI
+// layouts, where each blob is stored once in the pool and linked into the
+// layouts pushing it.
+//
+// Blobs are linked with reflinks where supported by the file system, such
+// that the layouts have independent files sharing the same data blocks.
+// Otherwise, blobs are linked with hard links, or copied if the layout is on
+// a different file system from the pool.
+//
+// Blobs deleted from the layouts remain in the pool until GC() is called,
+// which removes the blobs no longer referenced by any layout using the pool.
+// The pool can be shared by multiple processes.
+type BlobPool struct {
+	// IngestGracePeriod is the duration for which the temporary ingest files
+	// in the pool are considered in use by ongoing pushes, such as the ones of
+	// other processes, since their last modification. Older ingest files are
+	// left by interrupted pushes, and are removed by GC().
+	// If zero, all ingest files are removed by GC().
+	//   - Default value: DefaultIngestGracePeriod.
+	IngestGracePeriod time.Duration
+
+	root        string
+	ingestRoot  string
+	layoutsRoot string
+	lockPath    string
+
+	// lock ensures that only one go-routine is changing the pool. Across
+	// processes, the pool is guarded by an advisory lock on the `pool.lock`
+	// file.
+	lock sync.Mutex
+}
+
+// DefaultIngestGracePeriod is the default value of BlobPool.IngestGracePeriod.
+const DefaultIngestGracePeriod = time.Hour
+
+// NewBlobPool creates a new blob pool at root, or opens the existing one.
+func NewBlobPool(root string) (*BlobPool, error) {
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve absolute path for %s: %w", root, err)
+	}
+	pool := &BlobPool{
+		IngestGracePeriod: DefaultIngestGracePeriod,
+		root:              rootAbs,
+		ingestRoot:        filepath.Join(rootAbs, "ingest"),
+		layoutsRoot:       filepath.Join(rootAbs, "layouts"),
+		lockPath:          filepath.Join(rootAbs, "pool.lock"),
+	}
+	for _, dir := range []string{ocispec.ImageBlobsDir, "ingest", "layouts"} {
+		if err := ensureDir(filepath.Join(rootAbs, dir)); err != nil {
+			return nil, err
+		}
+	}
+	return pool, nil
+}
+
+// NewStorageWithPool creates a new CAS based on file system with the OCI-Image
+// layout, where the blobs are stored in the shared blob pool and linked into
+// the layout.
+func NewStorageWithPool(root string, pool *BlobPool) (*Storage, error) {
+	storage, err := NewStorage(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.register(storage.root); err != nil {
+		return nil, err
+	}
+	storage.pool = pool
+	return storage, nil
+}
+
+// NewWithPool creates a new OCI store, where the blobs are stored in the
+// shared blob pool and linked into the layout.
+func NewWithPool(ctx context.Context, root string, pool *BlobPool) (*Store, error) {
+	return newStore(ctx, root, pool)
+}
+
+// GC removes the blobs in the pool which are not referenced by any layout
+// using the pool, where a layout references a blob if the blob in the layout
+// is linked to the blob in the pool. Copies of the blob, such as the ones in
+// the layouts on different file systems from the pool, are not references.
+// The layouts whose root directories are removed from the file system no
+// longer use the pool.
+// The stale ingest files left by interrupted pushes are removed as well.
+func (p *BlobPool) GC(ctx context.Context) (err error) {
+	unlock, err := p.lockPool()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	refCounts, err := p.countReferences(ctx)
+	if err != nil {
+		return err
+	}
+	if err := walkBlobs(ctx, p.root, func(dgst digest.Digest, blobPath string, _ int64) error {
+		if refCounts[dgst] > 0 {
+			return nil
+		}
+		if err := os.Remove(blobPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return p.removeStaleIngests()
+}
+
+// countReferences returns the number of the layouts referencing each blob.
+// A blob in a layout references the blob in the pool if it is a hard link of
+// the blob in the pool, or if it may be a reflink of the blob in the pool,
+// i.e. the layout supports reflinks from the pool.
+// The caller must hold the lock on the pool.
+func (p *BlobPool) countReferences(ctx context.Context) (map[digest.Digest]int, error) {
+	poolInfos := make(map[digest.Digest]fs.FileInfo)
+	if err := walkBlobs(ctx, p.root, func(dgst digest.Digest, blobPath string, _ int64) error {
+		info, err := os.Stat(blobPath)
+		if err != nil {
+			return err
+		}
+		poolInfos[dgst] = info
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(p.layoutsRoot)
+	if err != nil {
+		return nil, err
+	}
+	refCounts := make(map[digest.Digest]int)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		entryPath := filepath.Join(p.layoutsRoot, entry.Name())
+		root, err := os.ReadFile(entryPath)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(string(root)); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			// the layout no longer exists
+			if err := os.Remove(entryPath); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// whether the layout supports reflinks from the pool is checked once
+		// on the first blob which is not a hard link
+		var reflinkChecked, reflinkSupported bool
+		if err := walkBlobs(ctx, string(root), func(dgst digest.Digest, layoutPath string, _ int64) error {
+			poolInfo, ok := poolInfos[dgst]
+			if !ok {
+				return nil
+			}
+			info, err := os.Stat(layoutPath)
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					// the blob is deleted from the layout
+					return nil
+				}
+				return err
+			}
+			if !os.SameFile(poolInfo, info) {
+				if !reflinkChecked {
+					path, err := blobPath(dgst)
+					if err != nil {
+						return err
+					}
+					poolPath := filepath.Join(p.root, path)
+					reflinkSupported, err = canReflink(poolPath, filepath.Join(string(root), "ingest"))
+					if err != nil {
+						return err
+					}
+					reflinkChecked = true
+				}
+				if !reflinkSupported {
+					// the blob is a copy
+					return nil
+				}
+			}
+			refCounts[dgst]++
+			return nil
+		}); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return refCounts, nil
+}
+
+// canReflink returns true if the file at src can be cloned into dir with a
+// reflink, by cloning it into a temporary file which is removed afterwards.
+func canReflink(src, dir string) (bool, error) {
+	if err := ensureDir(dir); err != nil {
+		return false, err
+	}
+	fp, err := os.CreateTemp(dir, "reflink_*")
+	if err != nil {
+		return false, err
+	}
+	probe := fp.Name()
+	if err := fp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Remove(probe); err != nil {
+		return false, err
+	}
+
+	err = reflink.Clone(src, probe)
+	if err == nil {
+		return true, os.Remove(probe)
+	}
+	if errors.Is(err, errors.ErrUnsupported) {
+		return false, nil
+	}
+	return false, err
+}
+
+// removeStaleIngests removes the ingest files in the pool which are not
+// modified within IngestGracePeriod.
+func (p *BlobPool) removeStaleIngests() error {
+	entries, err := os.ReadDir(p.ingestRoot)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// the ingest file has been committed or removed
+				continue
+			}
+			return err
+		}
+		if p.IngestGracePeriod > 0 && time.Since(info.ModTime()) < p.IngestGracePeriod {
+			continue
+		}
+		if err := os.Remove(filepath.Join(p.ingestRoot, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// register records the layout at root as a user of the pool.
+func (p *BlobPool) register(root string) error {
+	name := sha256.Sum256([]byte(root))
+	entryPath := filepath.Join(p.layoutsRoot, hex.EncodeToString(name[:]))
+	if _, err := os.Stat(entryPath); err == nil {
+		return nil
+	}
+	// write the entry atomically as it may be read by GC() concurrently
+	tempPath := entryPath + ".tmp"
+	if err := os.WriteFile(tempPath, []byte(root), 0666); err != nil {
+		return fmt.Errorf("failed to register layout %s: %w", root, err)
+	}
+	if err := os.Rename(tempPath, entryPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to register layout %s: %w", root, err)
+	}
+	return nil
+}
+
+// removeCorrupted removes the blob identified by dgst from the pool if its
+// content does not match the digest, so that it is replaced on the next push
+// instead of being linked into the layouts.
+func (p *BlobPool) removeCorrupted(dgst digest.Digest) (err error) {
+	path, err := blobPath(dgst)
+	if err != nil {
+		return err
+	}
+	poolPath := filepath.Join(p.root, path)
+
+	// hash the blob without holding the lock on the pool
+	info, err := os.Stat(poolPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	intact, err := verifyBlobFile(poolPath, dgst, -1)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if intact {
+		return nil
+	}
+
+	unlock, err := p.lockPool()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+	current, err := os.Stat(poolPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if !os.SameFile(info, current) {
+		// the blob has been replaced by a push
+		return nil
+	}
+	return os.Remove(poolPath)
+}
+
+// lockPool acquires the lock on the pool shared across processes, and
+// returns the function releasing the lock.
+// If file locking is not supported on the platform, the pool is guarded
+// within the process only.
+func (p *BlobPool) lockPool() (func() error, error) {
+	p.lock.Lock()
+	lockFile, err := filelock.Lock(p.lockPath)
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			return func() error {
+				p.lock.Unlock()
+				return nil
+			}, nil
+		}
+		p.lock.Unlock()
+		return nil, fmt.Errorf("failed to lock blob pool: %w", err)
+	}
+	return func() error {
+		defer p.lock.Unlock()
+		return filelock.Unlock(lockFile)
+	}, nil
+}
+
+// pushToPool stores the content in the pool if absent or truncated, and
+// links the blob in the pool to target.
+func (s *Storage) pushToPool(expected ocispec.Descriptor, content io.Reader, target string) error {
+	path, err := blobPath(expected.Digest)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", expected.Digest, expected.MediaType, errdef.ErrInvalidDigest)
+	}
+	poolPath := filepath.Join(s.pool.root, path)
+
+	// link the blob directly if it is already in the pool
+	linked, err := s.linkFromPool(expected, poolPath, target, "")
+	if err != nil {
+		return err
+	}
+	if linked {
+		// verify the content even if it is not stored
+		buf := bufPool.Get().(*[]byte)
+		defer bufPool.Put(buf)
+		if err := ioutil.CopyBuffer(io.Discard, content, *buf, expected); err != nil {
+			os.Remove(target)
+			return fmt.Errorf("failed to ingest: %w", err)
+		}
+		return nil
+	}
+
+	// write the content to a temporary ingest file in the pool, and move it
+	// into the pool before linking, replacing the truncated blob if any
+	ingest, err := ingestFile(s.pool.ingestRoot, expected, content)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(ingest)
+	_, err = s.linkFromPool(expected, poolPath, target, ingest)
+	return err
+}
+
+// linkFromPool links the blob at poolPath to target, and returns true if the
+// blob is linked. If the blob does not exist in the pool or its size does not
+// match expected, the ingest file is moved into the pool before linking if
+// provided, otherwise false is returned.
+// The lock on the pool is held so that the blob is not removed by GC() before
+// it is linked. The content of the blob is not re-hashed under the lock, and
+// the corrupted blobs in the pool are removed by Store.Verify() on repair.
+func (s *Storage) linkFromPool(expected ocispec.Descriptor, poolPath, target, ingest string) (linked bool, err error) {
+	unlock, err := s.pool.lockPool()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	info, err := os.Stat(poolPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	if err != nil || info.Size() != expected.Size {
+		if ingest == "" {
+			return false, nil
+		}
+		if err := ensureDir(filepath.Dir(poolPath)); err != nil {
+			return false, err
+		}
+		// the truncated blob is read-only, and is removed before replacing
+		if err := os.Remove(poolPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+		if err := os.Rename(ingest, poolPath); err != nil {
+			return false, err
+		}
+	}
+
+	if err := linkBlob(poolPath, target, s.ingestRoot); err != nil {
+		if !errors.Is(err, fs.ErrExist) {
+			return false, err
+		}
+		return false, fmt.Errorf("%s: %s: %w", expected.Digest, expected.MediaType, errdef.ErrAlreadyExists)
+	}
+	return true, nil
+}
+
+// linkBlob links the blob at src to dst with a reflink if supported, or with
+// a hard link otherwise. If neither is possible, such as dst is on a
+// different file system, the blob is copied through a temporary ingest file
+// under ingestRoot.
+// An error wrapping fs.ErrExist is returned if dst already exists.
+func linkBlob(src, dst, ingestRoot string) error {
+	err := reflink.Clone(src, dst)
+	if err == nil || !errors.Is(err, errors.ErrUnsupported) {
+		return err
+	}
+	err = os.Link(src, dst)
+	if err == nil || errors.Is(err, fs.ErrExist) {
+		return err
+	}
+
+	// fall back to copying the blob
+	fp, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	info, err := fp.Stat()
+	if err != nil {
+		return err
+	}
+	dgst := digest.Digest(filepath.Base(filepath.Dir(src)) + ":" + filepath.Base(src))
+	temp, err := ingestFile(ingestRoot, ocispec.Descriptor{
+		Digest: dgst,
+		Size:   info.Size(),
+	}, fp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp)
+
+	// link the copy into place instead of renaming it, so that the existing
+	// dst is not overwritten
+	err = os.Link(temp, dst)
+	if err == nil || errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	// the file system does not support hard links
+	if _, err := os.Lstat(dst); err == nil {
+		return &fs.PathError{Op: "link", Path: dst, Err: fs.ErrExist}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Rename(temp, dst)
+}
@@ -0,0 +1,336 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+)
+
+func TestBlobPool(t *testing.T) {
+	tempDir := t.TempDir()
+	pool, err := NewBlobPool(filepath.Join(tempDir, "pool"))
+	if err != nil {
+		t.Fatal("NewBlobPool() error =", err)
+	}
+	ctx := context.Background()
+	s1, err := NewWithPool(ctx, filepath.Join(tempDir, "layout1"), pool)
+	if err != nil {
+		t.Fatal("NewWithPool() error =", err)
+	}
+	s2, err := NewWithPool(ctx, filepath.Join(tempDir, "layout2"), pool)
+	if err != nil {
+		t.Fatal("NewWithPool() error =", err)
+	}
+
+	blob := []byte("hello world")
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, blob)
+	for _, s := range []*Store{s1, s2} {
+		if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+			t.Fatal("Store.Push() error =", err)
+		}
+		got, err := content.FetchAll(ctx, s, desc)
+		if err != nil {
+			t.Fatal("Store.Fetch() error =", err)
+		}
+		if !bytes.Equal(got, blob) {
+			t.Errorf("Store.Fetch() = %v, want %v", got, blob)
+		}
+	}
+	if err := s1.Push(ctx, desc, bytes.NewReader(blob)); !errors.Is(err, errdef.ErrAlreadyExists) {
+		t.Errorf("Store.Push() error = %v, wantErr %v", err, errdef.ErrAlreadyExists)
+	}
+
+	// the blob is stored once in the pool
+	path, err := blobPath(desc.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolPath := filepath.Join(pool.root, path)
+	poolInfo, err := os.Stat(poolPath)
+	if err != nil {
+		t.Fatal("blob not stored in the pool:", err)
+	}
+	for _, s := range []*Store{s1, s2} {
+		info, err := os.Stat(filepath.Join(s.root, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != desc.Size {
+			t.Errorf("blob size = %d, want %d", info.Size(), desc.Size)
+		}
+		// the blob is either hard linked or reflinked to the pool
+		if os.SameFile(info, poolInfo) {
+			continue
+		}
+		if linkErr := os.Link(poolPath, filepath.Join(t.TempDir(), "link")); linkErr == nil {
+			t.Errorf("blob in %s is not linked to the pool", s.root)
+		}
+	}
+
+	// content is verified even if it exists in the pool
+	s3, err := NewWithPool(ctx, filepath.Join(tempDir, "layout3"), pool)
+	if err != nil {
+		t.Fatal("NewWithPool() error =", err)
+	}
+	if err := s3.Push(ctx, desc, strings.NewReader("foobar bazz")); err == nil {
+		t.Errorf("Store.Push() error = %v, wantErr %v", err, true)
+	}
+	if exists, err := s3.Exists(ctx, desc); err != nil || exists {
+		t.Errorf("Store.Exists() = %v, %v, want %v", exists, err, false)
+	}
+
+	// the blob is kept in the pool while referenced by any layout
+	if err := s1.Delete(ctx, desc); err != nil {
+		t.Fatal("Store.Delete() error =", err)
+	}
+	if err := pool.GC(ctx); err != nil {
+		t.Fatal("BlobPool.GC() error =", err)
+	}
+	if _, err := os.Stat(poolPath); err != nil {
+		t.Errorf("blob removed from the pool: %v", err)
+	}
+	got, err := content.FetchAll(ctx, s2, desc)
+	if err != nil {
+		t.Fatal("Store.Fetch() error =", err)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Errorf("Store.Fetch() = %v, want %v", got, blob)
+	}
+
+	// the blob is removed from the pool once no layout references it
+	if err := s2.Delete(ctx, desc); err != nil {
+		t.Fatal("Store.Delete() error =", err)
+	}
+	if err := pool.GC(ctx); err != nil {
+		t.Fatal("BlobPool.GC() error =", err)
+	}
+	if _, err := os.Stat(poolPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("blob in the pool error = %v, wantErr %v", err, os.ErrNotExist)
+	}
+}
+
+func TestBlobPool_GC_RemovedLayout(t *testing.T) {
+	tempDir := t.TempDir()
+	pool, err := NewBlobPool(filepath.Join(tempDir, "pool"))
+	if err != nil {
+		t.Fatal("NewBlobPool() error =", err)
+	}
+	ctx := context.Background()
+	root := filepath.Join(tempDir, "layout")
+	s, err := NewWithPool(ctx, root, pool)
+	if err != nil {
+		t.Fatal("NewWithPool() error =", err)
+	}
+	blob := []byte("hello world")
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, blob)
+	if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+		t.Fatal("Store.Push() error =", err)
+	}
+	path, err := blobPath(desc.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// removing the layout releases its blobs in the pool
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.GC(ctx); err != nil {
+		t.Fatal("BlobPool.GC() error =", err)
+	}
+	if _, err := os.Stat(filepath.Join(pool.root, path)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("blob in the pool error = %v, wantErr %v", err, os.ErrNotExist)
+	}
+	entries, err := os.ReadDir(pool.layoutsRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("registered layouts = %v, want none", entries)
+	}
+}
+
+func TestBlobPool_CorruptedBlob(t *testing.T) {
+	tempDir := t.TempDir()
+	pool, err := NewBlobPool(filepath.Join(tempDir, "pool"))
+	if err != nil {
+		t.Fatal("NewBlobPool() error =", err)
+	}
+	ctx := context.Background()
+	s1, err := NewWithPool(ctx, filepath.Join(tempDir, "layout1"), pool)
+	if err != nil {
+		t.Fatal("NewWithPool() error =", err)
+	}
+	blob := []byte("hello world")
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, blob)
+	if err := s1.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+		t.Fatal("Store.Push() error =", err)
+	}
+	path, err := blobPath(desc.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolPath := filepath.Join(pool.root, path)
+	corrupt := func(content []byte) {
+		t.Helper()
+		if err := os.Remove(poolPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(poolPath, content, 0444); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkPool := func(want []byte) {
+		t.Helper()
+		got, err := os.ReadFile(poolPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("blob in the pool = %v, want %v", got, want)
+		}
+	}
+
+	// the truncated blob is replaced by the pushed content
+	corrupt([]byte("hello"))
+	s2, err := NewWithPool(ctx, filepath.Join(tempDir, "layout2"), pool)
+	if err != nil {
+		t.Fatal("NewWithPool() error =", err)
+	}
+	if err := s2.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+		t.Fatal("Store.Push() error =", err)
+	}
+	checkPool(blob)
+
+	// the corrupted blob is removed from the pool on repair, and replaced by
+	// the pushed content
+	corrupted := []byte("hello wordl")
+	corrupt(corrupted)
+	s3, err := NewWithPool(ctx, filepath.Join(tempDir, "layout3"), pool)
+	if err != nil {
+		t.Fatal("NewWithPool() error =", err)
+	}
+	if err := s3.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+		t.Fatal("Store.Push() error =", err)
+	}
+	report, err := s3.Verify(ctx, VerifyOptions{Repair: true})
+	if err != nil {
+		t.Fatal("Store.Verify() error =", err)
+	}
+	if len(report.Corrupted) != 1 || report.Corrupted[0].Digest != desc.Digest {
+		t.Errorf("VerifyReport.Corrupted = %v, want %v", report.Corrupted, desc.Digest)
+	}
+	if _, err := os.Stat(poolPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("blob in the pool error = %v, wantErr %v", err, os.ErrNotExist)
+	}
+	if err := s3.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+		t.Fatal("Store.Push() error =", err)
+	}
+	checkPool(blob)
+	for _, s := range []*Store{s2, s3} {
+		got, err := content.FetchAll(ctx, s, desc)
+		if err != nil {
+			t.Fatal("Store.Fetch() error =", err)
+		}
+		if !bytes.Equal(got, blob) {
+			t.Errorf("Store.Fetch() = %v, want %v", got, blob)
+		}
+	}
+}
+
+func TestBlobPool_GC_CopiedBlob(t *testing.T) {
+	tempDir := t.TempDir()
+	pool, err := NewBlobPool(filepath.Join(tempDir, "pool"))
+	if err != nil {
+		t.Fatal("NewBlobPool() error =", err)
+	}
+	ctx := context.Background()
+	root := filepath.Join(tempDir, "layout")
+	s, err := NewWithPool(ctx, root, pool)
+	if err != nil {
+		t.Fatal("NewWithPool() error =", err)
+	}
+	blob := []byte("hello world")
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, blob)
+	if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+		t.Fatal("Store.Push() error =", err)
+	}
+	path, err := blobPath(desc.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolPath := filepath.Join(pool.root, path)
+	if ok, err := canReflink(poolPath, t.TempDir()); err != nil || ok {
+		t.Skip("copies cannot be told from reflinks:", err)
+	}
+
+	// replace the link in the layout with a copy, as on a different file
+	// system from the pool
+	layoutPath := filepath.Join(root, path)
+	if err := os.Remove(layoutPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(layoutPath, blob, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	// leave ingest files in the pool by pushes, one of which is interrupted
+	staleIngest := filepath.Join(pool.ingestRoot, "stale")
+	ongoingIngest := filepath.Join(pool.ingestRoot, "ongoing")
+	for _, ingest := range []string{staleIngest, ongoingIngest} {
+		if err := os.WriteFile(ingest, blob, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	staleTime := time.Now().Add(-2 * pool.IngestGracePeriod)
+	if err := os.Chtimes(staleIngest, staleTime, staleTime); err != nil {
+		t.Fatal(err)
+	}
+
+	// the copy does not reference the blob in the pool
+	if err := pool.GC(ctx); err != nil {
+		t.Fatal("BlobPool.GC() error =", err)
+	}
+	if _, err := os.Stat(poolPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("blob in the pool error = %v, wantErr %v", err, os.ErrNotExist)
+	}
+	got, err := content.FetchAll(ctx, s, desc)
+	if err != nil {
+		t.Fatal("Store.Fetch() error =", err)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Errorf("Store.Fetch() = %v, want %v", got, blob)
+	}
+
+	// only the stale ingest file is removed
+	if _, err := os.Stat(staleIngest); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stale ingest file error = %v, wantErr %v", err, os.ErrNotExist)
+	}
+	if _, err := os.Stat(ongoingIngest); err != nil {
+		t.Errorf("ongoing ingest file error = %v", err)
+	}
+}
@@ -0,0 +1,605 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/container/set"
+	"oras.land/oras-go/v2/internal/descriptor"
+	"oras.land/oras-go/v2/internal/manifestutil"
+)
+
+// PrunePolicy specifies the tags to be removed by [Store.Prune].
+//
+// The creation time of a tag is read from the annotation
+// "org.opencontainers.image.created" of its descriptor in `index.json`, or
+// of its manifest if absent. The last use time of a tag is the last time the
+// tag is tagged or resolved by a store of the OCI layout with
+// [Store.TrackUsage] set, which is recorded at most once a minute. Tags without records of use are considered the least
+// recently used.
+//
+// Tags of the referrers tag schema, i.e. `<alg>-<ref>`, are not subject to the
+// policy. They are removed with their subjects if DeleteReferrers is set.
+type PrunePolicy struct {
+	// TagPattern selects the tags subject to the policy. Tags not matching
+	// TagPattern are always kept.
+	// If nil, all tags are subject to the policy.
+	TagPattern *regexp.Regexp
+
+	// KeepLast, if positive, keeps the KeepLast most recently created tags
+	// selected by TagPattern and removes the others, where the tags without
+	// creation times are considered the oldest.
+	KeepLast int
+
+	// MaxAge, if positive, removes the tags selected by TagPattern which were
+	// created more than MaxAge ago. Tags without creation times are kept.
+	MaxAge time.Duration
+
+	// MaxSize, if positive, caps the total size of the content reachable
+	// from `index.json` in bytes, where the least recently used tags selected
+	// by TagPattern are removed until the total size is within MaxSize or no
+	// tag is left. Blobs not reachable from `index.json` are not counted as
+	// they are left to GC().
+	MaxSize int64
+
+	// DeleteReferrers, if true, deletes the untagged referrers of the deleted
+	// manifests recursively, and removes the referrers tags of the deleted
+	// manifests. Otherwise, the referrers are kept in the store with their
+	// subjects deleted.
+	DeleteReferrers bool
+
+	// DryRun, if true, reports what would be removed without changing the
+	// store.
+	DryRun bool
+}
+
+// PruneReport reports the content removed by [Store.Prune].
+type PruneReport struct {
+	// Tags contains the removed tags.
+	Tags []string
+
+	// Deleted contains the deleted manifests and blobs, which are no longer
+	// referenced after removing the tags.
+	Deleted []ocispec.Descriptor
+
+	// ReclaimedBytes is the total size of the deleted content.
+	ReclaimedBytes int64
+}
+
+// Prune removes the tags selected by the policy, and deletes the manifests
+// and blobs which are no longer referenced by the remaining tags, such as the
+// layers only used by the removed images.
+// Blobs not reachable from `index.json` before pruning are left to GC().
+//
+// Changes to `index.json` are saved if AutoSaveIndex is true.
+func (s *Store) Prune(ctx context.Context, policy PrunePolicy) (_ *PruneReport, err error) {
+	s.sync.Lock()
+	defer s.sync.Unlock()
+
+	// hold the lock on index.json until the changes are saved, so that other
+	// processes sharing the same OCI layout cannot reference the content
+	// being deleted
+	s.indexLock.Lock()
+	defer s.indexLock.Unlock()
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+	// sync with the changes made by other processes
+	if err := s.mergeIndexFile(ctx); err != nil {
+		return nil, fmt.Errorf("unable to reload index: %w", err)
+	}
+
+	p := newPruner(s)
+	tags, err := p.selectTags(ctx, policy.TagPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// apply tag-based policies
+	removed := set.New[string]()
+	now := time.Now()
+	if policy.MaxAge > 0 {
+		for _, tag := range tags {
+			if !tag.created.IsZero() && now.Sub(tag.created) > policy.MaxAge {
+				removed.Add(tag.name)
+			}
+		}
+	}
+	if policy.KeepLast > 0 && len(tags) > policy.KeepLast {
+		byCreated := slices.Clone(tags)
+		slices.SortStableFunc(byCreated, func(a, b pruneTag) int {
+			return b.created.Compare(a.created)
+		})
+		for _, tag := range byCreated[policy.KeepLast:] {
+			removed.Add(tag.name)
+		}
+	}
+	deleted, err := p.plan(ctx, removed, policy.DeleteReferrers)
+	if err != nil {
+		return nil, err
+	}
+
+	// apply the size cap by evicting the least recently used tags
+	if policy.MaxSize > 0 {
+		total, err := p.reachableSize(ctx)
+		if err != nil {
+			return nil, err
+		}
+		byLastUsed := slices.Clone(tags)
+		slices.SortStableFunc(byLastUsed, func(a, b pruneTag) int {
+			return a.lastUsed.Compare(b.lastUsed)
+		})
+		for _, tag := range byLastUsed {
+			if total-descriptorsSize(deleted) <= policy.MaxSize {
+				break
+			}
+			if removed.Contains(tag.name) {
+				continue
+			}
+			removed.Add(tag.name)
+			if deleted, err = p.plan(ctx, removed, policy.DeleteReferrers); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	report := &PruneReport{
+		Deleted:        deleted,
+		ReclaimedBytes: descriptorsSize(deleted),
+	}
+	for tag := range removed {
+		report.Tags = append(report.Tags, tag)
+	}
+	slices.Sort(report.Tags)
+	sortDescriptors(report.Deleted)
+	if policy.DryRun {
+		return report, nil
+	}
+
+	for _, tag := range report.Tags {
+		s.tagResolver.Untag(tag)
+	}
+	for _, desc := range report.Deleted {
+		s.tagResolver.Untag(desc.Digest.String())
+		s.graph.Remove(desc)
+		if err := s.storage.Delete(ctx, desc); err != nil && !errors.Is(err, errdef.ErrNotFound) {
+			return nil, err
+		}
+	}
+	if err := s.removeStaleUses(); err != nil {
+		return nil, err
+	}
+	if s.AutoSaveIndex {
+		if err := s.saveIndexFile(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// useRecordInterval is the minimum interval between the records of the use
+// of a tag, which limits the writes on resolving tags.
+const useRecordInterval = time.Minute
+
+// recordUse records the use of the tag if s.TrackUsage is set, where the
+// modification time of the record file of the tag is the last use time.
+// Errors are ignored as the store may be on a read-only file system.
+func (s *Store) recordUse(tag string) {
+	if !s.TrackUsage {
+		return
+	}
+	path := s.usePath(tag)
+	now := time.Now()
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		if now.Sub(info.ModTime()) >= useRecordInterval {
+			_ = os.Chtimes(path, now, now)
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		if err := ensureDir(s.usageRoot); err == nil {
+			_ = os.WriteFile(path, []byte(tag), 0644)
+		}
+	}
+}
+
+// lastUsed returns the last use time of the tag, or the zero time if
+// unknown.
+func (s *Store) lastUsed(tag string) time.Time {
+	info, err := os.Stat(s.usePath(tag))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// removeUse removes the record of the use of the tag.
+func (s *Store) removeUse(tag string) {
+	_ = os.Remove(s.usePath(tag))
+}
+
+// removeStaleUses removes the records of the use of the tags no longer in
+// the store, such as the ones removed by other processes sharing the same OCI
+// layout.
+func (s *Store) removeStaleUses() error {
+	entries, err := os.ReadDir(s.usageRoot)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	tags := s.tagResolver.Map()
+	for _, entry := range entries {
+		path := filepath.Join(s.usageRoot, entry.Name())
+		tag, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		if _, ok := tags[string(tag)]; ok && s.usePath(string(tag)) == path {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// usePath returns the path of the record file of the use of the tag, where
+// the tag is hashed so that the tags differing only in case do not collide on
+// case-insensitive file systems.
+func (s *Store) usePath(tag string) string {
+	name := sha256.Sum256([]byte(tag))
+	return filepath.Join(s.usageRoot, hex.EncodeToString(name[:]))
+}
+
+// pruneTag is a tag subject to a prune policy.
+type pruneTag struct {
+	name     string
+	created  time.Time
+	lastUsed time.Time
+}
+
+// pruner plans the content to be deleted by Prune.
+type pruner struct {
+	store *Store
+	// refs is the references in the store.
+	refs map[string][]ocispec.Descriptor
+	// successors caches the successors of the nodes, excluding subjects.
+	successors map[digest.Digest][]ocispec.Descriptor
+	// subjects caches the subjects of the manifests.
+	subjects map[digest.Digest]*ocispec.Descriptor
+}
+
+// newPruner creates a new pruner with the references in the store.
+func newPruner(s *Store) *pruner {
+	return &pruner{
+		store:      s,
+		refs:       s.tagResolver.MapAll(),
+		successors: make(map[digest.Digest][]ocispec.Descriptor),
+		subjects:   make(map[digest.Digest]*ocispec.Descriptor),
+	}
+}
+
+// selectTags returns the tags matching the pattern, sorted by name.
+// Referrers tags are not selected.
+func (p *pruner) selectTags(ctx context.Context, pattern *regexp.Regexp) ([]pruneTag, error) {
+	var tags []pruneTag
+	for ref, descs := range p.refs {
+		if ref == descs[0].Digest.String() || (pattern != nil && !pattern.MatchString(ref)) {
+			continue
+		}
+		if _, ok := parseReferrersTag(ref); ok {
+			continue
+		}
+		// a tag of multiple platforms is as new as its latest manifest
+		tag := pruneTag{
+			name: ref,
+		}
+		for _, desc := range descs {
+			created, err := p.createdTime(ctx, desc)
+			if err != nil {
+				return nil, err
+			}
+			if created.After(tag.created) {
+				tag.created = created
+			}
+		}
+		tag.lastUsed = p.store.lastUsed(ref)
+		tags = append(tags, tag)
+	}
+	slices.SortFunc(tags, func(a, b pruneTag) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return tags, nil
+}
+
+// createdTime returns the creation time of the tagged descriptor, or the zero
+// time if unknown.
+func (p *pruner) createdTime(ctx context.Context, desc ocispec.Descriptor) (time.Time, error) {
+	created, ok := desc.Annotations[ocispec.AnnotationCreated]
+	if !ok && descriptor.IsManifest(desc) {
+		manifestJSON, err := content.FetchAll(ctx, p.store.storage, desc)
+		if err != nil {
+			if errors.Is(err, errdef.ErrNotFound) {
+				return time.Time{}, nil
+			}
+			return time.Time{}, err
+		}
+		var manifest struct {
+			Annotations map[string]string `json:"annotations"`
+		}
+		if err := json.Unmarshal(manifestJSON, &manifest); err == nil {
+			created = manifest.Annotations[ocispec.AnnotationCreated]
+		}
+	}
+	t, err := time.Parse(time.RFC3339, created)
+	if err != nil {
+		// ignore invalid creation times
+		return time.Time{}, nil
+	}
+	return t, nil
+}
+
+// plan returns the content to be deleted if the given tags are removed.
+// If deleteReferrers is true, the referrers tags of the deleted manifests are
+// added to removedTags.
+func (p *pruner) plan(ctx context.Context, removedTags set.Set[string], deleteReferrers bool) ([]ocispec.Descriptor, error) {
+	for {
+		deleted, err := p.planTags(ctx, removedTags, deleteReferrers)
+		if err != nil || !deleteReferrers {
+			return deleted, err
+		}
+
+		// remove the referrers tags of the deleted manifests, which in turn
+		// may delete more manifests
+		deletedDigests := set.New[digest.Digest]()
+		for _, desc := range deleted {
+			deletedDigests.Add(desc.Digest)
+		}
+		changed := false
+		for ref := range p.refs {
+			if removedTags.Contains(ref) {
+				continue
+			}
+			if subject, ok := parseReferrersTag(ref); ok && deletedDigests.Contains(subject) {
+				removedTags.Add(ref)
+				changed = true
+			}
+		}
+		if !changed {
+			return deleted, nil
+		}
+	}
+}
+
+// planTags returns the content to be deleted if the given tags are removed.
+func (p *pruner) planTags(ctx context.Context, removedTags set.Set[string], deleteReferrers bool) ([]ocispec.Descriptor, error) {
+	// find the roots to be removed and the roots to be kept
+	keptTagged := set.New[digest.Digest]()
+	removedRoots := make(map[digest.Digest]ocispec.Descriptor)
+	for ref, descs := range p.refs {
+		if ref == descs[0].Digest.String() {
+			continue
+		}
+		for _, desc := range descs {
+			if removedTags.Contains(ref) {
+				removedRoots[desc.Digest] = descriptor.Plain(desc)
+			} else {
+				keptTagged.Add(desc.Digest)
+			}
+		}
+	}
+	for dgst := range keptTagged {
+		delete(removedRoots, dgst)
+	}
+	var untagged []ocispec.Descriptor
+	for ref, descs := range p.refs {
+		desc := descs[0]
+		if ref == desc.Digest.String() && !keptTagged.Contains(desc.Digest) {
+			if _, ok := removedRoots[desc.Digest]; !ok {
+				untagged = append(untagged, descriptor.Plain(desc))
+			}
+		}
+	}
+
+	// remove the untagged referrers of the removed roots recursively
+	if deleteReferrers {
+		for changed := true; changed; {
+			changed = false
+			for _, desc := range untagged {
+				if _, ok := removedRoots[desc.Digest]; ok {
+					continue
+				}
+				subject, err := p.subject(ctx, desc)
+				if err != nil {
+					return nil, err
+				}
+				if subject == nil {
+					continue
+				}
+				if _, ok := removedRoots[subject.Digest]; ok {
+					removedRoots[desc.Digest] = desc
+					changed = true
+				}
+			}
+		}
+	}
+
+	// mark the nodes reachable from the kept roots
+	marked := set.New[digest.Digest]()
+	var kept []ocispec.Descriptor
+	for _, descs := range p.refs {
+		for _, desc := range descs {
+			if _, ok := removedRoots[desc.Digest]; !ok {
+				kept = append(kept, descriptor.Plain(desc))
+			}
+		}
+	}
+	if err := p.walk(ctx, kept, func(desc ocispec.Descriptor) bool {
+		if marked.Contains(desc.Digest) {
+			return false
+		}
+		marked.Add(desc.Digest)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	// sweep the unmarked nodes reachable from the removed roots
+	var deleted []ocispec.Descriptor
+	var roots []ocispec.Descriptor
+	for _, desc := range removedRoots {
+		roots = append(roots, desc)
+	}
+	if err := p.walk(ctx, roots, func(desc ocispec.Descriptor) bool {
+		if marked.Contains(desc.Digest) {
+			return false
+		}
+		marked.Add(desc.Digest)
+		deleted = append(deleted, desc)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// reachableSize returns the total size of the content reachable from the
+// references in the store.
+func (p *pruner) reachableSize(ctx context.Context) (int64, error) {
+	var roots []ocispec.Descriptor
+	for _, descs := range p.refs {
+		for _, desc := range descs {
+			roots = append(roots, descriptor.Plain(desc))
+		}
+	}
+	visited := set.New[digest.Digest]()
+	var total int64
+	if err := p.walk(ctx, roots, func(desc ocispec.Descriptor) bool {
+		if visited.Contains(desc.Digest) {
+			return false
+		}
+		visited.Add(desc.Digest)
+		total += desc.Size
+		return true
+	}); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// walk walks through the nodes reachable from the roots, excluding the
+// subjects, where the successors of a node are visited only if visit returns
+// true.
+func (p *pruner) walk(ctx context.Context, roots []ocispec.Descriptor, visit func(desc ocispec.Descriptor) bool) error {
+	stack := slices.Clone(roots)
+	for len(stack) > 0 {
+		if err := isContextDone(ctx); err != nil {
+			return err
+		}
+		desc := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !visit(desc) {
+			continue
+		}
+		successors, err := p.successorsOf(ctx, desc)
+		if err != nil {
+			return err
+		}
+		stack = append(stack, successors...)
+	}
+	return nil
+}
+
+// successorsOf returns the successors of the node, excluding the subject.
+func (p *pruner) successorsOf(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	if successors, ok := p.successors[desc.Digest]; ok {
+		return successors, nil
+	}
+	successors, err := content.Successors(ctx, p.store.storage, desc)
+	if err != nil {
+		if !errors.Is(err, errdef.ErrNotFound) {
+			return nil, err
+		}
+		// missing nodes have no successors
+		successors = nil
+	}
+	subject, err := p.subject(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	var result []ocispec.Descriptor
+	for _, successor := range successors {
+		if subject == nil || !content.Equal(successor, *subject) {
+			result = append(result, descriptor.Plain(successor))
+		}
+	}
+	p.successors[desc.Digest] = result
+	return result, nil
+}
+
+// subject returns the subject of the manifest, or nil if absent.
+func (p *pruner) subject(ctx context.Context, desc ocispec.Descriptor) (*ocispec.Descriptor, error) {
+	if subject, ok := p.subjects[desc.Digest]; ok {
+		return subject, nil
+	}
+	subject, err := manifestutil.Subject(ctx, p.store.storage, desc)
+	if err != nil {
+		if !errors.Is(err, errdef.ErrNotFound) {
+			return nil, err
+		}
+		subject = nil
+	}
+	p.subjects[desc.Digest] = subject
+	return subject, nil
+}
+
+// descriptorsSize returns the total size of the descriptors.
+func descriptorsSize(descs []ocispec.Descriptor) int64 {
+	var size int64
+	for _, desc := range descs {
+		size += desc.Size
+	}
+	return size
+}
@@ -0,0 +1,460 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// pushPruneTestManifest pushes a manifest with the given creation time,
+// layers and subject, and tags it if reference is not empty.
+func pushPruneTestManifest(t *testing.T, s *Store, reference string, created time.Time, subject *ocispec.Descriptor, layers ...ocispec.Descriptor) ocispec.Descriptor {
+	t.Helper()
+	ctx := context.Background()
+	manifest := ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    ocispec.DescriptorEmptyJSON,
+		Layers:    layers,
+		Subject:   subject,
+	}
+	if !created.IsZero() {
+		manifest.Annotations = map[string]string{
+			ocispec.AnnotationCreated: created.Format(time.RFC3339),
+		}
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestJSON)
+	if err := s.Push(ctx, desc, bytes.NewReader(manifestJSON)); err != nil {
+		t.Fatalf("failed to push test content: %v", err)
+	}
+	if reference != "" {
+		if err := s.Tag(ctx, desc, reference); err != nil {
+			t.Fatalf("failed to tag test content: %v", err)
+		}
+	}
+	return desc
+}
+
+// pushPruneTestBlob pushes a blob to the store, ignoring existing blobs.
+func pushPruneTestBlob(t *testing.T, s *Store, mediaType string, blob []byte) ocispec.Descriptor {
+	t.Helper()
+	desc := content.NewDescriptorFromBytes(mediaType, blob)
+	if err := s.Push(context.Background(), desc, bytes.NewReader(blob)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		t.Fatalf("failed to push test content: %v", err)
+	}
+	return desc
+}
+
+func TestStore_Prune(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	ctx := context.Background()
+
+	pushPruneTestBlob(t, s, ocispec.MediaTypeEmptyJSON, ocispec.DescriptorEmptyJSON.Data)
+	shared := pushPruneTestBlob(t, s, ocispec.MediaTypeImageLayer, []byte("shared"))
+	now := time.Now()
+	var layers []ocispec.Descriptor
+	var manifests []ocispec.Descriptor
+	for i, tag := range []string{"v1", "v2", "v3", "v4"} {
+		layer := pushPruneTestBlob(t, s, ocispec.MediaTypeImageLayer, []byte("layer "+tag))
+		created := now.Add(time.Duration(i-4) * 24 * time.Hour)
+		layers = append(layers, layer)
+		manifests = append(manifests, pushPruneTestManifest(t, s, tag, created, nil, shared, layer))
+	}
+	// v1 is also tagged as stable, which is not subject to the policy
+	if err := s.Tag(ctx, manifests[0], "stable"); err != nil {
+		t.Fatal("Tag() error =", err)
+	}
+	// dev has no creation time
+	devLayer := pushPruneTestBlob(t, s, ocispec.MediaTypeImageLayer, []byte("layer dev"))
+	dev := pushPruneTestManifest(t, s, "dev", time.Time{}, nil, devLayer)
+	// a signature of v2 and a signature of the signature
+	signature := pushPruneTestManifest(t, s, "", time.Time{}, &manifests[1])
+	if err := s.Tag(ctx, signature, signature.Digest.String()); err != nil {
+		t.Fatal("Tag() error =", err)
+	}
+	countersign := pushPruneTestManifest(t, s, "", time.Time{}, &signature)
+	if err := s.Tag(ctx, countersign, countersign.Digest.String()); err != nil {
+		t.Fatal("Tag() error =", err)
+	}
+
+	// dry run keeps the last 2 tags matching the pattern
+	policy := PrunePolicy{
+		TagPattern:      regexp.MustCompile(`^v\d+$`),
+		KeepLast:        2,
+		DeleteReferrers: true,
+		DryRun:          true,
+	}
+	got, err := s.Prune(ctx, policy)
+	if err != nil {
+		t.Fatal("Store.Prune() error =", err)
+	}
+	if want := []string{"v1", "v2"}; !reflect.DeepEqual(got.Tags, want) {
+		t.Errorf("PruneReport.Tags = %v, want %v", got.Tags, want)
+	}
+	// v1 is kept by the stable tag and the shared layer is kept by v3 and v4
+	wantDeleted := []ocispec.Descriptor{manifests[1], layers[1], signature, countersign}
+	sortDescriptors(wantDeleted)
+	if !reflect.DeepEqual(got.Deleted, wantDeleted) {
+		t.Errorf("PruneReport.Deleted = %v, want %v", got.Deleted, wantDeleted)
+	}
+	if want := descriptorsSize(wantDeleted); got.ReclaimedBytes != want {
+		t.Errorf("PruneReport.ReclaimedBytes = %d, want %d", got.ReclaimedBytes, want)
+	}
+	for _, desc := range wantDeleted {
+		if exists, err := s.Exists(ctx, desc); err != nil || !exists {
+			t.Errorf("Store.Exists(%s) = %v, %v, want true after dry run", desc.Digest, exists, err)
+		}
+	}
+
+	// prune for real
+	policy.DryRun = false
+	got, err = s.Prune(ctx, policy)
+	if err != nil {
+		t.Fatal("Store.Prune() error =", err)
+	}
+	if !reflect.DeepEqual(got.Deleted, wantDeleted) {
+		t.Errorf("PruneReport.Deleted = %v, want %v", got.Deleted, wantDeleted)
+	}
+	for _, desc := range wantDeleted {
+		if exists, err := s.Exists(ctx, desc); err != nil || exists {
+			t.Errorf("Store.Exists(%s) = %v, %v, want false", desc.Digest, exists, err)
+		}
+	}
+	for _, ref := range []string{"v1", "v2", signature.Digest.String()} {
+		if _, err := s.Resolve(ctx, ref); !errors.Is(err, errdef.ErrNotFound) {
+			t.Errorf("Store.Resolve(%s) error = %v, wantErr %v", ref, err, errdef.ErrNotFound)
+		}
+	}
+	for _, ref := range []string{"stable", "v3", "v4", "dev"} {
+		if _, err := s.Resolve(ctx, ref); err != nil {
+			t.Errorf("Store.Resolve(%s) error = %v", ref, err)
+		}
+	}
+
+	// the changes are saved to index.json
+	reloaded, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	if _, err := reloaded.Resolve(ctx, "v2"); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Store.Resolve(v2) error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+
+	// expire the tags older than 2 days, where dev has no creation time
+	got, err = s.Prune(ctx, PrunePolicy{
+		MaxAge: 2*24*time.Hour - time.Hour,
+	})
+	if err != nil {
+		t.Fatal("Store.Prune() error =", err)
+	}
+	if want := []string{"stable", "v3"}; !reflect.DeepEqual(got.Tags, want) {
+		t.Errorf("PruneReport.Tags = %v, want %v", got.Tags, want)
+	}
+	wantDeleted = []ocispec.Descriptor{manifests[0], layers[0], manifests[2], layers[2]}
+	sortDescriptors(wantDeleted)
+	if !reflect.DeepEqual(got.Deleted, wantDeleted) {
+		t.Errorf("PruneReport.Deleted = %v, want %v", got.Deleted, wantDeleted)
+	}
+	for _, desc := range []ocispec.Descriptor{manifests[3], shared, dev, devLayer} {
+		if exists, err := s.Exists(ctx, desc); err != nil || !exists {
+			t.Errorf("Store.Exists(%s) = %v, %v, want true", desc.Digest, exists, err)
+		}
+	}
+}
+
+func TestStore_Prune_KeepReferrers(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	ctx := context.Background()
+
+	pushPruneTestBlob(t, s, ocispec.MediaTypeEmptyJSON, ocispec.DescriptorEmptyJSON.Data)
+	layer := pushPruneTestBlob(t, s, ocispec.MediaTypeImageLayer, []byte("foo"))
+	subject := pushPruneTestManifest(t, s, "foo", time.Time{}, nil, layer)
+	referrer := pushPruneTestManifest(t, s, "", time.Time{}, &subject)
+	if err := s.Tag(ctx, referrer, referrer.Digest.String()); err != nil {
+		t.Fatal("Tag() error =", err)
+	}
+
+	got, err := s.Prune(ctx, PrunePolicy{MaxSize: 1})
+	if err != nil {
+		t.Fatal("Store.Prune() error =", err)
+	}
+	// the referrer and the shared empty config are kept
+	wantDeleted := []ocispec.Descriptor{subject, layer}
+	sortDescriptors(wantDeleted)
+	if !reflect.DeepEqual(got.Deleted, wantDeleted) {
+		t.Errorf("PruneReport.Deleted = %v, want %v", got.Deleted, wantDeleted)
+	}
+	if exists, err := s.Exists(ctx, referrer); err != nil || !exists {
+		t.Errorf("Store.Exists(referrer) = %v, %v, want true", exists, err)
+	}
+}
+
+func TestStore_Prune_ReferrersTag(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	ctx := context.Background()
+
+	pushPruneTestBlob(t, s, ocispec.MediaTypeEmptyJSON, ocispec.DescriptorEmptyJSON.Data)
+	now := time.Now().UTC().Truncate(time.Second)
+	subject := pushPruneTestManifest(t, s, "foo", now.Add(-time.Hour), nil)
+	pushPruneTestManifest(t, s, "bar", now, nil, pushPruneTestBlob(t, s, ocispec.MediaTypeImageLayer, []byte("bar")))
+	referrer := pushPruneTestManifest(t, s, "", time.Time{}, &subject)
+	indexJSON, err := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{referrer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	referrersIndex := content.NewDescriptorFromBytes(ocispec.MediaTypeImageIndex, indexJSON)
+	if err := s.Push(ctx, referrersIndex, bytes.NewReader(indexJSON)); err != nil {
+		t.Fatal("Store.Push() error =", err)
+	}
+	referrersTag, err := buildReferrersTag(subject)
+	if err != nil {
+		t.Fatal("buildReferrersTag() error =", err)
+	}
+	if err := s.Tag(ctx, referrersIndex, referrersTag); err != nil {
+		t.Fatal("Store.Tag() error =", err)
+	}
+
+	// the referrers tag is not subject to the policy
+	got, err := s.Prune(ctx, PrunePolicy{KeepLast: 1, TagPattern: regexp.MustCompile("^bar$"), DryRun: true})
+	if err != nil {
+		t.Fatal("Store.Prune() error =", err)
+	}
+	if len(got.Tags) != 0 {
+		t.Errorf("PruneReport.Tags = %v, want none", got.Tags)
+	}
+	got, err = s.Prune(ctx, PrunePolicy{KeepLast: 2, DryRun: true})
+	if err != nil {
+		t.Fatal("Store.Prune() error =", err)
+	}
+	if len(got.Tags) != 0 {
+		t.Errorf("PruneReport.Tags = %v, want none", got.Tags)
+	}
+
+	// the referrers tag is kept without DeleteReferrers
+	got, err = s.Prune(ctx, PrunePolicy{KeepLast: 1, DryRun: true})
+	if err != nil {
+		t.Fatal("Store.Prune() error =", err)
+	}
+	if want := []string{"foo"}; !reflect.DeepEqual(got.Tags, want) {
+		t.Errorf("PruneReport.Tags = %v, want %v", got.Tags, want)
+	}
+	if want := []ocispec.Descriptor{subject}; !reflect.DeepEqual(got.Deleted, want) {
+		t.Errorf("PruneReport.Deleted = %v, want %v", got.Deleted, want)
+	}
+
+	// the referrers tag is removed with its subject with DeleteReferrers
+	got, err = s.Prune(ctx, PrunePolicy{KeepLast: 1, DeleteReferrers: true})
+	if err != nil {
+		t.Fatal("Store.Prune() error =", err)
+	}
+	wantTags := []string{referrersTag, "foo"}
+	slices.Sort(wantTags)
+	if !reflect.DeepEqual(got.Tags, wantTags) {
+		t.Errorf("PruneReport.Tags = %v, want %v", got.Tags, wantTags)
+	}
+	wantDeleted := []ocispec.Descriptor{subject, referrer, referrersIndex}
+	sortDescriptors(wantDeleted)
+	if !reflect.DeepEqual(got.Deleted, wantDeleted) {
+		t.Errorf("PruneReport.Deleted = %v, want %v", got.Deleted, wantDeleted)
+	}
+	if _, err := s.Resolve(ctx, referrersTag); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Store.Resolve(referrersTag) error = %v, want %v", err, errdef.ErrNotFound)
+	}
+}
+
+func TestStore_Prune_MaxSize(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	s.TrackUsage = true
+	ctx := context.Background()
+
+	pushPruneTestBlob(t, s, ocispec.MediaTypeEmptyJSON, ocispec.DescriptorEmptyJSON.Data)
+	var manifests []ocispec.Descriptor
+	for _, tag := range []string{"foo", "bar", "baz"} {
+		layer := pushPruneTestBlob(t, s, ocispec.MediaTypeImageLayer, bytes.Repeat([]byte(tag), 100))
+		manifests = append(manifests, pushPruneTestManifest(t, s, tag, time.Time{}, nil, layer))
+	}
+	// foo is the most recently used, followed by baz and bar
+	past := time.Now().Add(-time.Hour)
+	for i, tag := range []string{"foo", "bar", "baz"} {
+		if err := os.Chtimes(s.usePath(tag), past, past.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path, err := s.blobFilePath(manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Resolve(ctx, "foo"); err != nil {
+		t.Fatal("Store.Resolve() error =", err)
+	}
+	// the use of the tag is not recorded in the blobs
+	newInfo, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !newInfo.ModTime().Equal(info.ModTime()) {
+		t.Errorf("blob modification time = %v, want %v", newInfo.ModTime(), info.ModTime())
+	}
+
+	total, err := newPruner(s).reachableSize(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// evicting a single image is sufficient
+	got, err := s.Prune(ctx, PrunePolicy{MaxSize: total - 1, DryRun: true})
+	if err != nil {
+		t.Fatal("Store.Prune() error =", err)
+	}
+	if want := []string{"bar"}; !reflect.DeepEqual(got.Tags, want) {
+		t.Errorf("PruneReport.Tags = %v, want %v", got.Tags, want)
+	}
+	// evicting images until the size cap is met
+	got, err = s.Prune(ctx, PrunePolicy{MaxSize: total - got.ReclaimedBytes - 1})
+	if err != nil {
+		t.Fatal("Store.Prune() error =", err)
+	}
+	if want := []string{"bar", "baz"}; !reflect.DeepEqual(got.Tags, want) {
+		t.Errorf("PruneReport.Tags = %v, want %v", got.Tags, want)
+	}
+	size, err := newPruner(s).reachableSize(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := total - got.ReclaimedBytes; size != want {
+		t.Errorf("reachable size = %d, want %d", size, want)
+	}
+	if _, err := s.Resolve(ctx, "foo"); err != nil {
+		t.Errorf("Store.Resolve(foo) error = %v", err)
+	}
+}
+
+func TestStore_TrackUsage(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	ctx := context.Background()
+
+	pushPruneTestBlob(t, s, ocispec.MediaTypeEmptyJSON, ocispec.DescriptorEmptyJSON.Data)
+	pushPruneTestManifest(t, s, "foo", time.Time{}, nil)
+	if _, err := s.Resolve(ctx, "foo"); err != nil {
+		t.Fatal("Store.Resolve() error =", err)
+	}
+	// the use of the tags is not recorded by default
+	if _, err := os.Stat(s.usageRoot); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("os.Stat(usageRoot) error = %v, want %v", err, fs.ErrNotExist)
+	}
+
+	s.TrackUsage = true
+	if _, err := s.Resolve(ctx, "foo"); err != nil {
+		t.Fatal("Store.Resolve() error =", err)
+	}
+	info, err := os.Stat(s.usePath("foo"))
+	if err != nil {
+		t.Fatal("os.Stat() error =", err)
+	}
+	if perm := info.Mode().Perm(); perm&0022 != 0 {
+		t.Errorf("usage record permission = %v, want not writable by others", perm)
+	}
+
+	// the records of the tags no longer in the store are removed by GC
+	stalePath := s.usePath("bar")
+	if err := os.WriteFile(stalePath, []byte("bar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.GC(ctx); err != nil {
+		t.Fatal("Store.GC() error =", err)
+	}
+	if _, err := os.Stat(stalePath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("os.Stat(stale record) error = %v, want %v", err, fs.ErrNotExist)
+	}
+	if _, err := os.Stat(s.usePath("foo")); err != nil {
+		t.Errorf("os.Stat(record) error = %v", err)
+	}
+}
+
+func TestStore_Prune_MaxSize_Unreachable(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	ctx := context.Background()
+
+	// the unreachable blobs are left to GC()
+	pushPruneTestBlob(t, s, ocispec.MediaTypeImageLayer, bytes.Repeat([]byte("x"), 1<<20))
+	pushPruneTestBlob(t, s, ocispec.MediaTypeEmptyJSON, ocispec.DescriptorEmptyJSON.Data)
+	for _, tag := range []string{"a", "b"} {
+		layer := pushPruneTestBlob(t, s, ocispec.MediaTypeImageLayer, bytes.Repeat([]byte(tag), 100))
+		pushPruneTestManifest(t, s, tag, time.Time{}, nil, layer)
+	}
+
+	got, err := s.Prune(ctx, PrunePolicy{MaxSize: 100 << 10})
+	if err != nil {
+		t.Fatal("Store.Prune() error =", err)
+	}
+	if len(got.Tags) != 0 || len(got.Deleted) != 0 {
+		t.Errorf("Store.Prune() = %v, want nothing removed", got)
+	}
+	for _, tag := range []string{"a", "b"} {
+		if _, err := s.Resolve(ctx, tag); err != nil {
+			t.Errorf("Store.Resolve(%s) error = %v", tag, err)
+		}
+	}
+}
@@ -22,16 +22,22 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"reflect"
 	"slices"
+	"strings"
+	"sync"
 
 	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/container/set"
 	"oras.land/oras-go/v2/internal/descriptor"
+	"oras.land/oras-go/v2/internal/docker"
 	"oras.land/oras-go/v2/internal/fs/tarfs"
 	"oras.land/oras-go/v2/internal/graph"
 	"oras.land/oras-go/v2/internal/resolver"
+	"oras.land/oras-go/v2/platforms"
 )
 
 // ReadOnlyStore implements `oras.ReadonlyTarget`, and represents a read-only
@@ -41,15 +47,18 @@ type ReadOnlyStore struct {
 	fsys        fs.FS
 	storage     content.ReadOnlyStorage
 	tagResolver *resolver.Memory
+	nestedTags  *nestedTags
 	graph       *graph.Memory
 }
 
 // NewFromFS creates a new read-only OCI store from fsys.
 func NewFromFS(ctx context.Context, fsys fs.FS) (*ReadOnlyStore, error) {
+	storage := NewStorageFromFS(fsys)
 	store := &ReadOnlyStore{
 		fsys:        fsys,
-		storage:     NewStorageFromFS(fsys),
+		storage:     storage,
 		tagResolver: resolver.NewMemory(),
+		nestedTags:  newNestedTags(storage),
 		graph:       graph.NewMemory(),
 	}
 
@@ -88,13 +97,17 @@ func (s *ReadOnlyStore) Exists(ctx context.Context, target ocispec.Descriptor) (
 //     a full descriptor declared by github.com/opencontainers/image-spec/specs-go/v1.
 //   - If the reference is a digest, the returned descriptor will be a
 //     plain descriptor (containing only the digest, media type and size).
+//
+// If the tag is associated with multiple descriptors, such as the manifests
+// of different platforms, the one best matching the platform of the host is
+// returned, or the first one if none of them matches. See also ResolveAll().
 func (s *ReadOnlyStore) Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error) {
 	if reference == "" {
 		return ocispec.Descriptor{}, errdef.ErrMissingReference
 	}
 
 	// attempt resolving manifest
-	desc, err := s.tagResolver.Resolve(ctx, reference)
+	descs, err := resolveAll(ctx, s.tagResolver, s.nestedTags, reference)
 	if err != nil {
 		if errors.Is(err, errdef.ErrNotFound) {
 			// attempt resolving blob
@@ -103,6 +116,7 @@ func (s *ReadOnlyStore) Resolve(ctx context.Context, reference string) (ocispec.
 		return ocispec.Descriptor{}, err
 	}
 
+	desc := selectDescriptor(descs)
 	if reference == desc.Digest.String() {
 		return descriptor.Plain(desc), nil
 	}
@@ -110,6 +124,34 @@ func (s *ReadOnlyStore) Resolve(ctx context.Context, reference string) (ocispec.
 	return desc, nil
 }
 
+// ResolveAll resolves a reference to all the descriptors associated with it.
+//   - If the reference is a tag, the returned descriptors are the entries of
+//     `index.json` annotated with the tag, such as the manifests of different
+//     platforms. If none of the entries is annotated with the tag, the
+//     manifests annotated with the tag in the nested indexes are returned.
+//   - If the reference is a digest, a single plain descriptor is returned.
+func (s *ReadOnlyStore) ResolveAll(ctx context.Context, reference string) ([]ocispec.Descriptor, error) {
+	if reference == "" {
+		return nil, errdef.ErrMissingReference
+	}
+
+	descs, err := resolveAll(ctx, s.tagResolver, s.nestedTags, reference)
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			desc, err := resolveBlob(s.fsys, reference)
+			if err != nil {
+				return nil, err
+			}
+			return []ocispec.Descriptor{desc}, nil
+		}
+		return nil, err
+	}
+	if reference == descs[0].Digest.String() {
+		return []ocispec.Descriptor{descriptor.Plain(descs[0])}, nil
+	}
+	return descs, nil
+}
+
 // Predecessors returns the nodes directly pointing to the current node.
 // Predecessors returns nil without error if the node does not exists in the
 // store.
@@ -117,15 +159,15 @@ func (s *ReadOnlyStore) Predecessors(ctx context.Context, node ocispec.Descripto
 	return s.graph.Predecessors(ctx, node)
 }
 
-// Tags lists the tags presented in the `index.json` file of the OCI layout,
-// returned in ascending order.
+// Tags lists the tags presented in the `index.json` file of the OCI layout
+// and in the indexes nested in it, returned in ascending order.
 // If `last` is NOT empty, the entries in the response start after the tag
 // specified by `last`. Otherwise, the response starts from the top of the tags
 // list.
 //
 // See also `Tags()` in the package `registry`.
 func (s *ReadOnlyStore) Tags(ctx context.Context, last string, fn func(tags []string) error) error {
-	return listTags(s.tagResolver, last, fn)
+	return listTags(ctx, s.tagResolver, s.nestedTags, last, fn)
 }
 
 // validateOCILayoutFile validates the `oci-layout` file.
@@ -164,28 +206,221 @@ func (s *ReadOnlyStore) loadIndexFile(ctx context.Context) error {
 	if err := json.NewDecoder(indexFile).Decode(&index); err != nil {
 		return fmt.Errorf("failed to decode index file: %w", err)
 	}
-	return loadIndex(ctx, &index, s.storage, s.tagResolver, s.graph)
+	return loadIndex(ctx, &index, s.storage, s.tagResolver, s.nestedTags, s.graph)
 }
 
 // loadIndex loads index into memory.
-func loadIndex(ctx context.Context, index *ocispec.Index, fetcher content.Fetcher, tagger content.Tagger, graph *graph.Memory) error {
+// Entries of index sharing the same reference, such as the manifests of
+// different platforms, are all tagged with the reference.
+// The tags in the nested indexes are loaded into nested.
+func loadIndex(ctx context.Context, index *ocispec.Index, fetcher content.Fetcher, tagResolver *resolver.Memory, nested *nestedTags, graph *graph.Memory) error {
+	for ref, descs := range indexRefs(index) {
+		if err := tagResolver.TagAll(ctx, descs, ref); err != nil {
+			return err
+		}
+	}
 	for _, desc := range index.Manifests {
-		if err := tagger.Tag(ctx, deleteAnnotationRefName(desc), desc.Digest.String()); err != nil {
+		plain := descriptor.Plain(desc)
+		if err := graph.IndexAll(ctx, fetcher, plain); err != nil {
 			return err
 		}
+	}
+	return nested.walk(ctx, tagResolver, func(string, ocispec.Descriptor) {})
+}
+
+// indexRefs returns the references in the index, as loaded by loadIndex.
+// The descriptors of each reference are in the order of the index.
+// A digest references all the entries of the digest without a ref name, or the
+// first entry of the digest if all of them are tagged.
+func indexRefs(index *ocispec.Index) map[string][]ocispec.Descriptor {
+	refs := make(map[string][]ocispec.Descriptor, len(index.Manifests))
+	untagged := make(map[string][]ocispec.Descriptor)
+	for _, desc := range index.Manifests {
 		if ref := desc.Annotations[ocispec.AnnotationRefName]; ref != "" {
-			if err := tagger.Tag(ctx, desc, ref); err != nil {
-				return err
-			}
+			refs[ref] = append(refs[ref], desc)
+			continue
 		}
-		plain := descriptor.Plain(desc)
-		if err := graph.IndexAll(ctx, fetcher, plain); err != nil {
+		dgst := desc.Digest.String()
+		if !slices.ContainsFunc(untagged[dgst], func(d ocispec.Descriptor) bool {
+			return sameIndexEntry(d, desc)
+		}) {
+			untagged[dgst] = append(untagged[dgst], desc)
+		}
+	}
+	for _, desc := range index.Manifests {
+		dgst := desc.Digest.String()
+		if _, ok := refs[dgst]; ok {
+			continue
+		}
+		if descs, ok := untagged[dgst]; ok {
+			refs[dgst] = descs
+		} else {
+			refs[dgst] = []ocispec.Descriptor{deleteAnnotationRefName(desc)}
+		}
+	}
+	return refs
+}
+
+// sameIndexEntry returns true if a and b are the same entry of index.json,
+// regardless of their ref names.
+func sameIndexEntry(a, b ocispec.Descriptor) bool {
+	return reflect.DeepEqual(deleteAnnotationRefName(a), deleteAnnotationRefName(b))
+}
+
+// resolveAll resolves a reference to all the descriptors tagged with it,
+// looking up the tags in the nested indexes if the reference is not tagged
+// in index.json.
+func resolveAll(ctx context.Context, tagResolver *resolver.Memory, nested *nestedTags, reference string) ([]ocispec.Descriptor, error) {
+	descs, err := tagResolver.ResolveAll(ctx, reference)
+	if err == nil || !errors.Is(err, errdef.ErrNotFound) {
+		return descs, err
+	}
+	if _, err := digest.Parse(reference); err == nil {
+		// digests are not tagged in nested indexes
+		return nil, fmt.Errorf("%s: %w", reference, errdef.ErrNotFound)
+	}
+	var found []ocispec.Descriptor
+	if err := nested.walk(ctx, tagResolver, func(ref string, desc ocispec.Descriptor) {
+		if ref == reference {
+			found = append(found, desc)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("%s: %w", reference, errdef.ErrNotFound)
+	}
+	return found, nil
+}
+
+// nestedTags caches the references annotated on the manifests of the indexes
+// by "org.opencontainers.image.ref.name", so that the indexes are fetched
+// and decoded once. As content is immutable, the cached entries of an index
+// never change.
+type nestedTags struct {
+	fetcher content.Fetcher
+	lock    sync.Mutex
+	indexes map[digest.Digest]nestedIndex
+}
+
+// nestedIndex is the cached entry of an index.
+type nestedIndex struct {
+	// manifests contains the manifests of the index.
+	manifests []ocispec.Descriptor
+}
+
+// newNestedTags creates a new nestedTags fetching the indexes from fetcher.
+func newNestedTags(fetcher content.Fetcher) *nestedTags {
+	return &nestedTags{
+		fetcher: fetcher,
+		indexes: make(map[digest.Digest]nestedIndex),
+	}
+}
+
+// walk walks through the indexes tagged in the resolver and their nested
+// indexes, and calls fn with the references annotated on the manifests of the
+// indexes by "org.opencontainers.image.ref.name".
+// Indexes missing in the storage or failed to be decoded are skipped.
+func (n *nestedTags) walk(ctx context.Context, tagResolver *resolver.Memory, fn func(ref string, desc ocispec.Descriptor)) error {
+	var stack []ocispec.Descriptor
+	visited := set.New[digest.Digest]()
+	for ref, desc := range tagResolver.Map() {
+		if ref == desc.Digest.String() {
+			stack = append(stack, desc)
+		}
+	}
+	// walk in a stable order
+	slices.SortFunc(stack, func(a, b ocispec.Descriptor) int {
+		return strings.Compare(string(b.Digest), string(a.Digest))
+	})
+	for len(stack) > 0 {
+		if err := isContextDone(ctx); err != nil {
+			return err
+		}
+		desc := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !isIndex(desc) || visited.Contains(desc.Digest) {
+			continue
+		}
+		visited.Add(desc.Digest)
+
+		index, err := n.load(ctx, desc)
+		if err != nil {
 			return err
 		}
+		for _, manifest := range index.manifests {
+			if ref := manifest.Annotations[ocispec.AnnotationRefName]; ref != "" {
+				fn(ref, manifest)
+			}
+		}
+		for i := len(index.manifests) - 1; i >= 0; i-- {
+			stack = append(stack, index.manifests[i])
+		}
 	}
 	return nil
 }
 
+// load returns the cached entry of the index described by desc, fetching
+// and decoding the index on cache miss.
+// An empty entry is returned for the indexes missing in the storage or
+// failed to be decoded, where only the latter is cached.
+func (n *nestedTags) load(ctx context.Context, desc ocispec.Descriptor) (nestedIndex, error) {
+	n.lock.Lock()
+	cached, ok := n.indexes[desc.Digest]
+	n.lock.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	indexJSON, err := content.FetchAll(ctx, n.fetcher, desc)
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return nestedIndex{}, nil
+		}
+		return nestedIndex{}, err
+	}
+	var index ocispec.Index
+	var entry nestedIndex
+	if err := json.Unmarshal(indexJSON, &index); err == nil {
+		entry.manifests = index.Manifests
+	}
+	n.lock.Lock()
+	n.indexes[desc.Digest] = entry
+	n.lock.Unlock()
+	return entry, nil
+}
+
+// isIndex returns true if the descriptor describes an index or a manifest
+// list.
+func isIndex(desc ocispec.Descriptor) bool {
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageIndex, docker.MediaTypeManifestList:
+		return true
+	default:
+		return false
+	}
+}
+
+// selectDescriptor returns the descriptor in the non-empty descs best
+// matching the platform of the host, or the first descriptor if none of them
+// matches.
+func selectDescriptor(descs []ocispec.Descriptor) ocispec.Descriptor {
+	matcher := platforms.Default()
+	best := -1
+	for i, desc := range descs {
+		if desc.Platform == nil || !matcher.Match(*desc.Platform) {
+			continue
+		}
+		if best < 0 || matcher.Less(*desc.Platform, *descs[best].Platform) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return descs[0]
+	}
+	return descs[best]
+}
+
 // resolveBlob returns a descriptor describing the blob identified by dgst.
 func resolveBlob(fsys fs.FS, dgst string) (ocispec.Descriptor, error) {
 	path, err := blobPath(digest.Digest(dgst))
@@ -210,20 +445,30 @@ func resolveBlob(fsys fs.FS, dgst string) (ocispec.Descriptor, error) {
 	}, nil
 }
 
-// listTags returns the tags in ascending order.
+// listTags returns the tags in ascending order, including the tags in the
+// nested indexes.
 // If `last` is NOT empty, the entries in the response start after the tag
 // specified by `last`. Otherwise, the response starts from the top of the tags
 // list.
 //
 // See also `Tags()` in the package `registry`.
-func listTags(tagResolver *resolver.Memory, last string, fn func(tags []string) error) error {
-	var tags []string
-
+func listTags(ctx context.Context, tagResolver *resolver.Memory, nested *nestedTags, last string, fn func(tags []string) error) error {
+	tagSet := set.New[string]()
 	tagMap := tagResolver.Map()
 	for tag, desc := range tagMap {
 		if tag == desc.Digest.String() {
 			continue
 		}
+		tagSet.Add(tag)
+	}
+	if err := nested.walk(ctx, tagResolver, func(ref string, _ ocispec.Descriptor) {
+		tagSet.Add(ref)
+	}); err != nil {
+		return err
+	}
+
+	var tags []string
+	for tag := range tagSet {
 		if last != "" && tag <= last {
 			continue
 		}
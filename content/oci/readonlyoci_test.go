@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"reflect"
 	"strconv"
 	"strings"
@@ -36,8 +37,10 @@ import (
 	"oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/errdef"
 	"oras.land/oras-go/v2/internal/docker"
 	"oras.land/oras-go/v2/internal/spec"
+	"oras.land/oras-go/v2/platforms"
 	"oras.land/oras-go/v2/registry"
 )
 
@@ -49,6 +52,9 @@ func TestReadonlyStoreInterface(t *testing.T) {
 	if _, ok := store.(registry.TagLister); !ok {
 		t.Error("&ReadOnlyStore{} does not conform registry.TagLister")
 	}
+	if _, ok := store.(registry.ReferrerLister); !ok {
+		t.Error("&ReadOnlyStore{} does not conform registry.ReferrerLister")
+	}
 }
 
 func TestReadOnlyStore(t *testing.T) {
@@ -862,3 +868,124 @@ func Test_deleteAnnotationRefName(t *testing.T) {
 		})
 	}
 }
+
+func TestReadOnlyStore_MultiplePlatforms(t *testing.T) {
+	fsys := fstest.MapFS{}
+	pushBlob := func(mediaType string, blob []byte) ocispec.Descriptor {
+		desc := content.NewDescriptorFromBytes(mediaType, blob)
+		fsys[path.Join(ocispec.ImageBlobsDir, desc.Digest.Algorithm().String(), desc.Digest.Encoded())] = &fstest.MapFile{Data: blob}
+		return desc
+	}
+	pushJSON := func(mediaType string, v any) ocispec.Descriptor {
+		blob, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pushBlob(mediaType, blob)
+	}
+	withRefName := func(desc ocispec.Descriptor, platform ocispec.Platform, ref string) ocispec.Descriptor {
+		desc.Platform = &platform
+		desc.Annotations = map[string]string{ocispec.AnnotationRefName: ref}
+		return desc
+	}
+
+	config := pushBlob(ocispec.MediaTypeImageConfig, []byte("{}"))
+	host := platforms.DefaultSpec()
+	other := ocispec.Platform{OS: "plan9", Architecture: "unknown"}
+	var manifests []ocispec.Descriptor
+	for _, layer := range []string{"foo", "bar", "baz", "qux"} {
+		manifests = append(manifests, pushJSON(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config:    config,
+			Layers:    []ocispec.Descriptor{pushBlob(ocispec.MediaTypeImageLayer, []byte(layer))},
+		}))
+	}
+	// the manifests of the nested tag are listed in a nested index
+	nested := pushJSON(ocispec.MediaTypeImageIndex, ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{
+			withRefName(manifests[2], other, "nested"),
+			withRefName(manifests[3], host, "nested"),
+		},
+	})
+	index := ocispec.Index{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2, // historical value
+		},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{
+			withRefName(manifests[0], other, "latest"),
+			withRefName(manifests[1], host, "latest"),
+			nested,
+		},
+	}
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys[ocispec.ImageIndexFile] = &fstest.MapFile{Data: indexJSON}
+	fsys[ocispec.ImageLayoutFile] = &fstest.MapFile{Data: []byte(`{"imageLayoutVersion":"1.0.0"}`)}
+
+	ctx := context.Background()
+	s, err := NewFromFS(ctx, fsys)
+	if err != nil {
+		t.Fatal("NewFromFS() error =", err)
+	}
+	tests := []struct {
+		reference string
+		want      []ocispec.Descriptor
+	}{
+		{reference: "latest", want: index.Manifests[:2]},
+		{reference: "nested", want: []ocispec.Descriptor{withRefName(manifests[2], other, "nested"), withRefName(manifests[3], host, "nested")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reference, func(t *testing.T) {
+			got, err := s.ResolveAll(ctx, tt.reference)
+			if err != nil {
+				t.Fatal("ReadOnlyStore.ResolveAll() error =", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadOnlyStore.ResolveAll() = %v, want %v", got, tt.want)
+			}
+			// the manifest of the host platform is preferred
+			desc, err := s.Resolve(ctx, tt.reference)
+			if err != nil {
+				t.Fatal("ReadOnlyStore.Resolve() error =", err)
+			}
+			if !reflect.DeepEqual(desc, tt.want[1]) {
+				t.Errorf("ReadOnlyStore.Resolve() = %v, want %v", desc, tt.want[1])
+			}
+		})
+	}
+
+	// resolve by digest
+	got, err := s.ResolveAll(ctx, manifests[1].Digest.String())
+	if err != nil {
+		t.Fatal("ReadOnlyStore.ResolveAll() error =", err)
+	}
+	if want := []ocispec.Descriptor{manifests[1]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadOnlyStore.ResolveAll() = %v, want %v", got, want)
+	}
+	if _, err := s.ResolveAll(ctx, "missing"); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("ReadOnlyStore.ResolveAll() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+
+	// nested tags are listed
+	if err := s.Tags(ctx, "", func(tags []string) error {
+		if want := []string{"latest", "nested"}; !reflect.DeepEqual(tags, want) {
+			t.Errorf("ReadOnlyStore.Tags() = %v, want %v", tags, want)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal("ReadOnlyStore.Tags() error =", err)
+	}
+
+	// the nested manifests are in the graph
+	predecessors, err := s.Predecessors(ctx, manifests[3])
+	if err != nil {
+		t.Fatal("ReadOnlyStore.Predecessors() error =", err)
+	}
+	if want := []ocispec.Descriptor{nested}; !reflect.DeepEqual(predecessors, want) {
+		t.Errorf("ReadOnlyStore.Predecessors() = %v, want %v", predecessors, want)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/container/set"
+	"oras.land/oras-go/v2/internal/graph"
+	"oras.land/oras-go/v2/internal/resolver"
+	"oras.land/oras-go/v2/registry"
+)
+
+// Referrers lists the descriptors of image or artifact manifests directly
+// referencing the given manifest descriptor.
+//
+// fn is called for the referrers result. If artifactType is not empty, only
+// referrers of the same artifact type are fed to fn. The artifact types and
+// the annotations of the referrers are populated from their manifests.
+//
+// Besides the manifests in the store referencing desc as the subject, the
+// referrers listed by the referrers tag schema are also included, which are
+// the manifests in the index tagged as `<alg>-<ref>` in `index.json`, such
+// as the layouts exported from registries without the Referrers API.
+// Manifests listed but not present in the store are skipped.
+//
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#listing-referrers
+func (s *Store) Referrers(ctx context.Context, desc ocispec.Descriptor, artifactType string, fn func(referrers []ocispec.Descriptor) error) error {
+	s.sync.RLock()
+	defer s.sync.RUnlock()
+
+	return listReferrers(ctx, s.storage, s.graph, s.tagResolver, desc, artifactType, fn)
+}
+
+// Referrers lists the descriptors of image or artifact manifests directly
+// referencing the given manifest descriptor.
+//
+// fn is called for the referrers result. If artifactType is not empty, only
+// referrers of the same artifact type are fed to fn. The artifact types and
+// the annotations of the referrers are populated from their manifests.
+//
+// Besides the manifests in the store referencing desc as the subject, the
+// referrers listed by the referrers tag schema are also included, which are
+// the manifests in the index tagged as `<alg>-<ref>` in `index.json`, such
+// as the layouts exported from registries without the Referrers API.
+// Manifests listed but not present in the store are skipped.
+//
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#listing-referrers
+func (s *ReadOnlyStore) Referrers(ctx context.Context, desc ocispec.Descriptor, artifactType string, fn func(referrers []ocispec.Descriptor) error) error {
+	return listReferrers(ctx, s.storage, s.graph, s.tagResolver, desc, artifactType, fn)
+}
+
+// graphStorage presents a storage with its graph, without providing the
+// Referrers API.
+type graphStorage struct {
+	content.ReadOnlyStorage
+	graph *graph.Memory
+}
+
+// Predecessors returns the nodes directly pointing to the current node.
+func (s graphStorage) Predecessors(ctx context.Context, node ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	return s.graph.Predecessors(ctx, node)
+}
+
+// listReferrers lists the referrers of desc found by the predecessors of desc
+// and by the referrers tag schema.
+func listReferrers(ctx context.Context, storage content.ReadOnlyStorage, graph *graph.Memory, tagResolver *resolver.Memory, desc ocispec.Descriptor, artifactType string, fn func(referrers []ocispec.Descriptor) error) error {
+	referrers, err := registry.Referrers(ctx, graphStorage{storage, graph}, desc, "")
+	if err != nil {
+		return err
+	}
+	// predecessors are listed in no particular order
+	slices.SortFunc(referrers, func(a, b ocispec.Descriptor) int {
+		return strings.Compare(string(a.Digest), string(b.Digest))
+	})
+	tagged, err := referrersFromTagSchema(ctx, storage, tagResolver, desc)
+	if err != nil {
+		return err
+	}
+
+	// the referrers from the manifests take precedence over the ones recorded
+	// in the referrers index
+	var results []ocispec.Descriptor
+	seen := set.New[digest.Digest]()
+	for _, referrer := range slices.Concat(referrers, tagged) {
+		if seen.Contains(referrer.Digest) {
+			continue
+		}
+		seen.Add(referrer.Digest)
+		if artifactType == "" || referrer.ArtifactType == artifactType {
+			results = append(results, referrer)
+		}
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	return fn(results)
+}
+
+// referrersFromTagSchema returns the referrers of desc listed in the
+// referrers indexes tagged by the referrers tag schema, skipping the ones not
+// present in the storage.
+//
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#referrers-tag-schema
+func referrersFromTagSchema(ctx context.Context, storage content.ReadOnlyStorage, tagResolver *resolver.Memory, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	indexes, err := referrersIndexes(ctx, tagResolver, desc)
+	if err != nil {
+		return nil, err
+	}
+	var referrers []ocispec.Descriptor
+	for _, indexDesc := range indexes {
+		indexJSON, err := content.FetchAll(ctx, storage, indexDesc)
+		if err != nil {
+			if errors.Is(err, errdef.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		var index ocispec.Index
+		if err := json.Unmarshal(indexJSON, &index); err != nil {
+			return nil, fmt.Errorf("failed to decode referrers index %s: %w", indexDesc.Digest, err)
+		}
+		for _, referrer := range index.Manifests {
+			exists, err := storage.Exists(ctx, referrer)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				referrers = append(referrers, referrer)
+			}
+		}
+	}
+	return referrers, nil
+}
+
+// referrersIndexes returns the descriptors of the referrers indexes of desc
+// tagged by the referrers tag schema.
+func referrersIndexes(ctx context.Context, tagResolver *resolver.Memory, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	referrersTag, err := buildReferrersTag(desc)
+	if err != nil {
+		return nil, err
+	}
+	descs, err := tagResolver.ResolveAll(ctx, referrersTag)
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return slices.DeleteFunc(descs, func(desc ocispec.Descriptor) bool {
+		return desc.MediaType != ocispec.MediaTypeImageIndex
+	}), nil
+}
+
+// buildReferrersTag builds the referrers tag for the given manifest
+// descriptor.
+// Format: <algorithm>-<digest>
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#referrers-tag-schema
+func buildReferrersTag(desc ocispec.Descriptor) (string, error) {
+	if err := desc.Digest.Validate(); err != nil {
+		return "", fmt.Errorf("failed to build referrers tag for %s: %w", desc.Digest, err)
+	}
+	alg := desc.Digest.Algorithm().String()
+	encoded := desc.Digest.Encoded()
+	return alg + "-" + encoded, nil
+}
+
+// parseReferrersTag returns the digest of the subject of the referrers tag
+// built by buildReferrersTag, or false if tag is not a referrers tag.
+func parseReferrersTag(tag string) (digest.Digest, bool) {
+	alg, encoded, ok := strings.Cut(tag, "-")
+	if !ok {
+		return "", false
+	}
+	dgst := digest.NewDigestFromEncoded(digest.Algorithm(alg), encoded)
+	if err := dgst.Validate(); err != nil {
+		return "", false
+	}
+	return dgst, true
+}
@@ -0,0 +1,161 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+)
+
+func TestStore_Referrers(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	ctx := context.Background()
+
+	pushJSON := func(mediaType string, v any) ocispec.Descriptor {
+		blob, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc := content.NewDescriptorFromBytes(mediaType, blob)
+		if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+			t.Fatalf("failed to push test content: %v", err)
+		}
+		return desc
+	}
+	pushReferrer := func(subject ocispec.Descriptor, artifactType string) ocispec.Descriptor {
+		annotations := map[string]string{"name": artifactType}
+		desc := pushJSON(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+			MediaType:    ocispec.MediaTypeImageManifest,
+			ArtifactType: artifactType,
+			Config:       ocispec.DescriptorEmptyJSON,
+			Layers:       []ocispec.Descriptor{ocispec.DescriptorEmptyJSON},
+			Subject:      &subject,
+			Annotations:  annotations,
+		})
+		desc.ArtifactType = artifactType
+		desc.Annotations = annotations
+		return desc
+	}
+	listReferrers := func(desc ocispec.Descriptor, artifactType string) []ocispec.Descriptor {
+		var referrers []ocispec.Descriptor
+		if err := s.Referrers(ctx, desc, artifactType, func(got []ocispec.Descriptor) error {
+			referrers = append(referrers, got...)
+			return nil
+		}); err != nil {
+			t.Fatal("Store.Referrers() error =", err)
+		}
+		return referrers
+	}
+
+	pushJSON(ocispec.MediaTypeEmptyJSON, map[string]string{})
+	subject := pushTestManifest(t, s, "foo", "foo")
+	sbom := pushReferrer(subject, "application/vnd.test.sbom")
+	signature := pushReferrer(subject, "application/vnd.test.signature")
+	want := []ocispec.Descriptor{sbom, signature}
+	slices.SortFunc(want, func(a, b ocispec.Descriptor) int {
+		return strings.Compare(string(a.Digest), string(b.Digest))
+	})
+	if got := listReferrers(subject, ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("Store.Referrers() = %v, want %v", got, want)
+	}
+	if got, want := listReferrers(subject, signature.ArtifactType), []ocispec.Descriptor{signature}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Store.Referrers() = %v, want %v", got, want)
+	}
+	if got := listReferrers(subject, "application/vnd.test.unknown"); len(got) != 0 {
+		t.Errorf("Store.Referrers() = %v, want none", got)
+	}
+	if err := s.Referrers(ctx, ocispec.DescriptorEmptyJSON, "", func([]ocispec.Descriptor) error { return nil }); !errors.Is(err, errdef.ErrUnsupported) {
+		t.Errorf("Store.Referrers() error = %v, wantErr %v", err, errdef.ErrUnsupported)
+	}
+
+	// referrers of an absent subject are listed by the referrers tag schema
+	absent := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, []byte(`{"layers":[]}`))
+	exported := pushReferrer(absent, "application/vnd.test.signature")
+	missing := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, []byte("missing"))
+	referrersIndex := pushJSON(ocispec.MediaTypeImageIndex, ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{exported, missing},
+	})
+	referrersTag, err := buildReferrersTag(absent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Tag(ctx, referrersIndex, referrersTag); err != nil {
+		t.Fatal("Store.Tag() error =", err)
+	}
+	// the referrers survive GC
+	if err := s.GC(ctx); err != nil {
+		t.Fatal("Store.GC() error =", err)
+	}
+	if got, want := listReferrers(absent, ""), []ocispec.Descriptor{exported}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Store.Referrers() = %v, want %v", got, want)
+	}
+
+	// the referrers are also listed by the read-only store
+	ros, err := NewFromFS(ctx, os.DirFS(tempDir))
+	if err != nil {
+		t.Fatal("NewFromFS() error =", err)
+	}
+	var got []ocispec.Descriptor
+	if err := ros.Referrers(ctx, absent, "", func(referrers []ocispec.Descriptor) error {
+		got = append(got, referrers...)
+		return nil
+	}); err != nil {
+		t.Fatal("ReadOnlyStore.Referrers() error =", err)
+	}
+	if want := []ocispec.Descriptor{exported}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadOnlyStore.Referrers() = %v, want %v", got, want)
+	}
+
+	// deleting the subject deletes its referrers and its referrers index
+	if err := s.Push(ctx, absent, bytes.NewReader([]byte(`{"layers":[]}`))); err != nil {
+		t.Fatal("Store.Push() error =", err)
+	}
+	if err := s.Delete(ctx, absent); err != nil {
+		t.Fatal("Store.Delete() error =", err)
+	}
+	for _, desc := range []ocispec.Descriptor{exported, referrersIndex} {
+		if exists, err := s.Exists(ctx, desc); err != nil || exists {
+			t.Errorf("Store.Exists(%s) = %v, %v, want false", desc.Digest, exists, err)
+		}
+	}
+	if _, err := s.Resolve(ctx, referrersTag); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Store.Resolve() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+	if err := s.Delete(ctx, subject); err != nil {
+		t.Fatal("Store.Delete() error =", err)
+	}
+	for _, desc := range want {
+		if exists, err := s.Exists(ctx, desc); err != nil || exists {
+			t.Errorf("Store.Exists(%s) = %v, %v, want false", desc.Digest, exists, err)
+		}
+	}
+}
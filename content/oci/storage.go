@@ -50,6 +50,8 @@ type Storage struct {
 	root string
 	// ingestRoot is the root directory of the temporary ingest files.
 	ingestRoot string
+	// pool is the shared blob pool storing the blobs, if set.
+	pool *BlobPool
 }
 
 // NewStorage creates a new CAS based on file system with the OCI-Image layout.
@@ -84,9 +86,12 @@ func (s *Storage) Push(_ context.Context, expected ocispec.Descriptor, content i
 	if err := ensureDir(filepath.Dir(target)); err != nil {
 		return err
 	}
+	if s.pool != nil {
+		return s.pushToPool(expected, content, target)
+	}
 
 	// write the content to a temporary ingest file.
-	ingest, err := s.ingest(expected, content)
+	ingest, err := ingestFile(s.ingestRoot, expected, content)
 	if err != nil {
 		return err
 	}
@@ -108,6 +113,8 @@ func (s *Storage) Push(_ context.Context, expected ocispec.Descriptor, content i
 }
 
 // Delete removes the target from the system.
+// If the storage uses a shared blob pool, only the link to the blob in the
+// pool is removed, and the blob is removed from the pool by BlobPool.GC().
 func (s *Storage) Delete(ctx context.Context, target ocispec.Descriptor) error {
 	path, err := blobPath(target.Digest)
 	if err != nil {
@@ -124,9 +131,10 @@ func (s *Storage) Delete(ctx context.Context, target ocispec.Descriptor) error {
 	return nil
 }
 
-// ingest write the content into a temporary ingest file.
-func (s *Storage) ingest(expected ocispec.Descriptor, content io.Reader) (path string, ingestErr error) {
-	if err := ensureDir(s.ingestRoot); err != nil {
+// ingestFile writes the content into a temporary ingest file under
+// ingestRoot.
+func ingestFile(ingestRoot string, expected ocispec.Descriptor, content io.Reader) (path string, ingestErr error) {
+	if err := ensureDir(ingestRoot); err != nil {
 		return "", fmt.Errorf("failed to ensure ingest dir: %w", err)
 	}
 
@@ -134,7 +142,7 @@ func (s *Storage) ingest(expected ocispec.Descriptor, content io.Reader) (path s
 	// in the ingest directory.
 	// Go ensures that multiple programs or goroutines calling CreateTemp
 	// simultaneously will not choose the same file.
-	fp, err := os.CreateTemp(s.ingestRoot, expected.Digest.Encoded()+"_*")
+	fp, err := os.CreateTemp(ingestRoot, expected.Digest.Encoded()+"_*")
 	if err != nil {
 		return "", fmt.Errorf("failed to create ingest file: %w", err)
 	}
@@ -0,0 +1,411 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/internal/container/set"
+	"oras.land/oras-go/v2/internal/descriptor"
+)
+
+// VerifyOptions contains parameters for [Store.Verify].
+type VerifyOptions struct {
+	// Repair, if true, removes the corrupted blobs, the orphaned blobs and the
+	// stale ingest files from the store, or moves them to QuarantineDir if
+	// set. The removed corrupted blobs are reported as missing by subsequent
+	// verifications until they are pushed again, while index.json is left
+	// unchanged. If the store uses a shared blob pool, the corrupted blobs are
+	// removed from the pool as well.
+	Repair bool
+
+	// QuarantineDir is the directory where the corrupted blobs, the orphaned
+	// blobs and the stale ingest files are moved on repair, in the layout of
+	// "<algorithm>/<encoded>" for blobs and "ingest/<name>" for ingest files.
+	// The directory should be on the same file system as the store.
+	// If empty, the files are removed on repair.
+	QuarantineDir string
+
+	// IngestGracePeriod is the duration for which the temporary ingest files
+	// are considered in use by ongoing pushes, such as the ones of other
+	// processes, since their last modification.
+	// If zero, all ingest files are considered stale.
+	IngestGracePeriod time.Duration
+}
+
+// VerifyReport contains the problems found by [Store.Verify].
+type VerifyReport struct {
+	// Corrupted contains the blobs whose content does not match their digests
+	// or the sizes of the descriptors referencing them, and the manifests
+	// which cannot be parsed.
+	Corrupted []ocispec.Descriptor
+
+	// Missing contains the nodes referenced by index.json or by the manifests
+	// reachable from index.json, which do not exist in the store.
+	Missing []ocispec.Descriptor
+
+	// Orphaned contains the blobs which are neither reachable from index.json
+	// nor pushed to the store since it was loaded, excluding the ones
+	// protected by Store.GCGracePeriod.
+	Orphaned []ocispec.Descriptor
+
+	// StaleIngests contains the paths of the stale temporary ingest files,
+	// relative to the root of the store.
+	StaleIngests []string
+}
+
+// Verify checks the integrity of the store, where every blob is re-hashed,
+// and every node reachable from index.json is checked to exist with the
+// correct size. Orphaned blobs and stale temporary ingest files are reported
+// as well.
+//
+// The problems found are returned in the report, and are repaired if
+// opts.Repair is set. An error is returned only if the verification cannot
+// be completed, such as failures in accessing the file system.
+func (s *Store) Verify(ctx context.Context, opts VerifyOptions) (report *VerifyReport, err error) {
+	s.sync.Lock()
+	defer s.sync.Unlock()
+
+	// hold the lock on index.json so that other processes sharing the same
+	// OCI layout cannot tag the blobs being repaired, and verify the changes
+	// made by them
+	s.indexLock.Lock()
+	defer s.indexLock.Unlock()
+	unlock, err := s.lockIndex()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			report, err = nil, unlockErr
+		}
+	}()
+	if err := s.mergeIndexFile(ctx); err != nil {
+		return nil, fmt.Errorf("unable to reload index: %w", err)
+	}
+
+	v := &verifier{
+		store:    s,
+		report:   &VerifyReport{},
+		verified: make(map[digest.Digest]bool),
+		visited:  make(map[descriptor.Descriptor]bool),
+		reached:  make(map[digest.Digest]struct{}),
+	}
+
+	// verify the nodes reachable from index.json
+	for _, descs := range s.tagResolver.MapAll() {
+		for _, desc := range descs {
+			if err := v.verifyGraph(ctx, descriptor.Plain(desc)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// find the orphaned blobs as GC() does, which are neither reachable nor
+	// protected by GCGracePeriod
+	reachable := s.graph.DigestSet()
+	for dgst := range v.reached {
+		reachable.Add(dgst)
+	}
+	orphaned := set.New[digest.Digest]()
+	if err := s.walkGarbage(ctx, reachable, func(dgst digest.Digest, _ string) error {
+		orphaned.Add(dgst)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// verify the blobs not reachable from index.json
+	var orphanedPaths, corruptedPaths []string
+	var corruptedDigests []digest.Digest
+	err = walkBlobs(ctx, s.root, func(dgst digest.Digest, blobPath string, size int64) error {
+		desc := ocispec.Descriptor{
+			MediaType: descriptor.DefaultMediaType,
+			Digest:    dgst,
+			Size:      size,
+		}
+		valid, checked := v.verified[dgst]
+		if !checked {
+			var err error
+			if valid, err = verifyBlobFile(blobPath, dgst, -1); err != nil {
+				return err
+			}
+			if !valid {
+				v.report.Corrupted = append(v.report.Corrupted, desc)
+			}
+		}
+		if !valid {
+			corruptedPaths = append(corruptedPaths, blobPath)
+			corruptedDigests = append(corruptedDigests, dgst)
+		}
+		if orphaned.Contains(dgst) {
+			v.report.Orphaned = append(v.report.Orphaned, desc)
+			if valid {
+				orphanedPaths = append(orphanedPaths, blobPath)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// find the stale ingest files
+	ingestRoot := s.storage.ingestRoot
+	entries, err := os.ReadDir(ingestRoot)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	var ingestPaths []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// the ingest file has been committed or removed
+				continue
+			}
+			return nil, err
+		}
+		if opts.IngestGracePeriod > 0 && time.Since(info.ModTime()) < opts.IngestGracePeriod {
+			continue
+		}
+		ingestPaths = append(ingestPaths, filepath.Join(ingestRoot, entry.Name()))
+		v.report.StaleIngests = append(v.report.StaleIngests, filepath.Join(filepath.Base(ingestRoot), entry.Name()))
+	}
+
+	sortDescriptors(v.report.Corrupted)
+	sortDescriptors(v.report.Missing)
+	sortDescriptors(v.report.Orphaned)
+	slices.Sort(v.report.StaleIngests)
+
+	if opts.Repair {
+		for _, path := range append(corruptedPaths, orphanedPaths...) {
+			// quarantine blobs as <algorithm>/<encoded>
+			rel, err := filepath.Rel(filepath.Join(s.root, ocispec.ImageBlobsDir), path)
+			if err != nil {
+				return nil, err
+			}
+			if err := quarantine(path, opts.QuarantineDir, rel); err != nil {
+				return nil, err
+			}
+		}
+		if s.storage.pool != nil {
+			// remove the corrupted blobs from the pool as well, so that they
+			// are not linked again when pushed
+			for _, dgst := range corruptedDigests {
+				if err := s.storage.pool.removeCorrupted(dgst); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for i, path := range ingestPaths {
+			if err := quarantine(path, opts.QuarantineDir, v.report.StaleIngests[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v.report, nil
+}
+
+// verifier verifies the graphs in a store.
+type verifier struct {
+	store  *Store
+	report *VerifyReport
+	// verified maps the digests of the verified blobs to their validity.
+	verified map[digest.Digest]bool
+	// visited contains the visited nodes.
+	visited map[descriptor.Descriptor]bool
+	// reached contains the digests of the nodes reachable from index.json.
+	reached map[digest.Digest]struct{}
+}
+
+// verifyGraph verifies the graph rooted by root.
+func (v *verifier) verifyGraph(ctx context.Context, root ocispec.Descriptor) error {
+	stack := []ocispec.Descriptor{root}
+	for len(stack) > 0 {
+		if err := isContextDone(ctx); err != nil {
+			return err
+		}
+		desc := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		key := descriptor.FromOCI(desc)
+		if v.visited[key] {
+			continue
+		}
+		v.visited[key] = true
+		v.reached[desc.Digest] = struct{}{}
+
+		blobPath, err := v.store.blobFilePath(desc.Digest)
+		if err != nil {
+			// invalid digests are never stored
+			v.report.Missing = append(v.report.Missing, desc)
+			continue
+		}
+		valid, err := verifyBlobFile(blobPath, desc.Digest, desc.Size)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				v.report.Missing = append(v.report.Missing, desc)
+				continue
+			}
+			return err
+		}
+		if !valid {
+			if _, checked := v.verified[desc.Digest]; !checked {
+				// check whether the content itself is corrupted or the
+				// size in the descriptor is incorrect
+				intact, err := verifyBlobFile(blobPath, desc.Digest, -1)
+				if err != nil {
+					return err
+				}
+				v.verified[desc.Digest] = intact
+			}
+			v.report.Corrupted = append(v.report.Corrupted, desc)
+			continue
+		}
+		v.verified[desc.Digest] = true
+
+		successors, err := content.Successors(ctx, v.store.storage, desc)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			// the manifest cannot be parsed
+			v.report.Corrupted = append(v.report.Corrupted, desc)
+			continue
+		}
+		stack = append(stack, successors...)
+	}
+	return nil
+}
+
+// walkBlobs walks through the blobs in the blobs directory under root.
+func walkBlobs(ctx context.Context, root string, fn func(dgst digest.Digest, blobPath string, size int64) error) error {
+	rootPath := filepath.Join(root, ocispec.ImageBlobsDir)
+	algDirs, err := os.ReadDir(rootPath)
+	if err != nil {
+		return err
+	}
+	for _, algDir := range algDirs {
+		if !algDir.IsDir() {
+			continue
+		}
+		alg := algDir.Name()
+		// skip unsupported directories
+		if !isKnownAlgorithm(alg) {
+			continue
+		}
+		algPath := filepath.Join(rootPath, alg)
+		digestEntries, err := os.ReadDir(algPath)
+		if err != nil {
+			return err
+		}
+		for _, digestEntry := range digestEntries {
+			if err := isContextDone(ctx); err != nil {
+				return err
+			}
+			dgst := digest.NewDigestFromEncoded(digest.Algorithm(alg), digestEntry.Name())
+			if err := dgst.Validate(); err != nil || !digestEntry.Type().IsRegular() {
+				// skip irrelevant content
+				continue
+			}
+			info, err := digestEntry.Info()
+			if err != nil {
+				return err
+			}
+			if err := fn(dgst, filepath.Join(algPath, digestEntry.Name()), info.Size()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// blobFilePath returns the path of the blob file identified by dgst.
+func (s *Store) blobFilePath(dgst digest.Digest) (string, error) {
+	path, err := blobPath(dgst)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, path), nil
+}
+
+// verifyBlobFile returns true if the content of the blob file matches the
+// digest, and the size if not negative.
+func verifyBlobFile(path string, dgst digest.Digest, size int64) (bool, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer fp.Close()
+
+	if size >= 0 {
+		info, err := fp.Stat()
+		if err != nil {
+			return false, err
+		}
+		if info.Size() != size {
+			return false, nil
+		}
+	}
+	verifier := dgst.Verifier()
+	buf := bufPool.Get().(*[]byte)
+	defer bufPool.Put(buf)
+	if _, err := io.CopyBuffer(verifier, fp, *buf); err != nil {
+		return false, fmt.Errorf("failed to read blob %s: %w", dgst, err)
+	}
+	return verifier.Verified(), nil
+}
+
+// quarantine moves the file at path to dir/name, or removes it if dir is
+// empty.
+func quarantine(path, dir, name string) error {
+	if dir == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	target := filepath.Join(dir, name)
+	if err := ensureDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+	if err := os.Rename(path, target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// sortDescriptors sorts the descriptors by digest.
+func sortDescriptors(descs []ocispec.Descriptor) {
+	slices.SortFunc(descs, func(a, b ocispec.Descriptor) int {
+		return strings.Compare(string(a.Digest), string(b.Digest))
+	})
+}
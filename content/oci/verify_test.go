@@ -0,0 +1,270 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/internal/descriptor"
+)
+
+func TestStore_Verify(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	ctx := context.Background()
+
+	push := func(mediaType string, blob []byte) ocispec.Descriptor {
+		desc := content.NewDescriptorFromBytes(mediaType, blob)
+		if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+			t.Fatalf("failed to push test content: %v", err)
+		}
+		return desc
+	}
+	pushManifest := func(reference string, config ocispec.Descriptor, layers ...ocispec.Descriptor) ocispec.Descriptor {
+		manifestJSON, err := json.Marshal(ocispec.Manifest{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config:    config,
+			Layers:    layers,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc := push(ocispec.MediaTypeImageManifest, manifestJSON)
+		if err := s.Tag(ctx, desc, reference); err != nil {
+			t.Fatalf("failed to tag test content: %v", err)
+		}
+		return desc
+	}
+	blobFile := func(dgst digest.Digest) string {
+		return filepath.Join(tempDir, "blobs", dgst.Algorithm().String(), dgst.Encoded())
+	}
+	overwrite := func(path string, data []byte) {
+		if err := os.Chmod(path, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := push(ocispec.MediaTypeImageConfig, []byte("{}"))
+	layer := push(ocispec.MediaTypeImageLayer, []byte("layer"))
+	pushManifest("v1", config, layer)
+	otherLayer := push(ocispec.MediaTypeImageLayer, []byte("other layer"))
+	manifest := pushManifest("v2", config, otherLayer)
+
+	// a clean store
+	report, err := s.Verify(ctx, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Store.Verify() error = %v", err)
+	}
+	if !reflect.DeepEqual(report, &VerifyReport{}) {
+		t.Fatalf("Store.Verify() = %+v, want empty report", report)
+	}
+
+	// damage the store
+	overwrite(blobFile(layer.Digest), []byte("LAYER"))
+	overwrite(blobFile(manifest.Digest), []byte("{"))
+	if err := os.Remove(blobFile(otherLayer.Digest)); err != nil {
+		t.Fatal(err)
+	}
+	orphan := []byte("orphan")
+	orphanDigest := digest.FromBytes(orphan)
+	if err := os.WriteFile(blobFile(orphanDigest), orphan, 0444); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tempDir, "ingest"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "ingest", "stale"), []byte("stale"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// ingest files in the grace period are not stale
+	report, err = s.Verify(ctx, VerifyOptions{IngestGracePeriod: time.Hour})
+	if err != nil {
+		t.Fatalf("Store.Verify() error = %v", err)
+	}
+	if len(report.StaleIngests) != 0 {
+		t.Errorf("VerifyReport.StaleIngests = %v, want none", report.StaleIngests)
+	}
+
+	quarantineDir := t.TempDir()
+	report, err = s.Verify(ctx, VerifyOptions{
+		Repair:        true,
+		QuarantineDir: quarantineDir,
+	})
+	if err != nil {
+		t.Fatalf("Store.Verify() error = %v", err)
+	}
+	wantCorrupted := []ocispec.Descriptor{layer, manifest}
+	sortDescriptors(wantCorrupted)
+	if got := report.Corrupted; len(got) != 2 || !content.Equal(got[0], wantCorrupted[0]) || !content.Equal(got[1], wantCorrupted[1]) {
+		t.Errorf("VerifyReport.Corrupted = %v, want %v", got, wantCorrupted)
+	}
+	if got := report.Missing; len(got) != 0 {
+		// the layer of the corrupted manifest is not reachable
+		t.Errorf("VerifyReport.Missing = %v, want none", got)
+	}
+	if got := report.Orphaned; len(got) != 1 || got[0].Digest != orphanDigest || got[0].Size != int64(len(orphan)) {
+		t.Errorf("VerifyReport.Orphaned = %v, want %v", got, orphanDigest)
+	}
+	if got, want := report.StaleIngests, []string{filepath.Join("ingest", "stale")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("VerifyReport.StaleIngests = %v, want %v", got, want)
+	}
+
+	// the damaged files are quarantined
+	for _, dgst := range []digest.Digest{layer.Digest, manifest.Digest, orphanDigest} {
+		if _, err := os.Stat(filepath.Join(quarantineDir, dgst.Algorithm().String(), dgst.Encoded())); err != nil {
+			t.Errorf("quarantined blob %s: %v", dgst, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(quarantineDir, "ingest", "stale")); err != nil {
+		t.Errorf("quarantined ingest file: %v", err)
+	}
+
+	// the corrupted blobs are missing after repair
+	report, err = s.Verify(ctx, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Store.Verify() error = %v", err)
+	}
+	wantMissing := []ocispec.Descriptor{layer, manifest}
+	sortDescriptors(wantMissing)
+	if got := report.Missing; len(got) != 2 || !content.Equal(got[0], wantMissing[0]) || !content.Equal(got[1], wantMissing[1]) {
+		t.Errorf("VerifyReport.Missing = %v, want %v", got, wantMissing)
+	}
+	if len(report.Corrupted) != 0 || len(report.Orphaned) != 0 || len(report.StaleIngests) != 0 {
+		t.Errorf("Store.Verify() = %+v, want missing blobs only", report)
+	}
+
+	// the missing blobs can be pushed again
+	push(ocispec.MediaTypeImageLayer, []byte("layer"))
+	if exists, err := s.Exists(ctx, layer); err != nil || !exists {
+		t.Errorf("Store.Exists() = %v, %v, want true", exists, err)
+	}
+}
+
+func TestStore_Verify_SizeMismatch(t *testing.T) {
+	tempDir := t.TempDir()
+	s, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	ctx := context.Background()
+	blob := []byte("foo")
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, blob)
+	if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+		t.Fatal(err)
+	}
+	wrongSize := desc
+	wrongSize.Size = 42
+	manifestJSON, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    ocispec.DescriptorEmptyJSON,
+		Layers:    []ocispec.Descriptor{wrongSize},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestJSON)
+	if err := s.Push(ctx, ocispec.DescriptorEmptyJSON, bytes.NewReader(ocispec.DescriptorEmptyJSON.Data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Push(ctx, manifest, bytes.NewReader(manifestJSON)); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.Verify(ctx, VerifyOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Store.Verify() error = %v", err)
+	}
+	if got := report.Corrupted; len(got) != 1 || !content.Equal(got[0], wrongSize) {
+		t.Errorf("VerifyReport.Corrupted = %v, want %v", got, wrongSize)
+	}
+	// the intact blob is kept
+	if exists, err := s.Exists(ctx, desc); err != nil || !exists {
+		t.Errorf("Store.Exists() = %v, %v, want true", exists, err)
+	}
+}
+
+func TestStore_Verify_SharedLayout(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+	s1, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	s2, err := New(tempDir)
+	if err != nil {
+		t.Fatal("New() error =", err)
+	}
+	s2.GCGracePeriod = time.Hour
+
+	// s1 tags a manifest and pushes a blob whose manifest is not yet pushed
+	// after s2 is loaded
+	foo := pushTestManifest(t, s1, "foo", "foo")
+	pending := []byte("pending")
+	pendingDesc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, pending)
+	if err := s1.Push(ctx, pendingDesc, bytes.NewReader(pending)); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s2.Verify(ctx, VerifyOptions{Repair: true})
+	if err != nil {
+		t.Fatal("Store.Verify() error =", err)
+	}
+	if len(report.Orphaned) != 0 {
+		t.Errorf("VerifyReport.Orphaned = %v, want none", report.Orphaned)
+	}
+	for _, desc := range []ocispec.Descriptor{foo, pendingDesc} {
+		if exists, err := s1.Exists(ctx, desc); err != nil || !exists {
+			t.Errorf("Store.Exists(%v) = %v, %v, want true", desc, exists, err)
+		}
+	}
+
+	// blobs outside the grace period are repaired
+	s2.GCGracePeriod = 0
+	report, err = s2.Verify(ctx, VerifyOptions{Repair: true})
+	if err != nil {
+		t.Fatal("Store.Verify() error =", err)
+	}
+	if want := []ocispec.Descriptor{{
+		MediaType: descriptor.DefaultMediaType,
+		Digest:    pendingDesc.Digest,
+		Size:      pendingDesc.Size,
+	}}; !reflect.DeepEqual(report.Orphaned, want) {
+		t.Errorf("VerifyReport.Orphaned = %v, want %v", report.Orphaned, want)
+	}
+	if exists, err := s1.Exists(ctx, pendingDesc); err != nil || exists {
+		t.Errorf("Store.Exists(%v) = %v, %v, want false", pendingDesc, exists, err)
+	}
+	if exists, err := s1.Exists(ctx, foo); err != nil || !exists {
+		t.Errorf("Store.Exists(%v) = %v, %v, want true", foo, exists, err)
+	}
+}
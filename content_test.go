@@ -36,6 +36,7 @@ import (
 	"oras.land/oras-go/v2/content/memory"
 	"oras.land/oras-go/v2/errdef"
 	"oras.land/oras-go/v2/internal/cas"
+	"oras.land/oras-go/v2/platforms"
 	"oras.land/oras-go/v2/registry/remote"
 )
 
@@ -647,6 +648,50 @@ func TestResolve_Memory(t *testing.T) {
 	}
 }
 
+func TestResolve_PlatformMatcher(t *testing.T) {
+	ctx := context.Background()
+	target := memory.New()
+	root := pushConvertTestImage(t, ctx, target, ocispec.MediaTypeImageIndex, ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageConfig, ocispec.MediaTypeImageLayer)
+	ref := "foobar"
+	if err := target.Tag(ctx, root, ref); err != nil {
+		t.Fatal("fail to tag root node", err)
+	}
+
+	// amd64/v2 falls back to amd64
+	opts := oras.ResolveOptions{
+		PlatformMatcher: platforms.Only(platforms.MustParse("linux/amd64/v2")),
+	}
+	gotDesc, err := oras.Resolve(ctx, target, ref, opts)
+	if err != nil {
+		t.Fatal("oras.Resolve() error =", err)
+	}
+	if gotDesc.Platform == nil || gotDesc.Platform.Architecture != "amd64" {
+		t.Errorf("oras.Resolve() = %v, want amd64 manifest", gotDesc)
+	}
+
+	// PlatformMatcher takes precedence over TargetPlatform
+	opts.TargetPlatform = &ocispec.Platform{OS: "linux", Architecture: "arm64"}
+	gotDesc, rc, err := oras.Fetch(ctx, target, ref, oras.FetchOptions{ResolveOptions: opts})
+	if err != nil {
+		t.Fatal("oras.Fetch() error =", err)
+	}
+	defer rc.Close()
+	if gotDesc.Platform == nil || gotDesc.Platform.Architecture != "amd64" {
+		t.Errorf("oras.Fetch() = %v, want amd64 manifest", gotDesc)
+	}
+	if _, err := io.ReadAll(rc); err != nil {
+		t.Fatal("io.ReadAll() error =", err)
+	}
+
+	// no match
+	opts = oras.ResolveOptions{
+		PlatformMatcher: platforms.Only(platforms.MustParse("linux/arm/v7")),
+	}
+	if _, err := oras.Resolve(ctx, target, ref, opts); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("oras.Resolve() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+}
+
 func TestResolve_Repository(t *testing.T) {
 	arc_1 := "test-arc-1"
 	arc_2 := "test-arc-2"
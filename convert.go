@@ -0,0 +1,308 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oras
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/cas"
+	"oras.land/oras-go/v2/internal/docker"
+)
+
+// ManifestFormat represents the format of manifests for
+// CopyOptions.ConvertFormat.
+type ManifestFormat int
+
+const (
+	// ManifestFormatOCI represents the OCI image format, where Docker image
+	// manifests, manifest lists, configs and layers are converted into OCI
+	// image manifests, indexes, configs and layers respectively.
+	ManifestFormatOCI ManifestFormat = 1
+
+	// ManifestFormatDocker represents the Docker image manifest schema 2
+	// format, where OCI image manifests, indexes, configs and layers are
+	// converted into Docker image manifests, manifest lists, configs and
+	// layers respectively.
+	// OCI manifests with artifactType or subject, with non-image configs, or
+	// with layers having no Docker equivalents such as zstd layers, cannot be
+	// converted and result in errdef.ErrUnsupported.
+	ManifestFormatDocker ManifestFormat = 2
+)
+
+// layerMediaTypesToOCI maps the media types of Docker layers to the media
+// types of OCI layers.
+var layerMediaTypesToOCI = map[string]string{
+	docker.MediaTypeLayer:             ocispec.MediaTypeImageLayerGzip,
+	docker.MediaTypeUncompressedLayer: ocispec.MediaTypeImageLayer,
+	docker.MediaTypeForeignLayer:      ocispec.MediaTypeImageLayerNonDistributableGzip,
+}
+
+// layerMediaTypesToDocker maps the media types of OCI layers to the media
+// types of Docker layers.
+var layerMediaTypesToDocker = map[string]string{
+	ocispec.MediaTypeImageLayerGzip:                 docker.MediaTypeLayer,
+	ocispec.MediaTypeImageLayer:                     docker.MediaTypeUncompressedLayer,
+	ocispec.MediaTypeImageLayerNonDistributableGzip: docker.MediaTypeForeignLayer,
+}
+
+// convertFormat converts the graph rooted by root in src into format, where
+// the nodes are fetched using proxy, and returns the storage presenting the
+// converted graph as well as the converted root.
+func convertFormat(ctx context.Context, src content.ReadOnlyStorage, proxy *cas.Proxy, root ocispec.Descriptor, format ManifestFormat) (content.ReadOnlyStorage, ocispec.Descriptor, error) {
+	// fetch the nodes generated or cached by proxy without caching more
+	cached := &cas.Proxy{
+		ReadOnlyStorage: src,
+		Cache:           proxy.Cache,
+		StopCaching:     true,
+	}
+	converter, err := newFormatConverter(cached, format)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, newCopyError("ConvertFormat", CopyErrorOriginSource, err)
+	}
+	root, err = converter.convert(ctx, proxy, root)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, newCopyError("ConvertFormat", CopyErrorOriginSource, err)
+	}
+	return converter, root, nil
+}
+
+// formatConverter presents the graphs in the source storage converted into
+// the given format.
+// The converted manifests and indexes are held in the memory, while the
+// blobs are fetched from the source storage with their original
+// descriptors.
+type formatConverter struct {
+	src       content.ReadOnlyStorage
+	format    ManifestFormat
+	converted *cas.Memory
+	// blobs maps the digests of the blobs with converted media types to
+	// their original descriptors.
+	blobs map[digest.Digest]ocispec.Descriptor
+	// nodes maps the digests of the converted nodes to their new
+	// descriptors.
+	nodes map[digest.Digest]ocispec.Descriptor
+}
+
+// newFormatConverter creates a new formatConverter converting the graphs in
+// src into format.
+func newFormatConverter(src content.ReadOnlyStorage, format ManifestFormat) (*formatConverter, error) {
+	switch format {
+	case ManifestFormatOCI, ManifestFormatDocker:
+	default:
+		return nil, fmt.Errorf("unknown manifest format %d: %w", format, errdef.ErrUnsupported)
+	}
+	return &formatConverter{
+		src:       src,
+		format:    format,
+		converted: cas.NewMemory(),
+		blobs:     make(map[digest.Digest]ocispec.Descriptor),
+		nodes:     make(map[digest.Digest]ocispec.Descriptor),
+	}, nil
+}
+
+// Fetch fetches the content identified by the descriptor, where the converted
+// nodes are fetched from the memory.
+func (c *formatConverter) Fetch(ctx context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
+	exists, err := c.converted.Exists(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return c.converted.Fetch(ctx, target)
+	}
+	if desc, ok := c.blobs[target.Digest]; ok {
+		return c.src.Fetch(ctx, desc)
+	}
+	return c.src.Fetch(ctx, target)
+}
+
+// Exists returns true if the described content exists.
+func (c *formatConverter) Exists(ctx context.Context, target ocispec.Descriptor) (bool, error) {
+	exists, err := c.converted.Exists(ctx, target)
+	if err != nil || exists {
+		return exists, err
+	}
+	if desc, ok := c.blobs[target.Digest]; ok {
+		return c.src.Exists(ctx, desc)
+	}
+	return c.src.Exists(ctx, target)
+}
+
+// convert converts the graph rooted by desc, where the nodes are fetched
+// using fetcher, and returns the descriptor of the converted root.
+// Nodes already in the target format are left unchanged unless any of their
+// successors are converted.
+func (c *formatConverter) convert(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) (ocispec.Descriptor, error) {
+	if converted, ok := c.nodes[desc.Digest]; ok {
+		return withContent(desc, converted), nil
+	}
+	var converted ocispec.Descriptor
+	var err error
+	switch desc.MediaType {
+	case docker.MediaTypeManifest, ocispec.MediaTypeImageManifest:
+		converted, err = c.convertManifest(ctx, fetcher, desc)
+	case docker.MediaTypeManifestList, ocispec.MediaTypeImageIndex:
+		converted, err = c.convertIndex(ctx, fetcher, desc)
+	default:
+		// other nodes, such as artifacts, are not converted
+		return desc, nil
+	}
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	c.nodes[desc.Digest] = converted
+	return converted, nil
+}
+
+// convertManifest converts the manifest described by desc.
+func (c *formatConverter) convertManifest(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) (ocispec.Descriptor, error) {
+	manifestJSON, err := content.FetchAll(ctx, fetcher, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to decode manifest %s: %w", desc.Digest, err)
+	}
+
+	mediaType := ocispec.MediaTypeImageManifest
+	configMediaType := manifest.Config.MediaType
+	layerMediaTypes := layerMediaTypesToOCI
+	switch c.format {
+	case ManifestFormatOCI:
+		if configMediaType == docker.MediaTypeConfig {
+			configMediaType = ocispec.MediaTypeImageConfig
+		}
+	case ManifestFormatDocker:
+		if manifest.ArtifactType != "" || manifest.Subject != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("%s: %s: artifact manifests cannot be converted into Docker format: %w", desc.Digest, desc.MediaType, errdef.ErrUnsupported)
+		}
+		switch configMediaType {
+		case ocispec.MediaTypeImageConfig:
+			configMediaType = docker.MediaTypeConfig
+		case docker.MediaTypeConfig:
+		default:
+			return ocispec.Descriptor{}, fmt.Errorf("%s: %s: config %s cannot be converted into Docker format: %w", desc.Digest, desc.MediaType, configMediaType, errdef.ErrUnsupported)
+		}
+		mediaType = docker.MediaTypeManifest
+		layerMediaTypes = layerMediaTypesToDocker
+	}
+
+	changed := desc.MediaType != mediaType
+	if configMediaType != manifest.Config.MediaType {
+		manifest.Config = c.convertBlob(manifest.Config, configMediaType)
+		changed = true
+	}
+	for i, layer := range manifest.Layers {
+		if layerMediaType, ok := layerMediaTypes[layer.MediaType]; ok {
+			manifest.Layers[i] = c.convertBlob(layer, layerMediaType)
+			changed = true
+			continue
+		}
+		if c.format == ManifestFormatDocker {
+			// Docker manifests only accept Docker layers
+			if _, ok := layerMediaTypesToOCI[layer.MediaType]; !ok {
+				return ocispec.Descriptor{}, fmt.Errorf("%s: %s: layer %s cannot be converted into Docker format: %w", desc.Digest, desc.MediaType, layer.MediaType, errdef.ErrUnsupported)
+			}
+		}
+	}
+	if !changed {
+		return desc, nil
+	}
+	manifest.MediaType = mediaType
+	manifest.SchemaVersion = 2
+	return c.push(ctx, desc, mediaType, manifest)
+}
+
+// convertIndex converts the index described by desc and its manifests.
+func (c *formatConverter) convertIndex(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) (ocispec.Descriptor, error) {
+	indexJSON, err := content.FetchAll(ctx, fetcher, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(indexJSON, &index); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to decode index %s: %w", desc.Digest, err)
+	}
+
+	mediaType := ocispec.MediaTypeImageIndex
+	if c.format == ManifestFormatDocker {
+		if index.ArtifactType != "" || index.Subject != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("%s: %s: artifact indexes cannot be converted into Docker format: %w", desc.Digest, desc.MediaType, errdef.ErrUnsupported)
+		}
+		mediaType = docker.MediaTypeManifestList
+	}
+
+	changed := desc.MediaType != mediaType
+	for i, manifest := range index.Manifests {
+		converted, err := c.convert(ctx, fetcher, manifest)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		if !content.Equal(converted, manifest) {
+			index.Manifests[i] = converted
+			changed = true
+		}
+	}
+	if !changed {
+		return desc, nil
+	}
+	index.MediaType = mediaType
+	index.SchemaVersion = 2
+	return c.push(ctx, desc, mediaType, index)
+}
+
+// convertBlob returns the descriptor of the blob described by desc with the
+// media type converted, and records the original descriptor for fetching.
+func (c *formatConverter) convertBlob(desc ocispec.Descriptor, mediaType string) ocispec.Descriptor {
+	c.blobs[desc.Digest] = desc
+	desc.MediaType = mediaType
+	return desc
+}
+
+// push marshals the converted node into JSON bytes and pushes it to the
+// memory, returning a descriptor of the converted node with the other
+// properties of desc, such as the platform and the annotations, kept.
+func (c *formatConverter) push(ctx context.Context, desc ocispec.Descriptor, mediaType string, node any) (ocispec.Descriptor, error) {
+	nodeJSON, err := json.Marshal(node)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to marshal %s: %w", mediaType, err)
+	}
+	converted := content.NewDescriptorFromBytes(mediaType, nodeJSON)
+	if err := c.converted.Push(ctx, converted, bytes.NewReader(nodeJSON)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return ocispec.Descriptor{}, err
+	}
+	return withContent(desc, converted), nil
+}
+
+// withContent returns desc with the media type, the digest and the size
+// replaced by the ones of converted.
+func withContent(desc ocispec.Descriptor, converted ocispec.Descriptor) ocispec.Descriptor {
+	desc.MediaType = converted.MediaType
+	desc.Digest = converted.Digest
+	desc.Size = converted.Size
+	desc.Data = nil
+	return desc
+}
@@ -0,0 +1,250 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oras_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/docker"
+)
+
+// pushConvertTestImage pushes a multi-platform image with the given media
+// types to the storage, and returns the descriptor of the root index.
+func pushConvertTestImage(t *testing.T, ctx context.Context, s content.Storage, indexMediaType, manifestMediaType, configMediaType, layerMediaType string) ocispec.Descriptor {
+	t.Helper()
+	push := func(mediaType string, blob []byte) ocispec.Descriptor {
+		desc := content.NewDescriptorFromBytes(mediaType, blob)
+		if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+			t.Fatalf("failed to push test content: %v", err)
+		}
+		return desc
+	}
+	pushJSON := func(mediaType string, v any) ocispec.Descriptor {
+		blob, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return push(mediaType, blob)
+	}
+
+	var manifests []ocispec.Descriptor
+	for _, arch := range []string{"amd64", "arm64"} {
+		config := push(configMediaType, []byte(`{"architecture":"`+arch+`","os":"linux"}`))
+		layer := push(layerMediaType, []byte("layer "+arch))
+		manifest := pushJSON(manifestMediaType, ocispec.Manifest{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: manifestMediaType,
+			Config:    config,
+			Layers:    []ocispec.Descriptor{layer},
+		})
+		manifest.Platform = &ocispec.Platform{OS: "linux", Architecture: arch}
+		manifests = append(manifests, manifest)
+	}
+	return pushJSON(indexMediaType, ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: indexMediaType,
+		Manifests: manifests,
+	})
+}
+
+// verifyConvertedImage verifies the media types of the image rooted by root,
+// as well as the content of its blobs.
+func verifyConvertedImage(t *testing.T, ctx context.Context, dst content.ReadOnlyStorage, root ocispec.Descriptor, indexMediaType, manifestMediaType, configMediaType, layerMediaType string) {
+	t.Helper()
+	if root.MediaType != indexMediaType {
+		t.Fatalf("root media type = %s, want %s", root.MediaType, indexMediaType)
+	}
+	indexJSON, err := content.FetchAll(ctx, dst, root)
+	if err != nil {
+		t.Fatalf("failed to fetch index: %v", err)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(indexJSON, &index); err != nil {
+		t.Fatal(err)
+	}
+	if index.MediaType != indexMediaType || len(index.Manifests) != 2 {
+		t.Fatalf("index = %s, want media type %s with 2 manifests", indexJSON, indexMediaType)
+	}
+	for _, desc := range index.Manifests {
+		if desc.MediaType != manifestMediaType || desc.Platform == nil {
+			t.Fatalf("index manifest = %v, want media type %s with platform", desc, manifestMediaType)
+		}
+		manifestJSON, err := content.FetchAll(ctx, dst, desc)
+		if err != nil {
+			t.Fatalf("failed to fetch manifest: %v", err)
+		}
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+			t.Fatal(err)
+		}
+		if manifest.MediaType != manifestMediaType || manifest.Config.MediaType != configMediaType {
+			t.Errorf("manifest = %s, want media type %s and config media type %s", manifestJSON, manifestMediaType, configMediaType)
+		}
+		for _, blob := range append(manifest.Layers, manifest.Config) {
+			if blob.MediaType != configMediaType && blob.MediaType != layerMediaType {
+				t.Errorf("layer media type = %s, want %s", blob.MediaType, layerMediaType)
+			}
+			got, err := content.FetchAll(ctx, dst, blob)
+			if err != nil {
+				t.Fatalf("failed to fetch blob from destination: %v", err)
+			}
+			if len(got) == 0 || !bytes.Contains(got, []byte(desc.Platform.Architecture)) {
+				t.Errorf("blob content = %s, want content for %s", got, desc.Platform.Architecture)
+			}
+		}
+	}
+}
+
+func TestCopy_ConvertFormat(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	root := pushConvertTestImage(t, ctx, src, docker.MediaTypeManifestList, docker.MediaTypeManifest, docker.MediaTypeConfig, docker.MediaTypeLayer)
+	ref := "foobar"
+	if err := src.Tag(ctx, root, ref); err != nil {
+		t.Fatal("fail to tag root node", err)
+	}
+
+	// Docker to OCI
+	dst := memory.New()
+	opts := oras.CopyOptions{ConvertFormat: oras.ManifestFormatOCI}
+	got, err := oras.Copy(ctx, src, ref, dst, "", opts)
+	if err != nil {
+		t.Fatalf("Copy() error = %v, wantErr %v", err, false)
+	}
+	verifyConvertedImage(t, ctx, dst, got, ocispec.MediaTypeImageIndex, ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageConfig, ocispec.MediaTypeImageLayerGzip)
+	tagged, err := dst.Resolve(ctx, ref)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if !content.Equal(tagged, got) {
+		t.Errorf("Resolve() = %v, want %v", tagged, got)
+	}
+
+	// OCI back to Docker restores the original image
+	dst2 := memory.New()
+	opts.ConvertFormat = oras.ManifestFormatDocker
+	got2, err := oras.Copy(ctx, dst, ref, dst2, "", opts)
+	if err != nil {
+		t.Fatalf("Copy() error = %v, wantErr %v", err, false)
+	}
+	if !content.Equal(got2, root) {
+		t.Errorf("Copy() = %v, want %v", got2, root)
+	}
+	verifyConvertedImage(t, ctx, dst2, got2, docker.MediaTypeManifestList, docker.MediaTypeManifest, docker.MediaTypeConfig, docker.MediaTypeLayer)
+
+	// content already in the target format is not changed
+	got3, err := oras.Copy(ctx, src, ref, memory.New(), "", opts)
+	if err != nil {
+		t.Fatalf("Copy() error = %v, wantErr %v", err, false)
+	}
+	if !content.Equal(got3, root) {
+		t.Errorf("Copy() = %v, want %v", got3, root)
+	}
+
+	// conversion applies on the selected platform
+	opts = oras.CopyOptions{ConvertFormat: oras.ManifestFormatOCI}
+	opts.WithTargetPlatform(&ocispec.Platform{OS: "linux", Architecture: "arm64"})
+	got4, err := oras.Copy(ctx, src, ref, memory.New(), "", opts)
+	if err != nil {
+		t.Fatalf("Copy() error = %v, wantErr %v", err, false)
+	}
+	if got4.MediaType != ocispec.MediaTypeImageManifest || got4.Platform == nil || got4.Platform.Architecture != "arm64" {
+		t.Errorf("Copy() = %v, want an OCI manifest for arm64", got4)
+	}
+}
+
+func TestCopy_ConvertFormat_Unsupported(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	artifactJSON, err := json.Marshal(ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.test",
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{ocispec.DescriptorEmptyJSON},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifact := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, artifactJSON)
+	if err := src.Push(ctx, artifact, bytes.NewReader(artifactJSON)); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Tag(ctx, artifact, "artifact"); err != nil {
+		t.Fatal(err)
+	}
+	zstdImage := pushConvertTestImage(t, ctx, src, ocispec.MediaTypeImageIndex, ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageConfig, ocispec.MediaTypeImageLayerZstd)
+	if err := src.Tag(ctx, zstdImage, "zstd"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		ref    string
+		format oras.ManifestFormat
+	}{
+		{name: "artifact to Docker", ref: "artifact", format: oras.ManifestFormatDocker},
+		{name: "zstd layers to Docker", ref: "zstd", format: oras.ManifestFormatDocker},
+		{name: "unknown format", ref: "artifact", format: oras.ManifestFormat(-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := oras.Copy(ctx, src, tt.ref, memory.New(), "", oras.CopyOptions{ConvertFormat: tt.format})
+			if !errors.Is(err, errdef.ErrUnsupported) {
+				t.Errorf("Copy() error = %v, wantErr %v", err, errdef.ErrUnsupported)
+			}
+			var copyErr *oras.CopyError
+			if !errors.As(err, &copyErr) || copyErr.Op != "ConvertFormat" {
+				t.Errorf("Copy() error = %v, want CopyError of ConvertFormat", err)
+			}
+		})
+	}
+}
+
+func TestPlanCopy_ConvertFormat(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	root := pushConvertTestImage(t, ctx, src, ocispec.MediaTypeImageIndex, ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageConfig, ocispec.MediaTypeImageLayerGzip)
+	ref := "foobar"
+	if err := src.Tag(ctx, root, ref); err != nil {
+		t.Fatal("fail to tag root node", err)
+	}
+
+	opts := oras.CopyOptions{ConvertFormat: oras.ManifestFormatDocker}
+	plan, err := oras.PlanCopy(ctx, src, ref, memory.New(), opts)
+	if err != nil {
+		t.Fatalf("PlanCopy() error = %v, wantErr %v", err, false)
+	}
+	if len(plan.Roots) != 1 || plan.Roots[0].MediaType != docker.MediaTypeManifestList {
+		t.Fatalf("CopyPlan.Roots = %v, want a Docker manifest list", plan.Roots)
+	}
+	for _, node := range plan.Nodes {
+		switch node.Descriptor.MediaType {
+		case docker.MediaTypeManifestList, docker.MediaTypeManifest, docker.MediaTypeConfig, docker.MediaTypeLayer:
+		default:
+			t.Errorf("planned node %v is not converted", node.Descriptor)
+		}
+	}
+}
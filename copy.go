@@ -16,10 +16,14 @@ limitations under the License.
 package oras
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"slices"
+	"time"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"golang.org/x/sync/semaphore"
@@ -27,10 +31,12 @@ import (
 	"oras.land/oras-go/v2/errdef"
 	"oras.land/oras-go/v2/internal/cas"
 	"oras.land/oras-go/v2/internal/descriptor"
+	"oras.land/oras-go/v2/internal/docker"
 	"oras.land/oras-go/v2/internal/platform"
 	"oras.land/oras-go/v2/internal/registryutil"
 	"oras.land/oras-go/v2/internal/status"
 	"oras.land/oras-go/v2/internal/syncutil"
+	"oras.land/oras-go/v2/platforms"
 	"oras.land/oras-go/v2/registry"
 )
 
@@ -54,6 +60,30 @@ type CopyOptions struct {
 	// reference will be passed to MapRoot, and the mapped descriptor will be
 	// used as the root node for copy.
 	MapRoot func(ctx context.Context, src content.ReadOnlyStorage, root ocispec.Descriptor) (ocispec.Descriptor, error)
+	// ConvertFormat converts the manifests, the indexes, the configs and the
+	// layers of the images into the given format while copying, where the
+	// converted nodes and all their parents are re-digested, and the
+	// converted root node is returned.
+	// The layers are copied as is, with only their media types converted.
+	// When MapRoot is provided, the conversion will be applied on the mapped
+	// root node.
+	// If ConvertFormat is 0, no conversion will be applied.
+	ConvertFormat ManifestFormat
+	// TargetPlatforms selects the manifests whose platforms match any of the
+	// given platforms. When MapRoot is provided, the platform selection will
+	// be applied on the mapped root node.
+	//   - If TargetPlatforms is empty, no platform selection will be applied.
+	//   - If the root node is a manifest, it will remain the same if its
+	//     platform matches, otherwise ErrNotFound will be returned.
+	//   - If the root node is a manifest list, a new manifest list containing
+	//     only the matching manifests will be copied and tagged as the root
+	//     node, or the root node will remain the same if all the manifests
+	//     match. ErrNotFound will be returned if none of the manifests match.
+	//   - Otherwise ErrUnsupported will be returned.
+	//
+	// See also [CopyOptions.WithTargetPlatform] for selecting a single
+	// manifest.
+	TargetPlatforms []*ocispec.Platform
 }
 
 // WithTargetPlatform configures opts.MapRoot to select the manifest whose
@@ -80,6 +110,31 @@ func (opts *CopyOptions) WithTargetPlatform(p *ocispec.Platform) {
 	}
 }
 
+// WithPlatformMatcher configures opts.MapRoot to select the manifest best
+// matching the given platform matcher. When MapRoot is provided, the platform
+// selection will be applied on the mapped root node.
+//   - If the given matcher is nil, no platform selection will be applied.
+//   - If the root node is a manifest, it will remain the same if platform
+//     matches, otherwise ErrNotFound will be returned.
+//   - If the root node is a manifest list, it will be mapped to the matching
+//     manifest preferred by the matcher if exists, otherwise ErrNotFound will
+//     be returned.
+//   - Otherwise ErrUnsupported will be returned.
+func (opts *CopyOptions) WithPlatformMatcher(m platforms.MatchComparer) {
+	if m == nil {
+		return
+	}
+	mapRoot := opts.MapRoot
+	opts.MapRoot = func(ctx context.Context, src content.ReadOnlyStorage, root ocispec.Descriptor) (desc ocispec.Descriptor, err error) {
+		if mapRoot != nil {
+			if root, err = mapRoot(ctx, src, root); err != nil {
+				return ocispec.Descriptor{}, err
+			}
+		}
+		return platforms.SelectManifest(ctx, src, root, m)
+	}
+}
+
 // defaultCopyMaxMetadataBytes is the default value of
 // CopyGraphOptions.MaxMetadataBytes.
 const defaultCopyMaxMetadataBytes int64 = 4 * 1024 * 1024 // 4 MiB
@@ -118,6 +173,16 @@ type CopyGraphOptions struct {
 	// source storage to fetch large blobs.
 	// If FindSuccessors is nil, content.Successors will be used.
 	FindSuccessors func(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) ([]ocispec.Descriptor, error)
+	// OnProgress reports the progress of the copy, including the state and
+	// the bytes transferred of each node as well as the overall progress.
+	// The bytes are counted as they are read by the destination storage.
+	// OnProgress may be invoked concurrently.
+	OnProgress func(ctx context.Context, progress Progress)
+	// ProgressInterval is the minimum interval between two reports of a node
+	// in the transferring state.
+	// If less than or equal to 0, a default (currently 100 milliseconds) is
+	// used.
+	ProgressInterval time.Duration
 }
 
 // Copy copies a rooted directed acyclic graph (DAG), such as an artifact,
@@ -159,11 +224,28 @@ func Copy(ctx context.Context, src ReadOnlyTarget, srcRef string, dst Target, ds
 		proxy.StopCaching = false
 	}
 
-	if err := prepareCopy(ctx, dst, dstRef, proxy, root, &opts); err != nil {
+	if len(opts.TargetPlatforms) > 0 {
+		root, err = selectPlatforms(ctx, proxy, root, opts.TargetPlatforms)
+		if err != nil {
+			return ocispec.Descriptor{}, newCopyError("SelectPlatforms", CopyErrorOriginSource, err)
+		}
+	}
+
+	var srcStorage content.ReadOnlyStorage = src
+	if opts.ConvertFormat != 0 {
+		srcStorage, root, err = convertFormat(ctx, src, proxy, root, opts.ConvertFormat)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		proxy = cas.NewProxyWithLimit(srcStorage, cas.NewMemory(), opts.MaxMetadataBytes)
+	}
+
+	progress := newProgressTracker(opts.CopyGraphOptions)
+	if err := prepareCopy(ctx, dst, dstRef, proxy, root, progress, &opts); err != nil {
 		return ocispec.Descriptor{}, err
 	}
 
-	if err := copyGraph(ctx, src, dst, root, proxy, nil, nil, opts.CopyGraphOptions); err != nil {
+	if err := copyGraph(ctx, srcStorage, dst, root, proxy, nil, nil, progress, opts.CopyGraphOptions); err != nil {
 		return ocispec.Descriptor{}, err
 	}
 
@@ -180,13 +262,14 @@ func CopyGraph(ctx context.Context, src content.ReadOnlyStorage, dst content.Sto
 	if dst == nil {
 		return newCopyError("CopyGraph", CopyErrorOriginDestination, errors.New("nil destination target"))
 	}
-	return copyGraph(ctx, src, dst, root, nil, nil, nil, opts)
+	return copyGraph(ctx, src, dst, root, nil, nil, nil, nil, opts)
 }
 
 // copyGraph copies a rooted directed acyclic graph (DAG) from the source CAS to
-// the destination CAS with specified caching, concurrency limiter and tracker.
+// the destination CAS with specified caching, concurrency limiter, tracker and
+// progress tracker.
 func copyGraph(ctx context.Context, src content.ReadOnlyStorage, dst content.Storage, root ocispec.Descriptor,
-	proxy *cas.Proxy, limiter *semaphore.Weighted, tracker *status.Tracker, opts CopyGraphOptions) error {
+	proxy *cas.Proxy, limiter *semaphore.Weighted, tracker *status.Tracker, progress *progressTracker, opts CopyGraphOptions) error {
 	if proxy == nil {
 		// use caching proxy on non-leaf nodes
 		if opts.MaxMetadataBytes <= 0 {
@@ -205,6 +288,9 @@ func copyGraph(ctx context.Context, src content.ReadOnlyStorage, dst content.Sto
 		// track content status
 		tracker = status.NewTracker()
 	}
+	if progress == nil {
+		progress = newProgressTracker(opts)
+	}
 	// if FindSuccessors is not provided, use the default one
 	if opts.FindSuccessors == nil {
 		opts.FindSuccessors = content.Successors
@@ -231,6 +317,7 @@ func copyGraph(ctx context.Context, src content.ReadOnlyStorage, dst content.Sto
 			return newCopyError("Exists", CopyErrorOriginDestination, err)
 		}
 		if exists {
+			progress.skipped(ctx, desc)
 			if opts.OnCopySkipped != nil {
 				if err := opts.OnCopySkipped(ctx, desc); err != nil {
 					return err
@@ -273,25 +360,25 @@ func copyGraph(ctx context.Context, src content.ReadOnlyStorage, dst content.Sto
 			return fmt.Errorf("failed to check cache existence: %s: %w", desc.Digest, err)
 		}
 		if exists {
-			return copyNode(ctx, proxy.Cache, dst, desc, opts)
+			return copyNode(ctx, proxy.Cache, dst, desc, progress, opts)
 		}
-		return mountOrCopyNode(ctx, src, dst, desc, opts)
+		return mountOrCopyNode(ctx, src, dst, desc, progress, opts)
 	}
 
 	return syncutil.Go(ctx, limiter, fn, root)
 }
 
 // mountOrCopyNode tries to mount the node, if not falls back to copying.
-func mountOrCopyNode(ctx context.Context, src content.ReadOnlyStorage, dst content.Storage, desc ocispec.Descriptor, opts CopyGraphOptions) error {
+func mountOrCopyNode(ctx context.Context, src content.ReadOnlyStorage, dst content.Storage, desc ocispec.Descriptor, progress *progressTracker, opts CopyGraphOptions) error {
 	// Need MountFrom and it must be a blob
 	if opts.MountFrom == nil || descriptor.IsManifest(desc) {
-		return copyNode(ctx, src, dst, desc, opts)
+		return copyNode(ctx, src, dst, desc, progress, opts)
 	}
 
 	mounter, ok := dst.(registry.Mounter)
 	if !ok {
 		// mounting is not supported by the destination
-		return copyNode(ctx, src, dst, desc, opts)
+		return copyNode(ctx, src, dst, desc, progress, opts)
 	}
 
 	sourceRepositories, err := opts.MountFrom(ctx, desc)
@@ -302,10 +389,11 @@ func mountOrCopyNode(ctx context.Context, src content.ReadOnlyStorage, dst conte
 	}
 
 	if len(sourceRepositories) == 0 {
-		return copyNode(ctx, src, dst, desc, opts)
+		return copyNode(ctx, src, dst, desc, progress, opts)
 	}
 
 	skipSource := errors.New("skip source")
+	var fetched io.Reader
 	for i, sourceRepository := range sourceRepositories {
 		// try mounting this source repository
 		var mountFailed bool
@@ -325,7 +413,15 @@ func mountOrCopyNode(ctx context.Context, src content.ReadOnlyStorage, dst conte
 					return nil, err
 				}
 			}
-			return src.Fetch(ctx, desc)
+			rc, err := src.Fetch(ctx, desc)
+			if err != nil {
+				return nil, err
+			}
+			fetched = progress.started(ctx, desc, rc)
+			return struct {
+				io.Reader
+				io.Closer
+			}{fetched, rc}, nil
 		}
 
 		// Mount or copy
@@ -335,6 +431,7 @@ func mountOrCopyNode(ctx context.Context, src content.ReadOnlyStorage, dst conte
 
 		if !mountFailed {
 			// mounted, success
+			progress.mounted(ctx, desc)
 			if opts.OnMounted != nil {
 				if err := opts.OnMounted(ctx, desc); err != nil {
 					return err
@@ -345,6 +442,7 @@ func mountOrCopyNode(ctx context.Context, src content.ReadOnlyStorage, dst conte
 	}
 
 	// we copied it
+	progress.complete(ctx, desc, fetched)
 	if opts.PostCopy != nil {
 		if err := opts.PostCopy(ctx, desc); err != nil {
 			return err
@@ -355,32 +453,35 @@ func mountOrCopyNode(ctx context.Context, src content.ReadOnlyStorage, dst conte
 }
 
 // doCopyNode copies a single content from the source CAS to the destination CAS.
-func doCopyNode(ctx context.Context, src content.ReadOnlyStorage, dst content.Storage, desc ocispec.Descriptor) error {
+func doCopyNode(ctx context.Context, src content.ReadOnlyStorage, dst content.Storage, desc ocispec.Descriptor, progress *progressTracker) error {
 	rc, err := src.Fetch(ctx, desc)
 	if err != nil {
 		return newCopyError("Fetch", CopyErrorOriginSource, err)
 	}
 	defer rc.Close()
-	err = dst.Push(ctx, desc, rc)
+	r := progress.started(ctx, desc, rc)
+	err = dst.Push(ctx, desc, r)
 	if err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
 		return newCopyError("Push", CopyErrorOriginDestination, err)
 	}
+	progress.complete(ctx, desc, r)
 	return nil
 }
 
 // copyNode copies a single content from the source CAS to the destination CAS,
 // and apply the given options.
-func copyNode(ctx context.Context, src content.ReadOnlyStorage, dst content.Storage, desc ocispec.Descriptor, opts CopyGraphOptions) error {
+func copyNode(ctx context.Context, src content.ReadOnlyStorage, dst content.Storage, desc ocispec.Descriptor, progress *progressTracker, opts CopyGraphOptions) error {
 	if opts.PreCopy != nil {
 		if err := opts.PreCopy(ctx, desc); err != nil {
 			if err == SkipNode {
+				progress.skipped(ctx, desc)
 				return nil
 			}
 			return err
 		}
 	}
 
-	if err := doCopyNode(ctx, src, dst, desc); err != nil {
+	if err := doCopyNode(ctx, src, dst, desc, progress); err != nil {
 		return err
 	}
 
@@ -392,17 +493,19 @@ func copyNode(ctx context.Context, src content.ReadOnlyStorage, dst content.Stor
 
 // copyCachedNodeWithReference copies a single content with a reference from the
 // source cache to the destination ReferencePusher.
-func copyCachedNodeWithReference(ctx context.Context, src *cas.Proxy, dst registry.ReferencePusher, desc ocispec.Descriptor, dstRef string) error {
+func copyCachedNodeWithReference(ctx context.Context, src *cas.Proxy, dst registry.ReferencePusher, desc ocispec.Descriptor, dstRef string, progress *progressTracker) error {
 	rc, err := src.FetchCached(ctx, desc)
 	if err != nil {
 		return newCopyError("Fetch", CopyErrorOriginSource, err)
 	}
 	defer rc.Close()
 
-	err = dst.PushReference(ctx, desc, rc, dstRef)
+	r := progress.started(ctx, desc, rc)
+	err = dst.PushReference(ctx, desc, r, dstRef)
 	if err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
 		return newCopyError("PushReference", CopyErrorOriginDestination, err)
 	}
+	progress.complete(ctx, desc, r)
 	return nil
 }
 
@@ -443,8 +546,64 @@ func resolveRoot(ctx context.Context, src ReadOnlyTarget, srcRef string, proxy *
 	return root, nil
 }
 
+// selectPlatforms selects the manifests matching any of the platforms from
+// root. If only part of the manifests of a manifest list match, a new
+// manifest list containing the matching manifests is generated and cached in
+// proxy, which is returned as the new root.
+func selectPlatforms(ctx context.Context, proxy *cas.Proxy, root ocispec.Descriptor, platforms []*ocispec.Platform) (ocispec.Descriptor, error) {
+	switch root.MediaType {
+	case docker.MediaTypeManifestList, ocispec.MediaTypeImageIndex:
+		indexJSON, err := content.FetchAll(ctx, proxy, root)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		var index ocispec.Index
+		if err := json.Unmarshal(indexJSON, &index); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to decode index %s: %w", root.Digest, err)
+		}
+		var manifests []ocispec.Descriptor
+		for _, m := range index.Manifests {
+			if slices.ContainsFunc(platforms, func(p *ocispec.Platform) bool {
+				return platform.Match(m.Platform, p)
+			}) {
+				manifests = append(manifests, m)
+			}
+		}
+		switch len(manifests) {
+		case 0:
+			return ocispec.Descriptor{}, fmt.Errorf("%s: %w: no matching manifest was found in the manifest list", root.Digest, errdef.ErrNotFound)
+		case len(index.Manifests):
+			return root, nil
+		}
+
+		index.Manifests = manifests
+		selectedJSON, err := json.Marshal(index)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to marshal index: %w", err)
+		}
+		selected := content.NewDescriptorFromBytes(root.MediaType, selectedJSON)
+		if err := proxy.Cache.Push(ctx, selected, bytes.NewReader(selectedJSON)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+			return ocispec.Descriptor{}, err
+		}
+		return withContent(root, selected), nil
+	case docker.MediaTypeManifest, ocispec.MediaTypeImageManifest:
+		for _, p := range platforms {
+			desc, err := platform.SelectManifest(ctx, proxy, root, p)
+			if err == nil {
+				return desc, nil
+			}
+			if !errors.Is(err, errdef.ErrNotFound) {
+				return ocispec.Descriptor{}, err
+			}
+		}
+		return ocispec.Descriptor{}, fmt.Errorf("%s: %w: platform in manifest does not match target platforms", root.Digest, errdef.ErrNotFound)
+	default:
+		return ocispec.Descriptor{}, fmt.Errorf("%s: %s: %w", root.Digest, root.MediaType, errdef.ErrUnsupported)
+	}
+}
+
 // prepareCopy prepares the hooks for copy.
-func prepareCopy(_ context.Context, dst Target, dstRef string, proxy *cas.Proxy, root ocispec.Descriptor, opts *CopyOptions) error {
+func prepareCopy(_ context.Context, dst Target, dstRef string, proxy *cas.Proxy, root ocispec.Descriptor, progress *progressTracker, opts *CopyOptions) error {
 	if refPusher, ok := dst.(registry.ReferencePusher); ok {
 		// optimize performance for ReferencePusher targets
 		preCopy := opts.PreCopy
@@ -460,7 +619,7 @@ func prepareCopy(_ context.Context, dst Target, dstRef string, proxy *cas.Proxy,
 			}
 
 			// for root node, prepare optimized copy
-			if err := copyCachedNodeWithReference(ctx, proxy, refPusher, desc, dstRef); err != nil {
+			if err := copyCachedNodeWithReference(ctx, proxy, refPusher, desc, dstRef, progress); err != nil {
 				return err
 			}
 			if opts.PostCopy != nil {
@@ -500,7 +659,7 @@ func prepareCopy(_ context.Context, dst Target, dstRef string, proxy *cas.Proxy,
 		if refPusher, ok := dst.(registry.ReferencePusher); ok {
 			// NOTE: refPusher tags the node by copying it with the reference,
 			// so onCopySkipped shouldn't be invoked in this case
-			return copyCachedNodeWithReference(ctx, proxy, refPusher, desc, dstRef)
+			return copyCachedNodeWithReference(ctx, proxy, refPusher, desc, dstRef, nil)
 		}
 
 		// invoke onCopySkipped before tagging
@@ -37,6 +37,7 @@ import (
 	"oras.land/oras-go/v2/internal/cas"
 	"oras.land/oras-go/v2/internal/docker"
 	"oras.land/oras-go/v2/internal/spec"
+	"oras.land/oras-go/v2/platforms"
 )
 
 // storageTracker tracks storage API counts.
@@ -1096,6 +1097,133 @@ func TestCopy_WithTargetPlatformOptions(t *testing.T) {
 	}
 }
 
+func TestCopy_WithTargetPlatforms(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	root := pushConvertTestImage(t, ctx, src, ocispec.MediaTypeImageIndex, ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageConfig, ocispec.MediaTypeImageLayer)
+	ref := "foobar"
+	if err := src.Tag(ctx, root, ref); err != nil {
+		t.Fatal("fail to tag root node", err)
+	}
+	manifests, err := content.Successors(ctx, src, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amd64, arm64 := manifests[0], manifests[1]
+
+	// select part of the platforms
+	dst := memory.New()
+	opts := oras.CopyOptions{
+		TargetPlatforms: []*ocispec.Platform{
+			{OS: "linux", Architecture: "arm64"},
+			{OS: "linux", Architecture: "riscv64"},
+		},
+	}
+	gotDesc, err := oras.Copy(ctx, src, ref, dst, "", opts)
+	if err != nil {
+		t.Fatalf("Copy() error = %v, wantErr %v", err, false)
+	}
+	if gotDesc.MediaType != ocispec.MediaTypeImageIndex || gotDesc.Digest == root.Digest {
+		t.Fatalf("Copy() = %v, want a new index", gotDesc)
+	}
+	tagged, err := dst.Resolve(ctx, ref)
+	if err != nil {
+		t.Fatalf("dst.Resolve() error = %v", err)
+	}
+	if !content.Equal(tagged, gotDesc) {
+		t.Errorf("dst.Resolve() = %v, want %v", tagged, gotDesc)
+	}
+	successors, err := content.Successors(ctx, dst, gotDesc)
+	if err != nil {
+		t.Fatalf("content.Successors() error = %v", err)
+	}
+	if want := []ocispec.Descriptor{arm64}; !reflect.DeepEqual(successors, want) {
+		t.Errorf("content.Successors() = %v, want %v", successors, want)
+	}
+	exists, err := dst.Exists(ctx, amd64)
+	if err != nil {
+		t.Fatalf("dst.Exists() error = %v", err)
+	}
+	if exists {
+		t.Errorf("dst.Exists(%v) = %v, want %v", amd64, exists, false)
+	}
+
+	// select all the platforms
+	opts.TargetPlatforms = []*ocispec.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	}
+	gotDesc, err = oras.Copy(ctx, src, ref, memory.New(), "", opts)
+	if err != nil {
+		t.Fatalf("Copy() error = %v, wantErr %v", err, false)
+	}
+	if !content.Equal(gotDesc, root) {
+		t.Errorf("Copy() = %v, want %v", gotDesc, root)
+	}
+
+	// select a manifest
+	manifestRef := "amd64"
+	if err := src.Tag(ctx, amd64, manifestRef); err != nil {
+		t.Fatal("fail to tag manifest", err)
+	}
+	gotDesc, err = oras.Copy(ctx, src, manifestRef, memory.New(), "", opts)
+	if err != nil {
+		t.Fatalf("Copy() error = %v, wantErr %v", err, false)
+	}
+	if !content.Equal(gotDesc, amd64) {
+		t.Errorf("Copy() = %v, want %v", gotDesc, amd64)
+	}
+
+	// no matching platforms
+	opts.TargetPlatforms = []*ocispec.Platform{{OS: "windows", Architecture: "amd64"}}
+	for _, ref := range []string{ref, manifestRef} {
+		_, err = oras.Copy(ctx, src, ref, memory.New(), "", opts)
+		if !errors.Is(err, errdef.ErrNotFound) {
+			t.Errorf("Copy() error = %v, wantErr %v", err, errdef.ErrNotFound)
+		}
+	}
+}
+
+func TestCopy_WithPlatformMatcher(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	root := pushConvertTestImage(t, ctx, src, ocispec.MediaTypeImageIndex, ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageConfig, ocispec.MediaTypeImageLayer)
+	ref := "foobar"
+	if err := src.Tag(ctx, root, ref); err != nil {
+		t.Fatal("fail to tag root node", err)
+	}
+
+	// the first preferred platform available is selected
+	opts := oras.CopyOptions{}
+	opts.WithPlatformMatcher(platforms.Ordered(
+		platforms.MustParse("linux/arm/v7"),
+		platforms.MustParse("linux/aarch64"),
+		platforms.MustParse("linux/amd64"),
+	))
+	dst := memory.New()
+	got, err := oras.Copy(ctx, src, ref, dst, "", opts)
+	if err != nil {
+		t.Fatalf("Copy() error = %v, wantErr %v", err, false)
+	}
+	if got.Platform == nil || got.Platform.Architecture != "arm64" {
+		t.Errorf("Copy() = %v, want arm64 manifest", got)
+	}
+	tagged, err := dst.Resolve(ctx, ref)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if !content.Equal(tagged, got) {
+		t.Errorf("Resolve() = %v, want %v", tagged, got)
+	}
+
+	// no match
+	opts = oras.CopyOptions{}
+	opts.WithPlatformMatcher(platforms.Only(platforms.MustParse("linux/s390x")))
+	if _, err := oras.Copy(ctx, src, ref, memory.New(), "", opts); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Copy() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+}
+
 func TestCopy_RestoreDuplicates(t *testing.T) {
 	src := memory.New()
 	temp := t.TempDir()
@@ -0,0 +1,140 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oras
+
+import (
+	"context"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/internal/container/set"
+	"oras.land/oras-go/v2/internal/descriptor"
+	"oras.land/oras-go/v2/internal/spec"
+	"oras.land/oras-go/v2/registry"
+)
+
+// DefaultDiscoverOptions provides the default DiscoverOptions.
+var DefaultDiscoverOptions DiscoverOptions
+
+// DiscoverOptions contains parameters for [oras.Discover].
+type DiscoverOptions struct {
+	// Depth limits the maximum depth of the referrers tree, where the direct
+	// referrers of the subject are at depth 1.
+	// If Depth is no specified, or the specified value is less than or
+	// equal to 0, the depth limit will be considered as infinity.
+	Depth int
+	// ArtifactTypes filters the referrers by artifact type at each level of
+	// the referrers tree, where ArtifactTypes[i] filters the referrers at
+	// depth i+1. The referrers at a level are not filtered if the artifact
+	// type of the level is empty or not specified.
+	ArtifactTypes []string
+	// FindPredecessors finds the predecessors of the current node, as
+	// ExtendedCopyGraphOptions.FindPredecessors does. For instance,
+	// ExtendedCopyGraphOptions.FindPredecessors configured by
+	// FilterAnnotation can be used to filter the referrers by annotations.
+	// If FindPredecessors is nil, the referrers listed by registry.Referrers
+	// are used.
+	FindPredecessors func(ctx context.Context, src content.ReadOnlyGraphStorage, desc ocispec.Descriptor) ([]ocispec.Descriptor, error)
+}
+
+// ReferrerNode is a node of a referrers tree.
+type ReferrerNode struct {
+	// Descriptor is the descriptor of the node.
+	Descriptor ocispec.Descriptor
+	// Referrers are the nodes of the referrers of the node.
+	Referrers []*ReferrerNode
+}
+
+// Discover walks the referrers tree rooted by the subject in src, which
+// contains the referrers of the subject, the referrers of the referrers, and
+// so on, such as the signatures of the SBOMs of an image.
+//
+// Returns the root node of the referrers tree, which is the node of the
+// subject.
+func Discover(ctx context.Context, src content.ReadOnlyGraphStorage, subject ocispec.Descriptor, opts DiscoverOptions) (*ReferrerNode, error) {
+	root := &ReferrerNode{Descriptor: subject}
+	ancestors := set.New[descriptor.Descriptor]()
+	if err := discover(ctx, src, root, 1, ancestors, opts); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// discover finds the referrers of the node at the given depth recursively,
+// where ancestors contains the nodes on the path from the root to the node.
+func discover(ctx context.Context, src content.ReadOnlyGraphStorage, node *ReferrerNode, depth int, ancestors set.Set[descriptor.Descriptor], opts DiscoverOptions) error {
+	key := descriptor.FromOCI(node.Descriptor)
+	ancestors.Add(key)
+	defer ancestors.Delete(key)
+
+	var artifactType string
+	if depth <= len(opts.ArtifactTypes) {
+		artifactType = opts.ArtifactTypes[depth-1]
+	}
+	referrers, err := opts.findReferrers(ctx, src, node.Descriptor, artifactType)
+	if err != nil {
+		return err
+	}
+	for _, referrer := range referrers {
+		if ancestors.Contains(descriptor.FromOCI(referrer)) {
+			// skip the cycles introduced by FindPredecessors
+			continue
+		}
+		child := &ReferrerNode{Descriptor: referrer}
+		node.Referrers = append(node.Referrers, child)
+		if opts.Depth > 0 && depth == opts.Depth {
+			continue
+		}
+		if err := discover(ctx, src, child, depth+1, ancestors, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findReferrers finds the referrers of desc with the given artifact type.
+// All referrers are returned if artifactType is empty.
+func (opts *DiscoverOptions) findReferrers(ctx context.Context, src content.ReadOnlyGraphStorage, desc ocispec.Descriptor, artifactType string) ([]ocispec.Descriptor, error) {
+	if opts.FindPredecessors == nil {
+		return registry.Referrers(ctx, src, desc, artifactType)
+	}
+	predecessors, err := opts.FindPredecessors(ctx, src, desc)
+	if err != nil {
+		return nil, err
+	}
+	if artifactType == "" {
+		return predecessors, nil
+	}
+	var kept []ocispec.Descriptor
+	for _, p := range predecessors {
+		if p.ArtifactType == "" {
+			// the artifact types of the predecessors are not guaranteed to
+			// be present in the descriptors
+			switch p.MediaType {
+			case spec.MediaTypeArtifactManifest, ocispec.MediaTypeImageManifest:
+				at, err := fetchArtifactType(ctx, src, p)
+				if err != nil {
+					return nil, err
+				}
+				p.ArtifactType = at
+			}
+		}
+		if p.ArtifactType == artifactType {
+			kept = append(kept, p)
+		}
+	}
+	return kept, nil
+}
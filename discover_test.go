@@ -0,0 +1,174 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oras_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// pushReferrer pushes an artifact manifest referencing subject to the storage.
+func pushReferrer(t *testing.T, ctx context.Context, s content.Storage, subject ocispec.Descriptor, artifactType string) ocispec.Descriptor {
+	t.Helper()
+	manifestJSON, err := json.Marshal(ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: artifactType,
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{ocispec.DescriptorEmptyJSON},
+		Subject:      &subject,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestJSON)
+	if err := s.Push(ctx, desc, bytes.NewReader(manifestJSON)); err != nil {
+		t.Fatalf("failed to push test content: %v", err)
+	}
+	desc.ArtifactType = artifactType
+	return desc
+}
+
+// flattenReferrerTree returns the artifact types of the nodes in the referrers
+// tree in depth-first order, sorted by artifact type at each level, where the depth of a node is denoted by the
+// number of the leading dots.
+func flattenReferrerTree(node *oras.ReferrerNode, depth int) []string {
+	referrers := slices.Clone(node.Referrers)
+	slices.SortFunc(referrers, func(a, b *oras.ReferrerNode) int {
+		return strings.Compare(a.Descriptor.ArtifactType, b.Descriptor.ArtifactType)
+	})
+	var got []string
+	for _, referrer := range referrers {
+		prefix := string(bytes.Repeat([]byte("."), depth))
+		got = append(got, prefix+referrer.Descriptor.ArtifactType)
+		got = append(got, flattenReferrerTree(referrer, depth+1)...)
+	}
+	return got
+}
+
+func TestDiscover(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+	if err := s.Push(ctx, ocispec.DescriptorEmptyJSON, bytes.NewReader(ocispec.DescriptorEmptyJSON.Data)); err != nil {
+		t.Fatal(err)
+	}
+	subject := pushReferrer(t, ctx, s, ocispec.DescriptorEmptyJSON, "application/vnd.test.image")
+	sbom := pushReferrer(t, ctx, s, subject, "sbom")
+	pushReferrer(t, ctx, s, sbom, "signature")
+	attestation := pushReferrer(t, ctx, s, subject, "attestation")
+	signature := pushReferrer(t, ctx, s, attestation, "signature")
+	pushReferrer(t, ctx, s, signature, "timestamp")
+
+	tests := []struct {
+		name string
+		opts oras.DiscoverOptions
+		want []string
+	}{
+		{
+			name: "full tree",
+			opts: oras.DefaultDiscoverOptions,
+			want: []string{".attestation", "..signature", "...timestamp", ".sbom", "..signature"},
+		},
+		{
+			name: "depth",
+			opts: oras.DiscoverOptions{Depth: 2},
+			want: []string{".attestation", "..signature", ".sbom", "..signature"},
+		},
+		{
+			name: "artifact types",
+			opts: oras.DiscoverOptions{ArtifactTypes: []string{"attestation", ""}},
+			want: []string{".attestation", "..signature", "...timestamp"},
+		},
+		{
+			name: "artifact types at deeper levels",
+			opts: oras.DiscoverOptions{ArtifactTypes: []string{"", "", "none"}},
+			want: []string{".attestation", "..signature", ".sbom", "..signature"},
+		},
+		{
+			name: "FindPredecessors",
+			opts: func() oras.DiscoverOptions {
+				var extendedOpts oras.ExtendedCopyGraphOptions
+				extendedOpts.FilterArtifactType(regexp.MustCompile("^s"))
+				return oras.DiscoverOptions{
+					FindPredecessors: extendedOpts.FindPredecessors,
+				}
+			}(),
+			want: []string{".sbom", "..signature"},
+		},
+		{
+			name: "FindPredecessors with artifact types",
+			opts: oras.DiscoverOptions{
+				ArtifactTypes: []string{"attestation", "signature", "timestamp"},
+				FindPredecessors: func(ctx context.Context, src content.ReadOnlyGraphStorage, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+					predecessors, err := src.Predecessors(ctx, desc)
+					for i := range predecessors {
+						// artifact types are fetched if not present
+						predecessors[i].ArtifactType = ""
+					}
+					return predecessors, err
+				},
+			},
+			want: []string{".attestation", "..signature", "...timestamp"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := oras.Discover(ctx, s, subject, tt.opts)
+			if err != nil {
+				t.Fatalf("Discover() error = %v", err)
+			}
+			if !content.Equal(root.Descriptor, subject) {
+				t.Errorf("Discover() root = %v, want %v", root.Descriptor, subject)
+			}
+			got := flattenReferrerTree(root, 1)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Discover() tree = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Discover() tree = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+
+	// errors are propagated
+	errTest := errors.New("test")
+	_, err := oras.Discover(ctx, s, subject, oras.DiscoverOptions{
+		FindPredecessors: func(ctx context.Context, src content.ReadOnlyGraphStorage, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+			return nil, errTest
+		},
+	})
+	if !errors.Is(err, errTest) {
+		t.Errorf("Discover() error = %v, wantErr %v", err, errTest)
+	}
+	_, err = oras.Discover(ctx, s, ocispec.DescriptorEmptyJSON, oras.DefaultDiscoverOptions)
+	if !errors.Is(err, errdef.ErrUnsupported) {
+		t.Errorf("Discover() error = %v, wantErr %v", err, errdef.ErrUnsupported)
+	}
+}
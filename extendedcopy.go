@@ -131,6 +131,7 @@ func ExtendedCopyGraph(ctx context.Context, src content.ReadOnlyGraphStorage, ds
 	proxy := cas.NewProxyWithLimit(src, cas.NewMemory(), opts.MaxMetadataBytes)
 	// track content status
 	tracker := status.NewTracker()
+	progress := newProgressTracker(opts.CopyGraphOptions)
 
 	// copy the sub-DAGs rooted by the root nodes
 	return syncutil.Go(ctx, limiter, func(ctx context.Context, region *syncutil.LimitedRegion, root ocispec.Descriptor) error {
@@ -138,7 +139,7 @@ func ExtendedCopyGraph(ctx context.Context, src content.ReadOnlyGraphStorage, ds
 		// for dispatching, to avoid dead locks where predecessor roots are
 		// handled first and are waiting for its successors to complete.
 		region.End()
-		if err := copyGraph(ctx, src, dst, root, proxy, limiter, tracker, opts.CopyGraphOptions); err != nil {
+		if err := copyGraph(ctx, src, dst, root, proxy, limiter, tracker, progress, opts.CopyGraphOptions); err != nil {
 			return err
 		}
 		return region.Start()
@@ -397,6 +398,9 @@ func fetchArtifactType(ctx context.Context, src content.ReadOnlyGraphStorage, de
 		if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
 			return "", err
 		}
+		if manifest.ArtifactType != "" {
+			return manifest.ArtifactType, nil
+		}
 		return manifest.Config.MediaType, nil
 	default:
 		return "", nil
@@ -21,4 +21,7 @@ const (
 	MediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
 	MediaTypeManifest     = "application/vnd.docker.distribution.manifest.v2+json"
 	MediaTypeForeignLayer = "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"
+
+	MediaTypeLayer             = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	MediaTypeUncompressedLayer = "application/vnd.docker.image.rootfs.diff.tar"
 )
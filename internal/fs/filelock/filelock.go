@@ -0,0 +1,49 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package filelock provides advisory file locks shared across processes.
+package filelock
+
+import (
+	"fmt"
+	"os"
+)
+
+// Lock opens the file at path, creating it if it does not exist, and acquires
+// an exclusive lock on it, blocking until the lock is acquired.
+// The returned file should be passed to Unlock to release the lock.
+//
+// The lock is advisory, which only excludes the other processes acquiring the
+// lock on the same file with Lock.
+func Lock(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lock(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Unlock releases the lock acquired by Lock and closes the file.
+func Unlock(f *os.File) error {
+	if err := unlock(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to unlock %s: %w", f.Name(), err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,35 @@
+//go:build !unix && !windows
+
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filelock
+
+import (
+	"errors"
+	"os"
+)
+
+// lock returns errors.ErrUnsupported as file locking is not supported on the
+// platform.
+func lock(*os.File) error {
+	return errors.ErrUnsupported
+}
+
+// unlock returns errors.ErrUnsupported as file locking is not supported on
+// the platform.
+func unlock(*os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,59 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filelock
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	f, err := Lock(path)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	var locked atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f, err := Lock(path)
+		if err != nil {
+			t.Errorf("Lock() error = %v", err)
+			return
+		}
+		locked.Store(true)
+		if err := Unlock(f); err != nil {
+			t.Errorf("Unlock() error = %v", err)
+		}
+	}()
+
+	// the second lock blocks until the first one is released
+	time.Sleep(100 * time.Millisecond)
+	if locked.Load() {
+		t.Fatal("Lock() acquired a held lock")
+	}
+	if err := Unlock(f); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	<-done
+	if !locked.Load() {
+		t.Error("Lock() failed to acquire a released lock")
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package reflink clones files with reflinks, where the cloned file shares
+// the data blocks with the source file until either of them is modified.
+package reflink
+
+import (
+	"fmt"
+	"os"
+)
+
+// Clone creates the file at dst as a reflink of the file at src, with the
+// permissions of src.
+// Returns a wrapped errors.ErrUnsupported if reflinks are not supported by
+// the platform or the file system, or if src and dst are on different file
+// systems.
+func Clone(src, dst string) (cloneErr error) {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	info, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := dstFile.Close(); err != nil && cloneErr == nil {
+			cloneErr = err
+		}
+		if cloneErr != nil {
+			os.Remove(dst)
+		}
+	}()
+	if err := clone(srcFile, dstFile); err != nil {
+		return fmt.Errorf("failed to clone %s to %s: %w", src, dst, err)
+	}
+	return dstFile.Chmod(info.Mode().Perm())
+}
@@ -0,0 +1,65 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reflink
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl request, i.e. _IOW(0x94, 9, int).
+const ficlone = 0x40049409
+
+// clone clones src into dst with the FICLONE ioctl.
+func clone(src, dst *os.File) error {
+	srcConn, err := src.SyscallConn()
+	if err != nil {
+		return err
+	}
+	dstConn, err := dst.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	var dstErr error
+	if err := srcConn.Control(func(srcFd uintptr) {
+		dstErr = dstConn.Control(func(dstFd uintptr) {
+			for {
+				_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, dstFd, ficlone, srcFd)
+				if errno != syscall.EINTR {
+					return
+				}
+			}
+		})
+	}); err != nil {
+		return err
+	}
+	if dstErr != nil {
+		return dstErr
+	}
+
+	switch errno {
+	case 0:
+		return nil
+	case syscall.EOPNOTSUPP, syscall.ENOTTY, syscall.EXDEV, syscall.EINVAL:
+		// the file system does not support reflinks
+		return fmt.Errorf("%w: %w", errors.ErrUnsupported, errno)
+	default:
+		return errno
+	}
+}
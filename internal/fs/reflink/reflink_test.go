@@ -0,0 +1,65 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reflink
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+	content := []byte("hello world")
+	if err := os.WriteFile(src, content, 0444); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(tempDir, "dst")
+
+	err := Clone(src, dst)
+	if errors.Is(err, errors.ErrUnsupported) {
+		// the dst file is not left behind
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			t.Errorf("Clone() left %s behind: %v", dst, err)
+		}
+		t.Skip("reflinks are not supported:", err)
+	}
+	if err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("cloned content = %s, want %s", got, content)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0444 {
+		t.Errorf("cloned file mode = %v, want %v", perm, os.FileMode(0444))
+	}
+
+	// cloning to an existing file fails
+	if err := Clone(src, dst); !errors.Is(err, os.ErrExist) {
+		t.Errorf("Clone() error = %v, wantErr %v", err, os.ErrExist)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tarfs
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"time"
+)
+
+// Writer writes regular files to a tar archive in a reproducible way.
+// All entries are owned by root with the Unix epoch as the modification time,
+// and the parent directories of each file are written as needed before the
+// file.
+type Writer struct {
+	tw   *tar.Writer
+	dirs map[string]struct{}
+}
+
+// NewWriter creates a new Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		tw:   tar.NewWriter(w),
+		dirs: make(map[string]struct{}),
+	}
+}
+
+// WriteFile writes a regular file with the given name and size, where the
+// content is read from r.
+// Returns an error if r does not provide exactly size bytes.
+func (w *Writer) WriteFile(name string, size int64, r io.Reader) error {
+	if err := w.writeDir(path.Dir(name)); err != nil {
+		return err
+	}
+	if err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  time.Unix(0, 0),
+	}); err != nil {
+		return fmt.Errorf("failed to write header of %s: %w", name, err)
+	}
+	n, err := io.Copy(w.tw, io.LimitReader(r, size))
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if n != size {
+		return fmt.Errorf("failed to write %s: %w", name, io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+// writeDir writes the directory with the given name and its parents if they
+// are not written yet.
+func (w *Writer) writeDir(name string) error {
+	if name == "." || name == "/" {
+		return nil
+	}
+	if _, ok := w.dirs[name]; ok {
+		return nil
+	}
+	if err := w.writeDir(path.Dir(name)); err != nil {
+		return err
+	}
+	if err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0755,
+		ModTime:  time.Unix(0, 0),
+	}); err != nil {
+		return fmt.Errorf("failed to write header of %s: %w", name, err)
+	}
+	w.dirs[name] = struct{}{}
+	return nil
+}
+
+// Close closes the tar archive by writing the footer.
+// It does not close the underlying writer.
+func (w *Writer) Close() error {
+	return w.tw.Close()
+}
@@ -0,0 +1,97 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	files := []struct {
+		name    string
+		content string
+	}{
+		{name: "foo", content: "hello"},
+		{name: "dir/subdir/bar", content: "world"},
+		{name: "dir/baz", content: ""},
+	}
+	for _, f := range files {
+		if err := w.WriteFile(f.name, int64(len(f.content)), strings.NewReader(f.content)); err != nil {
+			t.Fatalf("Writer.WriteFile() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar.Reader.Next() error = %v", err)
+		}
+		names = append(names, header.Name)
+		if !header.ModTime.Equal(time.Unix(0, 0)) || header.Uid != 0 || header.Gid != 0 {
+			t.Errorf("header of %s is not reproducible: %v", header.Name, header)
+		}
+	}
+	want := []string{"foo", "dir/", "dir/subdir/", "dir/subdir/bar", "dir/baz"}
+	if !slices.Equal(names, want) {
+		t.Errorf("entries = %v, want %v", names, want)
+	}
+
+	// the archive can be read by TarFS
+	path := filepath.Join(t.TempDir(), "test.tar")
+	if err := os.WriteFile(path, buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	tfs, err := New(path)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for _, f := range files {
+		got, err := fs.ReadFile(tfs, f.name)
+		if err != nil {
+			t.Fatalf("TarFS.ReadFile(%s) error = %v", f.name, err)
+		}
+		if string(got) != f.content {
+			t.Errorf("TarFS.ReadFile(%s) = %s, want %s", f.name, got, f.content)
+		}
+	}
+}
+
+func TestWriter_UnexpectedEOF(t *testing.T) {
+	w := NewWriter(io.Discard)
+	if err := w.WriteFile("foo", 10, strings.NewReader("hello")); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Writer.WriteFile() error = %v, wantErr %v", err, io.ErrUnexpectedEOF)
+	}
+}
@@ -99,17 +99,7 @@ func SelectManifest(ctx context.Context, src content.ReadOnlyStorage, root ocisp
 		}
 		return ocispec.Descriptor{}, fmt.Errorf("%s: %w: no matching manifest was found in the manifest list", root.Digest, errdef.ErrNotFound)
 	case docker.MediaTypeManifest, ocispec.MediaTypeImageManifest:
-		// config will be non-nil for docker manifest and OCI image manifest
-		config, err := manifestutil.Config(ctx, src, root)
-		if err != nil {
-			return ocispec.Descriptor{}, err
-		}
-
-		configMediaType := docker.MediaTypeConfig
-		if root.MediaType == ocispec.MediaTypeImageManifest {
-			configMediaType = ocispec.MediaTypeImageConfig
-		}
-		cfgPlatform, err := getPlatformFromConfig(ctx, src, *config, configMediaType)
+		cfgPlatform, err := ManifestPlatform(ctx, src, root)
 		if err != nil {
 			return ocispec.Descriptor{}, err
 		}
@@ -123,6 +113,25 @@ func SelectManifest(ctx context.Context, src content.ReadOnlyStorage, root ocisp
 	}
 }
 
+// ManifestPlatform returns the platform of the image manifest described by
+// desc, which is made up from the fields in its config blob.
+func ManifestPlatform(ctx context.Context, src content.ReadOnlyStorage, desc ocispec.Descriptor) (*ocispec.Platform, error) {
+	// config will be non-nil for docker manifest and OCI image manifest
+	config, err := manifestutil.Config(ctx, src, desc)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("%s: %s: %w", desc.Digest, desc.MediaType, errdef.ErrUnsupported)
+	}
+
+	configMediaType := docker.MediaTypeConfig
+	if desc.MediaType == ocispec.MediaTypeImageManifest {
+		configMediaType = ocispec.MediaTypeImageConfig
+	}
+	return getPlatformFromConfig(ctx, src, *config, configMediaType)
+}
+
 // getPlatformFromConfig returns a platform object which is made up from the
 // fields in config blob.
 func getPlatformFromConfig(ctx context.Context, src content.ReadOnlyStorage, desc ocispec.Descriptor, targetConfigMediaType string) (*ocispec.Platform, error) {
@@ -19,6 +19,7 @@ import (
 	"context"
 	"fmt"
 	"maps"
+	"slices"
 	"sync"
 
 	"github.com/opencontainers/go-digest"
@@ -31,18 +32,24 @@ import (
 type Memory struct {
 	lock  sync.RWMutex
 	index map[string]ocispec.Descriptor
-	tags  map[digest.Digest]set.Set[string]
+	// groups maps the references tagged on multiple descriptors to the
+	// descriptors, where the first one is also recorded in index.
+	groups map[string][]ocispec.Descriptor
+	tags   map[digest.Digest]set.Set[string]
 }
 
 // NewMemory creates a new Memory resolver.
 func NewMemory() *Memory {
 	return &Memory{
-		index: make(map[string]ocispec.Descriptor),
-		tags:  make(map[digest.Digest]set.Set[string]),
+		index:  make(map[string]ocispec.Descriptor),
+		groups: make(map[string][]ocispec.Descriptor),
+		tags:   make(map[digest.Digest]set.Set[string]),
 	}
 }
 
 // Resolve resolves a reference to a descriptor.
+// If the reference is tagged on multiple descriptors, the first one is
+// returned.
 func (m *Memory) Resolve(_ context.Context, reference string) (ocispec.Descriptor, error) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -54,40 +61,96 @@ func (m *Memory) Resolve(_ context.Context, reference string) (ocispec.Descripto
 	return desc, nil
 }
 
-// Tag tags a descriptor with a reference string.
+// ResolveAll resolves a reference to all the descriptors tagged with it.
+func (m *Memory) ResolveAll(_ context.Context, reference string) ([]ocispec.Descriptor, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if descs, ok := m.groups[reference]; ok {
+		return slices.Clone(descs), nil
+	}
+	desc, ok := m.index[reference]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", reference, errdef.ErrNotFound)
+	}
+	return []ocispec.Descriptor{desc}, nil
+}
+
+// Tag tags a descriptor with a reference string, replacing the descriptors
+// previously tagged with the reference.
 func (m *Memory) Tag(_ context.Context, desc ocispec.Descriptor, reference string) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	m.index[reference] = desc
-	tagSet, ok := m.tags[desc.Digest]
-	if !ok {
-		tagSet = set.New[string]()
-		m.tags[desc.Digest] = tagSet
+	m.tag([]ocispec.Descriptor{desc}, reference)
+	return nil
+}
+
+// TagAll tags the descriptors with a reference string, replacing the
+// descriptors previously tagged with the reference.
+// TagAll is a no-op if descs is empty.
+func (m *Memory) TagAll(_ context.Context, descs []ocispec.Descriptor, reference string) error {
+	if len(descs) == 0 {
+		return nil
 	}
-	tagSet.Add(reference)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.tag(descs, reference)
 	return nil
 }
 
+// tag tags the non-empty descs with the reference.
+func (m *Memory) tag(descs []ocispec.Descriptor, reference string) {
+	m.untag(reference)
+	m.index[reference] = descs[0]
+	if len(descs) > 1 {
+		m.groups[reference] = slices.Clone(descs)
+	}
+	for _, desc := range descs {
+		tagSet, ok := m.tags[desc.Digest]
+		if !ok {
+			tagSet = set.New[string]()
+			m.tags[desc.Digest] = tagSet
+		}
+		tagSet.Add(reference)
+	}
+}
+
 // Untag removes a reference from index map.
 func (m *Memory) Untag(reference string) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
+	m.untag(reference)
+}
+
+// untag removes a reference from index map.
+func (m *Memory) untag(reference string) {
 	desc, ok := m.index[reference]
 	if !ok {
 		return
 	}
+	descs, ok := m.groups[reference]
+	if !ok {
+		descs = []ocispec.Descriptor{desc}
+	}
 	delete(m.index, reference)
-	tagSet := m.tags[desc.Digest]
-	tagSet.Delete(reference)
-	if len(tagSet) == 0 {
-		delete(m.tags, desc.Digest)
+	delete(m.groups, reference)
+	for _, desc := range descs {
+		tagSet := m.tags[desc.Digest]
+		tagSet.Delete(reference)
+		if len(tagSet) == 0 {
+			delete(m.tags, desc.Digest)
+		}
 	}
 }
 
 // Map dumps the memory into a built-in map structure.
 // Like other operations, calling Map() is go-routine safe.
+// If a reference is tagged on multiple descriptors, only the first one is
+// dumped.
 func (m *Memory) Map() map[string]ocispec.Descriptor {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -95,6 +158,23 @@ func (m *Memory) Map() map[string]ocispec.Descriptor {
 	return maps.Clone(m.index)
 }
 
+// MapAll dumps the memory into a built-in map structure, mapping each
+// reference to all the descriptors tagged with it.
+func (m *Memory) MapAll() map[string][]ocispec.Descriptor {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	refs := make(map[string][]ocispec.Descriptor, len(m.index))
+	for ref, desc := range m.index {
+		if descs, ok := m.groups[ref]; ok {
+			refs[ref] = slices.Clone(descs)
+		} else {
+			refs[ref] = []ocispec.Descriptor{desc}
+		}
+	}
+	return refs
+}
+
 // TagSet returns the set of tags of the descriptor.
 func (m *Memory) TagSet(desc ocispec.Descriptor) set.Set[string] {
 	m.lock.RLock()
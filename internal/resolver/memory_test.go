@@ -106,3 +106,66 @@ func TestTagSet(t *testing.T) {
 		t.Fatalf("expect size = %d, got %d", 2, len(tagSet))
 	}
 }
+
+func TestMemory_TagAll(t *testing.T) {
+	s := NewMemory()
+	ctx := context.Background()
+	ref := "foobar"
+
+	var descs []ocispec.Descriptor
+	for _, content := range []string{"foo", "bar"} {
+		descs = append(descs, ocispec.Descriptor{
+			MediaType: "test",
+			Digest:    digest.FromString(content),
+			Size:      int64(len(content)),
+		})
+	}
+	if err := s.TagAll(ctx, descs, ref); err != nil {
+		t.Fatal("Memory.TagAll() error =", err)
+	}
+	got, err := s.ResolveAll(ctx, ref)
+	if err != nil {
+		t.Fatal("Memory.ResolveAll() error =", err)
+	}
+	if !reflect.DeepEqual(got, descs) {
+		t.Errorf("Memory.ResolveAll() = %v, want %v", got, descs)
+	}
+	desc, err := s.Resolve(ctx, ref)
+	if err != nil {
+		t.Fatal("Memory.Resolve() error =", err)
+	}
+	if !reflect.DeepEqual(desc, descs[0]) {
+		t.Errorf("Memory.Resolve() = %v, want %v", desc, descs[0])
+	}
+	if got := s.MapAll(); !reflect.DeepEqual(got, map[string][]ocispec.Descriptor{ref: descs}) {
+		t.Errorf("Memory.MapAll() = %v, want %v", got, descs)
+	}
+	for _, desc := range descs {
+		if !s.TagSet(desc).Contains(ref) {
+			t.Errorf("Memory.TagSet(%v) should contain %s", desc, ref)
+		}
+	}
+
+	// tagging a single descriptor replaces the descriptors
+	if err := s.Tag(ctx, descs[1], ref); err != nil {
+		t.Fatal("Memory.Tag() error =", err)
+	}
+	got, err = s.ResolveAll(ctx, ref)
+	if err != nil {
+		t.Fatal("Memory.ResolveAll() error =", err)
+	}
+	if want := descs[1:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Memory.ResolveAll() = %v, want %v", got, want)
+	}
+	if tagSet := s.TagSet(descs[0]); len(tagSet) != 0 {
+		t.Errorf("Memory.TagSet(%v) = %v, want empty", descs[0], tagSet)
+	}
+
+	s.Untag(ref)
+	if _, err := s.ResolveAll(ctx, ref); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Memory.ResolveAll() error = %v, want %v", err, errdef.ErrNotFound)
+	}
+	if tagSet := s.TagSet(descs[1]); len(tagSet) != 0 {
+		t.Errorf("Memory.TagSet(%v) = %v, want empty", descs[1], tagSet)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mutate edits existing manifests and indexes in a content storage.
+//
+// Instead of packing a new manifest from scratch, [Apply] fetches the manifest
+// or the index, applies the edits, pushes the changed nodes and returns the
+// descriptor of the new root. The original nodes are left untouched.
+package mutate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/docker"
+)
+
+// Options contains the edits to be applied by [Apply].
+// Edits with zero values are not applied.
+type Options struct {
+	// AppendLayers are the layers to be appended to the manifest.
+	// The layers must exist in the target before calling Apply.
+	// If the root is an index, the layers are appended to the selected
+	// manifests of the index.
+	AppendLayers []ocispec.Descriptor
+
+	// RemoveLayers removes the layers of the manifest for which it returns
+	// true. Layers are removed before AppendLayers are appended.
+	// If the root is an index, the layers are removed from the selected
+	// manifests of the index.
+	//
+	// Note that the diff IDs of image configs are not updated accordingly.
+	// Use Config to replace the config if needed.
+	RemoveLayers func(layer ocispec.Descriptor) bool
+
+	// Config replaces the config of the manifest.
+	// The config must exist in the target before calling Apply.
+	// If the root is an index, the config of the selected manifests of the
+	// index are replaced.
+	Config *ocispec.Descriptor
+
+	// Annotations are merged into the annotations of the root, where a key
+	// with an empty value removes the annotation.
+	Annotations map[string]string
+
+	// ArtifactType replaces the artifactType of the root, where an empty
+	// value removes the artifactType.
+	// Docker manifests and manifest lists do not support artifactType.
+	ArtifactType *string
+
+	// Subject sets the subject of the root.
+	// Docker manifests and manifest lists do not support subject.
+	Subject *ocispec.Descriptor
+
+	// SelectManifest selects the manifests of an index to which the layer
+	// and config edits are applied, and can be used to skip manifests such
+	// as attestations.
+	// The edits are applied recursively to the nested indexes selected.
+	// If SelectManifest is nil, all the manifests are selected.
+	SelectManifest func(desc ocispec.Descriptor) bool
+}
+
+// hasManifestEdits returns true if opts contains edits to be applied to
+// the manifests of an index.
+func (opts *Options) hasManifestEdits() bool {
+	return len(opts.AppendLayers) > 0 || opts.RemoveLayers != nil || opts.Config != nil
+}
+
+// Apply applies the edits in opts to the manifest or the index described by
+// root in target, pushes the changed nodes to target, and returns the
+// descriptor of the new root.
+//
+// If root is an index, the layer and config edits are applied to the selected
+// manifests of the index, and the index is updated to point to the new
+// manifests. The annotation, artifactType and subject edits are applied to
+// the root only.
+//
+// The new root is not tagged. Use oras.Tag or the Tag method of the target
+// to tag it.
+//
+// The edited nodes are decoded into and re-encoded from the structures
+// defined by the OCI image spec, so any properties not defined by the spec
+// are not kept in the new nodes.
+//
+// Returns errdef.ErrNotFound if any of the layers to be appended or the new
+// config does not exist in target.
+func Apply(ctx context.Context, target content.Storage, root ocispec.Descriptor, opts Options) (ocispec.Descriptor, error) {
+	if err := checkBlobs(ctx, target, opts); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	switch root.MediaType {
+	case docker.MediaTypeManifest, ocispec.MediaTypeImageManifest:
+		return applyManifest(ctx, target, root, opts, true)
+	case docker.MediaTypeManifestList, ocispec.MediaTypeImageIndex:
+		return applyIndex(ctx, target, root, opts, true)
+	default:
+		return ocispec.Descriptor{}, fmt.Errorf("%s: %s: %w", root.Digest, root.MediaType, errdef.ErrUnsupported)
+	}
+}
+
+// checkBlobs checks that the layers to be appended and the new config in
+// opts exist in target.
+func checkBlobs(ctx context.Context, target content.ReadOnlyStorage, opts Options) error {
+	blobs := opts.AppendLayers
+	if opts.Config != nil {
+		blobs = append(slices.Clip(blobs), *opts.Config)
+	}
+	for _, blob := range blobs {
+		exists, err := target.Exists(ctx, blob)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%s: %s: %w", blob.Digest, blob.MediaType, errdef.ErrNotFound)
+		}
+	}
+	return nil
+}
+
+// applyManifest applies the edits to the manifest described by desc.
+// The annotation, artifactType and subject edits are applied only if isRoot
+// is true.
+func applyManifest(ctx context.Context, target content.Storage, desc ocispec.Descriptor, opts Options, isRoot bool) (ocispec.Descriptor, error) {
+	manifestJSON, err := content.FetchAll(ctx, target, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to decode manifest %s: %w", desc.Digest, err)
+	}
+
+	if opts.RemoveLayers != nil {
+		manifest.Layers = slices.DeleteFunc(manifest.Layers, opts.RemoveLayers)
+	}
+	manifest.Layers = append(manifest.Layers, opts.AppendLayers...)
+	if manifest.Layers == nil {
+		// layers is a required property
+		manifest.Layers = []ocispec.Descriptor{}
+	}
+	if opts.Config != nil {
+		manifest.Config = *opts.Config
+	}
+	if isRoot {
+		if err := applyRootEdits(desc, opts, &manifest.Annotations, &manifest.ArtifactType, &manifest.Subject); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+	return pushNode(ctx, target, desc.MediaType, manifest, manifest.ArtifactType, manifest.Annotations)
+}
+
+// applyIndex applies the edits to the index described by desc, and
+// propagates the changes of its manifests.
+// The annotation, artifactType and subject edits are applied only if isRoot
+// is true.
+func applyIndex(ctx context.Context, target content.Storage, desc ocispec.Descriptor, opts Options, isRoot bool) (ocispec.Descriptor, error) {
+	indexJSON, err := content.FetchAll(ctx, target, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(indexJSON, &index); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to decode index %s: %w", desc.Digest, err)
+	}
+
+	if opts.hasManifestEdits() {
+		for i, child := range index.Manifests {
+			if opts.SelectManifest != nil && !opts.SelectManifest(child) {
+				continue
+			}
+			var newChild ocispec.Descriptor
+			switch child.MediaType {
+			case docker.MediaTypeManifest, ocispec.MediaTypeImageManifest:
+				newChild, err = applyManifest(ctx, target, child, opts, false)
+			case docker.MediaTypeManifestList, ocispec.MediaTypeImageIndex:
+				newChild, err = applyIndex(ctx, target, child, opts, false)
+			default:
+				continue
+			}
+			if err != nil {
+				return ocispec.Descriptor{}, err
+			}
+			// keep the platform and the annotations of the original entry
+			index.Manifests[i].Digest = newChild.Digest
+			index.Manifests[i].Size = newChild.Size
+		}
+	}
+	if isRoot {
+		if err := applyRootEdits(desc, opts, &index.Annotations, &index.ArtifactType, &index.Subject); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+	return pushNode(ctx, target, desc.MediaType, index, index.ArtifactType, index.Annotations)
+}
+
+// applyRootEdits applies the annotation, artifactType and subject edits to
+// the root described by desc.
+func applyRootEdits(desc ocispec.Descriptor, opts Options, annotations *map[string]string, artifactType *string, subject **ocispec.Descriptor) error {
+	isDocker := desc.MediaType == docker.MediaTypeManifest || desc.MediaType == docker.MediaTypeManifestList
+	if opts.ArtifactType != nil {
+		if isDocker && *opts.ArtifactType != "" {
+			return fmt.Errorf("%s: %s: artifactType: %w", desc.Digest, desc.MediaType, errdef.ErrUnsupported)
+		}
+		*artifactType = *opts.ArtifactType
+	}
+	if opts.Subject != nil {
+		if isDocker {
+			return fmt.Errorf("%s: %s: subject: %w", desc.Digest, desc.MediaType, errdef.ErrUnsupported)
+		}
+		*subject = opts.Subject
+	}
+	if len(opts.Annotations) > 0 {
+		*annotations = mergeAnnotations(*annotations, opts.Annotations)
+	}
+	return nil
+}
+
+// mergeAnnotations returns a new annotation map with the edits merged into
+// annotations, where a key with an empty value removes the annotation.
+func mergeAnnotations(annotations, edits map[string]string) map[string]string {
+	merged := maps.Clone(annotations)
+	if merged == nil {
+		merged = make(map[string]string, len(edits))
+	}
+	for k, v := range edits {
+		if v == "" {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// pushNode marshals node into JSON bytes and pushes it to target.
+func pushNode(ctx context.Context, target content.Pusher, mediaType string, node any, artifactType string, annotations map[string]string) (ocispec.Descriptor, error) {
+	nodeJSON, err := json.Marshal(node)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to marshal %s: %w", mediaType, err)
+	}
+	desc := content.NewDescriptorFromBytes(mediaType, nodeJSON)
+	desc.ArtifactType = artifactType
+	desc.Annotations = annotations
+	if err := target.Push(ctx, desc, bytes.NewReader(nodeJSON)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to push %s: %w", desc.Digest, err)
+	}
+	return desc, nil
+}
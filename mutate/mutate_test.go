@@ -0,0 +1,319 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mutate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/docker"
+)
+
+// pushBlob pushes a blob to the storage and returns its descriptor.
+func pushBlob(t *testing.T, ctx context.Context, s content.Storage, mediaType string, blob []byte) ocispec.Descriptor {
+	t.Helper()
+	desc := content.NewDescriptorFromBytes(mediaType, blob)
+	if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		t.Fatalf("failed to push test content: %v", err)
+	}
+	return desc
+}
+
+// pushJSON marshals v and pushes it to the storage.
+func pushJSON(t *testing.T, ctx context.Context, s content.Storage, mediaType string, v any) ocispec.Descriptor {
+	t.Helper()
+	blob, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pushBlob(t, ctx, s, mediaType, blob)
+}
+
+// fetchManifest fetches and decodes the manifest described by desc.
+func fetchManifest(t *testing.T, ctx context.Context, s content.Fetcher, desc ocispec.Descriptor) ocispec.Manifest {
+	t.Helper()
+	manifestJSON, err := content.FetchAll(ctx, s, desc)
+	if err != nil {
+		t.Fatalf("failed to fetch manifest: %v", err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	return manifest
+}
+
+func TestApply_Manifest(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+	config := pushBlob(t, ctx, s, ocispec.MediaTypeImageConfig, []byte("{}"))
+	foo := pushBlob(t, ctx, s, ocispec.MediaTypeImageLayer, []byte("foo"))
+	bar := pushBlob(t, ctx, s, ocispec.MediaTypeImageLayer, []byte("bar"))
+	root := pushJSON(t, ctx, s, ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{foo, bar},
+		Annotations: map[string]string{
+			"keep":   "value",
+			"remove": "value",
+		},
+	})
+	subject := pushBlob(t, ctx, s, ocispec.MediaTypeImageManifest, []byte(`{"layers":[]}`))
+	baz := pushBlob(t, ctx, s, ocispec.MediaTypeImageLayer, []byte("baz"))
+	newConfig := pushBlob(t, ctx, s, ocispec.MediaTypeImageConfig, []byte(`{"created":"2000-01-01T00:00:00Z"}`))
+	artifactType := "application/vnd.test"
+
+	got, err := Apply(ctx, s, root, Options{
+		AppendLayers: []ocispec.Descriptor{baz},
+		RemoveLayers: func(layer ocispec.Descriptor) bool {
+			return layer.Digest == foo.Digest
+		},
+		Config: &newConfig,
+		Annotations: map[string]string{
+			"remove":   "",
+			"build.id": "42",
+		},
+		ArtifactType: &artifactType,
+		Subject:      &subject,
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	wantAnnotations := map[string]string{
+		"keep":     "value",
+		"build.id": "42",
+	}
+	if got.MediaType != ocispec.MediaTypeImageManifest || got.ArtifactType != artifactType || !maps.Equal(got.Annotations, wantAnnotations) {
+		t.Errorf("Apply() = %v, want artifactType %s and annotations %v", got, artifactType, wantAnnotations)
+	}
+	manifest := fetchManifest(t, ctx, s, got)
+	if want := []ocispec.Descriptor{bar, baz}; !slices.EqualFunc(manifest.Layers, want, content.Equal) {
+		t.Errorf("manifest layers = %v, want %v", manifest.Layers, want)
+	}
+	if !content.Equal(manifest.Config, newConfig) {
+		t.Errorf("manifest config = %v, want %v", manifest.Config, newConfig)
+	}
+	if manifest.Subject == nil || !content.Equal(*manifest.Subject, subject) {
+		t.Errorf("manifest subject = %v, want %v", manifest.Subject, subject)
+	}
+	if manifest.ArtifactType != artifactType || !maps.Equal(manifest.Annotations, wantAnnotations) {
+		t.Errorf("manifest = %v, want artifactType %s and annotations %v", manifest, artifactType, wantAnnotations)
+	}
+
+	// the original manifest is untouched
+	original := fetchManifest(t, ctx, s, root)
+	if want := []ocispec.Descriptor{foo, bar}; !slices.EqualFunc(original.Layers, want, content.Equal) {
+		t.Errorf("original manifest layers = %v, want %v", original.Layers, want)
+	}
+
+	// removing all layers keeps the layers property
+	got, err = Apply(ctx, s, root, Options{
+		RemoveLayers: func(ocispec.Descriptor) bool { return true },
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	manifestJSON, err := content.FetchAll(ctx, s, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(manifestJSON, []byte(`"layers":[]`)) {
+		t.Errorf("manifest = %s, want empty layers", manifestJSON)
+	}
+}
+
+func TestApply_Index(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+	config := pushBlob(t, ctx, s, ocispec.MediaTypeImageConfig, []byte("{}"))
+	layer := pushBlob(t, ctx, s, ocispec.MediaTypeImageLayer, []byte("foo"))
+	amd64 := pushJSON(t, ctx, s, ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layer},
+	})
+	amd64.Platform = &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	attestation := pushJSON(t, ctx, s, ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{},
+	})
+	attestation.Platform = &ocispec.Platform{OS: "unknown", Architecture: "unknown"}
+	nested := pushJSON(t, ctx, s, ocispec.MediaTypeImageIndex, ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{amd64},
+	})
+	nested.Annotations = map[string]string{"nested": "true"}
+	root := pushJSON(t, ctx, s, ocispec.MediaTypeImageIndex, ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{amd64, attestation, nested},
+	})
+	added := pushBlob(t, ctx, s, ocispec.MediaTypeImageLayer, []byte("bar"))
+
+	got, err := Apply(ctx, s, root, Options{
+		AppendLayers: []ocispec.Descriptor{added},
+		Annotations:  map[string]string{"build.id": "42"},
+		SelectManifest: func(desc ocispec.Descriptor) bool {
+			return desc.Platform == nil || desc.Platform.OS != "unknown"
+		},
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got.Annotations["build.id"] != "42" {
+		t.Errorf("Apply() annotations = %v, want build.id", got.Annotations)
+	}
+	indexJSON, err := content.FetchAll(ctx, s, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(indexJSON, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 3 {
+		t.Fatalf("index manifests = %v, want 3 entries", index.Manifests)
+	}
+
+	// the selected manifest is updated with the platform kept
+	newAMD64 := index.Manifests[0]
+	if newAMD64.Digest == amd64.Digest || newAMD64.Platform == nil || newAMD64.Platform.Architecture != "amd64" {
+		t.Errorf("index.Manifests[0] = %v, want an updated amd64 manifest", newAMD64)
+	}
+	if want := []ocispec.Descriptor{layer, added}; !slices.EqualFunc(fetchManifest(t, ctx, s, newAMD64).Layers, want, content.Equal) {
+		t.Errorf("amd64 manifest layers do not match %v", want)
+	}
+	// the skipped manifest is untouched
+	if !content.Equal(index.Manifests[1], attestation) {
+		t.Errorf("index.Manifests[1] = %v, want %v", index.Manifests[1], attestation)
+	}
+	// the edits propagate through the nested index
+	newNested := index.Manifests[2]
+	if newNested.Digest == nested.Digest || newNested.Annotations["nested"] != "true" {
+		t.Errorf("index.Manifests[2] = %v, want an updated nested index", newNested)
+	}
+	nestedJSON, err := content.FetchAll(ctx, s, newNested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nestedIndex ocispec.Index
+	if err := json.Unmarshal(nestedJSON, &nestedIndex); err != nil {
+		t.Fatal(err)
+	}
+	if len(nestedIndex.Manifests) != 1 || nestedIndex.Manifests[0].Digest != newAMD64.Digest {
+		t.Errorf("nested index manifests = %v, want [%v]", nestedIndex.Manifests, newAMD64)
+	}
+	if len(nestedIndex.Annotations) != 0 {
+		t.Errorf("nested index annotations = %v, want none", nestedIndex.Annotations)
+	}
+}
+
+func TestApply_Unsupported(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+	config := pushBlob(t, ctx, s, docker.MediaTypeConfig, []byte("{}"))
+	manifest := pushJSON(t, ctx, s, docker.MediaTypeManifest, ocispec.Manifest{
+		MediaType: docker.MediaTypeManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{},
+	})
+	artifactType := "application/vnd.test"
+
+	tests := []struct {
+		name string
+		root ocispec.Descriptor
+		opts Options
+	}{
+		{
+			name: "docker manifest with artifactType",
+			root: manifest,
+			opts: Options{ArtifactType: &artifactType},
+		},
+		{
+			name: "docker manifest with subject",
+			root: manifest,
+			opts: Options{Subject: &manifest},
+		},
+		{
+			name: "blob",
+			root: config,
+			opts: Options{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Apply(ctx, s, tt.root, tt.opts); !errors.Is(err, errdef.ErrUnsupported) {
+				t.Errorf("Apply() error = %v, wantErr %v", err, errdef.ErrUnsupported)
+			}
+		})
+	}
+
+	// annotations are allowed on docker manifests
+	got, err := Apply(ctx, s, manifest, Options{Annotations: map[string]string{"foo": "bar"}})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got.MediaType != docker.MediaTypeManifest {
+		t.Errorf("Apply() media type = %s, want %s", got.MediaType, docker.MediaTypeManifest)
+	}
+}
+
+func TestApply_NotFound(t *testing.T) {
+	ctx := context.Background()
+	root := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, []byte("{}"))
+	if _, err := Apply(ctx, memory.New(), root, Options{}); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Apply() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+
+	// the appended layers and the new config must exist
+	s := memory.New()
+	config := pushBlob(t, ctx, s, ocispec.MediaTypeImageConfig, []byte("{}"))
+	root = pushJSON(t, ctx, s, ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{},
+	})
+	missing := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, []byte("missing"))
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{
+			name: "missing layer",
+			opts: Options{AppendLayers: []ocispec.Descriptor{missing}},
+		},
+		{
+			name: "missing config",
+			opts: Options{Config: &missing},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Apply(ctx, s, root, tt.opts); !errors.Is(err, errdef.ErrNotFound) {
+				t.Errorf("Apply() error = %v, wantErr %v", err, errdef.ErrNotFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oras
+
+import (
+	"context"
+	"errors"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/internal/cas"
+	"oras.land/oras-go/v2/internal/container/set"
+	"oras.land/oras-go/v2/internal/descriptor"
+	"oras.land/oras-go/v2/registry"
+)
+
+// CopyAction represents the action to be taken on a node by a copy.
+type CopyAction int
+
+const (
+	// CopyActionSkip indicates that the node exists in the destination, and
+	// the sub-DAG rooted by the node is not copied.
+	CopyActionSkip CopyAction = 1
+
+	// CopyActionMount indicates that the node is to be mounted from another
+	// repository of the destination. If mounting fails, the node is copied
+	// instead.
+	CopyActionMount CopyAction = 2
+
+	// CopyActionCopy indicates that the node is to be copied from the source
+	// to the destination.
+	CopyActionCopy CopyAction = 3
+)
+
+// String returns the string representation of the CopyAction.
+func (a CopyAction) String() string {
+	switch a {
+	case CopyActionSkip:
+		return "skip"
+	case CopyActionMount:
+		return "mount"
+	case CopyActionCopy:
+		return "copy"
+	default:
+		return "unknown"
+	}
+}
+
+// PlannedNode describes the action to be taken on a node by a copy.
+type PlannedNode struct {
+	// Descriptor is the descriptor of the node.
+	Descriptor ocispec.Descriptor
+	// Action is the action to be taken on the node.
+	Action CopyAction
+	// MountFrom is the candidate repositories that the node is to be mounted
+	// from, in the order of attempts. It is set only if Action is
+	// CopyActionMount.
+	MountFrom []string
+}
+
+// CopyPlan describes what a copy would do without performing it.
+type CopyPlan struct {
+	// Roots are the root nodes of the copy.
+	Roots []ocispec.Descriptor
+	// Nodes are the nodes visited by the copy in the order of completion,
+	// where the successors of a node always precede the node.
+	// The nodes in a skipped sub-DAG are not visited, and thus not listed,
+	// except for the root of the sub-DAG.
+	Nodes []PlannedNode
+	// CopyBytes is the total size of the nodes to be copied.
+	CopyBytes int64
+	// MountBytes is the total size of the nodes to be mounted.
+	MountBytes int64
+	// SkipBytes is the total size of the listed nodes to be skipped.
+	SkipBytes int64
+}
+
+// add adds a node to the plan.
+func (p *CopyPlan) add(node PlannedNode) {
+	p.Nodes = append(p.Nodes, node)
+	switch node.Action {
+	case CopyActionSkip:
+		p.SkipBytes += node.Descriptor.Size
+	case CopyActionMount:
+		p.MountBytes += node.Descriptor.Size
+	case CopyActionCopy:
+		p.CopyBytes += node.Descriptor.Size
+	}
+}
+
+// PlanCopy plans [oras.Copy] with the same parameters, without pushing or
+// tagging anything in the destination, which is only queried for the
+// existence of the nodes.
+//
+// The graph is walked as Copy does, using opts.MapRoot, opts.FindSuccessors
+// and opts.MountFrom if provided. The other hooks, such as opts.PreCopy and
+// opts.OnProgress, are not invoked.
+// Since a mount can only be attempted by performing it, the nodes to be
+// mounted may still be copied by Copy.
+func PlanCopy(ctx context.Context, src ReadOnlyTarget, srcRef string, dst content.ReadOnlyStorage, opts CopyOptions) (*CopyPlan, error) {
+	if src == nil {
+		return nil, newCopyError("PlanCopy", CopyErrorOriginSource, errors.New("nil source target"))
+	}
+	if dst == nil {
+		return nil, newCopyError("PlanCopy", CopyErrorOriginDestination, errors.New("nil destination target"))
+	}
+
+	if opts.MaxMetadataBytes <= 0 {
+		opts.MaxMetadataBytes = defaultCopyMaxMetadataBytes
+	}
+	proxy := cas.NewProxyWithLimit(src, cas.NewMemory(), opts.MaxMetadataBytes)
+	root, err := resolveRoot(ctx, src, srcRef, proxy)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MapRoot != nil {
+		proxy.StopCaching = true
+		root, err = opts.MapRoot(ctx, proxy, root)
+		if err != nil {
+			return nil, newCopyError("MapRoot", CopyErrorOriginSource, err)
+		}
+		proxy.StopCaching = false
+	}
+	if len(opts.TargetPlatforms) > 0 {
+		root, err = selectPlatforms(ctx, proxy, root, opts.TargetPlatforms)
+		if err != nil {
+			return nil, newCopyError("SelectPlatforms", CopyErrorOriginSource, err)
+		}
+	}
+	if opts.ConvertFormat != 0 {
+		var converted content.ReadOnlyStorage
+		converted, root, err = convertFormat(ctx, src, proxy, root, opts.ConvertFormat)
+		if err != nil {
+			return nil, err
+		}
+		proxy = cas.NewProxyWithLimit(converted, cas.NewMemory(), opts.MaxMetadataBytes)
+	}
+
+	plan := &CopyPlan{}
+	if err := planCopyGraph(ctx, dst, []ocispec.Descriptor{root}, proxy, plan, opts.CopyGraphOptions); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// PlanCopyGraph plans [oras.CopyGraph] with the same parameters, without
+// pushing anything to the destination, which is only queried for the
+// existence of the nodes.
+//
+// The graph is walked as CopyGraph does, using opts.FindSuccessors and
+// opts.MountFrom if provided. The other hooks, such as opts.PreCopy and
+// opts.OnProgress, are not invoked.
+func PlanCopyGraph(ctx context.Context, src content.ReadOnlyStorage, dst content.ReadOnlyStorage, root ocispec.Descriptor, opts CopyGraphOptions) (*CopyPlan, error) {
+	if src == nil {
+		return nil, newCopyError("PlanCopyGraph", CopyErrorOriginSource, errors.New("nil source target"))
+	}
+	if dst == nil {
+		return nil, newCopyError("PlanCopyGraph", CopyErrorOriginDestination, errors.New("nil destination target"))
+	}
+	plan := &CopyPlan{}
+	if err := planCopyGraph(ctx, dst, []ocispec.Descriptor{root}, newPlanProxy(src, &opts), plan, opts); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// PlanExtendedCopy plans [oras.ExtendedCopy] with the same parameters,
+// without pushing or tagging anything in the destination, which is only
+// queried for the existence of the nodes.
+//
+// The graph is walked as ExtendedCopy does, using opts.FindPredecessors,
+// opts.FindSuccessors and opts.MountFrom if provided. The other hooks, such
+// as opts.PreCopy and opts.OnProgress, are not invoked.
+func PlanExtendedCopy(ctx context.Context, src ReadOnlyGraphTarget, srcRef string, dst content.ReadOnlyStorage, opts ExtendedCopyOptions) (*CopyPlan, error) {
+	if src == nil {
+		return nil, newCopyError("PlanExtendedCopy", CopyErrorOriginSource, errors.New("nil source target"))
+	}
+	if dst == nil {
+		return nil, newCopyError("PlanExtendedCopy", CopyErrorOriginDestination, errors.New("nil destination target"))
+	}
+	node, err := src.Resolve(ctx, srcRef)
+	if err != nil {
+		return nil, newCopyError("Resolve", CopyErrorOriginSource, err)
+	}
+	return PlanExtendedCopyGraph(ctx, src, dst, node, opts.ExtendedCopyGraphOptions)
+}
+
+// PlanExtendedCopyGraph plans [oras.ExtendedCopyGraph] with the same
+// parameters, without pushing anything to the destination, which is only
+// queried for the existence of the nodes.
+//
+// The graph is walked as ExtendedCopyGraph does, using opts.FindPredecessors,
+// opts.FindSuccessors and opts.MountFrom if provided. The other hooks, such
+// as opts.PreCopy and opts.OnProgress, are not invoked.
+func PlanExtendedCopyGraph(ctx context.Context, src content.ReadOnlyGraphStorage, dst content.ReadOnlyStorage, node ocispec.Descriptor, opts ExtendedCopyGraphOptions) (*CopyPlan, error) {
+	if src == nil {
+		return nil, newCopyError("PlanExtendedCopyGraph", CopyErrorOriginSource, errors.New("nil source target"))
+	}
+	if dst == nil {
+		return nil, newCopyError("PlanExtendedCopyGraph", CopyErrorOriginDestination, errors.New("nil destination target"))
+	}
+	roots, err := findRoots(ctx, src, node, opts)
+	if err != nil {
+		return nil, err
+	}
+	plan := &CopyPlan{}
+	if err := planCopyGraph(ctx, dst, roots, newPlanProxy(src, &opts.CopyGraphOptions), plan, opts.CopyGraphOptions); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// newPlanProxy returns a caching proxy to the source storage for planning.
+func newPlanProxy(src content.ReadOnlyStorage, opts *CopyGraphOptions) *cas.Proxy {
+	if opts.MaxMetadataBytes <= 0 {
+		opts.MaxMetadataBytes = defaultCopyMaxMetadataBytes
+	}
+	return cas.NewProxyWithLimit(src, cas.NewMemory(), opts.MaxMetadataBytes)
+}
+
+// planCopyGraph walks the graphs rooted by the given roots in the same way as
+// copyGraph, and records the actions to be taken in the plan.
+func planCopyGraph(ctx context.Context, dst content.ReadOnlyStorage, roots []ocispec.Descriptor, proxy *cas.Proxy, plan *CopyPlan, opts CopyGraphOptions) error {
+	if opts.FindSuccessors == nil {
+		opts.FindSuccessors = content.Successors
+	}
+	_, canMount := dst.(registry.Mounter)
+	canMount = canMount && opts.MountFrom != nil
+
+	visited := set.New[descriptor.Descriptor]()
+	var walk func(desc ocispec.Descriptor) error
+	walk = func(desc ocispec.Descriptor) error {
+		key := descriptor.FromOCI(desc)
+		if visited.Contains(key) {
+			return nil
+		}
+		visited.Add(key)
+
+		exists, err := dst.Exists(ctx, desc)
+		if err != nil {
+			return newCopyError("Exists", CopyErrorOriginDestination, err)
+		}
+		if exists {
+			plan.add(PlannedNode{
+				Descriptor: desc,
+				Action:     CopyActionSkip,
+			})
+			return nil
+		}
+
+		successors, err := opts.FindSuccessors(ctx, proxy, desc)
+		if err != nil {
+			return newCopyError("FindSuccessors", CopyErrorOriginSource, err)
+		}
+		for _, successor := range removeForeignLayers(successors) {
+			if err := walk(successor); err != nil {
+				return err
+			}
+		}
+
+		node := PlannedNode{
+			Descriptor: desc,
+			Action:     CopyActionCopy,
+		}
+		if canMount && !descriptor.IsManifest(desc) {
+			sourceRepositories, err := opts.MountFrom(ctx, desc)
+			if err != nil {
+				return err
+			}
+			if len(sourceRepositories) > 0 {
+				node.Action = CopyActionMount
+				node.MountFrom = sourceRepositories
+			}
+		}
+		plan.add(node)
+		return nil
+	}
+
+	plan.Roots = roots
+	for _, root := range roots {
+		if err := walk(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
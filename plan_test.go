@@ -0,0 +1,257 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oras_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// plannedActions returns the actions of the planned nodes.
+func plannedActions(plan *oras.CopyPlan) []oras.CopyAction {
+	var actions []oras.CopyAction
+	for _, node := range plan.Nodes {
+		actions = append(actions, node.Action)
+	}
+	return actions
+}
+
+func TestPlanCopy(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	descs := pushProgressTestGraph(t, ctx, src, 1024)
+	config, layer1, layer2, root := descs[0], descs[1], descs[2], descs[3]
+	ref := "foobar"
+	if err := src.Tag(ctx, root, ref); err != nil {
+		t.Fatal("fail to tag root node", err)
+	}
+
+	// the config exists in the destination
+	dst := &countingStorage{storage: memory.New()}
+	if err := dst.Push(ctx, config, bytes.NewReader([]byte("{}"))); err != nil {
+		t.Fatal("fail to push config", err)
+	}
+	dst.numPush.Store(0)
+
+	plan, err := oras.PlanCopy(ctx, src, ref, dst, oras.CopyOptions{})
+	if err != nil {
+		t.Fatalf("PlanCopy() error = %v, wantErr %v", err, false)
+	}
+	if got, want := plan.Roots, []ocispec.Descriptor{root}; !slices.EqualFunc(got, want, content.Equal) {
+		t.Errorf("CopyPlan.Roots = %v, want %v", got, want)
+	}
+	wantDescs := []ocispec.Descriptor{config, layer1, layer2, root}
+	for i, node := range plan.Nodes {
+		if i >= len(wantDescs) || !content.Equal(node.Descriptor, wantDescs[i]) {
+			t.Fatalf("CopyPlan.Nodes[%d] = %v, want %v", i, node.Descriptor, wantDescs)
+		}
+	}
+	wantActions := []oras.CopyAction{oras.CopyActionSkip, oras.CopyActionCopy, oras.CopyActionCopy, oras.CopyActionCopy}
+	if got := plannedActions(plan); !slices.Equal(got, wantActions) {
+		t.Errorf("CopyPlan actions = %v, want %v", got, wantActions)
+	}
+	if got, want := plan.CopyBytes, layer1.Size+layer2.Size+root.Size; got != want {
+		t.Errorf("CopyPlan.CopyBytes = %d, want %d", got, want)
+	}
+	if got, want := plan.SkipBytes, config.Size; got != want {
+		t.Errorf("CopyPlan.SkipBytes = %d, want %d", got, want)
+	}
+	if got, want := plan.MountBytes, int64(0); got != want {
+		t.Errorf("CopyPlan.MountBytes = %d, want %d", got, want)
+	}
+	if got, want := dst.numPush.Load(), int64(0); got != want {
+		t.Errorf("count(Push()) = %d, want %d", got, want)
+	}
+
+	// the root exists in the destination
+	if _, err := oras.Copy(ctx, src, ref, dst.storage.(*memory.Store), "", oras.CopyOptions{}); err != nil {
+		t.Fatalf("Copy() error = %v, wantErr %v", err, false)
+	}
+	plan, err = oras.PlanCopy(ctx, src, ref, dst, oras.CopyOptions{})
+	if err != nil {
+		t.Fatalf("PlanCopy() error = %v, wantErr %v", err, false)
+	}
+	if got, want := plannedActions(plan), []oras.CopyAction{oras.CopyActionSkip}; !slices.Equal(got, want) {
+		t.Errorf("CopyPlan actions = %v, want %v", got, want)
+	}
+	if got, want := plan.SkipBytes, root.Size; got != want {
+		t.Errorf("CopyPlan.SkipBytes = %d, want %d", got, want)
+	}
+
+	// resolve error
+	_, err = oras.PlanCopy(ctx, src, "unknown", dst, oras.CopyOptions{})
+	if !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("PlanCopy() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+}
+
+func TestPlanCopy_MapRoot(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	descs := pushProgressTestGraph(t, ctx, src, 1024)
+	layer1, root := descs[1], descs[3]
+	ref := "foobar"
+	if err := src.Tag(ctx, root, ref); err != nil {
+		t.Fatal("fail to tag root node", err)
+	}
+
+	opts := oras.CopyOptions{
+		MapRoot: func(ctx context.Context, src content.ReadOnlyStorage, root ocispec.Descriptor) (ocispec.Descriptor, error) {
+			return layer1, nil
+		},
+	}
+	plan, err := oras.PlanCopy(ctx, src, ref, memory.New(), opts)
+	if err != nil {
+		t.Fatalf("PlanCopy() error = %v, wantErr %v", err, false)
+	}
+	if got := len(plan.Nodes); got != 1 || !content.Equal(plan.Nodes[0].Descriptor, layer1) {
+		t.Errorf("CopyPlan.Nodes = %v, want [%v]", plan.Nodes, layer1)
+	}
+}
+
+func TestPlanCopyGraph_MountFrom(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	descs := pushProgressTestGraph(t, ctx, src, 1024)
+	root := descs[len(descs)-1]
+
+	dst := &countingStorage{
+		storage: memory.New(),
+		mount: func(context.Context, ocispec.Descriptor, string, func() (io.ReadCloser, error)) error {
+			t.Error("unexpected mount")
+			return nil
+		},
+	}
+	opts := oras.CopyGraphOptions{
+		MountFrom: func(context.Context, ocispec.Descriptor) ([]string, error) {
+			return []string{"source1", "source2"}, nil
+		},
+		FindSuccessors: func(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+			successors, err := content.Successors(ctx, fetcher, desc)
+			if err != nil {
+				return nil, err
+			}
+			if len(successors) == 0 {
+				return nil, nil
+			}
+			// exclude the config
+			return successors[1:], nil
+		},
+	}
+	plan, err := oras.PlanCopyGraph(ctx, src, dst, root, opts)
+	if err != nil {
+		t.Fatalf("PlanCopyGraph() error = %v, wantErr %v", err, false)
+	}
+	want := []oras.CopyAction{oras.CopyActionMount, oras.CopyActionMount, oras.CopyActionCopy}
+	if got := plannedActions(plan); !slices.Equal(got, want) {
+		t.Fatalf("CopyPlan actions = %v, want %v", got, want)
+	}
+	for _, node := range plan.Nodes[:2] {
+		if got, want := node.MountFrom, []string{"source1", "source2"}; !slices.Equal(got, want) {
+			t.Errorf("PlannedNode.MountFrom = %v, want %v", got, want)
+		}
+	}
+	if got, want := plan.MountBytes, descs[1].Size+descs[2].Size; got != want {
+		t.Errorf("CopyPlan.MountBytes = %d, want %d", got, want)
+	}
+	if got, want := plan.CopyBytes, root.Size; got != want {
+		t.Errorf("CopyPlan.CopyBytes = %d, want %d", got, want)
+	}
+
+	// MountFrom error
+	errMountFrom := errors.New("mount from error")
+	opts.MountFrom = func(context.Context, ocispec.Descriptor) ([]string, error) {
+		return nil, errMountFrom
+	}
+	if _, err := oras.PlanCopyGraph(ctx, src, dst, root, opts); !errors.Is(err, errMountFrom) {
+		t.Errorf("PlanCopyGraph() error = %v, wantErr %v", err, errMountFrom)
+	}
+}
+
+func TestPlanExtendedCopy(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	descs := pushProgressTestGraph(t, ctx, src, 1024)
+	subject := descs[len(descs)-1]
+	ref := "foobar"
+	if err := src.Tag(ctx, subject, ref); err != nil {
+		t.Fatal("fail to tag root node", err)
+	}
+	referrerJSON, err := json.Marshal(ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "test/signature",
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{ocispec.DescriptorEmptyJSON},
+		Subject:      &subject,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	referrer := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, referrerJSON)
+	if err := src.Push(ctx, ocispec.DescriptorEmptyJSON, bytes.NewReader(ocispec.DescriptorEmptyJSON.Data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Push(ctx, referrer, bytes.NewReader(referrerJSON)); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := memory.New()
+	plan, err := oras.PlanExtendedCopy(ctx, src, ref, dst, oras.ExtendedCopyOptions{})
+	if err != nil {
+		t.Fatalf("PlanExtendedCopy() error = %v, wantErr %v", err, false)
+	}
+	if got, want := plan.Roots, []ocispec.Descriptor{referrer}; !slices.EqualFunc(got, want, content.Equal) {
+		t.Errorf("CopyPlan.Roots = %v, want %v", got, want)
+	}
+	var wantBytes int64
+	for _, desc := range append(descs, ocispec.DescriptorEmptyJSON, referrer) {
+		wantBytes += desc.Size
+	}
+	if got, want := len(plan.Nodes), len(descs)+2; got != want {
+		t.Errorf("len(CopyPlan.Nodes) = %d, want %d", got, want)
+	}
+	if got, want := plan.CopyBytes, wantBytes; got != want {
+		t.Errorf("CopyPlan.CopyBytes = %d, want %d", got, want)
+	}
+	if last := plan.Nodes[len(plan.Nodes)-1]; !content.Equal(last.Descriptor, referrer) {
+		t.Errorf("last planned node = %v, want %v", last.Descriptor, referrer)
+	}
+
+	// the plan matches the copy
+	if _, err := oras.ExtendedCopy(ctx, src, ref, dst, "", oras.ExtendedCopyOptions{}); err != nil {
+		t.Fatalf("ExtendedCopy() error = %v, wantErr %v", err, false)
+	}
+	for _, node := range plan.Nodes {
+		exists, err := dst.Exists(ctx, node.Descriptor)
+		if err != nil {
+			t.Fatalf("Exists() error = %v", err)
+		}
+		if !exists {
+			t.Errorf("Exists(%v) = %v, want %v", node.Descriptor, exists, true)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package platforms
+
+import (
+	"strconv"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Matcher matches platforms.
+type Matcher interface {
+	// Match returns true if the platform is accepted.
+	Match(platform ocispec.Platform) bool
+}
+
+// MatchComparer matches platforms and orders the matched platforms by
+// preference.
+type MatchComparer interface {
+	Matcher
+
+	// Less returns true if platform a is preferred over platform b.
+	Less(a, b ocispec.Platform) bool
+}
+
+// Only returns a MatchComparer that matches the given platform and the
+// platforms compatible with it, preferring the closest ones.
+//
+// The compatible platforms are:
+//   - arm/vN: arm/vN down to arm/v5, e.g. an arm/v7 platform accepts arm/v6
+//     and arm/v5.
+//   - arm64/vN: arm64/vN down to arm64/v8.
+//   - amd64/vN: amd64/vN down to amd64/v1, followed by 386.
+//
+// If the given platform has an OS version, platforms with the same OS version
+// are preferred, followed by platforms with the same build number, i.e. the
+// first three components of the OS version, and then platforms without OS
+// versions. Platforms with other OS versions are not accepted.
+func Only(platform ocispec.Platform) MatchComparer {
+	platform = Normalize(platform)
+	return &onlyComparer{
+		platform: platform,
+		vector:   platformVector(platform),
+	}
+}
+
+// OnlyStrict returns a MatchComparer that matches the given platform only,
+// where the aliases are normalized but no compatible platforms are accepted.
+func OnlyStrict(platform ocispec.Platform) MatchComparer {
+	platform = Normalize(platform)
+	return &onlyComparer{
+		platform: platform,
+		vector:   []ocispec.Platform{platform},
+	}
+}
+
+// Default returns a MatchComparer that matches the platform of the host and
+// the platforms compatible with it.
+func Default() MatchComparer {
+	return Only(DefaultSpec())
+}
+
+// Ordered returns a MatchComparer that matches any of the given platforms and
+// their compatible platforms, preferring the platforms in the given order.
+func Ordered(platforms ...ocispec.Platform) MatchComparer {
+	comparers := make([]MatchComparer, 0, len(platforms))
+	for _, p := range platforms {
+		comparers = append(comparers, Only(p))
+	}
+	return orderedComparer(comparers)
+}
+
+// Any returns a MatchComparer that matches any of the given platforms and
+// their compatible platforms without preference.
+func Any(platforms ...ocispec.Platform) MatchComparer {
+	comparers := make([]MatchComparer, 0, len(platforms))
+	for _, p := range platforms {
+		comparers = append(comparers, Only(p))
+	}
+	return anyComparer(comparers)
+}
+
+// All is a MatchComparer that matches all platforms without preference.
+var All MatchComparer = allComparer{}
+
+// onlyComparer matches a platform and its compatible platforms.
+type onlyComparer struct {
+	platform ocispec.Platform
+	// vector contains the compatible platforms ordered by preference.
+	vector []ocispec.Platform
+}
+
+// Match returns true if the platform is compatible.
+func (c *onlyComparer) Match(platform ocispec.Platform) bool {
+	return c.rank(platform) >= 0
+}
+
+// Less returns true if platform a is closer to the platform than platform b.
+func (c *onlyComparer) Less(a, b ocispec.Platform) bool {
+	rankA, rankB := c.rank(a), c.rank(b)
+	if rankA < 0 {
+		return false
+	}
+	return rankB < 0 || rankA < rankB
+}
+
+// rank returns the rank of the platform, where a lower rank is preferred.
+// Returns -1 if the platform is not compatible.
+func (c *onlyComparer) rank(platform ocispec.Platform) int {
+	platform = Normalize(platform)
+	if platform.OS != c.platform.OS {
+		return -1
+	}
+	osVersionRank := osVersionRank(c.platform.OSVersion, platform.OSVersion)
+	if osVersionRank < 0 {
+		return -1
+	}
+	for i, p := range c.vector {
+		if p.Architecture == platform.Architecture && p.Variant == platform.Variant {
+			return i*numOSVersionRanks + osVersionRank
+		}
+	}
+	return -1
+}
+
+// numOSVersionRanks is the number of the ranks returned by osVersionRank.
+const numOSVersionRanks = 3
+
+// osVersionRank returns the rank of the OS version got against the OS version
+// want, where a lower rank is preferred.
+// Returns -1 if the OS versions are not compatible.
+func osVersionRank(want, got string) int {
+	switch {
+	case want == "" || want == got:
+		return 0
+	case got == "":
+		return 2
+	case osVersionBuild(want) == osVersionBuild(got):
+		return 1
+	default:
+		return -1
+	}
+}
+
+// osVersionBuild returns the first three components of the OS version, such
+// as "10.0.17763" of "10.0.17763.1234".
+func osVersionBuild(osVersion string) string {
+	parts := strings.SplitN(osVersion, ".", 4)
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+	return strings.Join(parts, ".")
+}
+
+// platformVector returns the platforms compatible with the given normalized
+// platform, ordered by preference.
+func platformVector(platform ocispec.Platform) []ocispec.Platform {
+	vector := []ocispec.Platform{platform}
+	withArch := func(arch, variant string) ocispec.Platform {
+		p := platform
+		p.Architecture = arch
+		p.Variant = variant
+		return p
+	}
+
+	switch platform.Architecture {
+	case "amd64":
+		level := 1
+		if v, ok := parseVariant(platform.Variant); ok {
+			level = v
+		}
+		for v := level - 1; v >= 1; v-- {
+			vector = append(vector, withArch("amd64", amd64Variant(v)))
+		}
+		vector = append(vector, withArch("386", ""))
+	case "arm":
+		if v, ok := parseVariant(platform.Variant); ok {
+			for v := v - 1; v >= 5; v-- {
+				vector = append(vector, withArch("arm", "v"+strconv.Itoa(v)))
+			}
+		}
+	case "arm64":
+		// arm64 variants are v8 (normalized as empty) or above
+		if major, ok := parseVariant(platform.Variant); ok {
+			for v := major; v >= 9; v-- {
+				if variant := "v" + strconv.Itoa(v); variant != platform.Variant {
+					vector = append(vector, withArch("arm64", variant))
+				}
+			}
+		}
+		if platform.Variant != "" {
+			vector = append(vector, withArch("arm64", ""))
+		}
+	}
+	return vector
+}
+
+// parseVariant parses the major version of a variant in the form of "vN" or
+// "vN.M".
+func parseVariant(variant string) (int, bool) {
+	major, ok := strings.CutPrefix(variant, "v")
+	if !ok {
+		return 0, false
+	}
+	major, _, _ = strings.Cut(major, ".")
+	v, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// amd64Variant returns the normalized variant of the amd64 level v.
+func amd64Variant(v int) string {
+	if v <= 1 {
+		return ""
+	}
+	return "v" + strconv.Itoa(v)
+}
+
+// orderedComparer matches any of its comparers, preferring the earlier ones.
+type orderedComparer []MatchComparer
+
+// Match returns true if any of the comparers matches the platform.
+func (c orderedComparer) Match(platform ocispec.Platform) bool {
+	return c.index(platform) >= 0
+}
+
+// Less returns true if platform a is matched by an earlier comparer than
+// platform b, or by the same comparer which prefers a over b.
+func (c orderedComparer) Less(a, b ocispec.Platform) bool {
+	indexA, indexB := c.index(a), c.index(b)
+	switch {
+	case indexA < 0:
+		return false
+	case indexB < 0 || indexA < indexB:
+		return true
+	case indexA == indexB:
+		return c[indexA].Less(a, b)
+	default:
+		return false
+	}
+}
+
+// index returns the index of the first comparer matching the platform.
+// Returns -1 if none of the comparers matches.
+func (c orderedComparer) index(platform ocispec.Platform) int {
+	for i, comparer := range c {
+		if comparer.Match(platform) {
+			return i
+		}
+	}
+	return -1
+}
+
+// anyComparer matches any of its comparers without preference.
+type anyComparer []MatchComparer
+
+// Match returns true if any of the comparers matches the platform.
+func (c anyComparer) Match(platform ocispec.Platform) bool {
+	for _, comparer := range c {
+		if comparer.Match(platform) {
+			return true
+		}
+	}
+	return false
+}
+
+// Less returns true if platform a is matched while platform b is not.
+func (c anyComparer) Less(a, b ocispec.Platform) bool {
+	return c.Match(a) && !c.Match(b)
+}
+
+// allComparer matches all platforms without preference.
+type allComparer struct{}
+
+// Match returns true.
+func (allComparer) Match(ocispec.Platform) bool {
+	return true
+}
+
+// Less returns false.
+func (allComparer) Less(ocispec.Platform, ocispec.Platform) bool {
+	return false
+}
@@ -0,0 +1,138 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package platforms
+
+import (
+	"slices"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// sortMatched returns the specifiers matched by m in the order of preference.
+func sortMatched(m MatchComparer, specifiers ...string) []string {
+	var matched []ocispec.Platform
+	for _, s := range specifiers {
+		if p := MustParse(s); m.Match(p) {
+			matched = append(matched, p)
+		}
+	}
+	slices.SortStableFunc(matched, func(a, b ocispec.Platform) int {
+		switch {
+		case m.Less(a, b):
+			return -1
+		case m.Less(b, a):
+			return 1
+		default:
+			return 0
+		}
+	})
+	var got []string
+	for _, p := range matched {
+		got = append(got, Format(p))
+	}
+	return got
+}
+
+func TestOnly(t *testing.T) {
+	candidates := []string{
+		"linux/386", "linux/amd64", "linux/amd64/v3", "linux/arm/v5",
+		"linux/arm/v6", "linux/arm/v7", "linux/arm/v8", "linux/arm64",
+		"linux/arm64/v9", "windows/amd64",
+	}
+	tests := []struct {
+		platform string
+		want     []string
+	}{
+		{
+			platform: "linux/arm/v7",
+			want:     []string{"linux/arm/v7", "linux/arm/v6", "linux/arm/v5"},
+		},
+		{
+			platform: "linux/arm/v5",
+			want:     []string{"linux/arm/v5"},
+		},
+		{
+			platform: "linux/amd64",
+			want:     []string{"linux/amd64", "linux/386"},
+		},
+		{
+			platform: "linux/amd64/v3",
+			want:     []string{"linux/amd64/v3", "linux/amd64", "linux/386"},
+		},
+		{
+			platform: "linux/arm64/v9",
+			want:     []string{"linux/arm64/v9", "linux/arm64"},
+		},
+		{
+			platform: "linux/aarch64",
+			want:     []string{"linux/arm64"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			got := sortMatched(Only(MustParse(tt.platform)), candidates...)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Only() matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnly_OSVersion(t *testing.T) {
+	m := Only(MustParse("windows(10.0.17763.1234)/amd64"))
+	got := sortMatched(m,
+		"windows/amd64",
+		"windows(10.0.20348.1)/amd64",
+		"windows(10.0.17763.1)/amd64",
+		"windows(10.0.17763.1234)/amd64",
+	)
+	want := []string{
+		"windows(10.0.17763.1234)/amd64",
+		"windows(10.0.17763.1)/amd64",
+		"windows/amd64",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Only() matched %v, want %v", got, want)
+	}
+}
+
+func TestOnlyStrict(t *testing.T) {
+	m := OnlyStrict(MustParse("linux/arm/v7"))
+	got := sortMatched(m, "linux/arm/v5", "linux/armhf", "linux/arm/v6")
+	if want := []string{"linux/arm/v7"}; !slices.Equal(got, want) {
+		t.Errorf("OnlyStrict() matched %v, want %v", got, want)
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	m := Ordered(MustParse("linux/arm64"), MustParse("linux/arm/v7"))
+	got := sortMatched(m, "linux/amd64", "linux/arm/v6", "linux/arm/v7", "linux/arm64")
+	if want := []string{"linux/arm64", "linux/arm/v7", "linux/arm/v6"}; !slices.Equal(got, want) {
+		t.Errorf("Ordered() matched %v, want %v", got, want)
+	}
+}
+
+func TestAny(t *testing.T) {
+	m := Any(MustParse("linux/arm64"), MustParse("linux/arm/v7"))
+	got := sortMatched(m, "linux/arm/v6", "linux/amd64", "linux/arm64", "linux/arm/v7")
+	if want := []string{"linux/arm/v6", "linux/arm64", "linux/arm/v7"}; !slices.Equal(got, want) {
+		t.Errorf("Any() matched %v, want %v", got, want)
+	}
+	if !All.Match(MustParse("linux/amd64")) {
+		t.Error("All.Match() = false, want true")
+	}
+}
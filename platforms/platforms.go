@@ -0,0 +1,230 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package platforms parses, normalizes and matches platforms, and selects the
+// best matching manifest of a platform from a manifest list.
+//
+// A platform specifier is a string in the form of
+// `<os>[(<os version>)]/<architecture>[/<variant>]`, such as "linux/amd64",
+// "linux/arm64/v8" and "windows(10.0.17763)/amd64".
+// Common aliases such as "aarch64", "x86_64" and "armhf" are normalized to
+// the values defined by the OCI image spec.
+//
+// The package is modeled after the platforms package of containerd.
+// Reference: https://github.com/containerd/platforms
+package platforms
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+)
+
+var (
+	// componentRegexp checks the format of the components of a specifier.
+	componentRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+	// osRegexp checks the format of the OS component of a specifier, with an
+	// optional OS version.
+	osRegexp = regexp.MustCompile(`^([A-Za-z0-9_-]+)(?:\(([A-Za-z0-9_.-]*)\))?$`)
+)
+
+// knownOS contains the known values of GOOS.
+var knownOS = map[string]struct{}{
+	"aix": {}, "android": {}, "darwin": {}, "dragonfly": {}, "freebsd": {},
+	"hurd": {}, "illumos": {}, "ios": {}, "js": {}, "linux": {}, "nacl": {},
+	"netbsd": {}, "openbsd": {}, "plan9": {}, "solaris": {}, "wasip1": {},
+	"windows": {}, "zos": {},
+}
+
+// knownArch contains the known values of GOARCH.
+var knownArch = map[string]struct{}{
+	"386": {}, "amd64": {}, "amd64p32": {}, "arm": {}, "armbe": {},
+	"arm64": {}, "arm64be": {}, "loong64": {}, "mips": {}, "mipsle": {},
+	"mips64": {}, "mips64le": {}, "mips64p32": {}, "mips64p32le": {},
+	"ppc": {}, "ppc64": {}, "ppc64le": {}, "riscv": {}, "riscv64": {},
+	"s390": {}, "s390x": {}, "sparc": {}, "sparc64": {}, "wasm": {},
+}
+
+// Parse parses a platform specifier and returns the normalized platform.
+//
+// The specifier is in the form of
+// `<os>[(<os version>)]/<architecture>[/<variant>]`. If the specifier
+// contains a single component, it is treated as an OS with the architecture
+// of the host if it is a known OS, or as an architecture with the OS of the
+// host otherwise.
+func Parse(specifier string) (ocispec.Platform, error) {
+	parts := strings.Split(specifier, "/")
+	if len(parts) > 3 {
+		return ocispec.Platform{}, fmt.Errorf("%q: too many components: %w", specifier, errdef.ErrInvalidPlatform)
+	}
+	matches := osRegexp.FindStringSubmatch(parts[0])
+	if matches == nil {
+		return ocispec.Platform{}, fmt.Errorf("%q: invalid component %q: %w", specifier, parts[0], errdef.ErrInvalidPlatform)
+	}
+	for _, part := range parts[1:] {
+		if !componentRegexp.MatchString(part) {
+			return ocispec.Platform{}, fmt.Errorf("%q: invalid component %q: %w", specifier, part, errdef.ErrInvalidPlatform)
+		}
+	}
+
+	var p ocispec.Platform
+	switch len(parts) {
+	case 1:
+		if os := normalizeOS(matches[1]); isKnownOS(os) {
+			// the architecture of the host is used
+			p.OS = os
+			p.OSVersion = matches[2]
+			p.Architecture, p.Variant = normalizeArch(runtime.GOARCH, "")
+			return p, nil
+		}
+		p.Architecture, p.Variant = normalizeArch(parts[0], "")
+		if matches[2] != "" || !isKnownArch(p.Architecture) {
+			return ocispec.Platform{}, fmt.Errorf("%q: unknown operating system or architecture: %w", specifier, errdef.ErrInvalidPlatform)
+		}
+		// the OS of the host is used
+		p.OS = normalizeOS(runtime.GOOS)
+		return p, nil
+	case 2:
+		p.OS = normalizeOS(matches[1])
+		p.OSVersion = matches[2]
+		p.Architecture, p.Variant = normalizeArch(parts[1], "")
+		return p, nil
+	default:
+		p.OS = normalizeOS(matches[1])
+		p.OSVersion = matches[2]
+		p.Architecture, p.Variant = normalizeArch(parts[1], parts[2])
+		return p, nil
+	}
+}
+
+// MustParse is like [Parse] but panics if the specifier cannot be parsed.
+func MustParse(specifier string) ocispec.Platform {
+	p, err := Parse(specifier)
+	if err != nil {
+		panic("platforms: Parse(" + specifier + "): " + err.Error())
+	}
+	return p
+}
+
+// Format returns the specifier of the platform, in the form of
+// `<os>[(<os version>)]/<architecture>[/<variant>]`.
+// An empty component is formatted as "unknown".
+func Format(p ocispec.Platform) string {
+	os := p.OS
+	if os == "" {
+		os = "unknown"
+	}
+	if p.OSVersion != "" {
+		os += "(" + p.OSVersion + ")"
+	}
+	arch := p.Architecture
+	if arch == "" {
+		arch = "unknown"
+	}
+	if p.Variant == "" {
+		return os + "/" + arch
+	}
+	return os + "/" + arch + "/" + p.Variant
+}
+
+// Normalize returns the platform with the aliases of its OS, architecture and
+// variant normalized, such as "x86_64" to "amd64" and "aarch64" to "arm64".
+// The default variants are normalized as well, where "arm64/v8" is normalized
+// to "arm64", and "arm" is normalized to "arm/v7".
+func Normalize(p ocispec.Platform) ocispec.Platform {
+	p.OS = normalizeOS(p.OS)
+	p.Architecture, p.Variant = normalizeArch(p.Architecture, p.Variant)
+	return p
+}
+
+// DefaultSpec returns the platform of the host, determined by runtime.GOOS
+// and runtime.GOARCH.
+// The CPU variant of the host is not detected, and the default variant of
+// the architecture is used.
+func DefaultSpec() ocispec.Platform {
+	return Normalize(ocispec.Platform{
+		OS:           runtime.GOOS,
+		Architecture: runtime.GOARCH,
+	})
+}
+
+// DefaultString returns the specifier of the platform of the host.
+func DefaultString() string {
+	return Format(DefaultSpec())
+}
+
+// isKnownOS returns true if os is a known value of GOOS.
+func isKnownOS(os string) bool {
+	_, ok := knownOS[os]
+	return ok
+}
+
+// isKnownArch returns true if arch is a known value of GOARCH.
+func isKnownArch(arch string) bool {
+	_, ok := knownArch[arch]
+	return ok
+}
+
+// normalizeOS normalizes the aliases of os.
+func normalizeOS(os string) string {
+	os = strings.ToLower(os)
+	switch os {
+	case "macos":
+		return "darwin"
+	default:
+		return os
+	}
+}
+
+// normalizeArch normalizes the aliases of arch and variant.
+func normalizeArch(arch, variant string) (string, string) {
+	arch, variant = strings.ToLower(arch), strings.ToLower(variant)
+	switch arch {
+	case "i386", "i486", "i586", "i686", "x86":
+		return "386", ""
+	case "x86_64", "x86-64", "amd64":
+		if variant == "v1" {
+			variant = ""
+		}
+		return "amd64", variant
+	case "aarch64", "arm64":
+		switch variant {
+		case "8", "v8", "v8.0":
+			variant = ""
+		case "9", "9.0", "v9.0":
+			variant = "v9"
+		}
+		return "arm64", variant
+	case "armhf":
+		return "arm", "v7"
+	case "armel":
+		return "arm", "v6"
+	case "arm":
+		switch variant {
+		case "", "7":
+			variant = "v7"
+		case "5", "6", "8":
+			variant = "v" + variant
+		}
+		return "arm", variant
+	default:
+		return arch, variant
+	}
+}
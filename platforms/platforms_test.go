@@ -0,0 +1,174 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package platforms
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+)
+
+func TestParse(t *testing.T) {
+	hostArch, hostVariant := normalizeArch(runtime.GOARCH, "")
+	tests := []struct {
+		name      string
+		specifier string
+		want      ocispec.Platform
+	}{
+		{
+			name:      "os and arch",
+			specifier: "linux/amd64",
+			want:      ocispec.Platform{OS: "linux", Architecture: "amd64"},
+		},
+		{
+			name:      "arm64 with default variant",
+			specifier: "linux/arm64/v8",
+			want:      ocispec.Platform{OS: "linux", Architecture: "arm64"},
+		},
+		{
+			name:      "aarch64 alias",
+			specifier: "linux/aarch64",
+			want:      ocispec.Platform{OS: "linux", Architecture: "arm64"},
+		},
+		{
+			name:      "x86_64 alias",
+			specifier: "Linux/x86_64",
+			want:      ocispec.Platform{OS: "linux", Architecture: "amd64"},
+		},
+		{
+			name:      "armhf alias",
+			specifier: "linux/armhf",
+			want:      ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		},
+		{
+			name:      "arm with numeric variant",
+			specifier: "linux/arm/6",
+			want:      ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+		},
+		{
+			name:      "os version",
+			specifier: "windows(10.0.17763)/amd64",
+			want:      ocispec.Platform{OS: "windows", OSVersion: "10.0.17763", Architecture: "amd64"},
+		},
+		{
+			name:      "os only",
+			specifier: "linux",
+			want:      ocispec.Platform{OS: "linux", Architecture: hostArch, Variant: hostVariant},
+		},
+		{
+			name:      "arch only",
+			specifier: "s390x",
+			want:      ocispec.Platform{OS: normalizeOS(runtime.GOOS), Architecture: "s390x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.specifier)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, specifier := range []string{
+		"",
+		"linux/",
+		"linux/arm/v7/extra",
+		"linux/amd 64",
+		"unknown-os",
+		"linux(1.0",
+	} {
+		t.Run(specifier, func(t *testing.T) {
+			if _, err := Parse(specifier); !errors.Is(err, errdef.ErrInvalidPlatform) {
+				t.Errorf("Parse() error = %v, wantErr %v", err, errdef.ErrInvalidPlatform)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		platform ocispec.Platform
+		want     string
+	}{
+		{
+			platform: ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			want:     "linux/arm/v7",
+		},
+		{
+			platform: ocispec.Platform{OS: "windows", OSVersion: "10.0.17763", Architecture: "amd64"},
+			want:     "windows(10.0.17763)/amd64",
+		},
+		{
+			platform: ocispec.Platform{},
+			want:     "unknown/unknown",
+		},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.platform); got != tt.want {
+			t.Errorf("Format() = %s, want %s", got, tt.want)
+		}
+		if tt.platform.OS == "" {
+			continue
+		}
+		// formatted specifiers can be parsed back
+		if got := MustParse(tt.want); !reflect.DeepEqual(got, tt.platform) {
+			t.Errorf("MustParse() = %v, want %v", got, tt.platform)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		platform ocispec.Platform
+		want     ocispec.Platform
+	}{
+		{
+			platform: ocispec.Platform{OS: "MacOS", Architecture: "aarch64", Variant: "8"},
+			want:     ocispec.Platform{OS: "darwin", Architecture: "arm64"},
+		},
+		{
+			platform: ocispec.Platform{OS: "linux", Architecture: "arm"},
+			want:     ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		},
+		{
+			platform: ocispec.Platform{OS: "linux", Architecture: "armel"},
+			want:     ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+		},
+		{
+			platform: ocispec.Platform{OS: "linux", Architecture: "i686"},
+			want:     ocispec.Platform{OS: "linux", Architecture: "386"},
+		},
+		{
+			platform: ocispec.Platform{OS: "linux", Architecture: "amd64", Variant: "v1"},
+			want:     ocispec.Platform{OS: "linux", Architecture: "amd64"},
+		},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.platform); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Normalize(%v) = %v, want %v", tt.platform, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package platforms
+
+import (
+	"context"
+	"fmt"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/docker"
+	"oras.land/oras-go/v2/internal/manifestutil"
+	"oras.land/oras-go/v2/internal/platform"
+)
+
+// SelectManifest selects the manifest best matching the given MatchComparer.
+//   - If the root node is a manifest list, the matched manifest preferred by
+//     the MatchComparer is returned. Among the equally preferred manifests,
+//     the first one in the manifest list is returned. Manifests without
+//     platforms are ignored.
+//   - If the root node is a manifest, it is returned if the platform in its
+//     config matches.
+//   - Otherwise ErrUnsupported is returned.
+//
+// ErrNotFound is returned if no manifest matches.
+func SelectManifest(ctx context.Context, src content.ReadOnlyStorage, root ocispec.Descriptor, matcher MatchComparer) (ocispec.Descriptor, error) {
+	switch root.MediaType {
+	case docker.MediaTypeManifestList, ocispec.MediaTypeImageIndex:
+		manifests, err := manifestutil.Manifests(ctx, src, root)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+
+		var best *ocispec.Descriptor
+		for i, m := range manifests {
+			if m.Platform == nil || !matcher.Match(*m.Platform) {
+				continue
+			}
+			if best == nil || matcher.Less(*m.Platform, *best.Platform) {
+				best = &manifests[i]
+			}
+		}
+		if best == nil {
+			return ocispec.Descriptor{}, fmt.Errorf("%s: %w: no matching manifest was found in the manifest list", root.Digest, errdef.ErrNotFound)
+		}
+		return *best, nil
+	case docker.MediaTypeManifest, ocispec.MediaTypeImageManifest:
+		p, err := platform.ManifestPlatform(ctx, src, root)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		if matcher.Match(*p) {
+			return root, nil
+		}
+		return ocispec.Descriptor{}, fmt.Errorf("%s: %w: platform in manifest does not match target platform", root.Digest, errdef.ErrNotFound)
+	default:
+		return ocispec.Descriptor{}, fmt.Errorf("%s: %s: %w", root.Digest, root.MediaType, errdef.ErrUnsupported)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package platforms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/errdef"
+)
+
+func TestSelectManifest(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+	push := func(mediaType string, blob []byte) ocispec.Descriptor {
+		desc := content.NewDescriptorFromBytes(mediaType, blob)
+		if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+			t.Fatalf("failed to push test content: %v", err)
+		}
+		return desc
+	}
+	pushJSON := func(mediaType string, v any) ocispec.Descriptor {
+		blob, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return push(mediaType, blob)
+	}
+
+	var manifests []ocispec.Descriptor
+	for _, specifier := range []string{"linux/arm/v5", "linux/arm/v6", "linux/arm64"} {
+		p := MustParse(specifier)
+		configJSON, err := json.Marshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := push(ocispec.MediaTypeImageConfig, configJSON)
+		manifest := pushJSON(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config:    config,
+			Layers:    []ocispec.Descriptor{},
+		})
+		manifest.Platform = &p
+		manifests = append(manifests, manifest)
+	}
+	// manifests without platforms are ignored
+	manifests = append(manifests, ocispec.DescriptorEmptyJSON)
+	root := pushJSON(ocispec.MediaTypeImageIndex, ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: manifests,
+	})
+
+	// the closest platform is preferred over the first matching one
+	got, err := SelectManifest(ctx, s, root, Only(MustParse("linux/arm/v7")))
+	if err != nil {
+		t.Fatalf("SelectManifest() error = %v", err)
+	}
+	if !content.Equal(got, manifests[1]) {
+		t.Errorf("SelectManifest() = %v, want %v", got, manifests[1])
+	}
+
+	// manifests are matched against the platforms in their configs
+	got, err = SelectManifest(ctx, s, manifests[2], Only(MustParse("linux/aarch64/v8")))
+	if err != nil {
+		t.Fatalf("SelectManifest() error = %v", err)
+	}
+	if !content.Equal(got, manifests[2]) {
+		t.Errorf("SelectManifest() = %v, want %v", got, manifests[2])
+	}
+
+	// no match
+	for _, desc := range []ocispec.Descriptor{root, manifests[2]} {
+		if _, err := SelectManifest(ctx, s, desc, Only(MustParse("linux/amd64"))); !errors.Is(err, errdef.ErrNotFound) {
+			t.Errorf("SelectManifest() error = %v, wantErr %v", err, errdef.ErrNotFound)
+		}
+	}
+
+	// unsupported root
+	if _, err := SelectManifest(ctx, s, ocispec.DescriptorEmptyJSON, All); !errors.Is(err, errdef.ErrUnsupported) {
+		t.Errorf("SelectManifest() error = %v, wantErr %v", err, errdef.ErrUnsupported)
+	}
+}
@@ -0,0 +1,247 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oras
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/internal/container/set"
+	"oras.land/oras-go/v2/internal/descriptor"
+)
+
+// defaultProgressInterval is the default value of
+// CopyGraphOptions.ProgressInterval.
+const defaultProgressInterval = 100 * time.Millisecond
+
+// ProgressState represents the state of a node being copied.
+type ProgressState int
+
+const (
+	// ProgressStateStarted indicates that the transfer of the node has
+	// started.
+	ProgressStateStarted ProgressState = 1
+
+	// ProgressStateTransferring indicates that the content of the node is
+	// being transferred.
+	ProgressStateTransferring ProgressState = 2
+
+	// ProgressStateCompleted indicates that the node has been copied.
+	ProgressStateCompleted ProgressState = 3
+
+	// ProgressStateSkipped indicates that the node already exists in the
+	// destination and is not transferred.
+	ProgressStateSkipped ProgressState = 4
+
+	// ProgressStateMounted indicates that the node has been mounted from
+	// another repository of the destination.
+	ProgressStateMounted ProgressState = 5
+)
+
+// String returns the string representation of the ProgressState.
+func (s ProgressState) String() string {
+	switch s {
+	case ProgressStateStarted:
+		return "started"
+	case ProgressStateTransferring:
+		return "transferring"
+	case ProgressStateCompleted:
+		return "completed"
+	case ProgressStateSkipped:
+		return "skipped"
+	case ProgressStateMounted:
+		return "mounted"
+	default:
+		return "unknown"
+	}
+}
+
+// Progress describes the progress of copying a node, along with the
+// progress of the whole copy operation.
+type Progress struct {
+	// Descriptor is the descriptor of the node.
+	Descriptor ocispec.Descriptor
+	// State is the state of the node.
+	State ProgressState
+	// BytesTransferred is the number of bytes of the node transferred so far.
+	// The total number of bytes of the node is Descriptor.Size.
+	BytesTransferred int64
+	// Throughput is the average transfer rate of the node in bytes per
+	// second.
+	Throughput float64
+	// Overall is the progress of the whole copy operation.
+	Overall ProgressSummary
+}
+
+// ProgressSummary summarizes the progress of a copy operation.
+type ProgressSummary struct {
+	// BytesTransferred is the number of bytes transferred so far.
+	BytesTransferred int64
+	// TotalBytes is the total number of bytes of the nodes to be transferred.
+	// As the graph is discovered while it is being copied, TotalBytes grows
+	// as the copy proceeds.
+	TotalBytes int64
+	// Throughput is the average transfer rate of the copy operation in bytes
+	// per second.
+	Throughput float64
+}
+
+// progressTracker tracks the bytes transferred by a copy operation and
+// reports them to CopyGraphOptions.OnProgress.
+// A nil *progressTracker is valid and reports nothing.
+type progressTracker struct {
+	onProgress  func(ctx context.Context, progress Progress)
+	interval    time.Duration
+	start       time.Time
+	transferred atomic.Int64
+	total       atomic.Int64
+
+	lock      sync.Mutex
+	completed set.Set[descriptor.Descriptor]
+}
+
+// newProgressTracker creates a new progressTracker with the given options.
+// Returns nil if opts.OnProgress is not provided.
+func newProgressTracker(opts CopyGraphOptions) *progressTracker {
+	if opts.OnProgress == nil {
+		return nil
+	}
+	interval := opts.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	return &progressTracker{
+		onProgress: opts.OnProgress,
+		interval:   interval,
+		start:      time.Now(),
+		completed:  set.New[descriptor.Descriptor](),
+	}
+}
+
+// started reports that the transfer of desc has started, and returns a
+// reader reporting the bytes read from r.
+func (t *progressTracker) started(ctx context.Context, desc ocispec.Descriptor, r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	t.total.Add(desc.Size)
+	pr := &progressReader{
+		ctx:     ctx,
+		reader:  r,
+		tracker: t,
+		desc:    desc,
+		start:   time.Now(),
+	}
+	pr.last = pr.start
+	t.report(ctx, desc, ProgressStateStarted, 0, pr.start)
+	return pr
+}
+
+// complete reports that desc has been transferred through the reader
+// returned by started.
+func (t *progressTracker) complete(ctx context.Context, desc ocispec.Descriptor, r io.Reader) {
+	if t == nil {
+		return
+	}
+	pr, ok := r.(*progressReader)
+	if !ok {
+		return
+	}
+	t.lock.Lock()
+	t.completed.Add(descriptor.FromOCI(desc))
+	t.lock.Unlock()
+	t.report(ctx, desc, ProgressStateCompleted, pr.n, pr.start)
+}
+
+// skipped reports that desc is skipped, unless it is already reported as
+// completed.
+func (t *progressTracker) skipped(ctx context.Context, desc ocispec.Descriptor) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	done := t.completed.Contains(descriptor.FromOCI(desc))
+	t.lock.Unlock()
+	if done {
+		return
+	}
+	t.report(ctx, desc, ProgressStateSkipped, 0, time.Time{})
+}
+
+// mounted reports that desc is mounted.
+func (t *progressTracker) mounted(ctx context.Context, desc ocispec.Descriptor) {
+	if t == nil {
+		return
+	}
+	t.report(ctx, desc, ProgressStateMounted, 0, time.Time{})
+}
+
+// report invokes OnProgress with the current progress.
+func (t *progressTracker) report(ctx context.Context, desc ocispec.Descriptor, state ProgressState, n int64, start time.Time) {
+	now := time.Now()
+	progress := Progress{
+		Descriptor:       desc,
+		State:            state,
+		BytesTransferred: n,
+		Overall: ProgressSummary{
+			BytesTransferred: t.transferred.Load(),
+			TotalBytes:       t.total.Load(),
+			Throughput:       throughput(t.transferred.Load(), now.Sub(t.start)),
+		},
+	}
+	if !start.IsZero() {
+		progress.Throughput = throughput(n, now.Sub(start))
+	}
+	t.onProgress(ctx, progress)
+}
+
+// throughput returns the transfer rate in bytes per second.
+func throughput(n int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(n) / elapsed.Seconds()
+}
+
+// progressReader reports the bytes read from the underlying reader.
+type progressReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	tracker *progressTracker
+	desc    ocispec.Descriptor
+	start   time.Time
+	last    time.Time
+	n       int64
+}
+
+// Read reads from the underlying reader and reports the progress at most
+// once per the interval of the tracker.
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.reader.Read(p)
+	if n > 0 {
+		pr.n += int64(n)
+		pr.tracker.transferred.Add(int64(n))
+		if now := time.Now(); now.Sub(pr.last) >= pr.tracker.interval {
+			pr.last = now
+			pr.tracker.report(pr.ctx, pr.desc, ProgressStateTransferring, pr.n, pr.start)
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,282 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oras_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+// progressRecorder records the progress reported by a copy operation.
+type progressRecorder struct {
+	lock   sync.Mutex
+	events []oras.Progress
+}
+
+func (r *progressRecorder) record(_ context.Context, p oras.Progress) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, p)
+}
+
+// states returns the states reported for each node.
+func (r *progressRecorder) states() map[digest.Digest][]oras.ProgressState {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	states := make(map[digest.Digest][]oras.ProgressState)
+	for _, e := range r.events {
+		if e.State == oras.ProgressStateTransferring {
+			continue
+		}
+		states[e.Descriptor.Digest] = append(states[e.Descriptor.Digest], e.State)
+	}
+	return states
+}
+
+// last returns the last reported progress.
+func (r *progressRecorder) last() oras.Progress {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.events[len(r.events)-1]
+}
+
+// pushProgressTestGraph pushes a manifest with a config and two layers to
+// the given store, and returns the descriptors with the manifest at the end.
+func pushProgressTestGraph(t *testing.T, ctx context.Context, s oras.Target, layerSize int) []ocispec.Descriptor {
+	t.Helper()
+	var descs []ocispec.Descriptor
+	push := func(mediaType string, blob []byte) ocispec.Descriptor {
+		desc := ocispec.Descriptor{
+			MediaType: mediaType,
+			Digest:    digest.FromBytes(blob),
+			Size:      int64(len(blob)),
+		}
+		if err := s.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+			t.Fatalf("failed to push test content: %v", err)
+		}
+		descs = append(descs, desc)
+		return desc
+	}
+	config := push(ocispec.MediaTypeImageConfig, []byte("{}"))
+	layer1 := push(ocispec.MediaTypeImageLayer, bytes.Repeat([]byte("a"), layerSize))
+	layer2 := push(ocispec.MediaTypeImageLayer, bytes.Repeat([]byte("b"), layerSize))
+	manifestJSON, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layer1, layer2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	push(ocispec.MediaTypeImageManifest, manifestJSON)
+	return descs
+}
+
+func TestCopy_Progress(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	descs := pushProgressTestGraph(t, ctx, src, 1024)
+	root := descs[len(descs)-1]
+	ref := "foobar"
+	if err := src.Tag(ctx, root, ref); err != nil {
+		t.Fatal("fail to tag root node", err)
+	}
+	var wantTotal int64
+	for _, desc := range descs {
+		wantTotal += desc.Size
+	}
+
+	// test full copy
+	dst := memory.New()
+	recorder := &progressRecorder{}
+	opts := oras.CopyOptions{
+		CopyGraphOptions: oras.CopyGraphOptions{
+			OnProgress: recorder.record,
+		},
+	}
+	if _, err := oras.Copy(ctx, src, ref, dst, "", opts); err != nil {
+		t.Fatalf("Copy() error = %v, wantErr %v", err, false)
+	}
+	states := recorder.states()
+	for _, desc := range descs {
+		got := states[desc.Digest]
+		want := []oras.ProgressState{oras.ProgressStateStarted, oras.ProgressStateCompleted}
+		if !slices.Equal(got, want) {
+			t.Errorf("states of %s = %v, want %v", desc.Digest, got, want)
+		}
+	}
+	last := recorder.last()
+	if last.State != oras.ProgressStateCompleted || !content.Equal(last.Descriptor, root) {
+		t.Errorf("last progress = %v %v, want %v %v", last.State, last.Descriptor, oras.ProgressStateCompleted, root)
+	}
+	if last.BytesTransferred != root.Size {
+		t.Errorf("Progress.BytesTransferred = %d, want %d", last.BytesTransferred, root.Size)
+	}
+	if got := last.Overall.BytesTransferred; got != wantTotal {
+		t.Errorf("Progress.Overall.BytesTransferred = %d, want %d", got, wantTotal)
+	}
+	if got := last.Overall.TotalBytes; got != wantTotal {
+		t.Errorf("Progress.Overall.TotalBytes = %d, want %d", got, wantTotal)
+	}
+
+	// test copy to a destination with existing root
+	recorder = &progressRecorder{}
+	opts.OnProgress = recorder.record
+	if _, err := oras.Copy(ctx, src, ref, dst, "", opts); err != nil {
+		t.Fatalf("Copy() error = %v, wantErr %v", err, false)
+	}
+	states = recorder.states()
+	if got, want := len(states), 1; got != want {
+		t.Fatalf("number of reported nodes = %d, want %d", got, want)
+	}
+	if got, want := states[root.Digest], []oras.ProgressState{oras.ProgressStateSkipped}; !slices.Equal(got, want) {
+		t.Errorf("states of %s = %v, want %v", root.Digest, got, want)
+	}
+}
+
+func TestCopy_Progress_ReferencePusher(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	descs := pushProgressTestGraph(t, ctx, src, 1024)
+	root := descs[len(descs)-1]
+	ref := "foobar"
+	if err := src.Tag(ctx, root, ref); err != nil {
+		t.Fatal("fail to tag root node", err)
+	}
+
+	dst := &mockReferencePusher{Target: memory.New()}
+	recorder := &progressRecorder{}
+	opts := oras.CopyOptions{
+		CopyGraphOptions: oras.CopyGraphOptions{
+			OnProgress: recorder.record,
+		},
+	}
+	if _, err := oras.Copy(ctx, src, ref, dst, "", opts); err != nil {
+		t.Fatalf("Copy() error = %v, wantErr %v", err, false)
+	}
+	states := recorder.states()
+	for _, desc := range descs {
+		got := states[desc.Digest]
+		want := []oras.ProgressState{oras.ProgressStateStarted, oras.ProgressStateCompleted}
+		if !slices.Equal(got, want) {
+			t.Errorf("states of %s = %v, want %v", desc.Digest, got, want)
+		}
+	}
+}
+
+func TestCopyGraph_Progress_Transferring(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	layerSize := 1024 * 1024
+	descs := pushProgressTestGraph(t, ctx, src, layerSize)
+	root := descs[len(descs)-1]
+
+	dst := &slowPusher{Target: memory.New()}
+	recorder := &progressRecorder{}
+	opts := oras.CopyGraphOptions{
+		OnProgress:       recorder.record,
+		ProgressInterval: time.Nanosecond,
+	}
+	if err := oras.CopyGraph(ctx, src, dst, root, opts); err != nil {
+		t.Fatalf("CopyGraph() error = %v, wantErr %v", err, false)
+	}
+
+	layer := descs[1]
+	var prev int64
+	var numTransferring int
+	for _, e := range recorder.events {
+		if e.Descriptor.Digest != layer.Digest || e.State != oras.ProgressStateTransferring {
+			continue
+		}
+		numTransferring++
+		if e.BytesTransferred <= prev || e.BytesTransferred > layer.Size {
+			t.Errorf("Progress.BytesTransferred = %d, want in (%d, %d]", e.BytesTransferred, prev, layer.Size)
+		}
+		prev = e.BytesTransferred
+	}
+	if numTransferring < 2 {
+		t.Errorf("count(ProgressStateTransferring) = %d, want >= %d", numTransferring, 2)
+	}
+}
+
+func TestCopyGraph_Progress_Mounted(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	descs := pushProgressTestGraph(t, ctx, src, 1024)
+	root := descs[len(descs)-1]
+
+	dst := &countingStorage{
+		storage: memory.New(),
+		mount: func(context.Context, ocispec.Descriptor, string, func() (io.ReadCloser, error)) error {
+			// simulate a successful mount
+			return nil
+		},
+	}
+	recorder := &progressRecorder{}
+	opts := oras.CopyGraphOptions{
+		OnProgress: recorder.record,
+		MountFrom: func(context.Context, ocispec.Descriptor) ([]string, error) {
+			return []string{"source"}, nil
+		},
+	}
+	if err := oras.CopyGraph(ctx, src, dst, root, opts); err != nil {
+		t.Fatalf("CopyGraph() error = %v, wantErr %v", err, false)
+	}
+	states := recorder.states()
+	for _, desc := range descs[:len(descs)-1] {
+		if got, want := states[desc.Digest], []oras.ProgressState{oras.ProgressStateMounted}; !slices.Equal(got, want) {
+			t.Errorf("states of %s = %v, want %v", desc.Digest, got, want)
+		}
+	}
+	want := []oras.ProgressState{oras.ProgressStateStarted, oras.ProgressStateCompleted}
+	if got := states[root.Digest]; !slices.Equal(got, want) {
+		t.Errorf("states of %s = %v, want %v", root.Digest, got, want)
+	}
+}
+
+// slowPusher pushes content in small reads.
+type slowPusher struct {
+	oras.Target
+}
+
+func (p *slowPusher) Push(ctx context.Context, expected ocispec.Descriptor, content io.Reader) error {
+	return p.Target.Push(ctx, expected, io.LimitReader(&smallReader{content}, expected.Size))
+}
+
+// smallReader reads at most 4 KiB per call.
+type smallReader struct {
+	io.Reader
+}
+
+func (r *smallReader) Read(p []byte) (int, error) {
+	if len(p) > 4096 {
+		p = p[:4096]
+	}
+	time.Sleep(time.Microsecond)
+	return r.Reader.Read(p)
+}
@@ -45,3 +45,19 @@ func ExampleParseReference_digest() {
 	// Repository: oras-project/oras-go
 	// Digest: sha256:601d05a48832e7946dab8f49b14953549bebf42e42f4d7973b1a5a287d77ab76
 }
+
+// ExampleParseNormalizedReference demonstrates parsing a reference string in
+// the familiar form of Docker and formatting it back.
+func ExampleParseNormalizedReference() {
+	ref, err := registry.ParseNormalizedReference("alpine:3.19")
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("Reference:", ref)
+	fmt.Println("Familiar:", ref.FamiliarString())
+
+	// Output:
+	// Reference: docker.io/library/alpine:3.19
+	// Familiar: alpine:3.19
+}
@@ -0,0 +1,138 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import "strings"
+
+const (
+	// dockerRegistry is the name of the Docker Hub registry.
+	dockerRegistry = "docker.io"
+
+	// legacyDockerRegistry is the legacy name of the Docker Hub registry.
+	legacyDockerRegistry = "index.docker.io"
+
+	// dockerNamespace is the namespace of the official images on Docker Hub.
+	dockerNamespace = "library"
+)
+
+// DockerNormalizer normalizes references following the rules of Docker, where
+// "alpine" is normalized to "docker.io/library/alpine".
+var DockerNormalizer = Normalizer{
+	DefaultRegistry:  dockerRegistry,
+	DefaultNamespace: dockerNamespace,
+}
+
+// Normalizer parses references in the familiar form, which may omit the
+// registry and the namespace, and formats references into the familiar form.
+//
+// The first component of a reference is the registry if it contains a "." or
+// a ":", or if it is "localhost". Otherwise, the reference is on the default
+// registry.
+type Normalizer struct {
+	// DefaultRegistry is the registry of references without registries, such
+	// as "docker.io".
+	// If empty, references without registries are invalid.
+	DefaultRegistry string
+
+	// DefaultNamespace is the namespace of single-component repositories on
+	// the default registry, such as "library" for "docker.io".
+	// If empty, no namespace is prepended.
+	DefaultNamespace string
+}
+
+// ParseNormalizedReference parses a reference in the familiar form following
+// the rules of Docker, such as "alpine:3.19" and "library/alpine", using
+// DockerNormalizer.
+// See also [Normalizer.Parse].
+func ParseNormalizedReference(artifact string) (Reference, error) {
+	return DockerNormalizer.Parse(artifact)
+}
+
+// FamiliarString returns the reference string in the familiar form following
+// the rules of Docker, such as "alpine:3.19" for
+// "docker.io/library/alpine:3.19", using DockerNormalizer.
+// See also [Normalizer.FamiliarString].
+func (r Reference) FamiliarString() string {
+	return DockerNormalizer.FamiliarString(r)
+}
+
+// Parse parses a reference in the familiar form into a fully qualified
+// reference, where the default registry and the default namespace are
+// applied if omitted.
+// Fully qualified references are parsed as [ParseReference] does, except that
+// "index.docker.io" is normalized to "docker.io" if the default registry is
+// "docker.io".
+//
+// For any reference r returned by Parse, Parse(r.String()) and
+// Parse(n.FamiliarString(r)) return r.
+func (n Normalizer) Parse(artifact string) (Reference, error) {
+	registry, path, found := strings.Cut(artifact, "/")
+	if !found || !isRegistry(registry) {
+		registry, path = n.DefaultRegistry, artifact
+	} else if registry == legacyDockerRegistry && n.DefaultRegistry == dockerRegistry {
+		registry = dockerRegistry
+	}
+	if registry == n.DefaultRegistry && n.DefaultNamespace != "" && !strings.Contains(repositoryOf(path), "/") {
+		path = n.DefaultNamespace + "/" + path
+	}
+	return ParseReference(registry + "/" + path)
+}
+
+// FamiliarString returns the reference string in the familiar form, where the
+// default registry and the default namespace are omitted when doing so is
+// unambiguous.
+// The resulted string is meaningful only if the reference is valid.
+func (n Normalizer) FamiliarString(r Reference) string {
+	if n.DefaultRegistry == "" || r.Registry != n.DefaultRegistry || r.Repository == "" {
+		return r.String()
+	}
+	repository := r.Repository
+	if n.DefaultNamespace != "" {
+		name, ok := strings.CutPrefix(repository, n.DefaultNamespace+"/")
+		switch {
+		case ok && !strings.Contains(name, "/"):
+			repository = name
+		case !strings.Contains(repository, "/"):
+			// the default namespace would be prepended if the registry is
+			// omitted
+			return r.String()
+		}
+	}
+	if first, _, found := strings.Cut(repository, "/"); found && isRegistry(first) {
+		// the registry cannot be omitted as the first component of the
+		// repository would be parsed as a registry
+		return r.String()
+	}
+	familiar := r
+	familiar.Registry = ""
+	familiar.Repository = repository
+	return strings.TrimPrefix(familiar.String(), "/")
+}
+
+// isRegistry returns true if the first component of a reference is a
+// registry.
+func isRegistry(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost"
+}
+
+// repositoryOf returns the repository of a path in the form of
+// `<repository>[:<tag>][@<digest>]`.
+func repositoryOf(path string) string {
+	if index := strings.IndexAny(path, ":@"); index != -1 {
+		return path[:index]
+	}
+	return path
+}
@@ -0,0 +1,206 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"oras.land/oras-go/v2/errdef"
+)
+
+func TestNormalizer_Parse(t *testing.T) {
+	const dgst = "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	tests := []struct {
+		name       string
+		normalizer Normalizer
+		artifact   string
+		want       Reference
+		wantErr    error
+	}{
+		{
+			name:       "official image",
+			normalizer: DockerNormalizer,
+			artifact:   "alpine",
+			want:       Reference{Registry: "docker.io", Repository: "library/alpine"},
+		},
+		{
+			name:       "official image with tag",
+			normalizer: DockerNormalizer,
+			artifact:   "library/alpine:3.19",
+			want:       Reference{Registry: "docker.io", Repository: "library/alpine", Reference: "3.19"},
+		},
+		{
+			name:       "official image with digest",
+			normalizer: DockerNormalizer,
+			artifact:   "alpine:3.19@" + dgst,
+			want:       Reference{Registry: "docker.io", Repository: "library/alpine", Reference: dgst},
+		},
+		{
+			name:       "user image",
+			normalizer: DockerNormalizer,
+			artifact:   "foo/bar:v1",
+			want:       Reference{Registry: "docker.io", Repository: "foo/bar", Reference: "v1"},
+		},
+		{
+			name:       "legacy registry",
+			normalizer: DockerNormalizer,
+			artifact:   "index.docker.io/alpine",
+			want:       Reference{Registry: "docker.io", Repository: "library/alpine"},
+		},
+		{
+			name:       "fully qualified",
+			normalizer: DockerNormalizer,
+			artifact:   "ghcr.io/oras-project/oras:v1",
+			want:       Reference{Registry: "ghcr.io", Repository: "oras-project/oras", Reference: "v1"},
+		},
+		{
+			name:       "registry with port",
+			normalizer: DockerNormalizer,
+			artifact:   "foo:5000/bar",
+			want:       Reference{Registry: "foo:5000", Repository: "bar"},
+		},
+		{
+			name:       "localhost",
+			normalizer: DockerNormalizer,
+			artifact:   "localhost/bar",
+			want:       Reference{Registry: "localhost", Repository: "bar"},
+		},
+		{
+			name:       "custom default registry",
+			normalizer: Normalizer{DefaultRegistry: "registry.example.com"},
+			artifact:   "alpine:3.19",
+			want:       Reference{Registry: "registry.example.com", Repository: "alpine", Reference: "3.19"},
+		},
+		{
+			name:       "custom legacy registry is kept",
+			normalizer: Normalizer{DefaultRegistry: "registry.example.com"},
+			artifact:   "index.docker.io/alpine",
+			want:       Reference{Registry: "index.docker.io", Repository: "alpine"},
+		},
+		{
+			name:       "no default registry",
+			normalizer: Normalizer{},
+			artifact:   "alpine",
+			wantErr:    errdef.ErrInvalidReference,
+		},
+		{
+			name:       "invalid repository",
+			normalizer: DockerNormalizer,
+			artifact:   "Alpine",
+			wantErr:    errdef.ErrInvalidReference,
+		},
+		{
+			name:       "empty",
+			normalizer: DockerNormalizer,
+			artifact:   "",
+			wantErr:    errdef.ErrInvalidReference,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.normalizer.Parse(tt.artifact)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Normalizer.Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Normalizer.Parse() = %v, want %v", got, tt.want)
+			}
+			if err != nil {
+				return
+			}
+
+			// round trip
+			for _, s := range []string{got.String(), tt.normalizer.FamiliarString(got)} {
+				again, err := tt.normalizer.Parse(s)
+				if err != nil {
+					t.Fatalf("Normalizer.Parse(%q) error = %v", s, err)
+				}
+				if !reflect.DeepEqual(again, got) {
+					t.Errorf("Normalizer.Parse(%q) = %v, want %v", s, again, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizer_FamiliarString(t *testing.T) {
+	const dgst = "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	tests := []struct {
+		name string
+		ref  Reference
+		want string
+		// notNormalized is true if the reference is not in the normalized form
+		// and therefore does not round trip
+		notNormalized bool
+	}{
+		{
+			name: "official image",
+			ref:  Reference{Registry: "docker.io", Repository: "library/alpine", Reference: "3.19"},
+			want: "alpine:3.19",
+		},
+		{
+			name: "official image with digest",
+			ref:  Reference{Registry: "docker.io", Repository: "library/alpine", Reference: dgst},
+			want: "alpine@" + dgst,
+		},
+		{
+			name: "user image",
+			ref:  Reference{Registry: "docker.io", Repository: "foo/bar"},
+			want: "foo/bar",
+		},
+		{
+			name: "nested namespace",
+			ref:  Reference{Registry: "docker.io", Repository: "library/foo/bar"},
+			want: "library/foo/bar",
+		},
+		{
+			name: "single component without namespace",
+			ref:  Reference{Registry: "docker.io", Repository: "alpine"},
+			want: "docker.io/alpine",
+			// parsed as docker.io/library/alpine
+			notNormalized: true,
+		},
+		{
+			name: "ambiguous first component",
+			ref:  Reference{Registry: "docker.io", Repository: "foo.bar/baz"},
+			want: "docker.io/foo.bar/baz",
+		},
+		{
+			name: "other registry",
+			ref:  Reference{Registry: "ghcr.io", Repository: "oras-project/oras", Reference: "v1"},
+			want: "ghcr.io/oras-project/oras:v1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ref.FamiliarString(); got != tt.want {
+				t.Errorf("Reference.FamiliarString() = %v, want %v", got, tt.want)
+			}
+			if tt.notNormalized {
+				return
+			}
+			got, err := ParseNormalizedReference(tt.want)
+			if err != nil {
+				t.Fatalf("ParseNormalizedReference() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.ref) {
+				t.Errorf("ParseNormalizedReference() = %v, want %v", got, tt.ref)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"context"
 	"strings"
 	"sync"
+	"time"
 
 	"oras.land/oras-go/v2/errdef"
 	"oras.land/oras-go/v2/internal/syncutil"
@@ -53,19 +54,88 @@ type Cache interface {
 	Set(ctx context.Context, registry string, scheme Scheme, key string, fetch func(context.Context) (string, error)) (string, error)
 }
 
+// ExpiringCache is a Cache aware of the expiry of the cached tokens.
+// The caches created by [NewCache] and [NewSingleContextCache] implement
+// ExpiringCache.
+type ExpiringCache interface {
+	Cache
+
+	// SetWithExpiry is like Set, except that the fetch function also returns
+	// the time when the token expires, after which GetToken no longer returns
+	// the token.
+	// A zero expiry time indicates that the token does not expire.
+	SetWithExpiry(ctx context.Context, registry string, scheme Scheme, key string, fetch func(context.Context) (string, time.Time, error)) (string, error)
+}
+
+// setWithExpiry caches the token fetched by fetch with its expiry time if the
+// cache is an ExpiringCache, or without its expiry time otherwise.
+func setWithExpiry(ctx context.Context, cache Cache, registry string, scheme Scheme, key string, fetch func(context.Context) (string, time.Time, error)) (string, error) {
+	if ec, ok := cache.(ExpiringCache); ok {
+		return ec.SetWithExpiry(ctx, registry, scheme, key, fetch)
+	}
+	return cache.Set(ctx, registry, scheme, key, func(ctx context.Context) (string, error) {
+		token, _, err := fetch(ctx)
+		return token, err
+	})
+}
+
 // cacheEntry is a cache entry for a single registry.
 type cacheEntry struct {
 	scheme Scheme
-	tokens sync.Map // map[string]string
+	tokens sync.Map // map[string]*cachedToken
+}
+
+// cachedToken is a token with its expiry time.
+type cachedToken struct {
+	token string
+	// expiresAt is the time when the token expires. A zero value indicates
+	// that the token does not expire.
+	expiresAt time.Time
+	// refreshAt is the time when the token should be refreshed, which is
+	// slightly before expiresAt.
+	refreshAt time.Time
+}
+
+// expired returns true if the token has expired.
+func (t *cachedToken) expired() bool {
+	return !t.expiresAt.IsZero() && !time.Now().Before(t.expiresAt)
+}
+
+// needsRefresh returns true if the token is about to expire or has expired.
+func (t *cachedToken) needsRefresh() bool {
+	return !t.refreshAt.IsZero() && !time.Now().Before(t.refreshAt)
+}
+
+// bearerChallenge is the challenge of the authorization service for fetching
+// a bearer token.
+type bearerChallenge struct {
+	realm   string
+	service string
+	scopes  []string
+}
+
+// refreshingCache is a Cache remembering the challenges of the cached bearer
+// tokens, so that the tokens can be refreshed before they expire.
+type refreshingCache interface {
+	// setChallenge records the challenge for fetching the bearer token cached
+	// with the given key for the given registry.
+	setChallenge(registry string, key string, challenge bearerChallenge)
+
+	// challengeToRefresh returns the challenge recorded for the bearer token
+	// cached with the given key for the given registry, if the token is about
+	// to expire or has expired.
+	challengeToRefresh(registry string, key string) (bearerChallenge, bool)
 }
 
 // concurrentCache is a cache suitable for concurrent invocation.
 type concurrentCache struct {
-	status sync.Map // map[string]*syncutil.Once
-	cache  sync.Map // map[string]*cacheEntry
+	status     sync.Map // map[string]*syncutil.Once
+	cache      sync.Map // map[string]*cacheEntry
+	challenges sync.Map // map[string]bearerChallenge
 }
 
 // NewCache creates a new go-routine safe cache instance.
+// The returned cache implements ExpiringCache.
 func NewCache() Cache {
 	return &concurrentCache{}
 }
@@ -81,6 +151,7 @@ func (cc *concurrentCache) GetScheme(ctx context.Context, registry string) (Sche
 
 // GetToken returns the auth-token part cached for the given registry of a given
 // scheme.
+// Expired tokens are not returned.
 func (cc *concurrentCache) GetToken(ctx context.Context, registry string, scheme Scheme, key string) (string, error) {
 	entryValue, ok := cc.cache.Load(registry)
 	if !ok {
@@ -90,8 +161,10 @@ func (cc *concurrentCache) GetToken(ctx context.Context, registry string, scheme
 	if entry.scheme != scheme {
 		return "", errdef.ErrNotFound
 	}
-	if token, ok := entry.tokens.Load(key); ok {
-		return token.(string), nil
+	if value, ok := entry.tokens.Load(key); ok {
+		if token := value.(*cachedToken); !token.expired() {
+			return token.token, nil
+		}
 	}
 	return "", errdef.ErrNotFound
 }
@@ -101,6 +174,18 @@ func (cc *concurrentCache) GetToken(ctx context.Context, registry string, scheme
 // Set combines the fetch operation if the Set is invoked multiple times at the
 // same time.
 func (cc *concurrentCache) Set(ctx context.Context, registry string, scheme Scheme, key string, fetch func(context.Context) (string, error)) (string, error) {
+	return cc.SetWithExpiry(ctx, registry, scheme, key, func(ctx context.Context) (string, time.Time, error) {
+		token, err := fetch(ctx)
+		return token, time.Time{}, err
+	})
+}
+
+// SetWithExpiry fetches the token with its expiry time using the given fetch
+// function and caches the token for the given scheme with the given key for
+// the given registry until it expires.
+// SetWithExpiry combines the fetch operation if the SetWithExpiry is invoked
+// multiple times at the same time.
+func (cc *concurrentCache) SetWithExpiry(ctx context.Context, registry string, scheme Scheme, key string, fetch func(context.Context) (string, time.Time, error)) (string, error) {
 	// fetch token
 	statusKey := strings.Join([]string{
 		registry,
@@ -110,7 +195,18 @@ func (cc *concurrentCache) Set(ctx context.Context, registry string, scheme Sche
 	statusValue, _ := cc.status.LoadOrStore(statusKey, syncutil.NewOnce())
 	fetchOnce := statusValue.(*syncutil.Once)
 	fetchedFirst, result, err := fetchOnce.Do(ctx, func() (interface{}, error) {
-		return fetch(ctx)
+		token, expiresAt, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cached := &cachedToken{
+			token:     token,
+			expiresAt: expiresAt,
+		}
+		if !expiresAt.IsZero() {
+			cached.refreshAt = refreshTime(time.Now(), expiresAt)
+		}
+		return cached, nil
 	})
 	if fetchedFirst {
 		cc.status.Delete(statusKey)
@@ -118,9 +214,9 @@ func (cc *concurrentCache) Set(ctx context.Context, registry string, scheme Sche
 	if err != nil {
 		return "", err
 	}
-	token := result.(string)
+	token := result.(*cachedToken)
 	if !fetchedFirst {
-		return token, nil
+		return token.token, nil
 	}
 
 	// cache token
@@ -137,7 +233,36 @@ func (cc *concurrentCache) Set(ctx context.Context, registry string, scheme Sche
 	}
 	entry.tokens.Store(key, token)
 
-	return token, nil
+	return token.token, nil
+}
+
+// setChallenge records the challenge for fetching the bearer token cached with
+// the given key for the given registry.
+func (cc *concurrentCache) setChallenge(registry string, key string, challenge bearerChallenge) {
+	cc.challenges.Store(registry+" "+key, challenge)
+}
+
+// challengeToRefresh returns the challenge recorded for the bearer token cached
+// with the given key for the given registry, if the token is about to expire
+// or has expired.
+func (cc *concurrentCache) challengeToRefresh(registry string, key string) (bearerChallenge, bool) {
+	entryValue, ok := cc.cache.Load(registry)
+	if !ok {
+		return bearerChallenge{}, false
+	}
+	entry := entryValue.(*cacheEntry)
+	if entry.scheme != SchemeBearer {
+		return bearerChallenge{}, false
+	}
+	value, ok := entry.tokens.Load(key)
+	if !ok || !value.(*cachedToken).needsRefresh() {
+		return bearerChallenge{}, false
+	}
+	challenge, ok := cc.challenges.Load(registry + " " + key)
+	if !ok {
+		return bearerChallenge{}, false
+	}
+	return challenge.(bearerChallenge), true
 }
 
 // noCache is a cache implementation that does not do cache at all.
@@ -173,6 +298,26 @@ func (c *hostCache) Set(ctx context.Context, registry string, scheme Scheme, key
 	return c.Cache.Set(ctx, registry, scheme, "", fetch)
 }
 
+// SetWithExpiry implements ExpiringCache.
+func (c *hostCache) SetWithExpiry(ctx context.Context, registry string, scheme Scheme, key string, fetch func(context.Context) (string, time.Time, error)) (string, error) {
+	return setWithExpiry(ctx, c.Cache, registry, scheme, "", fetch)
+}
+
+// setChallenge implements refreshingCache.
+func (c *hostCache) setChallenge(registry string, key string, challenge bearerChallenge) {
+	if rc, ok := c.Cache.(refreshingCache); ok {
+		rc.setChallenge(registry, "", challenge)
+	}
+}
+
+// challengeToRefresh implements refreshingCache.
+func (c *hostCache) challengeToRefresh(registry string, key string) (bearerChallenge, bool) {
+	if rc, ok := c.Cache.(refreshingCache); ok {
+		return rc.challengeToRefresh(registry, "")
+	}
+	return bearerChallenge{}, false
+}
+
 // fallbackCache tries the primary cache then falls back to the secondary cache.
 type fallbackCache struct {
 	primary   Cache
@@ -213,6 +358,44 @@ func (fc *fallbackCache) Set(ctx context.Context, registry string, scheme Scheme
 	})
 }
 
+// SetWithExpiry implements ExpiringCache.
+func (fc *fallbackCache) SetWithExpiry(ctx context.Context, registry string, scheme Scheme, key string, fetch func(context.Context) (string, time.Time, error)) (string, error) {
+	var expiresAt time.Time
+	token, err := setWithExpiry(ctx, fc.primary, registry, scheme, key, func(ctx context.Context) (string, time.Time, error) {
+		token, exp, err := fetch(ctx)
+		expiresAt = exp
+		return token, exp, err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return setWithExpiry(ctx, fc.secondary, registry, scheme, key, func(ctx context.Context) (string, time.Time, error) {
+		return token, expiresAt, nil
+	})
+}
+
+// setChallenge implements refreshingCache.
+func (fc *fallbackCache) setChallenge(registry string, key string, challenge bearerChallenge) {
+	for _, cache := range []Cache{fc.primary, fc.secondary} {
+		if rc, ok := cache.(refreshingCache); ok {
+			rc.setChallenge(registry, key, challenge)
+		}
+	}
+}
+
+// challengeToRefresh implements refreshingCache.
+func (fc *fallbackCache) challengeToRefresh(registry string, key string) (bearerChallenge, bool) {
+	for _, cache := range []Cache{fc.primary, fc.secondary} {
+		if rc, ok := cache.(refreshingCache); ok {
+			if challenge, ok := rc.challengeToRefresh(registry, key); ok {
+				return challenge, true
+			}
+		}
+	}
+	return bearerChallenge{}, false
+}
+
 // NewSingleContextCache creates a host-based cache for optimizing the auth flow for non-compliant registries.
 // It is intended to be used in a single context, such as pulling from a single repository.
 // This cache should not be shared.
@@ -673,3 +673,61 @@ func Test_fallbackCache(t *testing.T) {
 		}
 	}
 }
+
+func Test_concurrentCache_SetWithExpiry(t *testing.T) {
+	ctx := context.Background()
+	registry := "localhost:5000"
+	scheme := SchemeBearer
+	key := "key"
+
+	for name, cache := range map[string]Cache{
+		"concurrentCache": NewCache(),
+		"fallbackCache":   NewSingleContextCache(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ec, ok := cache.(ExpiringCache)
+			if !ok {
+				t.Fatalf("%T is not an ExpiringCache", cache)
+			}
+
+			// expired tokens are not returned
+			got, err := ec.SetWithExpiry(ctx, registry, scheme, key, func(context.Context) (string, time.Time, error) {
+				return "foo", time.Now().Add(-time.Second), nil
+			})
+			if err != nil {
+				t.Fatalf("SetWithExpiry() error = %v", err)
+			}
+			if want := "foo"; got != want {
+				t.Errorf("SetWithExpiry() = %v, want %v", got, want)
+			}
+			if _, err := ec.GetToken(ctx, registry, scheme, key); !errors.Is(err, errdef.ErrNotFound) {
+				t.Errorf("GetToken() error = %v, wantErr %v", err, errdef.ErrNotFound)
+			}
+			if _, err := ec.GetToken(ctx, registry, scheme, "other key"); !errors.Is(err, errdef.ErrNotFound) {
+				t.Errorf("GetToken() error = %v, wantErr %v", err, errdef.ErrNotFound)
+			}
+
+			// unexpired tokens are returned
+			if _, err := ec.SetWithExpiry(ctx, registry, scheme, key, func(context.Context) (string, time.Time, error) {
+				return "bar", time.Now().Add(time.Hour), nil
+			}); err != nil {
+				t.Fatalf("SetWithExpiry() error = %v", err)
+			}
+			got, err = ec.GetToken(ctx, registry, scheme, key)
+			if err != nil {
+				t.Fatalf("GetToken() error = %v", err)
+			}
+			if want := "bar"; got != want {
+				t.Errorf("GetToken() = %v, want %v", got, want)
+			}
+
+			// fetch errors are returned
+			wantErr := errors.New("fetch failed")
+			if _, err := ec.SetWithExpiry(ctx, registry, scheme, key, func(context.Context) (string, time.Time, error) {
+				return "", time.Time{}, wantErr
+			}); !errors.Is(err, wantErr) {
+				t.Errorf("SetWithExpiry() error = %v, wantErr %v", err, wantErr)
+			}
+		})
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"oras.land/oras-go/v2/registry/remote/internal/errutil"
 	"oras.land/oras-go/v2/registry/remote/retry"
@@ -58,6 +59,15 @@ var maxResponseBytes int64 = 128 * 1024 // 128 KiB
 // See also ClientID.
 var defaultClientID = "oras-go"
 
+// defaultTokenExpiresIn is the lifetime of the bearer tokens in seconds if the
+// authorization service does not specify the `expires_in` field.
+// Reference: https://distribution.github.io/distribution/spec/auth/token/#token-response-fields
+const defaultTokenExpiresIn = 60
+
+// maxTokenRefreshWindow is the maximum duration before the expiry of a cached
+// bearer token when the token is refreshed proactively.
+const maxTokenRefreshWindow = time.Minute
+
 // CredentialFunc represents a function that resolves the credential for the
 // given registry (i.e. host:port).
 //
@@ -120,6 +130,20 @@ type Client struct {
 	// - https://distribution.github.io/distribution/spec/auth/jwt/
 	// - https://distribution.github.io/distribution/spec/auth/oauth/
 	ForceAttemptOAuth2 bool
+
+	// HandleRefreshToken, if set, is called with the refresh token returned
+	// by the authorization service for the given registry (i.e. host:port),
+	// so that it can be stored into the credential source and used for
+	// fetching access tokens afterwards.
+	// For instance, the refresh token can be set as the RefreshToken of the
+	// credential in a credential store.
+	// Setting HandleRefreshToken also requests refresh tokens from the
+	// authorization service when authenticating using username and password.
+	// If HandleRefreshToken returns an error, the authentication fails.
+	// References:
+	//   - https://distribution.github.io/distribution/spec/auth/token/#requesting-a-token
+	//   - https://distribution.github.io/distribution/spec/auth/oauth/#getting-a-token
+	HandleRefreshToken func(ctx context.Context, registry string, refreshToken string) error
 }
 
 // client returns an HTTP client used to access the remote registry.
@@ -193,6 +217,15 @@ func (c *Client) Do(originalReq *http.Request) (*http.Response, error) {
 		case SchemeBearer:
 			scopes := GetAllScopesForHost(ctx, host)
 			attemptedKey = strings.Join(scopes, " ")
+			if rc, ok := cache.(refreshingCache); ok {
+				if challenge, ok := rc.challengeToRefresh(host, attemptedKey); ok {
+					// refresh the token before it expires, so that the
+					// request is not rejected.
+					// On failure, the token is used until it expires, and
+					// the request is authenticated again if rejected.
+					_, _ = c.setBearerToken(ctx, cache, host, attemptedKey, challenge)
+				}
+			}
 			token, err := cache.GetToken(ctx, host, SchemeBearer, attemptedKey)
 			if err == nil {
 				req.Header.Set("Authorization", "Bearer "+token)
@@ -256,10 +289,10 @@ func (c *Client) Do(originalReq *http.Request) (*http.Response, error) {
 		}
 
 		// attempt with credentials
-		realm := params["realm"]
-		service := params["service"]
-		token, err := cache.Set(ctx, host, SchemeBearer, key, func(ctx context.Context) (string, error) {
-			return c.fetchBearerToken(ctx, host, realm, service, scopes)
+		token, err := c.setBearerToken(ctx, cache, host, key, bearerChallenge{
+			realm:   params["realm"],
+			service: params["service"],
+			scopes:  scopes,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("%s %q: %w", resp.Request.Method, resp.Request.URL, err)
@@ -277,6 +310,22 @@ func (c *Client) Do(originalReq *http.Request) (*http.Response, error) {
 	return c.send(req)
 }
 
+// setBearerToken fetches a bearer token for the challenge and caches it with
+// the key for the registry, where the challenge is recorded so that the token
+// can be refreshed before it expires.
+func (c *Client) setBearerToken(ctx context.Context, cache Cache, registry string, key string, challenge bearerChallenge) (string, error) {
+	token, err := setWithExpiry(ctx, cache, registry, SchemeBearer, key, func(ctx context.Context) (string, time.Time, error) {
+		return c.fetchBearerToken(ctx, registry, challenge.realm, challenge.service, challenge.scopes)
+	})
+	if err != nil {
+		return "", err
+	}
+	if rc, ok := cache.(refreshingCache); ok {
+		rc.setChallenge(registry, key, challenge)
+	}
+	return token, nil
+}
+
 // fetchBasicAuth fetches a basic auth token for the basic challenge.
 func (c *Client) fetchBasicAuth(ctx context.Context, registry string) (string, error) {
 	cred, err := c.credential(ctx, registry)
@@ -293,19 +342,86 @@ func (c *Client) fetchBasicAuth(ctx context.Context, registry string) (string, e
 	return base64.StdEncoding.EncodeToString([]byte(auth)), nil
 }
 
-// fetchBearerToken fetches an access token for the bearer challenge.
-func (c *Client) fetchBearerToken(ctx context.Context, registry, realm, service string, scopes []string) (string, error) {
+// fetchBearerToken fetches an access token for the bearer challenge, and
+// returns the token with the time when it expires.
+// A zero time is returned if the token does not expire.
+func (c *Client) fetchBearerToken(ctx context.Context, registry, realm, service string, scopes []string) (string, time.Time, error) {
 	cred, err := c.credential(ctx, registry)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 	if cred.AccessToken != "" {
-		return cred.AccessToken, nil
+		return cred.AccessToken, time.Time{}, nil
 	}
+
+	var result *tokenResponse
+	requestedAt := time.Now()
 	if cred == EmptyCredential || (cred.RefreshToken == "" && !c.ForceAttemptOAuth2) {
-		return c.fetchDistributionToken(ctx, realm, service, scopes, cred.Username, cred.Password)
+		result, err = c.fetchDistributionToken(ctx, realm, service, scopes, cred.Username, cred.Password)
+	} else {
+		result, err = c.fetchOAuth2Token(ctx, realm, service, scopes, cred)
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if result.RefreshToken != "" && result.RefreshToken != cred.RefreshToken && c.HandleRefreshToken != nil {
+		if err := c.HandleRefreshToken(ctx, registry, result.RefreshToken); err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to handle refresh token: %w", err)
+		}
+	}
+	return result.token(), result.expiryTime(requestedAt), nil
+}
+
+// tokenResponse is the response of the authorization service.
+// References:
+//   - https://distribution.github.io/distribution/spec/auth/token/#token-response-fields
+//   - https://distribution.github.io/distribution/spec/auth/oauth/#token-response-fields
+type tokenResponse struct {
+	// Token and AccessToken are the bearer token. As specified by the
+	// distribution, the token is either in `token` or `access_token`. If both
+	// present, they are identical.
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+
+	// RefreshToken is the token which can be used to get additional access
+	// tokens.
+	RefreshToken string `json:"refresh_token"`
+
+	// ExpiresIn is the lifetime of the token in seconds.
+	ExpiresIn int `json:"expires_in"`
+
+	// IssuedAt is the time when the token was issued in RFC 3339 format.
+	// It is not used to compute the expiry of the token since it is truncated
+	// to seconds and is subject to the clock of the server.
+	IssuedAt string `json:"issued_at"`
+}
+
+// token returns the bearer token in the response.
+func (r *tokenResponse) token() string {
+	if r.AccessToken != "" {
+		return r.AccessToken
 	}
-	return c.fetchOAuth2Token(ctx, realm, service, scopes, cred)
+	return r.Token
+}
+
+// expiryTime returns the time when the token expires.
+// The lifetime of the token is anchored on the time of requesting, which is
+// never later than the actual issued time, so that neither the clock skews
+// between the client and the server nor the truncated issued time in the
+// response shortens the lifetime.
+func (r *tokenResponse) expiryTime(requestedAt time.Time) time.Time {
+	expiresIn := time.Duration(r.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = defaultTokenExpiresIn * time.Second
+	}
+	return requestedAt.Add(expiresIn)
+}
+
+// refreshTime returns the time when a token expiring at expiresAt should be
+// refreshed as of now, which is slightly before its expiry.
+func refreshTime(now, expiresAt time.Time) time.Time {
+	refreshWindow := max(min(expiresAt.Sub(now)/10, maxTokenRefreshWindow), 0)
+	return expiresAt.Add(-refreshWindow)
 }
 
 // fetchDistributionToken fetches an access token as defined by the distribution
@@ -314,15 +430,19 @@ func (c *Client) fetchBearerToken(ctx context.Context, registry, realm, service
 // References:
 // - https://distribution.github.io/distribution/spec/auth/jwt/
 // - https://distribution.github.io/distribution/spec/auth/token/
-func (c *Client) fetchDistributionToken(ctx context.Context, realm, service string, scopes []string, username, password string) (string, error) {
+func (c *Client) fetchDistributionToken(ctx context.Context, realm, service string, scopes []string, username, password string) (*tokenResponse, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	q := req.URL.Query()
 	if username != "" || password != "" {
 		req.SetBasicAuth(username, password)
+		if c.HandleRefreshToken != nil {
+			q.Set("offline_token", "true")
+			q.Set("client_id", c.clientID())
+		}
 	}
-	q := req.URL.Query()
 	if service != "" {
 		q.Set("service", service)
 	}
@@ -333,36 +453,27 @@ func (c *Client) fetchDistributionToken(ctx context.Context, realm, service stri
 
 	resp, err := c.send(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return "", errutil.ParseErrorResponse(resp)
+		return nil, errutil.ParseErrorResponse(resp)
 	}
 
-	// As specified in https://distribution.github.io/distribution/spec/auth/token/ section
-	// "Token Response Fields", the token is either in `token` or
-	// `access_token`. If both present, they are identical.
-	var result struct {
-		Token       string `json:"token"`
-		AccessToken string `json:"access_token"`
-	}
+	var result tokenResponse
 	lr := io.LimitReader(resp.Body, maxResponseBytes)
 	if err := json.NewDecoder(lr).Decode(&result); err != nil {
-		return "", fmt.Errorf("%s %q: failed to decode response: %w", resp.Request.Method, resp.Request.URL, err)
-	}
-	if result.AccessToken != "" {
-		return result.AccessToken, nil
+		return nil, fmt.Errorf("%s %q: failed to decode response: %w", resp.Request.Method, resp.Request.URL, err)
 	}
-	if result.Token != "" {
-		return result.Token, nil
+	if result.token() == "" {
+		return nil, fmt.Errorf("%s %q: empty token returned", resp.Request.Method, resp.Request.URL)
 	}
-	return "", fmt.Errorf("%s %q: empty token returned", resp.Request.Method, resp.Request.URL)
+	return &result, nil
 }
 
 // fetchOAuth2Token fetches an OAuth2 access token.
 // Reference: https://distribution.github.io/distribution/spec/auth/oauth/
-func (c *Client) fetchOAuth2Token(ctx context.Context, realm, service string, scopes []string, cred Credential) (string, error) {
+func (c *Client) fetchOAuth2Token(ctx context.Context, realm, service string, scopes []string, cred Credential) (*tokenResponse, error) {
 	form := url.Values{}
 	if cred.RefreshToken != "" {
 		form.Set("grant_type", "refresh_token")
@@ -371,15 +482,14 @@ func (c *Client) fetchOAuth2Token(ctx context.Context, realm, service string, sc
 		form.Set("grant_type", "password")
 		form.Set("username", cred.Username)
 		form.Set("password", cred.Password)
+		if c.HandleRefreshToken != nil {
+			form.Set("access_type", "offline")
+		}
 	} else {
-		return "", errors.New("missing username or password for bearer auth")
+		return nil, errors.New("missing username or password for bearer auth")
 	}
 	form.Set("service", service)
-	clientID := c.ClientID
-	if clientID == "" {
-		clientID = defaultClientID
-	}
-	form.Set("client_id", clientID)
+	form.Set("client_id", c.clientID())
 	if len(scopes) != 0 {
 		form.Set("scope", strings.Join(scopes, " "))
 	}
@@ -387,30 +497,37 @@ func (c *Client) fetchOAuth2Token(ctx context.Context, realm, service string, sc
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, realm, body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	resp, err := c.send(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return "", errutil.ParseErrorResponse(resp)
+		return nil, errutil.ParseErrorResponse(resp)
 	}
 
-	var result struct {
-		AccessToken string `json:"access_token"`
-	}
+	var result tokenResponse
 	lr := io.LimitReader(resp.Body, maxResponseBytes)
 	if err := json.NewDecoder(lr).Decode(&result); err != nil {
-		return "", fmt.Errorf("%s %q: failed to decode response: %w", resp.Request.Method, resp.Request.URL, err)
+		return nil, fmt.Errorf("%s %q: failed to decode response: %w", resp.Request.Method, resp.Request.URL, err)
 	}
-	if result.AccessToken != "" {
-		return result.AccessToken, nil
+	if result.AccessToken == "" {
+		return nil, fmt.Errorf("%s %q: empty token returned", resp.Request.Method, resp.Request.URL)
+	}
+	return &result, nil
+}
+
+// clientID returns the client ID used in fetching tokens.
+// A default client ID is returned if the client ID is not configured.
+func (c *Client) clientID() string {
+	if c.ClientID == "" {
+		return defaultClientID
 	}
-	return "", fmt.Errorf("%s %q: empty token returned", resp.Request.Method, resp.Request.URL)
+	return c.ClientID
 }
 
 // rewindRequestBody tries to rewind the request body if exists.
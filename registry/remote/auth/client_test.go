@@ -24,9 +24,11 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"oras.land/oras-go/v2/registry/remote/errcode"
 )
@@ -3976,3 +3978,310 @@ func TestClient_fetchBasicAuth(t *testing.T) {
 		t.Errorf("incorrect error: %v, expected %v", err, ErrBasicCredentialNotFound)
 	}
 }
+
+func TestClient_Do_Token_Refresh(t *testing.T) {
+	var tokenCount int64
+	var requestCount, wantRequestCount int64
+	var successCount, wantSuccessCount int64
+	var unauthorizedCount int64
+	var authCount, wantAuthCount int64
+	var service string
+	scope := "repository:test:pull"
+	as := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/" {
+			t.Error("unexecuted attempt of authorization service")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		atomic.AddInt64(&authCount, 1)
+		token := strconv.FormatInt(atomic.AddInt64(&tokenCount, 1), 10)
+		if _, err := fmt.Fprintf(w, `{"token":%q,"expires_in":300}`, token); err != nil {
+			t.Errorf("failed to write %q: %v", r.URL, err)
+		}
+	}))
+	defer as.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requestCount, 1)
+		header := "Bearer " + strconv.FormatInt(atomic.LoadInt64(&tokenCount), 10)
+		switch auth := r.Header.Get("Authorization"); auth {
+		case header:
+			atomic.AddInt64(&successCount, 1)
+		case "":
+			atomic.AddInt64(&unauthorizedCount, 1)
+			challenge := fmt.Sprintf("Bearer realm=%q,service=%q,scope=%q", as.URL, service, scope)
+			w.Header().Set("Www-Authenticate", challenge)
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			t.Errorf("expiring token is used: %s", auth)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+	uri, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("invalid test http server: %v", err)
+	}
+	service = uri.Host
+
+	cache := NewCache().(*concurrentCache)
+	client := &Client{
+		Cache: cache,
+	}
+	ctx := WithScopes(context.Background(), scope)
+	do := func() {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+		if err != nil {
+			t.Fatalf("failed to create test request: %v", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Client.Do() error = %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Client.Do() = %v, want %v", resp.StatusCode, http.StatusOK)
+		}
+	}
+	// age moves the refresh time and the expiry time of the cached token
+	age := func(refreshAt, expiresAt time.Time) {
+		entry, ok := cache.cache.Load(uri.Host)
+		if !ok {
+			t.Fatal("token not cached")
+		}
+		tokens := &entry.(*cacheEntry).tokens
+		value, ok := tokens.Load(scope)
+		if !ok {
+			t.Fatal("token not cached")
+		}
+		tokens.Store(scope, &cachedToken{
+			token:     value.(*cachedToken).token,
+			refreshAt: refreshAt,
+			expiresAt: expiresAt,
+		})
+	}
+
+	// first request
+	do()
+	if wantRequestCount += 2; requestCount != wantRequestCount {
+		t.Errorf("unexpected number of requests: %d, want %d", requestCount, wantRequestCount)
+	}
+	if wantSuccessCount++; successCount != wantSuccessCount {
+		t.Errorf("unexpected number of successful requests: %d, want %d", successCount, wantSuccessCount)
+	}
+	if wantAuthCount++; authCount != wantAuthCount {
+		t.Errorf("unexpected number of auth requests: %d, want %d", authCount, wantAuthCount)
+	}
+
+	// the cached token is used before it should be refreshed
+	do()
+	if wantRequestCount++; requestCount != wantRequestCount {
+		t.Errorf("unexpected number of requests: %d, want %d", requestCount, wantRequestCount)
+	}
+	if wantSuccessCount++; successCount != wantSuccessCount {
+		t.Errorf("unexpected number of successful requests: %d, want %d", successCount, wantSuccessCount)
+	}
+	if authCount != wantAuthCount {
+		t.Errorf("unexpected number of auth requests: %d, want %d", authCount, wantAuthCount)
+	}
+
+	// the token is refreshed before sending the request once it is about to
+	// expire, or has expired
+	now := time.Now()
+	for _, expiresAt := range []time.Time{now.Add(time.Second), now.Add(-time.Second)} {
+		age(now.Add(-time.Second), expiresAt)
+		do()
+		if wantRequestCount++; requestCount != wantRequestCount {
+			t.Errorf("unexpected number of requests: %d, want %d", requestCount, wantRequestCount)
+		}
+		if wantSuccessCount++; successCount != wantSuccessCount {
+			t.Errorf("unexpected number of successful requests: %d, want %d", successCount, wantSuccessCount)
+		}
+		if wantAuthCount++; authCount != wantAuthCount {
+			t.Errorf("unexpected number of auth requests: %d, want %d", authCount, wantAuthCount)
+		}
+	}
+	if unauthorizedCount != 1 {
+		t.Errorf("unexpected number of unauthorized requests: %d, want %d", unauthorizedCount, 1)
+	}
+}
+
+func TestClient_Do_HandleRefreshToken(t *testing.T) {
+	username := "test_user"
+	password := "test_password"
+	accessToken := "test/access/token"
+	refreshToken := "test/refresh/token"
+	var service string
+	scope := "repository:test:pull"
+	as := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if got := r.PostForm.Get("access_type"); got != "offline" {
+			t.Errorf("unexpected access type: %v, want %v", got, "offline")
+		}
+		if got := r.PostForm.Get("grant_type"); got != "password" {
+			t.Errorf("unexpected grant type: %v, want %v", got, "password")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if _, err := fmt.Fprintf(w, `{"access_token":%q,"refresh_token":%q}`, accessToken, refreshToken); err != nil {
+			t.Errorf("failed to write %q: %v", r.URL, err)
+		}
+	}))
+	defer as.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer "+accessToken {
+			challenge := fmt.Sprintf("Bearer realm=%q,service=%q,scope=%q", as.URL, service, scope)
+			w.Header().Set("Www-Authenticate", challenge)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}))
+	defer ts.Close()
+	uri, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("invalid test http server: %v", err)
+	}
+	service = uri.Host
+
+	var gotRegistry, gotRefreshToken string
+	client := &Client{
+		Credential: StaticCredential(uri.Host, Credential{
+			Username: username,
+			Password: password,
+		}),
+		ForceAttemptOAuth2: true,
+		HandleRefreshToken: func(ctx context.Context, registry string, refreshToken string) error {
+			gotRegistry, gotRefreshToken = registry, refreshToken
+			return nil
+		},
+	}
+	ctx := WithScopes(context.Background(), scope)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create test request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Client.Do() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Client.Do() = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	if gotRegistry != uri.Host || gotRefreshToken != refreshToken {
+		t.Errorf("HandleRefreshToken() got (%s, %s), want (%s, %s)", gotRegistry, gotRefreshToken, uri.Host, refreshToken)
+	}
+
+	// errors of the handler fail the authentication
+	wantErr := errors.New("failed to store")
+	client.HandleRefreshToken = func(context.Context, string, string) error {
+		return wantErr
+	}
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create test request: %v", err)
+	}
+	if _, err := client.Do(req); !errors.Is(err, wantErr) {
+		t.Errorf("Client.Do() error = %v, wantErr %v", err, wantErr)
+	}
+}
+
+func Test_tokenResponse_expiryTime(t *testing.T) {
+	requestedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		response tokenResponse
+		want     time.Time
+	}{
+		{
+			name:     "default lifetime",
+			response: tokenResponse{},
+			want:     requestedAt.Add(60 * time.Second),
+		},
+		{
+			name:     "long lifetime",
+			response: tokenResponse{ExpiresIn: 3600},
+			want:     requestedAt.Add(time.Hour),
+		},
+		{
+			name: "issued earlier",
+			response: tokenResponse{
+				ExpiresIn: 300,
+				IssuedAt:  requestedAt.Add(-100 * time.Second).Format(time.RFC3339),
+			},
+			want: requestedAt.Add(300 * time.Second),
+		},
+		{
+			name: "truncated issued time",
+			response: tokenResponse{
+				ExpiresIn: 1,
+				IssuedAt:  requestedAt.Add(-time.Second).Format(time.RFC3339),
+			},
+			want: requestedAt.Add(time.Second),
+		},
+		{
+			name: "server clock behind",
+			response: tokenResponse{
+				ExpiresIn: 5,
+				IssuedAt:  requestedAt.Add(-4 * time.Second).Format(time.RFC3339),
+			},
+			want: requestedAt.Add(5 * time.Second),
+		},
+		{
+			name: "issued in the future",
+			response: tokenResponse{
+				ExpiresIn: 300,
+				IssuedAt:  requestedAt.Add(time.Hour).Format(time.RFC3339),
+			},
+			want: requestedAt.Add(300 * time.Second),
+		},
+		{
+			name: "issued long ago",
+			response: tokenResponse{
+				ExpiresIn: 300,
+				IssuedAt:  requestedAt.Add(-time.Hour).Format(time.RFC3339),
+			},
+			want: requestedAt.Add(300 * time.Second),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.response.expiryTime(requestedAt); !got.Equal(tt.want) {
+				t.Errorf("tokenResponse.expiryTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_refreshTime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		want      time.Time
+	}{
+		{
+			name:      "short lifetime",
+			expiresAt: now.Add(60 * time.Second),
+			want:      now.Add(54 * time.Second),
+		},
+		{
+			name:      "long lifetime",
+			expiresAt: now.Add(time.Hour),
+			want:      now.Add(59 * time.Minute),
+		},
+		{
+			name:      "expired",
+			expiresAt: now.Add(-time.Second),
+			want:      now.Add(-time.Second),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refreshTime(now, tt.expiresAt); !got.Equal(tt.want) {
+				t.Errorf("refreshTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
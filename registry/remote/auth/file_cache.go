@@ -0,0 +1,263 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/fs/filelock"
+)
+
+// fileCacheRecord is the content of a cache file for a single registry.
+type fileCacheRecord struct {
+	// Registry is the registry of the record.
+	Registry string `json:"registry"`
+
+	// Scheme is the auth-scheme of the registry.
+	Scheme string `json:"scheme"`
+
+	// Tokens maps the keys to the bearer tokens.
+	Tokens map[string]fileCacheToken `json:"tokens,omitempty"`
+}
+
+// fileCacheToken is a bearer token stored in a cache file.
+type fileCacheToken struct {
+	// Token is the bearer token.
+	Token string `json:"token"`
+
+	// ExpiresAt is the time when the token expires.
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// expired returns true if the token has expired.
+func (t fileCacheToken) expired() bool {
+	return !time.Now().Before(t.ExpiresAt)
+}
+
+// fileCache is a cache persisting the auth-schemes and the bearer tokens in
+// files, backed by an in-memory cache.
+type fileCache struct {
+	root   string
+	memory *concurrentCache
+}
+
+// NewFileCache creates a go-routine safe cache, which persists the
+// auth-schemes and the bearer tokens of the registries in the directory root,
+// so that the cache can be shared by multiple processes, such as the
+// invocations of a command line tool.
+// The returned cache implements ExpiringCache.
+//
+// Only the bearer tokens with expiry times, i.e. the tokens fetched from the
+// authorization services, are persisted. The other tokens, such as the basic
+// auth tokens encoding usernames and passwords, are cached in the memory
+// only. Expired tokens are evicted from the files on updates.
+//
+// The files are not encrypted but are only accessible by the current user,
+// where the directory is created with permission 0700 and the files with
+// permission 0600. Updates to the files are serialized by file locks and
+// are written atomically, so that concurrent processes always read
+// consistent files.
+// Failures in persisting the tokens are ignored as the tokens are still
+// cached in the memory.
+func NewFileCache(root string) (Cache, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &fileCache{
+		root:   root,
+		memory: &concurrentCache{},
+	}, nil
+}
+
+// GetScheme returns the auth-scheme part cached for the given registry.
+func (fc *fileCache) GetScheme(ctx context.Context, registry string) (Scheme, error) {
+	if scheme, err := fc.memory.GetScheme(ctx, registry); err == nil {
+		return scheme, nil
+	}
+	record, err := fc.load(registry)
+	if err != nil {
+		return SchemeUnknown, err
+	}
+	return parseScheme(record.Scheme), nil
+}
+
+// GetToken returns the auth-token part cached for the given registry of a given
+// scheme.
+// Expired tokens are not returned.
+func (fc *fileCache) GetToken(ctx context.Context, registry string, scheme Scheme, key string) (string, error) {
+	if token, err := fc.memory.GetToken(ctx, registry, scheme, key); err == nil {
+		return token, nil
+	}
+	record, err := fc.load(registry)
+	if err != nil {
+		return "", err
+	}
+	if parseScheme(record.Scheme) != scheme {
+		return "", errdef.ErrNotFound
+	}
+	token, ok := record.Tokens[key]
+	if !ok || token.expired() {
+		return "", errdef.ErrNotFound
+	}
+	return token.Token, nil
+}
+
+// Set fetches the token using the given fetch function and caches the token
+// for the given scheme with the given key for the given registry.
+func (fc *fileCache) Set(ctx context.Context, registry string, scheme Scheme, key string, fetch func(context.Context) (string, error)) (string, error) {
+	return fc.SetWithExpiry(ctx, registry, scheme, key, func(ctx context.Context) (string, time.Time, error) {
+		token, err := fetch(ctx)
+		return token, time.Time{}, err
+	})
+}
+
+// SetWithExpiry fetches the token with its expiry time using the given fetch
+// function and caches the token for the given scheme with the given key for
+// the given registry until it expires.
+// SetWithExpiry combines the fetch operation if the SetWithExpiry is invoked
+// multiple times at the same time in the same process.
+func (fc *fileCache) SetWithExpiry(ctx context.Context, registry string, scheme Scheme, key string, fetch func(context.Context) (string, time.Time, error)) (string, error) {
+	return fc.memory.SetWithExpiry(ctx, registry, scheme, key, func(ctx context.Context) (string, time.Time, error) {
+		token, expiresAt, err := fetch(ctx)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		// the token is cached in the memory even if it fails to persist
+		_ = fc.store(registry, scheme, key, token, expiresAt)
+		return token, expiresAt, nil
+	})
+}
+
+// setChallenge implements refreshingCache.
+// The challenges are recorded in the memory only, so that only the tokens
+// fetched by the current process are refreshed before they expire.
+func (fc *fileCache) setChallenge(registry string, key string, challenge bearerChallenge) {
+	fc.memory.setChallenge(registry, key, challenge)
+}
+
+// challengeToRefresh implements refreshingCache.
+func (fc *fileCache) challengeToRefresh(registry string, key string) (bearerChallenge, bool) {
+	return fc.memory.challengeToRefresh(registry, key)
+}
+
+// path returns the path of the cache file for the registry.
+func (fc *fileCache) path(registry string) string {
+	sum := sha256.Sum256([]byte(registry))
+	return filepath.Join(fc.root, hex.EncodeToString(sum[:])+".json")
+}
+
+// load reads the cache file for the registry.
+// Returns errdef.ErrNotFound if the file does not exist or is corrupted.
+func (fc *fileCache) load(registry string) (*fileCacheRecord, error) {
+	data, err := os.ReadFile(fc.path(registry))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errdef.ErrNotFound
+		}
+		return nil, err
+	}
+	var record fileCacheRecord
+	if err := json.Unmarshal(data, &record); err != nil || record.Registry != registry {
+		return nil, errdef.ErrNotFound
+	}
+	return &record, nil
+}
+
+// store updates the cache file for the registry with the scheme and the
+// token, evicting the expired tokens.
+// The token is persisted only if it is a bearer token with an expiry time.
+func (fc *fileCache) store(registry string, scheme Scheme, key string, token string, expiresAt time.Time) (err error) {
+	path := fc.path(registry)
+	lock, err := filelock.Lock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := filelock.Unlock(lock); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	record, err := fc.load(registry)
+	if err != nil {
+		if !errors.Is(err, errdef.ErrNotFound) {
+			return err
+		}
+		record = &fileCacheRecord{Registry: registry}
+	}
+	if parseScheme(record.Scheme) != scheme {
+		// invalidate all the tokens of the previous scheme
+		record.Scheme = scheme.String()
+		record.Tokens = nil
+	}
+	for k, t := range record.Tokens {
+		if t.expired() {
+			delete(record.Tokens, k)
+		}
+	}
+	if scheme == SchemeBearer && !expiresAt.IsZero() {
+		if record.Tokens == nil {
+			record.Tokens = make(map[string]fileCacheToken)
+		}
+		record.Tokens[key] = fileCacheToken{
+			Token:     token,
+			ExpiresAt: expiresAt,
+		}
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes data to a temporary file with permission 0600 and
+// renames it to path, so that the readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) (writeErr error) {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), ".oras_auth_cache_temp_*")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+	defer func() {
+		// remove the temp file in case of error
+		if writeErr != nil {
+			os.Remove(tempPath)
+		}
+	}()
+
+	if err := tempFile.Chmod(0600); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
@@ -0,0 +1,198 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"oras.land/oras-go/v2/errdef"
+)
+
+func Test_fileCache(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	registry := "localhost:5000"
+	cache, err := NewFileCache(root)
+	if err != nil {
+		t.Fatalf("NewFileCache() error = %v", err)
+	}
+	ec := cache.(ExpiringCache)
+	expiresAt := time.Now().Add(time.Hour)
+	if _, err := ec.SetWithExpiry(ctx, registry, SchemeBearer, "key", func(context.Context) (string, time.Time, error) {
+		return "foo", expiresAt, nil
+	}); err != nil {
+		t.Fatalf("fileCache.SetWithExpiry() error = %v", err)
+	}
+	if _, err := ec.SetWithExpiry(ctx, registry, SchemeBearer, "expired", func(context.Context) (string, time.Time, error) {
+		return "bar", time.Now().Add(-time.Second), nil
+	}); err != nil {
+		t.Fatalf("fileCache.SetWithExpiry() error = %v", err)
+	}
+	if _, err := ec.Set(ctx, registry, SchemeBearer, "static", func(context.Context) (string, error) {
+		return "baz", nil
+	}); err != nil {
+		t.Fatalf("fileCache.Set() error = %v", err)
+	}
+
+	// a cache in another process shares the persisted tokens
+	other, err := NewFileCache(root)
+	if err != nil {
+		t.Fatalf("NewFileCache() error = %v", err)
+	}
+	scheme, err := other.GetScheme(ctx, registry)
+	if err != nil {
+		t.Fatalf("fileCache.GetScheme() error = %v", err)
+	}
+	if scheme != SchemeBearer {
+		t.Errorf("fileCache.GetScheme() = %v, want %v", scheme, SchemeBearer)
+	}
+	got, err := other.GetToken(ctx, registry, SchemeBearer, "key")
+	if err != nil {
+		t.Fatalf("fileCache.GetToken() error = %v", err)
+	}
+	if want := "foo"; got != want {
+		t.Errorf("fileCache.GetToken() = %v, want %v", got, want)
+	}
+	// expired tokens and tokens without expiry are not shared
+	for _, key := range []string{"expired", "static"} {
+		if _, err := other.GetToken(ctx, registry, SchemeBearer, key); !errors.Is(err, errdef.ErrNotFound) {
+			t.Errorf("fileCache.GetToken(%s) error = %v, wantErr %v", key, err, errdef.ErrNotFound)
+		}
+	}
+	if _, err := other.GetToken(ctx, registry, SchemeBasic, "key"); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("fileCache.GetToken() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+	// tokens without expiry are cached in the memory
+	got, err = cache.GetToken(ctx, registry, SchemeBearer, "static")
+	if err != nil {
+		t.Fatalf("fileCache.GetToken() error = %v", err)
+	}
+	if want := "baz"; got != want {
+		t.Errorf("fileCache.GetToken() = %v, want %v", got, want)
+	}
+
+	// expired tokens are evicted from the file
+	record, err := cache.(*fileCache).load(registry)
+	if err != nil {
+		t.Fatalf("fileCache.load() error = %v", err)
+	}
+	if _, ok := record.Tokens["expired"]; ok || len(record.Tokens) != 1 {
+		t.Errorf("persisted tokens = %v, want only %q", record.Tokens, "key")
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(cache.(*fileCache).path(registry))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("cache file permission = %v, want %v", perm, os.FileMode(0600))
+		}
+	}
+
+	// a scheme change invalidates the persisted tokens
+	if _, err := other.Set(ctx, registry, SchemeBasic, "", func(context.Context) (string, error) {
+		return "basic", nil
+	}); err != nil {
+		t.Fatalf("fileCache.Set() error = %v", err)
+	}
+	third, err := NewFileCache(root)
+	if err != nil {
+		t.Fatalf("NewFileCache() error = %v", err)
+	}
+	if scheme, err := third.GetScheme(ctx, registry); err != nil || scheme != SchemeBasic {
+		t.Errorf("fileCache.GetScheme() = %v, %v, want %v", scheme, err, SchemeBasic)
+	}
+	for _, scheme := range []Scheme{SchemeBasic, SchemeBearer} {
+		if _, err := third.GetToken(ctx, registry, scheme, "key"); !errors.Is(err, errdef.ErrNotFound) {
+			t.Errorf("fileCache.GetToken() error = %v, wantErr %v", err, errdef.ErrNotFound)
+		}
+	}
+}
+
+func Test_fileCache_Corrupted(t *testing.T) {
+	ctx := context.Background()
+	registry := "localhost:5000"
+	cache, err := NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileCache() error = %v", err)
+	}
+	if err := os.WriteFile(cache.(*fileCache).path(registry), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.GetScheme(ctx, registry); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("fileCache.GetScheme() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+
+	// corrupted files are overwritten
+	if _, err := cache.(ExpiringCache).SetWithExpiry(ctx, registry, SchemeBearer, "key", func(context.Context) (string, time.Time, error) {
+		return "foo", time.Now().Add(time.Hour), nil
+	}); err != nil {
+		t.Fatalf("fileCache.SetWithExpiry() error = %v", err)
+	}
+	if _, err := cache.(*fileCache).load(registry); err != nil {
+		t.Errorf("fileCache.load() error = %v", err)
+	}
+}
+
+func Test_fileCache_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	registry := "localhost:5000"
+	expiresAt := time.Now().Add(time.Hour)
+
+	// simulate concurrent processes with separate caches
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cache, err := NewFileCache(root)
+			if err != nil {
+				t.Errorf("NewFileCache() error = %v", err)
+				return
+			}
+			key := strconv.Itoa(i)
+			if _, err := cache.(ExpiringCache).SetWithExpiry(ctx, registry, SchemeBearer, key, func(context.Context) (string, time.Time, error) {
+				return key, expiresAt, nil
+			}); err != nil {
+				t.Errorf("fileCache.SetWithExpiry() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	cache, err := NewFileCache(root)
+	if err != nil {
+		t.Fatalf("NewFileCache() error = %v", err)
+	}
+	for i := 0; i < 16; i++ {
+		key := strconv.Itoa(i)
+		got, err := cache.GetToken(ctx, registry, SchemeBearer, key)
+		if err != nil {
+			t.Fatalf("fileCache.GetToken(%s) error = %v", key, err)
+		}
+		if got != key {
+			t.Errorf("fileCache.GetToken(%s) = %v, want %v", key, got, key)
+		}
+	}
+}
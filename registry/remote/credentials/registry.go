@@ -79,6 +79,28 @@ func Credential(store Store) auth.CredentialFunc {
 	}
 }
 
+// RefreshTokenHandler returns a HandleRefreshToken() function that can be used
+// by auth.Client, which stores the refresh tokens returned by the
+// authorization services into the store as the RefreshToken of the
+// credentials, so that they are resolved by Credential() afterwards.
+func RefreshTokenHandler(store Store) func(ctx context.Context, registry string, refreshToken string) error {
+	return func(ctx context.Context, registry string, refreshToken string) error {
+		serverAddress := ServerAddressFromHostname(registry)
+		if serverAddress == "" {
+			return nil
+		}
+		cred, err := store.Get(ctx, serverAddress)
+		if err != nil {
+			return fmt.Errorf("failed to get the credential for %s: %w", serverAddress, err)
+		}
+		cred.RefreshToken = refreshToken
+		if err := store.Put(ctx, serverAddress, cred); err != nil {
+			return fmt.Errorf("failed to store the refresh token for %s: %w", serverAddress, err)
+		}
+		return nil
+	}
+}
+
 // ServerAddressFromRegistry maps a registry to a server address, which is used as
 // a key for credentials store. The Docker CLI expects that the credentials of
 // the registry 'docker.io' will be added under the key "https://index.docker.io/v1/".
@@ -245,3 +245,29 @@ func TestCredential(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshTokenHandler(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	if err := s.Put(ctx, "https://index.docker.io/v1/", auth.Credential{Username: "user", Password: "word"}); err != nil {
+		t.Fatal(err)
+	}
+	handle := RefreshTokenHandler(s)
+	if err := handle(ctx, "registry-1.docker.io", "refresh-token"); err != nil {
+		t.Fatalf("RefreshTokenHandler() error = %v", err)
+	}
+
+	got, err := Credential(s)(ctx, "registry-1.docker.io")
+	if err != nil {
+		t.Fatalf("Credential() error = %v", err)
+	}
+	want := auth.Credential{Username: "user", Password: "word", RefreshToken: "refresh-token"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Credential() = %v, want %v", got, want)
+	}
+
+	// empty registry is ignored
+	if err := handle(ctx, "", "refresh-token"); err != nil {
+		t.Errorf("RefreshTokenHandler() error = %v", err)
+	}
+}
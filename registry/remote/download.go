@@ -0,0 +1,232 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote/internal/errutil"
+)
+
+// defaultBlobFetchPartSize is the default value of
+// Repository.BlobFetchPartSize.
+const defaultBlobFetchPartSize int64 = 16 * 1024 * 1024 // 16 MiB
+
+// blobFetchPartSize returns the size of the parts for fetching blobs in
+// parallel.
+func (r *Repository) blobFetchPartSize() int64 {
+	if r.BlobFetchPartSize <= 0 {
+		return defaultBlobFetchPartSize
+	}
+	return r.BlobFetchPartSize
+}
+
+// fetchParallel fetches the blob in parts concurrently, where the first part
+// is requested by req and responded by resp.
+// The parts are reassembled in order, and the content is verified against the
+// target digest when the end of the content is reached.
+func (s *blobStore) fetchParallel(req *http.Request, resp *http.Response, target ocispec.Descriptor) (io.ReadCloser, error) {
+	partSize := s.repo.blobFetchPartSize()
+	if err := checkPartialContent(resp, 0, partSize-1, target.Size); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	// the first part is being read with the context of req
+	context.AfterFunc(ctx, func() {
+		resp.Body.Close()
+	})
+	numParts := int((target.Size + partSize - 1) / partSize)
+	r := &parallelReader{
+		cancel:   cancel,
+		parts:    make([]chan partResult, numParts),
+		tokens:   make(chan struct{}, s.repo.BlobFetchConcurrency),
+		verifier: target.Digest.Verifier(),
+		target:   target,
+	}
+	for i := range r.parts {
+		r.parts[i] = make(chan partResult, 1)
+	}
+
+	fetchPart := func(i int) ([]byte, error) {
+		start := int64(i) * partSize
+		end := min(start+partSize, target.Size) - 1
+		body := resp.Body
+		if i > 0 {
+			req := req.Clone(ctx)
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+			resp, err := s.repo.do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusPartialContent {
+				if resp.StatusCode == http.StatusOK {
+					return nil, fmt.Errorf("%s %q: range request not honored", resp.Request.Method, resp.Request.URL)
+				}
+				return nil, errutil.ParseErrorResponse(resp)
+			}
+			if err := checkPartialContent(resp, start, end, target.Size); err != nil {
+				return nil, err
+			}
+			body = resp.Body
+		} else {
+			defer body.Close()
+		}
+		data := make([]byte, end-start+1)
+		if _, err := io.ReadFull(body, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	// schedule the parts in order, with at most BlobFetchConcurrency parts
+	// being fetched or buffered at a time.
+	go func() {
+		for i := range r.parts {
+			select {
+			case r.tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int) {
+				data, err := fetchPart(i)
+				r.parts[i] <- partResult{data: data, err: err}
+			}(i)
+		}
+	}()
+	return r, nil
+}
+
+// checkPartialContent checks if the partial content response covers the
+// expected range of the blob.
+func checkPartialContent(resp *http.Response, start, end, size int64) error {
+	gotStart, gotEnd, gotSize, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return fmt.Errorf("%s %q: %w", resp.Request.Method, resp.Request.URL, err)
+	}
+	if gotStart != start || gotEnd != end || (gotSize != -1 && gotSize != size) {
+		return fmt.Errorf("%s %q: mismatch Content-Range: %s", resp.Request.Method, resp.Request.URL, resp.Header.Get("Content-Range"))
+	}
+	if length := resp.ContentLength; length != -1 && length != end-start+1 {
+		return fmt.Errorf("%s %q: mismatch Content-Length", resp.Request.Method, resp.Request.URL)
+	}
+	return nil
+}
+
+// parseContentRange parses the Content-Range header in the form of
+// "bytes <start>-<end>/<size>", where size is -1 if it is unknown.
+func parseContentRange(contentRange string) (start, end, size int64, err error) {
+	rangeValue, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	rangeValue, sizeValue, ok := strings.Cut(rangeValue, "/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	startValue, endValue, ok := strings.Cut(rangeValue, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	if start, err = strconv.ParseInt(startValue, 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	if end, err = strconv.ParseInt(endValue, 10, 64); err != nil || end < start {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	if sizeValue == "*" {
+		return start, end, -1, nil
+	}
+	if size, err = strconv.ParseInt(sizeValue, 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	return start, end, size, nil
+}
+
+// partResult is the result of fetching a part of a blob.
+type partResult struct {
+	data []byte
+	err  error
+}
+
+// parallelReader reads the parts of a blob in order.
+type parallelReader struct {
+	cancel   context.CancelFunc
+	parts    []chan partResult
+	tokens   chan struct{}
+	verifier digest.Verifier
+	target   ocispec.Descriptor
+
+	next   int
+	buf    []byte
+	err    error
+	closed bool
+}
+
+// Read reads the content of the parts in order.
+// The content is verified when the end of the content is reached.
+func (r *parallelReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, errors.New("read: already closed")
+	}
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.next == len(r.parts) {
+			if !r.verifier.Verified() {
+				r.err = fmt.Errorf("%s: %v: %w", r.target.Digest, r.target.MediaType, content.ErrMismatchedDigest)
+			} else {
+				r.err = io.EOF
+			}
+			return 0, r.err
+		}
+		result := <-r.parts[r.next]
+		if result.err != nil {
+			r.err = result.err
+			r.cancel()
+			return 0, r.err
+		}
+		// release the slot for the next part
+		<-r.tokens
+		r.next++
+		r.buf = result.data
+		r.verifier.Write(r.buf)
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// Close cancels the ongoing requests.
+func (r *parallelReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.cancel()
+	return nil
+}
@@ -0,0 +1,280 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// newRangeTestRepository creates a repository to a test server serving blob
+// at the path of blobDesc.
+func newRangeTestRepository(t *testing.T, blob []byte, blobDesc ocispec.Descriptor, handler func(w http.ResponseWriter, r *http.Request) bool) *Repository {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v2/test/blobs/"+blobDesc.Digest.String() {
+			t.Errorf("unexpected access: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if handler != nil && handler(w, r) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Docker-Content-Digest", blobDesc.Digest.String())
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(blob))
+	}))
+	t.Cleanup(ts.Close)
+	uri, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("invalid test http server: %v", err)
+	}
+	repo, err := NewRepository(uri.Host + "/test")
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+	repo.PlainHTTP = true
+	return repo
+}
+
+func Test_BlobStore_Fetch_Parallel(t *testing.T) {
+	blob := make([]byte, 1000)
+	for i := range blob {
+		blob[i] = byte(i)
+	}
+	blobDesc := ocispec.Descriptor{
+		MediaType: "test",
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+	var numRequests, numRangeRequests atomic.Int64
+	repo := newRangeTestRepository(t, blob, blobDesc, func(w http.ResponseWriter, r *http.Request) bool {
+		numRequests.Add(1)
+		if r.Header.Get("Range") != "" {
+			numRangeRequests.Add(1)
+		}
+		return false
+	})
+	repo.BlobFetchConcurrency = 4
+	repo.BlobFetchPartSize = 64
+	ctx := context.Background()
+
+	rc, err := repo.Fetch(ctx, blobDesc)
+	if err != nil {
+		t.Fatalf("Repository.Fetch() error = %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Repository.Fetch().Read() error = %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Errorf("Repository.Fetch().Close() error = %v", err)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Errorf("Repository.Fetch() = %v, want %v", got, blob)
+	}
+	if got, want := numRangeRequests.Load(), int64(16); got != want {
+		t.Errorf("count(range requests) = %d, want %d", got, want)
+	}
+	if got, want := numRequests.Load(), int64(16); got != want {
+		t.Errorf("count(requests) = %d, want %d", got, want)
+	}
+
+	// small blobs are fetched in a single request
+	numRequests.Store(0)
+	numRangeRequests.Store(0)
+	repo.BlobFetchPartSize = 1000
+	rc, err = repo.Fetch(ctx, blobDesc)
+	if err != nil {
+		t.Fatalf("Repository.Fetch() error = %v", err)
+	}
+	if got, err = io.ReadAll(rc); err != nil {
+		t.Fatalf("Repository.Fetch().Read() error = %v", err)
+	}
+	rc.Close()
+	if !bytes.Equal(got, blob) {
+		t.Errorf("Repository.Fetch() = %v, want %v", got, blob)
+	}
+	if got, want := numRangeRequests.Load(), int64(0); got != want {
+		t.Errorf("count(range requests) = %d, want %d", got, want)
+	}
+}
+
+func Test_BlobStore_Fetch_Parallel_RangeNotSupported(t *testing.T) {
+	blob := bytes.Repeat([]byte("hello world"), 100)
+	blobDesc := ocispec.Descriptor{
+		MediaType: "test",
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+	var numRequests atomic.Int64
+	repo := newRangeTestRepository(t, blob, blobDesc, func(w http.ResponseWriter, r *http.Request) bool {
+		numRequests.Add(1)
+		w.Header().Set("Docker-Content-Digest", blobDesc.Digest.String())
+		if _, err := w.Write(blob); err != nil {
+			t.Errorf("failed to write %q: %v", r.URL, err)
+		}
+		return true
+	})
+	repo.BlobFetchConcurrency = 4
+	repo.BlobFetchPartSize = 64
+	ctx := context.Background()
+
+	rc, err := repo.Fetch(ctx, blobDesc)
+	if err != nil {
+		t.Fatalf("Repository.Fetch() error = %v", err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Repository.Fetch().Read() error = %v", err)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Errorf("Repository.Fetch() = %v, want %v", got, blob)
+	}
+	if got, want := numRequests.Load(), int64(1); got != want {
+		t.Errorf("count(requests) = %d, want %d", got, want)
+	}
+}
+
+func Test_BlobStore_Fetch_Parallel_MismatchedDigest(t *testing.T) {
+	blob := bytes.Repeat([]byte("hello world"), 100)
+	blobDesc := ocispec.Descriptor{
+		MediaType: "test",
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+	corrupted := bytes.Clone(blob)
+	corrupted[len(corrupted)-1] = '!'
+	repo := newRangeTestRepository(t, blob, blobDesc, func(w http.ResponseWriter, r *http.Request) bool {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(corrupted))
+		return true
+	})
+	repo.BlobFetchConcurrency = 4
+	repo.BlobFetchPartSize = 64
+	ctx := context.Background()
+
+	rc, err := repo.Fetch(ctx, blobDesc)
+	if err != nil {
+		t.Fatalf("Repository.Fetch() error = %v", err)
+	}
+	defer rc.Close()
+	if _, err = io.ReadAll(rc); !errors.Is(err, content.ErrMismatchedDigest) {
+		t.Errorf("Repository.Fetch().Read() error = %v, wantErr %v", err, content.ErrMismatchedDigest)
+	}
+}
+
+func Test_BlobStore_Fetch_Parallel_PartFailure(t *testing.T) {
+	blob := bytes.Repeat([]byte("hello world"), 100)
+	blobDesc := ocispec.Descriptor{
+		MediaType: "test",
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+	repo := newRangeTestRepository(t, blob, blobDesc, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Range") == "bytes=128-191" {
+			w.WriteHeader(http.StatusForbidden)
+			return true
+		}
+		return false
+	})
+	repo.BlobFetchConcurrency = 4
+	repo.BlobFetchPartSize = 64
+	ctx := context.Background()
+
+	rc, err := repo.Fetch(ctx, blobDesc)
+	if err != nil {
+		t.Fatalf("Repository.Fetch() error = %v", err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err == nil {
+		t.Fatal("Repository.Fetch().Read() error = nil, wantErr true")
+	}
+	if want := blob[:128]; !bytes.Equal(got, want) {
+		t.Errorf("Repository.Fetch() = %v, want %v", got, want)
+	}
+}
+
+func Test_BlobStore_Fetch_Parallel_Close(t *testing.T) {
+	blob := bytes.Repeat([]byte("hello world"), 100)
+	blobDesc := ocispec.Descriptor{
+		MediaType: "test",
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+	repo := newRangeTestRepository(t, blob, blobDesc, nil)
+	repo.BlobFetchConcurrency = 2
+	repo.BlobFetchPartSize = 64
+	ctx := context.Background()
+
+	rc, err := repo.Fetch(ctx, blobDesc)
+	if err != nil {
+		t.Fatalf("Repository.Fetch() error = %v", err)
+	}
+	buf := make([]byte, 100)
+	if _, err := io.ReadFull(rc, buf); err != nil {
+		t.Fatalf("Repository.Fetch().Read() error = %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Errorf("Repository.Fetch().Close() error = %v", err)
+	}
+	if _, err := rc.Read(buf); err == nil {
+		t.Error("Repository.Fetch().Read() error = nil, wantErr true")
+	}
+}
+
+func Test_parseContentRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		wantStart int64
+		wantEnd   int64
+		wantSize  int64
+		wantErr   bool
+	}{
+		{name: "full", header: "bytes 0-99/1000", wantStart: 0, wantEnd: 99, wantSize: 1000},
+		{name: "unknown size", header: "bytes 100-199/*", wantStart: 100, wantEnd: 199, wantSize: -1},
+		{name: "missing unit", header: "0-99/1000", wantErr: true},
+		{name: "missing size", header: "bytes 0-99", wantErr: true},
+		{name: "missing end", header: "bytes 0/1000", wantErr: true},
+		{name: "invalid start", header: "bytes a-99/1000", wantErr: true},
+		{name: "invalid end", header: "bytes 100-99/1000", wantErr: true},
+		{name: "invalid size", header: "bytes 0-99/a", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, size, err := parseContentRange(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseContentRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if start != tt.wantStart || end != tt.wantEnd || size != tt.wantSize {
+				t.Errorf("parseContentRange() = (%d, %d, %d), want (%d, %d, %d)", start, end, size, tt.wantStart, tt.wantEnd, tt.wantSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"strings"
+
+	"oras.land/oras-go/v2/registry"
+)
+
+// Mirror is a mirror endpoint of a registry or a namespace of a registry.
+type Mirror struct {
+	// Location is the location of the mirror in the form of
+	// `<registry>[/<namespace>]`, such as "mirror.example.com" or
+	// "mirror.example.com/docker.io".
+	// The path of a repository relative to the mirrored prefix is appended
+	// to the namespace. For example, "docker.io/library/alpine" is pulled as
+	// "mirror.example.com/docker.io/library/alpine" from the mirror
+	// "mirror.example.com/docker.io" of the prefix "docker.io".
+	Location string
+
+	// PlainHTTP signals the transport to access the mirror via HTTP instead
+	// of HTTPS.
+	PlainHTTP bool
+
+	// Client is the underlying HTTP client used to access the mirror, which
+	// can be configured with the TLS settings of the mirror, such as the
+	// trusted CAs and the client certificates.
+	// If nil, the Client of the Repository is used.
+	Client Client
+}
+
+// MirrorConfig maps registries or namespaces of registries to their mirrors,
+// similar to the mirror configuration of containers-registries.conf.
+//
+// A key is a prefix in the form of `<registry>[/<namespace>]`, such as
+// "docker.io" or "ghcr.io/oras-project", which matches the repositories under
+// it. If multiple prefixes match a repository, the longest one is used.
+//
+// Content is pulled from the mirrors in order, and then from the origin
+// registry if none of the mirrors serves it. Content is always pushed to,
+// tagged in, deleted from and listed by the origin registry.
+//
+// Reference: https://github.com/containers/image/blob/main/docs/containers-registries.conf.5.md
+type MirrorConfig map[string][]Mirror
+
+// clone returns a copy of the mirror configuration.
+func (c MirrorConfig) clone() MirrorConfig {
+	if c == nil {
+		return nil
+	}
+	cloned := make(MirrorConfig, len(c))
+	for prefix, mirrors := range c {
+		cloned[prefix] = append([]Mirror(nil), mirrors...)
+	}
+	return cloned
+}
+
+// lookup returns the mirrors of the repository referenced by ref, as well as
+// the path of the repository relative to the matched prefix.
+func (c MirrorConfig) lookup(ref registry.Reference) ([]Mirror, string) {
+	name := ref.Registry + "/" + ref.Repository
+	var matched string
+	var matchedMirrors []Mirror
+	for prefix, mirrors := range c {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if name != prefix && !strings.HasPrefix(name, prefix+"/") {
+			continue
+		}
+		if matchedMirrors == nil || len(prefix) > len(matched) {
+			matched, matchedMirrors = prefix, mirrors
+		}
+	}
+	if matchedMirrors == nil {
+		return nil, ""
+	}
+	return matchedMirrors, strings.TrimPrefix(strings.TrimPrefix(name, matched), "/")
+}
+
+// mirrorRepositories returns the clients to the mirrors of the repository,
+// in the configured order. Mirrors with invalid locations are skipped.
+func (r *Repository) mirrorRepositories() []*Repository {
+	mirrors, relative := r.Mirrors.lookup(r.Reference)
+	repos := make([]*Repository, 0, len(mirrors))
+	for _, mirror := range mirrors {
+		location := strings.TrimSuffix(mirror.Location, "/")
+		host, namespace, _ := strings.Cut(location, "/")
+		var repository string
+		switch {
+		case namespace == "":
+			repository = relative
+		case relative == "":
+			repository = namespace
+		default:
+			repository = namespace + "/" + relative
+		}
+		ref := registry.Reference{
+			Registry:   host,
+			Repository: repository,
+		}
+		if err := ref.ValidateRegistry(); err != nil {
+			continue
+		}
+		if err := ref.ValidateRepository(); err != nil {
+			continue
+		}
+
+		repo := r.clone()
+		repo.Reference = ref
+		repo.PlainHTTP = mirror.PlainHTTP
+		if mirror.Client != nil {
+			repo.Client = mirror.Client
+		}
+		repo.Mirrors = nil
+		repos = append(repos, repo)
+	}
+	return repos
+}
+
+// pullWithMirrors invokes pull against the mirrors of the repository in order,
+// and then against the repository itself, until pull succeeds.
+// The error of pulling from the repository itself is returned if pull fails
+// against all the mirrors.
+func pullWithMirrors[T any](ctx context.Context, r *Repository, pull func(repo *Repository) (T, error)) (T, error) {
+	if len(r.Mirrors) > 0 {
+		for _, mirror := range r.mirrorRepositories() {
+			result, err := pull(mirror)
+			if err == nil {
+				return result, nil
+			}
+			if ctx.Err() != nil {
+				var zero T
+				return zero, ctx.Err()
+			}
+		}
+	}
+	return pull(r)
+}
@@ -0,0 +1,284 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+)
+
+func TestMirrorConfig_lookup(t *testing.T) {
+	config := MirrorConfig{
+		"docker.io":                 {{Location: "mirror.example.com/hub"}},
+		"docker.io/library/":        {{Location: "mirror.example.com/library"}},
+		"ghcr.io/oras-project/oras": {{Location: "localhost:5000"}},
+	}
+	tests := []struct {
+		name         string
+		ref          registry.Reference
+		wantLocation string
+		wantRelative string
+	}{
+		{
+			name:         "registry prefix",
+			ref:          registry.Reference{Registry: "docker.io", Repository: "foo/bar"},
+			wantLocation: "mirror.example.com/hub",
+			wantRelative: "foo/bar",
+		},
+		{
+			name:         "longest prefix",
+			ref:          registry.Reference{Registry: "docker.io", Repository: "library/alpine"},
+			wantLocation: "mirror.example.com/library",
+			wantRelative: "alpine",
+		},
+		{
+			name:         "exact match",
+			ref:          registry.Reference{Registry: "ghcr.io", Repository: "oras-project/oras"},
+			wantLocation: "localhost:5000",
+			wantRelative: "",
+		},
+		{
+			name: "partial component",
+			ref:  registry.Reference{Registry: "ghcr.io", Repository: "oras-project/oras-go"},
+		},
+		{
+			name: "no match",
+			ref:  registry.Reference{Registry: "quay.io", Repository: "foo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mirrors, relative := config.lookup(tt.ref)
+			var location string
+			if len(mirrors) > 0 {
+				location = mirrors[0].Location
+			}
+			if location != tt.wantLocation || relative != tt.wantRelative {
+				t.Errorf("MirrorConfig.lookup() = %q, %q, want %q, %q", location, relative, tt.wantLocation, tt.wantRelative)
+			}
+		})
+	}
+}
+
+func TestRepository_mirrorRepositories(t *testing.T) {
+	client := &http.Client{}
+	repo := &Repository{
+		Reference: registry.Reference{Registry: "docker.io", Repository: "library/alpine"},
+		PlainHTTP: true,
+		Mirrors: MirrorConfig{
+			"docker.io": {
+				{Location: "mirror.example.com/hub/", Client: client},
+				{Location: "INVALID HOST"},
+				{Location: "localhost:5000", PlainHTTP: true},
+			},
+		},
+	}
+	got := repo.mirrorRepositories()
+	if len(got) != 2 {
+		t.Fatalf("Repository.mirrorRepositories() = %d repositories, want 2", len(got))
+	}
+	want := []registry.Reference{
+		{Registry: "mirror.example.com", Repository: "hub/library/alpine"},
+		{Registry: "localhost:5000", Repository: "library/alpine"},
+	}
+	for i, mirror := range got {
+		if !reflect.DeepEqual(mirror.Reference, want[i]) {
+			t.Errorf("mirror[%d].Reference = %v, want %v", i, mirror.Reference, want[i])
+		}
+		if mirror.Mirrors != nil {
+			t.Errorf("mirror[%d].Mirrors = %v, want nil", i, mirror.Mirrors)
+		}
+	}
+	if got[0].Client != client || got[0].PlainHTTP {
+		t.Errorf("mirror[0] = {Client: %v, PlainHTTP: %v}, want {Client: %v, PlainHTTP: false}", got[0].Client, got[0].PlainHTTP, client)
+	}
+	if got[1].Client != nil || !got[1].PlainHTTP {
+		t.Errorf("mirror[1] = {Client: %v, PlainHTTP: %v}, want {Client: nil, PlainHTTP: true}", got[1].Client, got[1].PlainHTTP)
+	}
+}
+
+func TestRepository_Mirrors(t *testing.T) {
+	blob := []byte("hello world")
+	blobDesc := content.NewDescriptorFromBytes("test", blob)
+	manifest := []byte(`{"layers":[]}`)
+	manifestDesc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifest)
+	ref := "latest"
+	newBlob := []byte("foo")
+	newBlobDesc := content.NewDescriptorFromBytes("test", newBlob)
+
+	// the origin serves all content and accepts pushes
+	var originRequests atomic.Int64
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		originRequests.Add(1)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/library/alpine/manifests/"+ref:
+			w.Header().Set("Content-Type", manifestDesc.MediaType)
+			w.Header().Set("Docker-Content-Digest", manifestDesc.Digest.String())
+			w.Write(manifest)
+		case r.Method == http.MethodHead && r.URL.Path == "/v2/library/alpine/manifests/"+ref:
+			w.Header().Set("Content-Type", manifestDesc.MediaType)
+			w.Header().Set("Docker-Content-Digest", manifestDesc.Digest.String())
+			w.Header().Set("Content-Length", "13")
+		case r.Method == http.MethodPost && r.URL.Path == "/v2/library/alpine/blobs/uploads/":
+			w.Header().Set("Location", "/v2/library/alpine/blobs/uploads/id")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && r.URL.Path == "/v2/library/alpine/blobs/uploads/id":
+			if got := r.URL.Query().Get("digest"); got != newBlobDesc.Digest.String() {
+				t.Errorf("unexpected digest: %v, want %v", got, newBlobDesc.Digest)
+			}
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Errorf("unexpected access to origin: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer origin.Close()
+
+	// the first mirror serves nothing
+	var emptyRequests atomic.Int64
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		emptyRequests.Add(1)
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			t.Errorf("unexpected access to mirror: %s %s", r.Method, r.URL)
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer empty.Close()
+
+	// the second mirror serves the blob over TLS under a namespace
+	var mirrorRequests atomic.Int64
+	mirror := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorRequests.Add(1)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/hub/library/alpine/blobs/"+blobDesc.Digest.String():
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Docker-Content-Digest", blobDesc.Digest.String())
+			w.Write(blob)
+		case r.Method == http.MethodHead && r.URL.Path == "/v2/hub/library/alpine/blobs/"+blobDesc.Digest.String():
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Docker-Content-Digest", blobDesc.Digest.String())
+			w.Header().Set("Content-Length", "11")
+		case r.Method == http.MethodGet || r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			t.Errorf("unexpected access to mirror: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer mirror.Close()
+
+	host := func(s *httptest.Server) string {
+		uri, err := url.Parse(s.URL)
+		if err != nil {
+			t.Fatalf("invalid test http server: %v", err)
+		}
+		return uri.Host
+	}
+	repo, err := NewRepository(host(origin) + "/library/alpine")
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+	repo.PlainHTTP = true
+	repo.Mirrors = MirrorConfig{
+		host(origin): {
+			{Location: host(empty), PlainHTTP: true},
+			{Location: host(mirror) + "/hub", Client: mirror.Client()},
+		},
+	}
+	ctx := context.Background()
+
+	// blobs are pulled from the mirror
+	got, err := content.FetchAll(ctx, repo, blobDesc)
+	if err != nil {
+		t.Fatalf("Repository.Fetch() error = %v", err)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Errorf("Repository.Fetch() = %s, want %s", got, blob)
+	}
+	exists, err := repo.Exists(ctx, blobDesc)
+	if err != nil || !exists {
+		t.Errorf("Repository.Exists() = %v, %v, want true", exists, err)
+	}
+	if n := originRequests.Load(); n != 0 {
+		t.Errorf("origin requests = %d, want 0", n)
+	}
+	if n := emptyRequests.Load(); n != 2 {
+		t.Errorf("empty mirror requests = %d, want 2", n)
+	}
+
+	// manifests fall back to the origin
+	desc, err := repo.Resolve(ctx, repo.Reference.Registry+"/library/alpine:"+ref)
+	if err != nil {
+		t.Fatalf("Repository.Resolve() error = %v", err)
+	}
+	if !content.Equal(desc, manifestDesc) {
+		t.Errorf("Repository.Resolve() = %v, want %v", desc, manifestDesc)
+	}
+	desc, rc, err := repo.FetchReference(ctx, ref)
+	if err != nil {
+		t.Fatalf("Repository.FetchReference() error = %v", err)
+	}
+	rc.Close()
+	if !content.Equal(desc, manifestDesc) {
+		t.Errorf("Repository.FetchReference() = %v, want %v", desc, manifestDesc)
+	}
+	if n := originRequests.Load(); n != 2 {
+		t.Errorf("origin requests = %d, want 2", n)
+	}
+
+	// pushes go to the origin only
+	emptyRequests.Store(0)
+	mirrorRequests.Store(0)
+	if err := repo.Push(ctx, newBlobDesc, bytes.NewReader(newBlob)); err != nil {
+		t.Fatalf("Repository.Push() error = %v", err)
+	}
+	if n := emptyRequests.Load() + mirrorRequests.Load(); n != 0 {
+		t.Errorf("mirror requests = %d, want 0", n)
+	}
+
+	// missing content is reported by the origin
+	missing := ocispec.Descriptor{
+		MediaType: "test",
+		Digest:    digest.FromString("missing"),
+		Size:      7,
+	}
+	origin.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	if _, err := repo.Fetch(ctx, missing); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Repository.Fetch() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+	if exists, err := repo.Exists(ctx, missing); err != nil || exists {
+		t.Errorf("Repository.Exists() = %v, %v, want false", exists, err)
+	}
+	if _, err := repo.Resolve(ctx, "other.registry/library/alpine:"+ref); err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Errorf("Repository.Resolve() error = %v, want mismatch error", err)
+	}
+}
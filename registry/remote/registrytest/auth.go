@@ -0,0 +1,188 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registrytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+const (
+	// tokenPath is the path of the token endpoint.
+	tokenPath = "/token"
+
+	// tokenService is the service name of the token endpoint.
+	tokenService = "registrytest"
+
+	// tokenExpiresIn is the lifetime of the issued tokens in seconds.
+	tokenExpiresIn = 300
+)
+
+// tokenGrant is the set of scopes granted to a token.
+type tokenGrant map[string]struct{}
+
+// allows returns true if all the actions of the scope are granted.
+func (g tokenGrant) allows(scope string) bool {
+	if scope == "" {
+		return true
+	}
+	if _, ok := g[scope]; ok {
+		return true
+	}
+	i := strings.LastIndexByte(scope, ':')
+	if i == -1 {
+		return false
+	}
+	resource, actions := scope[:i], scope[i+1:]
+	for _, action := range strings.Split(actions, ",") {
+		if _, ok := g[resource+":"+action]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// newTokenGrant returns a grant of the given scopes, where the actions of each
+// scope are granted individually.
+func newTokenGrant(scopes []string) tokenGrant {
+	grant := make(tokenGrant)
+	for _, scope := range scopes {
+		grant[scope] = struct{}{}
+		i := strings.LastIndexByte(scope, ':')
+		if i == -1 {
+			continue
+		}
+		resource, actions := scope[:i], scope[i+1:]
+		for _, action := range strings.Split(actions, ",") {
+			grant[resource+":"+action] = struct{}{}
+		}
+	}
+	return grant
+}
+
+// authorized returns true if the request carries a token granted with the
+// given scope, or if authentication is not required.
+func (h *Handler) authorized(r *http.Request, scope string) bool {
+	if h.opts.Username == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	grant, ok := h.tokens[token]
+	return ok && grant.allows(scope)
+}
+
+// authorize checks if the request is authorized for the given scope.
+// If not, it writes a bearer challenge and returns false.
+//
+// Reference: https://distribution.github.io/distribution/spec/auth/token/
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if h.authorized(r, scope) {
+		return true
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	challenge := fmt.Sprintf("Bearer realm=%q,service=%q", scheme+"://"+r.Host+tokenPath, tokenService)
+	if scope != "" {
+		challenge += fmt.Sprintf(",scope=%q", scope)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	writeError(w, http.StatusUnauthorized, errcode.ErrorCodeUnauthorized, "authentication required")
+	return false
+}
+
+// serveToken serves the token endpoint, issuing tokens by the credential in
+// the options.
+//
+// References:
+//   - https://distribution.github.io/distribution/spec/auth/token/
+//   - https://distribution.github.io/distribution/spec/auth/oauth/
+func (h *Handler) serveToken(w http.ResponseWriter, r *http.Request) {
+	var scopes []string
+	var refreshToken string
+	switch r.Method {
+	case http.MethodGet:
+		username, password, _ := r.BasicAuth()
+		if username != h.opts.Username || password != h.opts.Password {
+			writeError(w, http.StatusUnauthorized, errcode.ErrorCodeUnauthorized, "invalid credential")
+			return
+		}
+		for _, scope := range r.URL.Query()["scope"] {
+			scopes = append(scopes, strings.Fields(scope)...)
+		}
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			writeError(w, http.StatusBadRequest, errcode.ErrorCodeUnsupported, err.Error())
+			return
+		}
+		switch r.PostForm.Get("grant_type") {
+		case "password":
+			if r.PostForm.Get("username") != h.opts.Username || r.PostForm.Get("password") != h.opts.Password {
+				writeError(w, http.StatusUnauthorized, errcode.ErrorCodeUnauthorized, "invalid credential")
+				return
+			}
+			refreshToken = newID()
+			h.lock.Lock()
+			h.refreshTokens[refreshToken] = struct{}{}
+			h.lock.Unlock()
+		case "refresh_token":
+			h.lock.Lock()
+			_, ok := h.refreshTokens[r.PostForm.Get("refresh_token")]
+			h.lock.Unlock()
+			if !ok {
+				writeError(w, http.StatusUnauthorized, errcode.ErrorCodeUnauthorized, "invalid refresh token")
+				return
+			}
+		default:
+			writeError(w, http.StatusBadRequest, errcode.ErrorCodeUnsupported, "unsupported grant type")
+			return
+		}
+		scopes = strings.Fields(r.PostForm.Get("scope"))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := newID()
+	h.lock.Lock()
+	h.tokens[token] = newTokenGrant(scopes)
+	h.lock.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Token        string `json:"token,omitempty"`
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token,omitempty"`
+		ExpiresIn    int    `json:"expires_in"`
+		IssuedAt     string `json:"issued_at"`
+	}{
+		Token:        token,
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    tokenExpiresIn,
+		IssuedAt:     time.Now().UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,806 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registrytest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/internal/descriptor"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+const (
+	// maxManifestBytes is the maximum size of the manifests accepted by the
+	// registry.
+	// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pushing-manifests
+	maxManifestBytes = 4 * 1024 * 1024 // 4 MiB
+
+	// errorCodeTagInvalid is the error code for invalid tags.
+	errorCodeTagInvalid = "TAG_INVALID"
+)
+
+// routePattern matches the paths of the repository endpoints in the form of
+// "/v2/<name>/<endpoint>/<rest>".
+var routePattern = regexp.MustCompile(`^/v2/(.+)/(blobs/uploads|blobs|manifests|tags|referrers)/(.*)$`)
+
+// Handler is an http.Handler serving an in-memory registry, where the content
+// of each repository is stored in a memory.Store.
+type Handler struct {
+	opts Options
+
+	lock          sync.Mutex
+	repositories  map[string]*repository
+	uploads       map[string]*upload
+	tokens        map[string]tokenGrant
+	refreshTokens map[string]struct{}
+}
+
+// NewHandler returns a new Handler with an empty registry.
+func NewHandler(opts Options) *Handler {
+	return &Handler{
+		opts:          opts,
+		repositories:  make(map[string]*repository),
+		uploads:       make(map[string]*upload),
+		tokens:        make(map[string]tokenGrant),
+		refreshTokens: make(map[string]struct{}),
+	}
+}
+
+// repository is a repository in the registry.
+// Deleted content is removed from the indexes, and left unreachable in the
+// store.
+type repository struct {
+	store     *memory.Store
+	blobs     map[digest.Digest]ocispec.Descriptor
+	manifests map[digest.Digest]ocispec.Descriptor
+	tags      map[string]digest.Digest
+	referrers map[digest.Digest][]ocispec.Descriptor
+}
+
+// push pushes the verified content to the store of the repository.
+func (repo *repository) push(ctx context.Context, desc ocispec.Descriptor, data []byte) error {
+	// content deleted from the indexes may still exist in the store
+	if err := repo.store.Push(ctx, desc, bytes.NewReader(data)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return err
+	}
+	return nil
+}
+
+// blob returns the descriptor of the blob identified by dgst.
+// Manifests are also accessible as blobs.
+func (repo *repository) blob(dgst digest.Digest) (ocispec.Descriptor, bool) {
+	if desc, ok := repo.blobs[dgst]; ok {
+		return desc, true
+	}
+	desc, ok := repo.manifests[dgst]
+	return desc, ok
+}
+
+// upload is a blob upload session.
+type upload struct {
+	repository string
+	buf        bytes.Buffer
+}
+
+// ServeHTTP serves the registry API.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	if r.URL.Path == tokenPath {
+		h.serveToken(w, r)
+		return
+	}
+	if r.URL.Path == "/v2/" || r.URL.Path == "/v2" {
+		if !h.authorize(w, r, "") {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+		return
+	}
+	if r.URL.Path == "/v2/_catalog" {
+		if !h.authorize(w, r, "registry:catalog:*") {
+			return
+		}
+		h.serveCatalog(w, r)
+		return
+	}
+
+	matches := routePattern.FindStringSubmatch(r.URL.Path)
+	if matches == nil {
+		writeError(w, http.StatusNotFound, errcode.ErrorCodeUnsupported, "unknown endpoint")
+		return
+	}
+	name, endpoint, rest := matches[1], matches[2], matches[3]
+	ref := registry.Reference{
+		Registry:   "localhost",
+		Repository: name,
+	}
+	if err := ref.ValidateRepository(); err != nil {
+		writeError(w, http.StatusBadRequest, errcode.ErrorCodeNameInvalid, err.Error())
+		return
+	}
+	var actions []string
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		actions = []string{auth.ActionPull}
+	case http.MethodDelete:
+		actions = []string{auth.ActionDelete}
+	default:
+		actions = []string{auth.ActionPull, auth.ActionPush}
+	}
+	if !h.authorize(w, r, auth.ScopeRepository(name, actions...)) {
+		return
+	}
+
+	switch endpoint {
+	case "blobs":
+		h.serveBlob(w, r, name, rest)
+	case "blobs/uploads":
+		if rest == "" {
+			h.startUpload(w, r, name)
+		} else {
+			h.serveUpload(w, r, name, rest)
+		}
+	case "manifests":
+		h.serveManifest(w, r, name, rest)
+	case "tags":
+		if rest != "list" {
+			writeError(w, http.StatusNotFound, errcode.ErrorCodeUnsupported, "unknown endpoint")
+			return
+		}
+		h.serveTags(w, r, name)
+	case "referrers":
+		h.serveReferrers(w, r, name, rest)
+	}
+}
+
+// repository returns the repository with the given name.
+// If create is true, the repository is created if not exists.
+// The caller must hold h.lock.
+func (h *Handler) repository(name string, create bool) *repository {
+	repo, ok := h.repositories[name]
+	if !ok && create {
+		repo = &repository{
+			store:     memory.New(),
+			blobs:     make(map[digest.Digest]ocispec.Descriptor),
+			manifests: make(map[digest.Digest]ocispec.Descriptor),
+			tags:      make(map[string]digest.Digest),
+			referrers: make(map[digest.Digest][]ocispec.Descriptor),
+		}
+		h.repositories[name] = repo
+	}
+	return repo
+}
+
+// serveBlob serves the blob endpoint.
+//
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pulling-blobs
+func (h *Handler) serveBlob(w http.ResponseWriter, r *http.Request, name, reference string) {
+	dgst, err := digest.Parse(reference)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errcode.ErrorCodeDigestInvalid, err.Error())
+		return
+	}
+
+	h.lock.Lock()
+	repo := h.repository(name, false)
+	if repo == nil {
+		h.lock.Unlock()
+		writeError(w, http.StatusNotFound, errcode.ErrorCodeNameUnknown, "repository name not known to registry")
+		return
+	}
+	desc, ok := repo.blob(dgst)
+	if !ok {
+		h.lock.Unlock()
+		writeError(w, http.StatusNotFound, errcode.ErrorCodeBlobUnknown, "blob unknown to registry")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodDelete:
+		delete(repo.blobs, dgst)
+		h.lock.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+		return
+	default:
+		h.lock.Unlock()
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := content.FetchAll(r.Context(), repo.store, desc)
+	h.lock.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errcode.ErrorCodeBlobUnknown, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	if !h.opts.DisableRangeRequests {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+// startUpload serves the requests initiating blob uploads, including
+// monolithic uploads and cross-repository mounts.
+//
+// References:
+//   - https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pushing-blobs
+//   - https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#mounting-a-blob-from-another-repository
+func (h *Handler) startUpload(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	if mount, from := q.Get("mount"), q.Get("from"); mount != "" && from != "" && !h.opts.DisableMount {
+		if h.mount(r, name, mount, from) {
+			writeBlobCreated(w, name, digest.Digest(mount))
+			return
+		}
+	} else if dgst := q.Get("digest"); dgst != "" {
+		h.commitUpload(w, r, name, dgst, r.Body)
+		return
+	}
+
+	id := newID()
+	h.lock.Lock()
+	h.uploads[id] = &upload{repository: name}
+	h.lock.Unlock()
+	if h.opts.ChunkMinLength > 0 {
+		w.Header().Set("OCI-Chunk-Min-Length", strconv.FormatInt(h.opts.ChunkMinLength, 10))
+	}
+	writeUploadStatus(w, name, id, 0, http.StatusAccepted)
+}
+
+// mount mounts the blob identified by dgst from the repository from to the
+// repository name. Returns false if the blob cannot be mounted.
+func (h *Handler) mount(r *http.Request, name, dgst, from string) bool {
+	if !h.authorized(r, auth.ScopeRepository(from, auth.ActionPull)) {
+		return false
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	fromRepo := h.repository(from, false)
+	if fromRepo == nil {
+		return false
+	}
+	desc, ok := fromRepo.blob(digest.Digest(dgst))
+	if !ok {
+		return false
+	}
+	data, err := content.FetchAll(r.Context(), fromRepo.store, desc)
+	if err != nil {
+		return false
+	}
+	desc = ocispec.Descriptor{
+		MediaType: descriptor.DefaultMediaType,
+		Digest:    desc.Digest,
+		Size:      desc.Size,
+	}
+	repo := h.repository(name, true)
+	if err := repo.push(r.Context(), desc, data); err != nil {
+		return false
+	}
+	repo.blobs[desc.Digest] = desc
+	return true
+}
+
+// serveUpload serves the requests to an upload session.
+//
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pushing-a-blob-in-chunks
+func (h *Handler) serveUpload(w http.ResponseWriter, r *http.Request, name, id string) {
+	h.lock.Lock()
+	session, ok := h.uploads[id]
+	h.lock.Unlock()
+	if !ok || session.repository != name {
+		writeError(w, http.StatusNotFound, errcode.ErrorCodeBlobUploadUnknown, "blob upload unknown to registry")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.lock.Lock()
+		offset := int64(session.buf.Len())
+		h.lock.Unlock()
+		writeUploadStatus(w, name, id, offset, http.StatusNoContent)
+	case http.MethodPatch:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errcode.ErrorCodeBlobUploadInvalid, err.Error())
+			return
+		}
+		h.lock.Lock()
+		offset := int64(session.buf.Len())
+		if contentRange := r.Header.Get("Content-Range"); contentRange != "" {
+			start, end, err := parseContentRange(contentRange)
+			if err != nil {
+				h.lock.Unlock()
+				writeError(w, http.StatusBadRequest, errcode.ErrorCodeBlobUploadInvalid, err.Error())
+				return
+			}
+			if start != offset || end-start+1 != int64(len(data)) {
+				h.lock.Unlock()
+				writeUploadStatus(w, name, id, offset, http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+		}
+		session.buf.Write(data)
+		offset = int64(session.buf.Len())
+		h.lock.Unlock()
+		writeUploadStatus(w, name, id, offset, http.StatusAccepted)
+	case http.MethodPut:
+		dgst := r.URL.Query().Get("digest")
+		if dgst == "" {
+			writeError(w, http.StatusBadRequest, errcode.ErrorCodeDigestInvalid, "missing digest")
+			return
+		}
+		h.lock.Lock()
+		delete(h.uploads, id)
+		h.lock.Unlock()
+		h.commitUpload(w, r, name, dgst, io.MultiReader(&session.buf, r.Body))
+	case http.MethodDelete:
+		h.lock.Lock()
+		delete(h.uploads, id)
+		h.lock.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// commitUpload verifies the uploaded content against the given digest and
+// stores it as a blob.
+func (h *Handler) commitUpload(w http.ResponseWriter, r *http.Request, name, reference string, uploaded io.Reader) {
+	dgst, err := digest.Parse(reference)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errcode.ErrorCodeDigestInvalid, err.Error())
+		return
+	}
+	data, err := io.ReadAll(uploaded)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errcode.ErrorCodeBlobUploadInvalid, err.Error())
+		return
+	}
+	if dgst.Algorithm().FromBytes(data) != dgst {
+		writeError(w, http.StatusBadRequest, errcode.ErrorCodeDigestInvalid, "provided digest did not match uploaded content")
+		return
+	}
+	desc := ocispec.Descriptor{
+		MediaType: descriptor.DefaultMediaType,
+		Digest:    dgst,
+		Size:      int64(len(data)),
+	}
+
+	h.lock.Lock()
+	repo := h.repository(name, true)
+	err = repo.push(r.Context(), desc, data)
+	if err == nil {
+		repo.blobs[dgst] = desc
+	}
+	h.lock.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errcode.ErrorCodeBlobUploadInvalid, err.Error())
+		return
+	}
+	writeBlobCreated(w, name, dgst)
+}
+
+// serveManifest serves the manifest endpoint.
+//
+// References:
+//   - https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pulling-manifests
+//   - https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pushing-manifests
+//   - https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#deleting-manifests
+func (h *Handler) serveManifest(w http.ResponseWriter, r *http.Request, name, reference string) {
+	ref := registry.Reference{
+		Registry:   "localhost",
+		Repository: name,
+		Reference:  reference,
+	}
+	if err := ref.ValidateReference(); err != nil {
+		writeError(w, http.StatusBadRequest, errorCodeTagInvalid, err.Error())
+		return
+	}
+	var dgst digest.Digest
+	if err := ref.ValidateReferenceAsDigest(); err == nil {
+		dgst = digest.Digest(reference)
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.lock.Lock()
+		repo := h.repository(name, false)
+		if repo == nil {
+			h.lock.Unlock()
+			writeError(w, http.StatusNotFound, errcode.ErrorCodeNameUnknown, "repository name not known to registry")
+			return
+		}
+		if dgst == "" {
+			dgst = repo.tags[reference]
+		}
+		desc, ok := repo.manifests[dgst]
+		if !ok {
+			h.lock.Unlock()
+			writeError(w, http.StatusNotFound, errcode.ErrorCodeManifestUnknown, "manifest unknown to registry")
+			return
+		}
+		data, err := content.FetchAll(r.Context(), repo.store, desc)
+		h.lock.Unlock()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, errcode.ErrorCodeManifestUnknown, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", desc.MediaType)
+		w.Header().Set("Docker-Content-Digest", desc.Digest.String())
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodPut:
+		h.putManifest(w, r, name, reference, dgst)
+	case http.MethodDelete:
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		repo := h.repository(name, false)
+		if repo == nil {
+			writeError(w, http.StatusNotFound, errcode.ErrorCodeNameUnknown, "repository name not known to registry")
+			return
+		}
+		if dgst == "" {
+			// delete the tag only
+			if _, ok := repo.tags[reference]; !ok {
+				writeError(w, http.StatusNotFound, errcode.ErrorCodeManifestUnknown, "manifest unknown to registry")
+				return
+			}
+			delete(repo.tags, reference)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if _, ok := repo.manifests[dgst]; !ok {
+			writeError(w, http.StatusNotFound, errcode.ErrorCodeManifestUnknown, "manifest unknown to registry")
+			return
+		}
+		delete(repo.manifests, dgst)
+		for tag, tagged := range repo.tags {
+			if tagged == dgst {
+				delete(repo.tags, tag)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// manifestContent contains the fields of all supported manifest types.
+type manifestContent struct {
+	MediaType    string               `json:"mediaType"`
+	ArtifactType string               `json:"artifactType"`
+	Config       *ocispec.Descriptor  `json:"config"`
+	Layers       []ocispec.Descriptor `json:"layers"`
+	Blobs        []ocispec.Descriptor `json:"blobs"`
+	Manifests    []ocispec.Descriptor `json:"manifests"`
+	Subject      *ocispec.Descriptor  `json:"subject"`
+	Annotations  map[string]string    `json:"annotations"`
+}
+
+// putManifest pushes a manifest, and tags it if reference is a tag.
+func (h *Handler) putManifest(w http.ResponseWriter, r *http.Request, name, reference string, dgst digest.Digest) {
+	mediaType := r.Header.Get("Content-Type")
+	if mediaType == "" {
+		writeError(w, http.StatusBadRequest, errcode.ErrorCodeManifestInvalid, "missing Content-Type")
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxManifestBytes+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errcode.ErrorCodeManifestInvalid, err.Error())
+		return
+	}
+	if len(data) > maxManifestBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, errcode.ErrorCodeSizeInvalid, "manifest too large")
+		return
+	}
+	desc := content.NewDescriptorFromBytes(mediaType, data)
+	if dgst != "" && dgst != desc.Digest {
+		writeError(w, http.StatusBadRequest, errcode.ErrorCodeDigestInvalid, "provided digest did not match uploaded content")
+		return
+	}
+	var manifest manifestContent
+	if descriptor.IsManifest(desc) {
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			writeError(w, http.StatusBadRequest, errcode.ErrorCodeManifestInvalid, err.Error())
+			return
+		}
+		if manifest.MediaType != "" && manifest.MediaType != mediaType {
+			writeError(w, http.StatusBadRequest, errcode.ErrorCodeManifestInvalid, fmt.Sprintf("mediaType %q does not match Content-Type %q", manifest.MediaType, mediaType))
+			return
+		}
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	repo := h.repository(name, true)
+	blobs := slices.Concat(manifest.Layers, manifest.Blobs)
+	if manifest.Config != nil {
+		blobs = append(blobs, *manifest.Config)
+	}
+	for _, blob := range blobs {
+		if _, ok := repo.blob(blob.Digest); !ok && len(blob.URLs) == 0 {
+			writeError(w, http.StatusBadRequest, errcode.ErrorCodeManifestBlobUnknown, fmt.Sprintf("blob %s unknown to registry", blob.Digest))
+			return
+		}
+	}
+	for _, child := range manifest.Manifests {
+		if _, ok := repo.manifests[child.Digest]; !ok {
+			writeError(w, http.StatusBadRequest, errcode.ErrorCodeManifestBlobUnknown, fmt.Sprintf("manifest %s unknown to registry", child.Digest))
+			return
+		}
+	}
+	if err := repo.push(r.Context(), desc, data); err != nil {
+		writeError(w, http.StatusInternalServerError, errcode.ErrorCodeManifestInvalid, err.Error())
+		return
+	}
+	repo.manifests[desc.Digest] = desc
+	if dgst == "" {
+		repo.tags[reference] = desc.Digest
+	}
+	if manifest.Subject != nil {
+		subject := manifest.Subject.Digest
+		if !slices.ContainsFunc(repo.referrers[subject], func(referrer ocispec.Descriptor) bool {
+			return referrer.Digest == desc.Digest
+		}) {
+			referrer := desc
+			referrer.ArtifactType = manifest.ArtifactType
+			if referrer.ArtifactType == "" && manifest.Config != nil {
+				referrer.ArtifactType = manifest.Config.MediaType
+			}
+			referrer.Annotations = manifest.Annotations
+			repo.referrers[subject] = append(repo.referrers[subject], referrer)
+		}
+		if !h.opts.DisableReferrersAPI {
+			w.Header().Set("OCI-Subject", subject.String())
+		}
+	}
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", name, desc.Digest))
+	w.Header().Set("Docker-Content-Digest", desc.Digest.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+// serveTags serves the tag listing endpoint.
+//
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#listing-tags
+func (h *Handler) serveTags(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	h.lock.Lock()
+	repo := h.repository(name, false)
+	if repo == nil {
+		h.lock.Unlock()
+		writeError(w, http.StatusNotFound, errcode.ErrorCodeNameUnknown, "repository name not known to registry")
+		return
+	}
+	tags := make([]string, 0, len(repo.tags))
+	for tag := range repo.tags {
+		tags = append(tags, tag)
+	}
+	h.lock.Unlock()
+
+	writeList(w, r, fmt.Sprintf("/v2/%s/tags/list", name), tags, func(page []string) any {
+		return struct {
+			Name string   `json:"name"`
+			Tags []string `json:"tags"`
+		}{
+			Name: name,
+			Tags: page,
+		}
+	})
+}
+
+// serveCatalog serves the catalog endpoint.
+//
+// Reference: https://distribution.github.io/distribution/spec/api/#catalog
+func (h *Handler) serveCatalog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	h.lock.Lock()
+	repos := make([]string, 0, len(h.repositories))
+	for name := range h.repositories {
+		repos = append(repos, name)
+	}
+	h.lock.Unlock()
+
+	writeList(w, r, "/v2/_catalog", repos, func(page []string) any {
+		return struct {
+			Repositories []string `json:"repositories"`
+		}{
+			Repositories: page,
+		}
+	})
+}
+
+// serveReferrers serves the referrers API.
+//
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#listing-referrers
+func (h *Handler) serveReferrers(w http.ResponseWriter, r *http.Request, name, reference string) {
+	if h.opts.DisableReferrersAPI {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	dgst, err := digest.Parse(reference)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errcode.ErrorCodeDigestInvalid, err.Error())
+		return
+	}
+
+	h.lock.Lock()
+	repo := h.repository(name, false)
+	if repo == nil {
+		h.lock.Unlock()
+		writeError(w, http.StatusNotFound, errcode.ErrorCodeNameUnknown, "repository name not known to registry")
+		return
+	}
+	artifactType := r.URL.Query().Get("artifactType")
+	referrers := []ocispec.Descriptor{}
+	for _, referrer := range repo.referrers[dgst] {
+		if _, ok := repo.manifests[referrer.Digest]; !ok {
+			// deleted
+			continue
+		}
+		if artifactType == "" || referrer.ArtifactType == artifactType {
+			referrers = append(referrers, referrer)
+		}
+	}
+	h.lock.Unlock()
+
+	if artifactType != "" {
+		w.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+	w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+	index := ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: referrers,
+	}
+	index.SchemaVersion = 2
+	json.NewEncoder(w).Encode(index)
+}
+
+// writeList writes a page of the sorted items, paginated by the "n" and
+// "last" query parameters, with a "Link" header to the next page if any.
+func writeList(w http.ResponseWriter, r *http.Request, path string, items []string, body func(page []string) any) {
+	slices.Sort(items)
+	q := r.URL.Query()
+	if last := q.Get("last"); last != "" {
+		i, _ := slices.BinarySearch(items, last)
+		for i < len(items) && items[i] <= last {
+			i++
+		}
+		items = items[i:]
+	}
+	if n := q.Get("n"); n != "" {
+		size, err := strconv.Atoi(n)
+		if err != nil || size < 0 {
+			writeError(w, http.StatusBadRequest, errcode.ErrorCodeUnsupported, "invalid page size")
+			return
+		}
+		if size < len(items) {
+			items = items[:size]
+			if size > 0 {
+				next := url.Values{}
+				next.Set("last", items[size-1])
+				next.Set("n", n)
+				w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", path, next.Encode()))
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body(items))
+}
+
+// writeBlobCreated writes the response of a completed blob upload.
+func writeBlobCreated(w http.ResponseWriter, name string, dgst digest.Digest) {
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, dgst))
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+// writeUploadStatus writes the status of an upload session, where offset is
+// the number of bytes received.
+func writeUploadStatus(w http.ResponseWriter, name, id string, offset int64, statusCode int) {
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, id))
+	w.Header().Set("Docker-Upload-UUID", id)
+	w.Header().Set("Range", fmt.Sprintf("0-%d", max(offset-1, 0)))
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(statusCode)
+}
+
+// writeError writes an error response in the format of the distribution spec.
+//
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#error-codes
+func writeError(w http.ResponseWriter, statusCode int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(struct {
+		Errors errcode.Errors `json:"errors"`
+	}{
+		Errors: errcode.Errors{
+			{
+				Code:    code,
+				Message: message,
+			},
+		},
+	})
+}
+
+// parseContentRange parses the Content-Range header of a chunk in the form of
+// "<start>-<end>".
+func parseContentRange(contentRange string) (start, end int64, err error) {
+	startValue, endValue, ok := strings.Cut(contentRange, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	if start, err = strconv.ParseInt(startValue, 10, 64); err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	if end, err = strconv.ParseInt(endValue, 10, 64); err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	return start, end, nil
+}
+
+// newID returns a random identifier.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
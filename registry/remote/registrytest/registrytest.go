@@ -0,0 +1,122 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package registrytest provides an in-memory registry implementing the OCI
+// distribution spec for testing clients such as remote.Repository.
+//
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md
+package registrytest
+
+import (
+	"net/http/httptest"
+	"net/url"
+
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+// Options contains parameters for the registry.
+// The zero value emulates a registry supporting all the optional features
+// without authentication.
+type Options struct {
+	// DisableReferrersAPI emulates a registry without the referrers API.
+	// If true, the referrers API responds 404 without an error code, and the
+	// "OCI-Subject" header is not set when pushing manifests with a subject.
+	DisableReferrersAPI bool
+
+	// DisableRangeRequests emulates a registry ignoring the Range header on
+	// blob requests. If true, blobs are always served in full.
+	DisableRangeRequests bool
+
+	// DisableMount emulates a registry without cross-repository blob
+	// mounting. If true, mount requests start an upload session instead.
+	DisableMount bool
+
+	// ChunkMinLength is the minimum size of the chunks of blob uploads
+	// reported by the "OCI-Chunk-Min-Length" header.
+	// If not positive, the header is not set.
+	ChunkMinLength int64
+
+	// Username and Password are the credential required by the registry.
+	// If Username is not empty, the registry requires bearer tokens issued by
+	// its token endpoint, which authenticates the clients with the credential.
+	Username string
+	Password string
+}
+
+// Server is an in-memory registry serving over HTTP.
+type Server struct {
+	*httptest.Server
+
+	// Handler is the handler of the registry.
+	Handler *Handler
+
+	// Host is the host of the registry in the form of "<hostname>:<port>".
+	Host string
+}
+
+// NewServer starts and returns a new Server.
+// The caller should call Close when finished, to shut it down.
+func NewServer(opts Options) *Server {
+	handler := NewHandler(opts)
+	ts := httptest.NewServer(handler)
+	u, _ := url.Parse(ts.URL)
+	return &Server{
+		Server:  ts,
+		Handler: handler,
+		Host:    u.Host,
+	}
+}
+
+// Repository returns a client to the repository with the given name in the
+// registry. The client is authenticated with the credential in the options,
+// if any.
+func (s *Server) Repository(name string) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(s.Host + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	repo.PlainHTTP = true
+	repo.Client = s.client()
+	return repo, nil
+}
+
+// Registry returns a client to the registry. The client is authenticated with
+// the credential in the options, if any.
+func (s *Server) Registry() (*remote.Registry, error) {
+	reg, err := remote.NewRegistry(s.Host)
+	if err != nil {
+		return nil, err
+	}
+	reg.PlainHTTP = true
+	reg.Client = s.client()
+	return reg, nil
+}
+
+// client returns an auth client with the credential in the options.
+func (s *Server) client() remote.Client {
+	if s.Handler.opts.Username == "" {
+		return auth.DefaultClient
+	}
+	return &auth.Client{
+		Client: retry.DefaultClient,
+		Cache:  auth.NewCache(),
+		Credential: auth.StaticCredential(s.Host, auth.Credential{
+			Username: s.Handler.opts.Username,
+			Password: s.Handler.opts.Password,
+		}),
+	}
+}
@@ -0,0 +1,478 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registrytest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"testing/iotest"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/registrytest"
+)
+
+// testImage contains the content of an image.
+type testImage struct {
+	config   ocispec.Descriptor
+	layer    ocispec.Descriptor
+	manifest ocispec.Descriptor
+	blobs    map[digest.Digest][]byte
+}
+
+// newTestImage generates an image with the given layer content.
+// If subject is not nil, the image is generated as a referrer of the subject.
+func newTestImage(t *testing.T, layer []byte, artifactType string, subject *ocispec.Descriptor) testImage {
+	t.Helper()
+	img := testImage{
+		blobs: make(map[digest.Digest][]byte),
+	}
+	add := func(mediaType string, blob []byte) ocispec.Descriptor {
+		desc := content.NewDescriptorFromBytes(mediaType, blob)
+		img.blobs[desc.Digest] = blob
+		return desc
+	}
+	img.config = add(ocispec.MediaTypeImageConfig, []byte("{}"))
+	img.layer = add(ocispec.MediaTypeImageLayer, layer)
+	manifest := ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: artifactType,
+		Config:       img.config,
+		Layers:       []ocispec.Descriptor{img.layer},
+		Subject:      subject,
+	}
+	manifest.SchemaVersion = 2
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img.manifest = add(ocispec.MediaTypeImageManifest, manifestJSON)
+	return img
+}
+
+// push pushes the image to the repository.
+func (img testImage) push(t *testing.T, ctx context.Context, repo *remote.Repository, tag string) {
+	t.Helper()
+	for _, desc := range []ocispec.Descriptor{img.config, img.layer} {
+		if err := repo.Push(ctx, desc, bytes.NewReader(img.blobs[desc.Digest])); err != nil {
+			t.Fatalf("Repository.Push() error = %v", err)
+		}
+	}
+	manifest := bytes.NewReader(img.blobs[img.manifest.Digest])
+	if tag == "" {
+		if err := repo.Push(ctx, img.manifest, manifest); err != nil {
+			t.Fatalf("Repository.Push() error = %v", err)
+		}
+		return
+	}
+	if err := repo.PushReference(ctx, img.manifest, manifest, tag); err != nil {
+		t.Fatalf("Repository.PushReference() error = %v", err)
+	}
+}
+
+func newTestRepository(t *testing.T, s *registrytest.Server, name string) *remote.Repository {
+	t.Helper()
+	repo, err := s.Repository(name)
+	if err != nil {
+		t.Fatalf("Server.Repository() error = %v", err)
+	}
+	return repo
+}
+
+func TestServer_PushAndPull(t *testing.T) {
+	s := registrytest.NewServer(registrytest.Options{})
+	defer s.Close()
+	repo := newTestRepository(t, s, "test/hello")
+	ctx := context.Background()
+	img := newTestImage(t, []byte("hello world"), "", nil)
+	img.push(t, ctx, repo, "latest")
+
+	desc, rc, err := repo.FetchReference(ctx, "latest")
+	if err != nil {
+		t.Fatalf("Repository.FetchReference() error = %v", err)
+	}
+	got, err := content.ReadAll(rc, desc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("Repository.FetchReference().Read() error = %v", err)
+	}
+	if !content.Equal(desc, img.manifest) {
+		t.Errorf("Repository.FetchReference() = %v, want %v", desc, img.manifest)
+	}
+	if want := img.blobs[img.manifest.Digest]; !bytes.Equal(got, want) {
+		t.Errorf("Repository.FetchReference() = %s, want %s", got, want)
+	}
+
+	for _, want := range []ocispec.Descriptor{img.config, img.layer, img.manifest} {
+		exists, err := repo.Exists(ctx, want)
+		if err != nil {
+			t.Fatalf("Repository.Exists() error = %v", err)
+		}
+		if !exists {
+			t.Errorf("Repository.Exists(%s) = %v, want %v", want.Digest, exists, true)
+		}
+		got, err := content.FetchAll(ctx, repo, want)
+		if err != nil {
+			t.Fatalf("content.FetchAll() error = %v", err)
+		}
+		if !bytes.Equal(got, img.blobs[want.Digest]) {
+			t.Errorf("content.FetchAll(%s) = %s, want %s", want.Digest, got, img.blobs[want.Digest])
+		}
+	}
+
+	// manifests referencing unknown blobs are rejected
+	missing := newTestImage(t, []byte("missing"), "", nil)
+	if err := repo.Push(ctx, missing.manifest, bytes.NewReader(missing.blobs[missing.manifest.Digest])); err == nil {
+		t.Error("Repository.Push() error = nil, wantErr true")
+	}
+
+	// blobs with mismatched digests are rejected
+	blob := []byte("foo")
+	desc = content.NewDescriptorFromBytes("test", []byte("bar"))
+	desc.Size = int64(len(blob))
+	if err := repo.Push(ctx, desc, bytes.NewReader(blob)); err == nil {
+		t.Error("Repository.Push() error = nil, wantErr true")
+	}
+}
+
+func TestServer_ChunkedUpload(t *testing.T) {
+	s := registrytest.NewServer(registrytest.Options{
+		ChunkMinLength: 100,
+	})
+	defer s.Close()
+	repo := newTestRepository(t, s, "test")
+	repo.BlobUploadChunkSize = 10
+	var sessions []remote.BlobUploadSession
+	repo.HandleBlobUploadSession = func(session remote.BlobUploadSession) {
+		sessions = append(sessions, session)
+	}
+	ctx := context.Background()
+
+	blob := bytes.Repeat([]byte("hello world "), 100)
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, blob)
+	if err := repo.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+		t.Fatalf("Repository.Push() error = %v", err)
+	}
+	// the chunk size is raised to the minimum chunk length
+	if got, want := len(sessions), 13; got != want {
+		t.Errorf("count(sessions) = %d, want %d", got, want)
+	}
+	got, err := content.FetchAll(ctx, repo, desc)
+	if err != nil {
+		t.Fatalf("content.FetchAll() error = %v", err)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Errorf("content.FetchAll() = %s, want %s", got, blob)
+	}
+}
+
+func TestServer_ResumeBlobUpload(t *testing.T) {
+	s := registrytest.NewServer(registrytest.Options{})
+	defer s.Close()
+	repo := newTestRepository(t, s, "test")
+	repo.BlobUploadChunkSize = 100
+	ctx := context.Background()
+
+	blob := bytes.Repeat([]byte("hello world "), 100)
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, blob)
+	var session remote.BlobUploadSession
+	errStop := errors.New("stop")
+	repo.HandleBlobUploadSession = func(s remote.BlobUploadSession) {
+		session = s
+	}
+	// interrupt the upload after 500 bytes
+	r := io.MultiReader(bytes.NewReader(blob[:500]), iotest.ErrReader(errStop))
+	if err := repo.Push(ctx, desc, r); !errors.Is(err, errStop) {
+		t.Fatalf("Repository.Push() error = %v, wantErr %v", err, errStop)
+	}
+	if got, want := session.Offset, int64(500); got != want {
+		t.Fatalf("BlobUploadSession.Offset = %d, want %d", got, want)
+	}
+
+	if err := repo.ResumeBlobUpload(ctx, session, bytes.NewReader(blob)); err != nil {
+		t.Fatalf("Repository.ResumeBlobUpload() error = %v", err)
+	}
+	got, err := content.FetchAll(ctx, repo, desc)
+	if err != nil {
+		t.Fatalf("content.FetchAll() error = %v", err)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Errorf("content.FetchAll() = %s, want %s", got, blob)
+	}
+
+	// the session is closed after completion
+	if err := repo.ResumeBlobUpload(ctx, session, bytes.NewReader(blob)); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Repository.ResumeBlobUpload() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+}
+
+func TestServer_Mount(t *testing.T) {
+	ctx := context.Background()
+	blob := []byte("hello world")
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, blob)
+
+	for _, disableMount := range []bool{false, true} {
+		s := registrytest.NewServer(registrytest.Options{
+			DisableMount: disableMount,
+		})
+		defer s.Close()
+		src := newTestRepository(t, s, "source")
+		if err := src.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+			t.Fatalf("Repository.Push() error = %v", err)
+		}
+
+		dst := newTestRepository(t, s, "destination")
+		var fallback bool
+		getContent := func() (io.ReadCloser, error) {
+			fallback = true
+			return io.NopCloser(bytes.NewReader(blob)), nil
+		}
+		if err := dst.Mount(ctx, desc, "source", getContent); err != nil {
+			t.Fatalf("Repository.Mount() error = %v", err)
+		}
+		if fallback != disableMount {
+			t.Errorf("Repository.Mount() fallback = %v, want %v", fallback, disableMount)
+		}
+		exists, err := dst.Exists(ctx, desc)
+		if err != nil {
+			t.Fatalf("Repository.Exists() error = %v", err)
+		}
+		if !exists {
+			t.Errorf("Repository.Exists() = %v, want %v", exists, true)
+		}
+	}
+}
+
+func TestServer_Tags(t *testing.T) {
+	s := registrytest.NewServer(registrytest.Options{})
+	defer s.Close()
+	repo := newTestRepository(t, s, "test")
+	repo.TagListPageSize = 2
+	ctx := context.Background()
+	img := newTestImage(t, []byte("hello world"), "", nil)
+	img.push(t, ctx, repo, "")
+	want := []string{"v1", "v2", "v3", "v4", "v5"}
+	for _, tag := range slices.Backward(want) {
+		if err := repo.Tag(ctx, img.manifest, tag); err != nil {
+			t.Fatalf("Repository.Tag() error = %v", err)
+		}
+	}
+
+	var pages [][]string
+	if err := repo.Tags(ctx, "", func(tags []string) error {
+		pages = append(pages, tags)
+		return nil
+	}); err != nil {
+		t.Fatalf("Repository.Tags() error = %v", err)
+	}
+	if got, want := len(pages), 3; got != want {
+		t.Errorf("count(pages) = %d, want %d", got, want)
+	}
+	if got := slices.Concat(pages...); !slices.Equal(got, want) {
+		t.Errorf("Repository.Tags() = %v, want %v", got, want)
+	}
+
+	var got []string
+	if err := repo.Tags(ctx, "v3", func(tags []string) error {
+		got = append(got, tags...)
+		return nil
+	}); err != nil {
+		t.Fatalf("Repository.Tags() error = %v", err)
+	}
+	if want := want[3:]; !slices.Equal(got, want) {
+		t.Errorf("Repository.Tags() = %v, want %v", got, want)
+	}
+}
+
+func TestServer_Catalog(t *testing.T) {
+	s := registrytest.NewServer(registrytest.Options{})
+	defer s.Close()
+	ctx := context.Background()
+	want := []string{"bar", "foo", "foo/bar"}
+	for _, name := range want {
+		repo := newTestRepository(t, s, name)
+		newTestImage(t, []byte(name), "", nil).push(t, ctx, repo, "latest")
+	}
+
+	reg, err := s.Registry()
+	if err != nil {
+		t.Fatalf("Server.Registry() error = %v", err)
+	}
+	reg.RepositoryListPageSize = 2
+	got, err := registry.Repositories(ctx, reg)
+	if err != nil {
+		t.Fatalf("Repositories() error = %v", err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Repositories() = %v, want %v", got, want)
+	}
+}
+
+func TestServer_Referrers(t *testing.T) {
+	ctx := context.Background()
+	for _, disableReferrersAPI := range []bool{false, true} {
+		s := registrytest.NewServer(registrytest.Options{
+			DisableReferrersAPI: disableReferrersAPI,
+		})
+		defer s.Close()
+		repo := newTestRepository(t, s, "test")
+
+		subject := newTestImage(t, []byte("subject"), "", nil)
+		subject.push(t, ctx, repo, "latest")
+		sbom := newTestImage(t, []byte("sbom"), "application/vnd.test.sbom", &subject.manifest)
+		sbom.push(t, ctx, repo, "")
+		sig := newTestImage(t, []byte("signature"), "application/vnd.test.signature", &subject.manifest)
+		sig.push(t, ctx, repo, "")
+
+		listReferrers := func(artifactType string) []digest.Digest {
+			t.Helper()
+			var got []digest.Digest
+			if err := repo.Referrers(ctx, subject.manifest, artifactType, func(referrers []ocispec.Descriptor) error {
+				for _, referrer := range referrers {
+					got = append(got, referrer.Digest)
+				}
+				return nil
+			}); err != nil {
+				t.Fatalf("Repository.Referrers() error = %v", err)
+			}
+			slices.Sort(got)
+			return got
+		}
+		want := []digest.Digest{sbom.manifest.Digest, sig.manifest.Digest}
+		slices.Sort(want)
+		if got := listReferrers(""); !slices.Equal(got, want) {
+			t.Errorf("Repository.Referrers() = %v, want %v", got, want)
+		}
+		want = []digest.Digest{sig.manifest.Digest}
+		if got := listReferrers("application/vnd.test.signature"); !slices.Equal(got, want) {
+			t.Errorf("Repository.Referrers() = %v, want %v", got, want)
+		}
+
+		// deleted referrers are not listed
+		if err := repo.Delete(ctx, sbom.manifest); err != nil {
+			t.Fatalf("Repository.Delete() error = %v", err)
+		}
+		if got := listReferrers(""); !slices.Equal(got, want) {
+			t.Errorf("Repository.Referrers() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestServer_Delete(t *testing.T) {
+	s := registrytest.NewServer(registrytest.Options{})
+	defer s.Close()
+	repo := newTestRepository(t, s, "test")
+	ctx := context.Background()
+	img := newTestImage(t, []byte("hello world"), "", nil)
+	img.push(t, ctx, repo, "latest")
+
+	if err := repo.Delete(ctx, img.manifest); err != nil {
+		t.Fatalf("Repository.Delete() error = %v", err)
+	}
+	if _, err := repo.Resolve(ctx, "latest"); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Repository.Resolve() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+	if err := repo.Delete(ctx, img.manifest); !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Repository.Delete() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+
+	if err := repo.Delete(ctx, img.layer); err != nil {
+		t.Fatalf("Repository.Delete() error = %v", err)
+	}
+	exists, err := repo.Exists(ctx, img.layer)
+	if err != nil {
+		t.Fatalf("Repository.Exists() error = %v", err)
+	}
+	if exists {
+		t.Errorf("Repository.Exists() = %v, want %v", exists, false)
+	}
+
+	// deleted content can be pushed again
+	img.push(t, ctx, repo, "latest")
+	if _, err := repo.Resolve(ctx, "latest"); err != nil {
+		t.Errorf("Repository.Resolve() error = %v", err)
+	}
+}
+
+func TestServer_Auth(t *testing.T) {
+	s := registrytest.NewServer(registrytest.Options{
+		Username: "username",
+		Password: "password",
+	})
+	defer s.Close()
+	ctx := context.Background()
+	repo := newTestRepository(t, s, "test")
+	img := newTestImage(t, []byte("hello world"), "", nil)
+	img.push(t, ctx, repo, "latest")
+	if _, err := repo.Resolve(ctx, "latest"); err != nil {
+		t.Fatalf("Repository.Resolve() error = %v", err)
+	}
+	reg, err := s.Registry()
+	if err != nil {
+		t.Fatalf("Server.Registry() error = %v", err)
+	}
+	if err := reg.Ping(ctx); err != nil {
+		t.Errorf("Registry.Ping() error = %v", err)
+	}
+	if _, err := registry.Repositories(ctx, reg); err != nil {
+		t.Errorf("Repositories() error = %v", err)
+	}
+
+	// anonymous access is denied
+	anonymous, err := remote.NewRepository(s.Host + "/test")
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+	anonymous.PlainHTTP = true
+	if _, err := anonymous.Resolve(ctx, "latest"); err == nil {
+		t.Error("Repository.Resolve() error = nil, wantErr true")
+	}
+}
+
+func TestServer_FetchRange(t *testing.T) {
+	ctx := context.Background()
+	blob := bytes.Repeat([]byte("hello world "), 100)
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, blob)
+	for _, disableRangeRequests := range []bool{false, true} {
+		s := registrytest.NewServer(registrytest.Options{
+			DisableRangeRequests: disableRangeRequests,
+		})
+		defer s.Close()
+		repo := newTestRepository(t, s, "test")
+		if err := repo.Push(ctx, desc, bytes.NewReader(blob)); err != nil {
+			t.Fatalf("Repository.Push() error = %v", err)
+		}
+
+		repo.BlobFetchConcurrency = 4
+		repo.BlobFetchPartSize = 64
+		got, err := content.FetchAll(ctx, repo, desc)
+		if err != nil {
+			t.Fatalf("content.FetchAll() error = %v", err)
+		}
+		if !bytes.Equal(got, blob) {
+			t.Errorf("content.FetchAll() = %s, want %s", got, blob)
+		}
+	}
+}
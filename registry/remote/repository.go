@@ -151,6 +151,47 @@ type Repository struct {
 	//   - https://www.rfc-editor.org/rfc/rfc7234#section-5.5
 	HandleWarning func(warning Warning)
 
+	// BlobUploadChunkSize specifies the size of the chunks when pushing blobs.
+	//  - If less than or equal to zero, blobs are pushed by monolithic upload.
+	//  - If positive, blobs larger than BlobUploadChunkSize are pushed in
+	//    chunks of BlobUploadChunkSize bytes, or of the minimum chunk size
+	//    required by the remote registry if larger. A chunk is buffered in the
+	//    memory, and its upload is resumed from the offset reported by the
+	//    registry on failures.
+	// By default, it is disabled (set to 0). See also:
+	//  - https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pushing-a-blob-in-chunks
+	BlobUploadChunkSize int64
+
+	// HandleBlobUploadSession handles the session of a chunked blob upload.
+	// It is invoked when the upload session is initiated and after each chunk
+	// is uploaded. The session can be persisted by the caller and resumed by
+	// ResumeBlobUpload, for example, after a process restart.
+	HandleBlobUploadSession func(session BlobUploadSession)
+
+	// BlobFetchConcurrency specifies the maximum number of concurrent range
+	// requests when fetching a blob.
+	//  - If less than or equal to 1, blobs are fetched in a single request.
+	//  - If greater than 1, blobs larger than BlobFetchPartSize are fetched in
+	//    parts of BlobFetchPartSize bytes concurrently if the remote registry
+	//    supports range requests. The parts are buffered in the memory and
+	//    reassembled in order, and the content is verified against the digest
+	//    of the blob.
+	// By default, it is disabled (set to 0).
+	BlobFetchConcurrency int
+
+	// BlobFetchPartSize specifies the size of the parts when fetching blobs
+	// concurrently. See also BlobFetchConcurrency.
+	// If less than or equal to zero, a default (currently 16 MiB) is used.
+	BlobFetchPartSize int64
+
+	// Mirrors specifies the mirrors of the remote repository, which are tried
+	// in order before the remote repository when fetching, resolving and
+	// checking the existence of content. Other operations, such as pushing,
+	// tagging, deleting and listing, always access the remote repository.
+	// If no mirror is configured for the remote repository, the remote
+	// repository is accessed directly.
+	Mirrors MirrorConfig
+
 	// NOTE: Must keep fields in sync with clone().
 
 	// referrersState represents that if the repository supports Referrers API.
@@ -207,6 +248,13 @@ func (r *Repository) clone() *Repository {
 		MaxMetadataBytes:     r.MaxMetadataBytes,
 		SkipReferrersGC:      r.SkipReferrersGC,
 		HandleWarning:        r.HandleWarning,
+
+		BlobUploadChunkSize:     r.BlobUploadChunkSize,
+		HandleBlobUploadSession: r.HandleBlobUploadSession,
+		BlobFetchConcurrency:    r.BlobFetchConcurrency,
+		BlobFetchPartSize:       r.BlobFetchPartSize,
+
+		Mirrors: r.Mirrors.clone(),
 	}
 }
 
@@ -278,8 +326,11 @@ func (r *Repository) blobStore(desc ocispec.Descriptor) registry.BlobStore {
 }
 
 // Fetch fetches the content identified by the descriptor.
+// See also `Mirrors`.
 func (r *Repository) Fetch(ctx context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
-	return r.blobStore(target).Fetch(ctx, target)
+	return pullWithMirrors(ctx, r, func(repo *Repository) (io.ReadCloser, error) {
+		return repo.blobStore(target).Fetch(ctx, target)
+	})
 }
 
 // Push pushes the content, matching the expected descriptor.
@@ -301,8 +352,16 @@ func (r *Repository) Mount(ctx context.Context, desc ocispec.Descriptor, fromRep
 }
 
 // Exists returns true if the described content exists.
+// See also `Mirrors`.
 func (r *Repository) Exists(ctx context.Context, target ocispec.Descriptor) (bool, error) {
-	return r.blobStore(target).Exists(ctx, target)
+	return pullWithMirrors(ctx, r, func(repo *Repository) (bool, error) {
+		exists, err := repo.blobStore(target).Exists(ctx, target)
+		if err == nil && !exists && repo != r {
+			// fall back to the next endpoint
+			return false, errdef.ErrNotFound
+		}
+		return exists, err
+	})
 }
 
 // Delete removes the content identified by the descriptor.
@@ -322,9 +381,18 @@ func (r *Repository) Manifests() registry.ManifestStore {
 }
 
 // Resolve resolves a reference to a manifest descriptor.
-// See also `ManifestMediaTypes`.
+// See also `ManifestMediaTypes` and `Mirrors`.
 func (r *Repository) Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error) {
-	return r.Manifests().Resolve(ctx, reference)
+	if len(r.Mirrors) == 0 {
+		return r.Manifests().Resolve(ctx, reference)
+	}
+	ref, err := r.ParseReference(reference)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return pullWithMirrors(ctx, r, func(repo *Repository) (ocispec.Descriptor, error) {
+		return repo.Manifests().Resolve(ctx, ref.Reference)
+	})
 }
 
 // Tag tags a manifest descriptor with a reference string.
@@ -339,8 +407,24 @@ func (r *Repository) PushReference(ctx context.Context, expected ocispec.Descrip
 
 // FetchReference fetches the manifest identified by the reference.
 // The reference can be a tag or digest.
+// See also `Mirrors`.
 func (r *Repository) FetchReference(ctx context.Context, reference string) (ocispec.Descriptor, io.ReadCloser, error) {
-	return r.Manifests().FetchReference(ctx, reference)
+	if len(r.Mirrors) == 0 {
+		return r.Manifests().FetchReference(ctx, reference)
+	}
+	ref, err := r.ParseReference(reference)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	type result struct {
+		desc ocispec.Descriptor
+		rc   io.ReadCloser
+	}
+	res, err := pullWithMirrors(ctx, r, func(repo *Repository) (result, error) {
+		desc, rc, err := repo.Manifests().FetchReference(ctx, ref.Reference)
+		return result{desc: desc, rc: rc}, err
+	})
+	return res.desc, res.rc, err
 }
 
 // ParseReference resolves a tag or a digest reference to a fully qualified
@@ -735,6 +819,12 @@ func (s *blobStore) Fetch(ctx context.Context, target ocispec.Descriptor) (rc io
 	if err != nil {
 		return nil, err
 	}
+	parallel := s.repo.BlobFetchConcurrency > 1 && target.Size > s.repo.blobFetchPartSize()
+	if parallel {
+		// request the first part, and fetch the rest in parallel if the
+		// server honors the range request.
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", s.repo.blobFetchPartSize()-1))
+	}
 
 	resp, err := s.repo.do(req)
 	if err != nil {
@@ -747,6 +837,14 @@ func (s *blobStore) Fetch(ctx context.Context, target ocispec.Descriptor) (rc io
 	}()
 
 	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if !parallel {
+			return nil, fmt.Errorf("%s %q: unexpected status code %d", resp.Request.Method, resp.Request.URL, resp.StatusCode)
+		}
+		if err := verifyContentDigest(resp, target.Digest); err != nil {
+			return nil, err
+		}
+		return s.fetchParallel(req, resp, target)
 	case http.StatusOK:
 		if size := resp.ContentLength; size != -1 && size != target.Size {
 			return nil, fmt.Errorf("%s %q: mismatch Content-Length", resp.Request.Method, resp.Request.URL)
@@ -843,11 +941,14 @@ func (s *blobStore) sibling(otherRepoName string) *blobStore {
 // Push is done by conventional 2-step monolithic upload instead of a single
 // `POST` request for better overall performance. It also allows early fail on
 // authentication errors.
+// If Repository.BlobUploadChunkSize is positive, large blobs are pushed by
+// chunked upload instead.
 //
 // References:
 //   - https://distribution.github.io/distribution/spec/api/#pushing-an-image
 //   - https://distribution.github.io/distribution/spec/api/#initiate-blob-upload
 //   - https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pushing-a-blob-monolithically
+//   - https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pushing-a-blob-in-chunks
 func (s *blobStore) Push(ctx context.Context, expected ocispec.Descriptor, content io.Reader) error {
 	// start an upload
 	// pushing usually requires both pull and push actions.
@@ -876,24 +977,15 @@ func (s *blobStore) Push(ctx context.Context, expected ocispec.Descriptor, conte
 // Push or by Mount when the receiving repository does not implement the
 // mount endpoint.
 func (s *blobStore) completePushAfterInitialPost(ctx context.Context, req *http.Request, resp *http.Response, expected ocispec.Descriptor, content io.Reader) error {
-	reqHostname := req.URL.Hostname()
-	reqPort := req.URL.Port()
-	// monolithic upload
-	location, err := resp.Location()
+	location, err := uploadLocation(req, resp)
 	if err != nil {
 		return err
 	}
-	// work-around solution for https://github.com/oras-project/oras-go/issues/177
-	// For some registries, if the port 443 is explicitly set to the hostname
-	// like registry.wabbit-networks.io:443/myrepo, blob push will fail since
-	// the hostname of the Location header in the response is set to
-	// registry.wabbit-networks.io instead of registry.wabbit-networks.io:443.
-	locationHostname := location.Hostname()
-	locationPort := location.Port()
-	// if location port 443 is missing, add it back
-	if reqPort == "443" && locationHostname == reqHostname && locationPort == "" {
-		location.Host = locationHostname + ":" + reqPort
+	if chunkSize := s.repo.BlobUploadChunkSize; chunkSize > 0 && expected.Size > chunkSize {
+		return s.pushChunked(ctx, resp, location, expected, content)
 	}
+
+	// monolithic upload
 	url := location.String()
 	req, err = http.NewRequestWithContext(ctx, http.MethodPut, url, content)
 	if err != nil {
@@ -0,0 +1,302 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a request is short-circuited by an open
+// CircuitBreaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a circuit of a host.
+type CircuitState int
+
+const (
+	// CircuitClosed indicates that the requests are sent as normal.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen indicates that the requests are short-circuited without
+	// being sent.
+	CircuitOpen
+
+	// CircuitHalfOpen indicates that the cool-down period has passed and
+	// probe requests are sent to determine whether the host has recovered.
+	CircuitHalfOpen
+)
+
+// String returns the string representation of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// DefaultCircuitBreakerThreshold is the default value of
+// CircuitBreaker.FailureThreshold.
+const DefaultCircuitBreakerThreshold = 5
+
+// DefaultCircuitBreakerCoolDown is the default value of
+// CircuitBreaker.CoolDown.
+const DefaultCircuitBreakerCoolDown = 30 * time.Second
+
+// CircuitBreaker short-circuits the requests to a host after consecutive
+// failures, so that a host being down is not overloaded by retries.
+//
+// A circuit of a host opens after FailureThreshold consecutive failures, where
+// the requests fail immediately with ErrCircuitOpen. After the CoolDown
+// period, the circuit becomes half-open and lets MaxProbes requests through
+// at a time. The circuit closes on a successful probe, or opens again on a
+// failed probe. The results of the other requests in flight, which are sent
+// before the circuit opens, do not change the state of a half-open circuit.
+//
+// Requests aborted by the callers, where the contexts of the requests are
+// canceled or past the deadlines, are neither failures nor successes.
+//
+// A CircuitBreaker is safe for concurrent use, and should be shared by the
+// transports accessing the same hosts. Its zero value is a usable circuit
+// breaker with the default parameters.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures to open a
+	// circuit.
+	// If less than or equal to 0, DefaultCircuitBreakerThreshold is used.
+	FailureThreshold int
+
+	// CoolDown is the duration for which an open circuit short-circuits the
+	// requests before letting probe requests through.
+	// If less than or equal to 0, DefaultCircuitBreakerCoolDown is used.
+	CoolDown time.Duration
+
+	// MaxProbes is the maximum number of concurrent probe requests of a
+	// half-open circuit.
+	// If less than or equal to 0, a single probe request is allowed.
+	MaxProbes int
+
+	// IsFailure returns true if the result of a request is a failure.
+	// It is not called for the requests aborted by the callers.
+	// If nil, network errors and 5xx responses are failures.
+	IsFailure func(resp *http.Response, err error) bool
+
+	// OnStateChange, if set, is called when the state of the circuit of a
+	// host changes.
+	OnStateChange func(host string, from, to CircuitState)
+
+	lock     sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit is the state of a circuit of a host.
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probes   int
+	// generation is increased every time the circuit opens, so that the
+	// probes of the previous half-open periods are told apart.
+	generation int
+}
+
+// ticket identifies a request let through by the circuit breaker.
+type ticket struct {
+	// probe is true if the request is a probe of a half-open circuit.
+	probe bool
+	// generation is the generation of the circuit when the request is let
+	// through.
+	generation int
+}
+
+// State returns the current state of the circuit of the host.
+func (cb *CircuitBreaker) State(host string) CircuitState {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	c, ok := cb.circuits[host]
+	if !ok {
+		return CircuitClosed
+	}
+	if c.state == CircuitOpen && !time.Now().Before(c.openedAt.Add(cb.coolDown())) {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+// allow returns ErrCircuitOpen if the request to the host should be
+// short-circuited. Otherwise, it returns the ticket of the request to be
+// passed to record or release.
+func (cb *CircuitBreaker) allow(host string) (ticket, error) {
+	var changes []circuitChange
+	defer cb.notify(host, &changes)
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	c, ok := cb.circuits[host]
+	if !ok {
+		return ticket{}, nil
+	}
+	switch c.state {
+	case CircuitOpen:
+		if time.Now().Before(c.openedAt.Add(cb.coolDown())) {
+			return ticket{}, ErrCircuitOpen
+		}
+		c.setState(&changes, CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if c.probes >= cb.maxProbes() {
+			return ticket{}, ErrCircuitOpen
+		}
+		c.probes++
+		return ticket{probe: true, generation: c.generation}, nil
+	}
+	return ticket{generation: c.generation}, nil
+}
+
+// release releases the request to the host without recording its result,
+// such as when the request is aborted by the caller.
+func (cb *CircuitBreaker) release(host string, t ticket) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	if c, ok := cb.circuits[host]; ok && c.isProbe(t) && c.probes > 0 {
+		c.probes--
+	}
+}
+
+// record records the result of a request to the host.
+func (cb *CircuitBreaker) record(host string, t ticket, resp *http.Response, err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// the request is aborted by the caller
+		cb.release(host, t)
+		return
+	}
+	failed := cb.isFailure(resp, err)
+
+	var changes []circuitChange
+	defer cb.notify(host, &changes)
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	c, ok := cb.circuits[host]
+	if !ok {
+		if !failed {
+			return
+		}
+		if cb.circuits == nil {
+			cb.circuits = make(map[string]*circuit)
+		}
+		c = &circuit{}
+		cb.circuits[host] = c
+	}
+
+	switch c.state {
+	case CircuitClosed:
+		if !failed {
+			c.failures = 0
+			return
+		}
+		c.failures++
+		if c.failures >= cb.failureThreshold() {
+			c.openedAt = time.Now()
+			c.setState(&changes, CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		if !c.isProbe(t) {
+			// only the probes determine the state of a half-open circuit
+			return
+		}
+		if c.probes > 0 {
+			c.probes--
+		}
+		if failed {
+			c.openedAt = time.Now()
+			c.setState(&changes, CircuitOpen)
+			return
+		}
+		c.failures = 0
+		c.probes = 0
+		c.setState(&changes, CircuitClosed)
+	}
+}
+
+// isProbe returns true if the ticket is of a probe of the current half-open
+// period of the circuit.
+func (c *circuit) isProbe(t ticket) bool {
+	return t.probe && t.generation == c.generation && c.state == CircuitHalfOpen
+}
+
+// circuitChange is a change of the state of a circuit.
+type circuitChange struct {
+	from, to CircuitState
+}
+
+// setState changes the state of the circuit and records the change.
+func (c *circuit) setState(changes *[]circuitChange, state CircuitState) {
+	*changes = append(*changes, circuitChange{from: c.state, to: state})
+	c.state = state
+	if state == CircuitOpen {
+		c.probes = 0
+		c.generation++
+	}
+}
+
+// notify calls OnStateChange with the changes of the circuit of the host,
+// outside of the lock so that OnStateChange can inspect the circuit breaker.
+func (cb *CircuitBreaker) notify(host string, changes *[]circuitChange) {
+	if cb.OnStateChange == nil {
+		return
+	}
+	for _, change := range *changes {
+		cb.OnStateChange(host, change.from, change.to)
+	}
+}
+
+func (cb *CircuitBreaker) failureThreshold() int {
+	if cb.FailureThreshold <= 0 {
+		return DefaultCircuitBreakerThreshold
+	}
+	return cb.FailureThreshold
+}
+
+func (cb *CircuitBreaker) coolDown() time.Duration {
+	if cb.CoolDown <= 0 {
+		return DefaultCircuitBreakerCoolDown
+	}
+	return cb.CoolDown
+}
+
+func (cb *CircuitBreaker) maxProbes() int {
+	if cb.MaxProbes <= 0 {
+		return 1
+	}
+	return cb.MaxProbes
+}
+
+func (cb *CircuitBreaker) isFailure(resp *http.Response, err error) bool {
+	if cb.IsFailure != nil {
+		return cb.IsFailure(resp, err)
+	}
+	return err != nil || resp.StatusCode >= 500
+}
@@ -0,0 +1,171 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	host := "registry.example"
+	var changes []string
+	cb := &CircuitBreaker{
+		FailureThreshold: 2,
+		CoolDown:         50 * time.Millisecond,
+		OnStateChange: func(host string, from, to CircuitState) {
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+	}
+	fail := &http.Response{StatusCode: http.StatusServiceUnavailable}
+	ok := &http.Response{StatusCode: http.StatusOK}
+
+	// successes reset the consecutive failures
+	cb.record(host, ticket{}, fail, nil)
+	cb.record(host, ticket{}, ok, nil)
+	cb.record(host, ticket{}, fail, nil)
+	if got := cb.State(host); got != CircuitClosed {
+		t.Fatalf("CircuitBreaker.State() = %v, want %v", got, CircuitClosed)
+	}
+
+	// requests aborted by the callers are not failures
+	cb.record(host, ticket{}, nil, context.Canceled)
+	if got := cb.State(host); got != CircuitClosed {
+		t.Fatalf("CircuitBreaker.State() = %v, want %v", got, CircuitClosed)
+	}
+
+	// consecutive failures open the circuit
+	inflight, err := cb.allow(host)
+	if err != nil {
+		t.Fatalf("CircuitBreaker.allow() error = %v, wantErr %v", err, nil)
+	}
+	cb.record(host, ticket{}, nil, errors.New("connection refused"))
+	if got := cb.State(host); got != CircuitOpen {
+		t.Fatalf("CircuitBreaker.State() = %v, want %v", got, CircuitOpen)
+	}
+	if _, err := cb.allow(host); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("CircuitBreaker.allow() error = %v, wantErr %v", err, ErrCircuitOpen)
+	}
+	if _, err := cb.allow("other.example"); err != nil {
+		t.Errorf("CircuitBreaker.allow() error = %v, wantErr %v", err, nil)
+	}
+
+	// a single probe is let through after the cool-down
+	time.Sleep(50 * time.Millisecond)
+	probe, err := cb.allow(host)
+	if err != nil {
+		t.Fatalf("CircuitBreaker.allow() error = %v, wantErr %v", err, nil)
+	}
+	if _, err := cb.allow(host); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("CircuitBreaker.allow() error = %v, wantErr %v", err, ErrCircuitOpen)
+	}
+
+	// the requests other than the probe do not close the circuit
+	cb.record(host, inflight, ok, nil)
+	if got := cb.State(host); got != CircuitHalfOpen {
+		t.Fatalf("CircuitBreaker.State() = %v, want %v", got, CircuitHalfOpen)
+	}
+
+	// an aborted probe lets another probe through
+	cb.release(host, probe)
+	if got := cb.State(host); got != CircuitHalfOpen {
+		t.Fatalf("CircuitBreaker.State() = %v, want %v", got, CircuitHalfOpen)
+	}
+	probe, err = cb.allow(host)
+	if err != nil {
+		t.Fatalf("CircuitBreaker.allow() error = %v, wantErr %v", err, nil)
+	}
+
+	// a failed probe opens the circuit again
+	cb.record(host, probe, fail, nil)
+	if got := cb.State(host); got != CircuitOpen {
+		t.Fatalf("CircuitBreaker.State() = %v, want %v", got, CircuitOpen)
+	}
+
+	// a successful probe closes the circuit
+	time.Sleep(50 * time.Millisecond)
+	probe, err = cb.allow(host)
+	if err != nil {
+		t.Fatalf("CircuitBreaker.allow() error = %v, wantErr %v", err, nil)
+	}
+	cb.record(host, probe, ok, nil)
+	if got := cb.State(host); got != CircuitClosed {
+		t.Fatalf("CircuitBreaker.State() = %v, want %v", got, CircuitClosed)
+	}
+
+	want := []string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+		"open->half-open",
+		"half-open->closed",
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("state changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("state changes = %v, want %v", changes, want)
+			break
+		}
+	}
+}
+
+func TestTransport_CircuitBreaker(t *testing.T) {
+	var count int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	transport := NewTransport(nil)
+	transport.Policy = func() Policy {
+		return &GenericPolicy{
+			Retryable: DefaultPredicate,
+			Backoff:   DefaultBackoff,
+			MinWait:   time.Millisecond,
+			MaxWait:   time.Millisecond,
+			MaxRetry:  5,
+		}
+	}
+	transport.CircuitBreaker = &CircuitBreaker{
+		FailureThreshold: 3,
+		CoolDown:         time.Hour,
+	}
+	client := &http.Client{Transport: transport}
+
+	// retries stop once the circuit opens
+	if _, err := client.Get(ts.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Client.Get() error = %v, wantErr %v", err, ErrCircuitOpen)
+	}
+	if got := atomic.LoadInt64(&count); got != 3 {
+		t.Errorf("number of requests = %d, want %d", got, 3)
+	}
+
+	// further requests are short-circuited
+	if _, err := client.Get(ts.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Client.Get() error = %v, wantErr %v", err, ErrCircuitOpen)
+	}
+	if got := atomic.LoadInt64(&count); got != 3 {
+		t.Errorf("number of requests = %d, want %d", got, 3)
+	}
+}
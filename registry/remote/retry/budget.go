@@ -0,0 +1,165 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultRetryBudgetRatio is the default value of RetryBudget.Ratio.
+const DefaultRetryBudgetRatio = 0.2
+
+// DefaultRetryBudgetMinRetries is the default value of RetryBudget.MinRetries.
+const DefaultRetryBudgetMinRetries = 10
+
+// DefaultRetryBudgetWindow is the default value of RetryBudget.Window.
+const DefaultRetryBudgetWindow = 10 * time.Second
+
+// RetryBudget caps the retries to each host as a fraction of the requests to
+// the host within a sliding window, so that retries do not amplify the load
+// of a struggling host.
+//
+// A RetryBudget is safe for concurrent use, and should be shared by the
+// transports accessing the same hosts. Its zero value is a usable retry
+// budget with the default parameters.
+type RetryBudget struct {
+	// Ratio is the maximum ratio of the retries to the requests.
+	// For instance, a ratio of 0.2 allows 1 retry every 5 requests.
+	// If less than or equal to 0, DefaultRetryBudgetRatio is used.
+	Ratio float64
+
+	// MinRetries is the number of retries allowed within the window
+	// regardless of the ratio, so that hosts with few requests can still be
+	// retried.
+	// If less than or equal to 0, DefaultRetryBudgetMinRetries is used.
+	MinRetries int
+
+	// Window is the duration of the sliding window in which the requests and
+	// the retries are counted.
+	// If less than or equal to 0, DefaultRetryBudgetWindow is used.
+	Window time.Duration
+
+	// OnExhausted, if set, is called when a retry to a host is denied as the
+	// budget is exhausted.
+	OnExhausted func(host string)
+
+	lock     sync.Mutex
+	counters map[string]*budgetCounter
+}
+
+// budgetCounter counts the requests and the retries to a host in a sliding
+// window, approximated by the counts in the current and the previous fixed
+// windows.
+type budgetCounter struct {
+	start        time.Time
+	requests     float64
+	retries      float64
+	prevRequests float64
+	prevRetries  float64
+}
+
+// rotate moves to the fixed window containing now.
+func (c *budgetCounter) rotate(now time.Time, window time.Duration) {
+	switch elapsed := now.Sub(c.start); {
+	case elapsed >= 2*window:
+		c.prevRequests, c.prevRetries = 0, 0
+		c.requests, c.retries = 0, 0
+		c.start = now
+	case elapsed >= window:
+		c.prevRequests, c.prevRetries = c.requests, c.retries
+		c.requests, c.retries = 0, 0
+		c.start = c.start.Add(window)
+	}
+}
+
+// counts returns the estimated numbers of the requests and the retries in the
+// sliding window ending at now.
+func (c *budgetCounter) counts(now time.Time, window time.Duration) (requests, retries float64) {
+	weight := 1 - float64(now.Sub(c.start))/float64(window)
+	return c.prevRequests*weight + c.requests, c.prevRetries*weight + c.retries
+}
+
+// recordRequest records a request to the host.
+func (b *RetryBudget) recordRequest(host string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	c := b.counter(host, time.Now())
+	c.requests++
+}
+
+// allowRetry returns true and records the retry if a retry to the host is
+// within the budget.
+func (b *RetryBudget) allowRetry(host string) bool {
+	if b.withdraw(host) {
+		return true
+	}
+	if b.OnExhausted != nil {
+		b.OnExhausted(host)
+	}
+	return false
+}
+
+// withdraw records a retry to the host if it is within the budget.
+func (b *RetryBudget) withdraw(host string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	c := b.counter(host, now)
+	requests, retries := c.counts(now, b.window())
+	if retries+1 > max(float64(b.minRetries()), requests*b.ratio()) {
+		return false
+	}
+	c.retries++
+	return true
+}
+
+// counter returns the counter of the host rotated to now.
+func (b *RetryBudget) counter(host string, now time.Time) *budgetCounter {
+	c, ok := b.counters[host]
+	if !ok {
+		if b.counters == nil {
+			b.counters = make(map[string]*budgetCounter)
+		}
+		c = &budgetCounter{start: now}
+		b.counters[host] = c
+	}
+	c.rotate(now, b.window())
+	return c
+}
+
+func (b *RetryBudget) ratio() float64 {
+	if b.Ratio <= 0 {
+		return DefaultRetryBudgetRatio
+	}
+	return b.Ratio
+}
+
+func (b *RetryBudget) minRetries() int {
+	if b.MinRetries <= 0 {
+		return DefaultRetryBudgetMinRetries
+	}
+	return b.MinRetries
+}
+
+func (b *RetryBudget) window() time.Duration {
+	if b.Window <= 0 {
+		return DefaultRetryBudgetWindow
+	}
+	return b.Window
+}
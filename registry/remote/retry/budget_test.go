@@ -0,0 +1,125 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryBudget(t *testing.T) {
+	host := "registry.example"
+	var exhausted []string
+	b := &RetryBudget{
+		Ratio:      0.5,
+		MinRetries: 1,
+		Window:     time.Hour,
+		OnExhausted: func(host string) {
+			exhausted = append(exhausted, host)
+		},
+	}
+
+	// the minimum retries are allowed without requests
+	if !b.allowRetry(host) {
+		t.Fatal("RetryBudget.allowRetry() = false, want true")
+	}
+	if b.allowRetry(host) {
+		t.Fatal("RetryBudget.allowRetry() = true, want false")
+	}
+
+	// retries are allowed by the ratio of the requests
+	for i := 0; i < 4; i++ {
+		b.recordRequest(host)
+	}
+	if !b.allowRetry(host) {
+		t.Fatal("RetryBudget.allowRetry() = false, want true")
+	}
+	if b.allowRetry(host) {
+		t.Fatal("RetryBudget.allowRetry() = true, want false")
+	}
+
+	// hosts have separate budgets
+	if !b.allowRetry("other.example") {
+		t.Fatal("RetryBudget.allowRetry() = false, want true")
+	}
+	if len(exhausted) != 2 || exhausted[0] != host || exhausted[1] != host {
+		t.Errorf("exhausted hosts = %v, want [%s %s]", exhausted, host, host)
+	}
+}
+
+func Test_budgetCounter(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	window := 10 * time.Second
+	c := &budgetCounter{start: start}
+	c.requests, c.retries = 10, 4
+
+	// the previous window is weighted by its overlap with the sliding window
+	now := start.Add(15 * time.Second)
+	c.rotate(now, window)
+	c.requests++
+	requests, retries := c.counts(now, window)
+	if requests != 6 || retries != 2 {
+		t.Errorf("budgetCounter.counts() = (%v, %v), want (6, 2)", requests, retries)
+	}
+
+	// the counts expire after 2 windows
+	now = now.Add(2 * window)
+	c.rotate(now, window)
+	requests, retries = c.counts(now, window)
+	if requests != 0 || retries != 0 {
+		t.Errorf("budgetCounter.counts() = (%v, %v), want (0, 0)", requests, retries)
+	}
+}
+
+func TestTransport_RetryBudget(t *testing.T) {
+	var count int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	transport := NewTransport(nil)
+	transport.Policy = func() Policy {
+		return &GenericPolicy{
+			Retryable: DefaultPredicate,
+			Backoff:   DefaultBackoff,
+			MinWait:   time.Millisecond,
+			MaxWait:   time.Millisecond,
+			MaxRetry:  5,
+		}
+	}
+	transport.RetryBudget = &RetryBudget{
+		MinRetries: 2,
+	}
+	client := &http.Client{Transport: transport}
+
+	// the last response is returned once the budget is exhausted
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Client.Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Client.Get() status = %v, want %v", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	if got := atomic.LoadInt64(&count); got != 3 {
+		t.Errorf("number of requests = %d, want %d", got, 3)
+	}
+}
@@ -16,6 +16,7 @@ limitations under the License.
 package retry
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 )
@@ -39,6 +40,17 @@ type Transport struct {
 	// Policy returns a retry Policy to use for the request.
 	// If nil, DefaultPolicy is used to determine if the request should be retried.
 	Policy func() Policy
+
+	// CircuitBreaker, if set, short-circuits the requests to the hosts with
+	// consecutive failures, including the retries.
+	// It should be shared by the transports accessing the same hosts.
+	CircuitBreaker *CircuitBreaker
+
+	// RetryBudget, if set, caps the retries to each host as a fraction of the
+	// requests. The last response is returned if a retry is not within the
+	// budget.
+	// It should be shared by the transports accessing the same hosts.
+	RetryBudget *RetryBudget
 }
 
 // NewTransport creates an HTTP Transport with the default retry policy.
@@ -55,9 +67,23 @@ func NewTransport(base http.RoundTripper) *Transport {
 func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	ctx := req.Context()
 	policy := t.policy()
+	host := req.URL.Host
+	if t.RetryBudget != nil {
+		t.RetryBudget.recordRequest(host)
+	}
 	attempt := 0
 	for {
+		var cbTicket ticket
+		if t.CircuitBreaker != nil {
+			var err error
+			if cbTicket, err = t.CircuitBreaker.allow(host); err != nil {
+				return nil, fmt.Errorf("%s: %w", host, err)
+			}
+		}
 		resp, respErr := t.roundTrip(req)
+		if t.CircuitBreaker != nil {
+			t.CircuitBreaker.record(host, cbTicket, resp, respErr)
+		}
 		duration, err := policy.Retry(attempt, resp, respErr)
 		if err != nil {
 			if respErr == nil {
@@ -68,6 +94,9 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		if duration < 0 {
 			return resp, respErr
 		}
+		if t.RetryBudget != nil && !t.RetryBudget.allowRetry(host) {
+			return resp, respErr
+		}
 
 		// rewind the body if possible
 		if req.Body != nil {
@@ -0,0 +1,315 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retry
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limit header keys.
+// References:
+//   - https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+//   - https://docs.docker.com/docker-hub/usage/pulls/
+const (
+	headerRateLimitLimit      = "RateLimit-Limit"
+	headerRateLimitRemaining  = "RateLimit-Remaining"
+	headerRateLimitReset      = "RateLimit-Reset"
+	headerXRateLimitLimit     = "X-RateLimit-Limit"
+	headerXRateLimitRemaining = "X-RateLimit-Remaining"
+	headerXRateLimitReset     = "X-RateLimit-Reset"
+)
+
+// RateLimit is the rate limit status advertised by a remote server.
+type RateLimit struct {
+	// Limit is the number of requests allowed in a window.
+	Limit int
+
+	// Remaining is the number of requests remaining in the current window.
+	Remaining int
+
+	// Reset is the time when the quota is restored.
+	// It is zero if the server does not advertise it.
+	Reset time.Time
+
+	// Window is the duration of the window for a sliding window rate limit,
+	// such as "w=21600" in the "RateLimit-Limit: 100;w=21600" header returned
+	// by Docker Hub.
+	// It is zero if the server does not advertise it.
+	Window time.Duration
+}
+
+// RateLimitTransport is an HTTP transport pacing the requests to each host by
+// the rate limits advertised by the host in the responses, so that the quota
+// is not exhausted.
+//
+// The rate limits are read from the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers, as well as their X-RateLimit-* variants and the
+// "<limit>;w=<window>" form returned by Docker Hub. Each host is tracked by a
+// token bucket shared by all the requests sent through the transport, where
+// the requests are sent immediately until the host reports the quota
+// exhausted, and then wait until the quota is restored. The requests are not
+// counted locally, as hosts may not count all the requests, such as the blob
+// requests to Docker Hub. A 429 Too Many Requests response with a Retry-After
+// header pauses all the requests to the host as well.
+//
+// RateLimitTransport is expected to be the base transport of [Transport], so
+// that the retries are paced as well. For instance,
+//
+//	client := &http.Client{
+//		Transport: retry.NewTransport(retry.NewRateLimitTransport(nil)),
+//	}
+//
+// The client should be shared by all the repositories accessing the same
+// hosts so that they see the same rate limits.
+type RateLimitTransport struct {
+	// Base is the underlying HTTP transport to use.
+	// If nil, http.DefaultTransport is used for round trips.
+	Base http.RoundTripper
+
+	// OnRateLimit, if set, is called with the rate limit status whenever a
+	// host advertises it in a response.
+	OnRateLimit func(host string, limit RateLimit)
+
+	buckets sync.Map // map[string]*rateLimitBucket
+}
+
+// NewRateLimitTransport creates an HTTP transport pacing the requests by the
+// rate limits advertised by the remote servers.
+func NewRateLimitTransport(base http.RoundTripper) *RateLimitTransport {
+	return &RateLimitTransport{
+		Base: base,
+	}
+}
+
+// RoundTrip executes a single HTTP transaction, returning a Response for the
+// provided Request.
+// It waits before sending the request if the rate limit of the host is
+// exhausted.
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if value, ok := t.buckets.Load(host); ok {
+		if wait := value.(*rateLimitBucket).delay(time.Now()); wait > 0 {
+			ctx := req.Context()
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+
+	resp, err := t.roundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	limit, ok := parseRateLimit(resp.Header, now)
+	if ok {
+		value, _ := t.buckets.LoadOrStore(host, &rateLimitBucket{})
+		value.(*rateLimitBucket).update(limit, now)
+		if t.OnRateLimit != nil {
+			t.OnRateLimit(host, limit)
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if retryAfter := parseRetryAfter(resp.Header, now); retryAfter > 0 {
+			value, _ := t.buckets.LoadOrStore(host, &rateLimitBucket{})
+			value.(*rateLimitBucket).pause(now.Add(retryAfter))
+		}
+	}
+	return resp, nil
+}
+
+func (t *RateLimitTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if t.Base == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return t.Base.RoundTrip(req)
+}
+
+// rateLimitBucket is a token bucket tracking the rate limit of a host.
+type rateLimitBucket struct {
+	lock sync.Mutex
+	// known indicates whether the rate limit of the host is known.
+	known bool
+	// tokens is the number of requests remaining as last reported by the
+	// host, restored over time for sliding windows.
+	tokens float64
+	// capacity is the number of requests allowed in a window.
+	capacity float64
+	// rate is the number of tokens restored per second for sliding windows.
+	rate float64
+	// resetAt is the time when the tokens are restored to the capacity.
+	resetAt time.Time
+	// updatedAt is the time when the tokens were last refilled.
+	updatedAt time.Time
+}
+
+// delay returns the duration to wait before sending a request to the host.
+// Requests wait only if the quota is exhausted, as the tokens are not taken
+// locally but reported by the host for the requests counted by the host.
+func (b *rateLimitBucket) delay(now time.Time) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refill(now)
+	switch {
+	case !b.known:
+		// the host is paused by a 429 response if resetAt is set
+		if !b.resetAt.IsZero() {
+			return b.resetAt.Sub(now)
+		}
+		return 0
+	case b.tokens >= 1:
+		return 0
+	case !b.resetAt.IsZero():
+		return b.resetAt.Sub(now)
+	case b.rate > 0:
+		return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	default:
+		// the time when the quota is restored is unknown
+		return 0
+	}
+}
+
+// refill restores the tokens by the time elapsed.
+func (b *rateLimitBucket) refill(now time.Time) {
+	if !b.resetAt.IsZero() && !now.Before(b.resetAt) {
+		if b.known {
+			b.tokens = b.capacity
+		}
+		b.resetAt = time.Time{}
+	} else if b.rate > 0 && now.After(b.updatedAt) {
+		b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.updatedAt).Seconds()*b.rate)
+	}
+	b.updatedAt = now
+}
+
+// update updates the bucket by the rate limit advertised by the host.
+func (b *rateLimitBucket) update(limit RateLimit, now time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refill(now)
+	// the host is the source of truth, as not all requests are counted
+	b.tokens = float64(limit.Remaining)
+	b.known = true
+	b.capacity = float64(limit.Limit)
+	b.resetAt = limit.Reset
+	b.rate = 0
+	if limit.Window > 0 && limit.Reset.IsZero() {
+		b.rate = float64(limit.Limit) / limit.Window.Seconds()
+	}
+}
+
+// pause stops sending requests until the given time.
+func (b *rateLimitBucket) pause(until time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.tokens = math.Min(b.tokens, 0)
+	if until.After(b.resetAt) {
+		b.resetAt = until
+	}
+}
+
+// parseRateLimit parses the rate limit headers in the response.
+// Returns false if the rate limit is not advertised.
+func parseRateLimit(header http.Header, now time.Time) (RateLimit, bool) {
+	limitValue := header.Get(headerRateLimitLimit)
+	remainingValue := header.Get(headerRateLimitRemaining)
+	resetValue := header.Get(headerRateLimitReset)
+	if limitValue == "" && remainingValue == "" {
+		limitValue = header.Get(headerXRateLimitLimit)
+		remainingValue = header.Get(headerXRateLimitRemaining)
+		resetValue = header.Get(headerXRateLimitReset)
+	}
+
+	var limit RateLimit
+	var ok bool
+	limit.Limit, limit.Window, ok = parseRateLimitValue(limitValue)
+	if !ok {
+		return RateLimit{}, false
+	}
+	var window time.Duration
+	limit.Remaining, window, ok = parseRateLimitValue(remainingValue)
+	if !ok {
+		return RateLimit{}, false
+	}
+	if limit.Window == 0 {
+		limit.Window = window
+	}
+	if reset, err := strconv.ParseInt(strings.TrimSpace(resetValue), 10, 64); err == nil && reset >= 0 {
+		if reset > maxRateLimitResetSeconds {
+			// the reset is a Unix timestamp, such as the X-RateLimit-Reset
+			// header of GitHub
+			limit.Reset = time.Unix(reset, 0)
+		} else {
+			limit.Reset = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+	return limit, true
+}
+
+// maxRateLimitResetSeconds is the maximum value of the rate limit reset
+// header treated as seconds, where greater values are treated as Unix
+// timestamps.
+const maxRateLimitResetSeconds = 365 * 24 * 60 * 60
+
+// parseRateLimitValue parses a rate limit header value in the form of
+// "<quota>" or "<quota>;w=<window>", where the window is in seconds.
+func parseRateLimitValue(value string) (int, time.Duration, bool) {
+	quotaValue, params, _ := strings.Cut(value, ";")
+	quota, err := strconv.Atoi(strings.TrimSpace(quotaValue))
+	if err != nil || quota < 0 {
+		return 0, 0, false
+	}
+	var window time.Duration
+	for _, param := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if key != "w" {
+			continue
+		}
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			window = time.Duration(seconds) * time.Second
+		}
+	}
+	return quota, window, true
+}
+
+// parseRetryAfter parses the Retry-After header in either delay seconds or
+// HTTP date, and returns the duration to wait.
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	value := header.Get(headerRetryAfter)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,208 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_parseRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   RateLimit
+		wantOK bool
+	}{
+		{
+			name: "IETF headers",
+			header: http.Header{
+				"Ratelimit-Limit":     {"100"},
+				"Ratelimit-Remaining": {"42"},
+				"Ratelimit-Reset":     {"30"},
+			},
+			want:   RateLimit{Limit: 100, Remaining: 42, Reset: now.Add(30 * time.Second)},
+			wantOK: true,
+		},
+		{
+			name: "Docker Hub headers",
+			header: http.Header{
+				"Ratelimit-Limit":     {"100;w=21600"},
+				"Ratelimit-Remaining": {"76;w=21600"},
+			},
+			want:   RateLimit{Limit: 100, Remaining: 76, Window: 6 * time.Hour},
+			wantOK: true,
+		},
+		{
+			name: "X-RateLimit headers with Unix timestamp",
+			header: http.Header{
+				"X-Ratelimit-Limit":     {"5000"},
+				"X-Ratelimit-Remaining": {"4999"},
+				"X-Ratelimit-Reset":     {"1700000060"},
+			},
+			want:   RateLimit{Limit: 5000, Remaining: 4999, Reset: now.Add(time.Minute)},
+			wantOK: true,
+		},
+		{
+			name:   "no headers",
+			header: http.Header{},
+		},
+		{
+			name: "invalid remaining",
+			header: http.Header{
+				"Ratelimit-Limit":     {"100"},
+				"Ratelimit-Remaining": {"unknown"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRateLimit(tt.header, now)
+			if ok != tt.wantOK {
+				t.Fatalf("parseRateLimit() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("parseRateLimit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rateLimitBucket_SlidingWindow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := &rateLimitBucket{}
+	b.update(RateLimit{Limit: 100, Remaining: 1, Window: 100 * time.Second}, now)
+
+	// the requests are not counted locally, as not all of them are counted
+	// by the host
+	for i := 0; i < 3; i++ {
+		if wait := b.delay(now); wait != 0 {
+			t.Fatalf("rateLimitBucket.delay() = %v, want 0", wait)
+		}
+	}
+	// the requests wait once the host reports the quota exhausted
+	b.update(RateLimit{Limit: 100, Remaining: 0, Window: 100 * time.Second}, now)
+	for i := 0; i < 2; i++ {
+		if wait := b.delay(now); wait != time.Second {
+			t.Errorf("rateLimitBucket.delay() = %v, want %v", wait, time.Second)
+		}
+	}
+	// tokens are restored over time
+	if wait := b.delay(now.Add(time.Second / 2)); wait != time.Second/2 {
+		t.Errorf("rateLimitBucket.delay() = %v, want %v", wait, time.Second/2)
+	}
+	if wait := b.delay(now.Add(time.Second)); wait != 0 {
+		t.Errorf("rateLimitBucket.delay() = %v, want 0", wait)
+	}
+}
+
+func Test_rateLimitBucket_Reset(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	resetAt := now.Add(30 * time.Second)
+	b := &rateLimitBucket{}
+	b.update(RateLimit{Limit: 2, Remaining: 1, Reset: resetAt}, now)
+
+	if wait := b.delay(now); wait != 0 {
+		t.Fatalf("rateLimitBucket.delay() = %v, want 0", wait)
+	}
+	// the requests wait for the reset once the quota is exhausted
+	b.update(RateLimit{Limit: 2, Remaining: 0, Reset: resetAt}, now)
+	for i := 0; i < 2; i++ {
+		if wait := b.delay(now); wait != 30*time.Second {
+			t.Errorf("rateLimitBucket.delay() = %v, want %v", wait, 30*time.Second)
+		}
+	}
+	// the quota is restored in the new window
+	if wait := b.delay(resetAt); wait != 0 {
+		t.Errorf("rateLimitBucket.delay() = %v, want 0", wait)
+	}
+	if wait := b.delay(resetAt); wait != 0 {
+		t.Errorf("rateLimitBucket.delay() = %v, want 0", wait)
+	}
+}
+
+func Test_rateLimitBucket_Pause(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := &rateLimitBucket{}
+	b.pause(now.Add(time.Minute))
+	if wait := b.delay(now); wait != time.Minute {
+		t.Errorf("rateLimitBucket.delay() = %v, want %v", wait, time.Minute)
+	}
+	if wait := b.delay(now.Add(time.Minute)); wait != 0 {
+		t.Errorf("rateLimitBucket.delay() = %v, want 0", wait)
+	}
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	var lock sync.Mutex
+	var requestTimes []time.Time
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requestTimes = append(requestTimes, time.Now())
+		lock.Unlock()
+		w.Header().Set("RateLimit-Limit", "10")
+		w.Header().Set("RateLimit-Remaining", "0")
+		w.Header().Set("RateLimit-Reset", "1")
+	}))
+	defer ts.Close()
+	uri, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("invalid test http server: %v", err)
+	}
+
+	var gotHost string
+	var gotLimit RateLimit
+	transport := NewRateLimitTransport(nil)
+	transport.OnRateLimit = func(host string, limit RateLimit) {
+		gotHost, gotLimit = host, limit
+	}
+	client := &http.Client{Transport: transport}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("Client.Get() error = %v", err)
+		}
+		resp.Body.Close()
+	}
+	if gotHost != uri.Host || gotLimit.Limit != 10 || gotLimit.Remaining != 0 {
+		t.Errorf("OnRateLimit() got (%s, %v), want (%s, limit 10 with 0 remaining)", gotHost, gotLimit, uri.Host)
+	}
+	// the second request waits for the quota
+	if len(requestTimes) != 2 {
+		t.Fatalf("number of requests = %d, want 2", len(requestTimes))
+	}
+	if elapsed := requestTimes[1].Sub(requestTimes[0]); elapsed < 900*time.Millisecond {
+		t.Errorf("second request sent after %v, want at least 1s", elapsed)
+	}
+
+	// waiting is cancellable
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create test request: %v", err)
+	}
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RateLimitTransport.RoundTrip() error = %v, wantErr %v", err, context.DeadlineExceeded)
+	}
+}
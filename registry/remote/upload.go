@@ -0,0 +1,323 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/internal/errutil"
+)
+
+const (
+	// headerOCIChunkMinLength is the "OCI-Chunk-Min-Length" header.
+	// If present on the response of initiating a blob upload, it indicates the
+	// minimum size of the chunks accepted by the registry.
+	//
+	// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pushing-a-blob-in-chunks
+	headerOCIChunkMinLength = "OCI-Chunk-Min-Length"
+
+	// defaultBlobUploadChunkSize is the chunk size used for resuming a blob
+	// upload when Repository.BlobUploadChunkSize is not set.
+	defaultBlobUploadChunkSize int64 = 8 * 1024 * 1024 // 8 MiB
+
+	// maxBlobUploadChunkRetries is the maximum number of retries for uploading
+	// a chunk from the offset reported by the registry.
+	maxBlobUploadChunkRetries = 3
+)
+
+// BlobUploadSession represents an in-progress chunked blob upload.
+//
+// A session is reported by Repository.HandleBlobUploadSession as the upload
+// proceeds. It can be persisted, for example in JSON, and resumed later by
+// Repository.ResumeBlobUpload, even by another process.
+//
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pushing-a-blob-in-chunks
+type BlobUploadSession struct {
+	// Location is the URL of the upload session.
+	Location string `json:"location"`
+	// Descriptor describes the blob being uploaded.
+	Descriptor ocispec.Descriptor `json:"descriptor"`
+	// Offset is the number of bytes accepted by the registry.
+	Offset int64 `json:"offset"`
+}
+
+// ResumeBlobUpload resumes the chunked upload of a blob from the offset
+// reported by the registry for the given session.
+// content must provide the whole blob from the beginning. If content
+// implements io.Seeker, it is seeked to the offset; otherwise the bytes before
+// the offset are read and discarded.
+//
+// The chunks are sized by BlobUploadChunkSize, or by a default (currently
+// 8 MiB) if BlobUploadChunkSize is not positive.
+// Returns ErrNotFound if the upload session no longer exists.
+func (r *Repository) ResumeBlobUpload(ctx context.Context, session BlobUploadSession, content io.Reader) error {
+	s := &blobStore{repo: r}
+	return s.resumePush(ctx, session, content)
+}
+
+// resumePush resumes the chunked upload session.
+func (s *blobStore) resumePush(ctx context.Context, session BlobUploadSession, content io.Reader) error {
+	// pushing usually requires both pull and push actions.
+	// Reference: https://github.com/distribution/distribution/blob/v2.7.1/registry/handlers/app.go#L921-L930
+	ctx = auth.AppendRepositoryScope(ctx, s.repo.Reference, auth.ActionPull, auth.ActionPush)
+	location, offset, err := s.uploadStatus(ctx, session.Location)
+	if err != nil {
+		return err
+	}
+	if offset > session.Descriptor.Size {
+		return fmt.Errorf("%s: upload offset %d exceeds the blob size %d", session.Descriptor.Digest, offset, session.Descriptor.Size)
+	}
+	if seeker, ok := content.(io.Seeker); ok {
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+	} else if _, err := io.CopyN(io.Discard, content, offset); err != nil {
+		return err
+	}
+	session.Location = location
+	session.Offset = offset
+
+	chunkSize := s.repo.BlobUploadChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultBlobUploadChunkSize
+	}
+	return s.pushChunks(ctx, &session, content, chunkSize)
+}
+
+// pushChunked uploads the content in chunks to the upload session initiated
+// by resp.
+func (s *blobStore) pushChunked(ctx context.Context, resp *http.Response, location *url.URL, expected ocispec.Descriptor, content io.Reader) error {
+	chunkSize := s.repo.BlobUploadChunkSize
+	if minLength, err := strconv.ParseInt(resp.Header.Get(headerOCIChunkMinLength), 10, 64); err == nil && minLength > chunkSize {
+		chunkSize = minLength
+	}
+	session := BlobUploadSession{
+		Location:   location.String(),
+		Descriptor: expected,
+	}
+	if s.repo.HandleBlobUploadSession != nil {
+		s.repo.HandleBlobUploadSession(session)
+	}
+	return s.pushChunks(ctx, &session, content, chunkSize)
+}
+
+// pushChunks uploads the rest of the content in chunks from the offset of the
+// session, and completes the upload.
+func (s *blobStore) pushChunks(ctx context.Context, session *BlobUploadSession, content io.Reader, chunkSize int64) error {
+	size := session.Descriptor.Size
+	buf := make([]byte, min(chunkSize, size-session.Offset))
+	for session.Offset < size {
+		chunk := buf[:min(int64(len(buf)), size-session.Offset)]
+		if _, err := io.ReadFull(content, chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("failed to read chunk at offset %d: %w", session.Offset, err)
+		}
+		if err := s.pushChunk(ctx, session, chunk); err != nil {
+			return err
+		}
+		if s.repo.HandleBlobUploadSession != nil {
+			s.repo.HandleBlobUploadSession(*session)
+		}
+	}
+	return s.completeChunkedPush(ctx, session)
+}
+
+// pushChunk uploads a chunk starting at the offset of the session.
+// If the upload fails, the chunk is uploaded again from the offset reported
+// by the registry.
+func (s *blobStore) pushChunk(ctx context.Context, session *BlobUploadSession, chunk []byte) error {
+	start := session.Offset
+	end := start + int64(len(chunk))
+	var retry int
+	for session.Offset < end {
+		offset := session.Offset
+		err := s.patchChunk(ctx, session, chunk[offset-start:])
+		if err == nil {
+			if session.Offset > offset {
+				// progress has been made
+				continue
+			}
+			err = fmt.Errorf("%s: upload offset %d is not advanced by the registry", session.Descriptor.Digest, offset)
+		}
+		if retry >= maxBlobUploadChunkRetries || ctx.Err() != nil {
+			return err
+		}
+		retry++
+
+		// resume from the offset accepted by the registry
+		location, accepted, statusErr := s.uploadStatus(ctx, session.Location)
+		if statusErr != nil || accepted < start || accepted > end {
+			return err
+		}
+		session.Location = location
+		session.Offset = accepted
+	}
+	return nil
+}
+
+// patchChunk uploads the given chunk at the offset of the session, and
+// updates the session with the response.
+//
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pushing-a-blob-in-chunks
+func (s *blobStore) patchChunk(ctx context.Context, session *BlobUploadSession, chunk []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, session.Location, bytes.NewReader(chunk))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", session.Offset, session.Offset+int64(len(chunk))-1))
+	resp, err := s.repo.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return errutil.ParseErrorResponse(resp)
+	}
+	location := session.Location
+	if resp.Header.Get("Location") != "" {
+		u, err := uploadLocation(req, resp)
+		if err != nil {
+			return err
+		}
+		location = u.String()
+	}
+	offset := session.Offset + int64(len(chunk))
+	if rangeHeader := resp.Header.Get("Range"); rangeHeader != "" {
+		if offset, err = parseUploadRange(rangeHeader); err != nil {
+			return err
+		}
+	}
+	session.Location = location
+	session.Offset = offset
+	return nil
+}
+
+// completeChunkedPush closes the upload session.
+func (s *blobStore) completeChunkedPush(ctx context.Context, session *BlobUploadSession) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, session.Location, http.NoBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	q := req.URL.Query()
+	q.Set("digest", session.Descriptor.Digest.String())
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := s.repo.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return errutil.ParseErrorResponse(resp)
+	}
+	return nil
+}
+
+// uploadStatus returns the location and the offset of the upload session
+// reported by the registry.
+//
+// Reference: https://github.com/opencontainers/distribution-spec/blob/v1.1.1/spec.md#pushing-a-blob-in-chunks
+func (s *blobStore) uploadStatus(ctx context.Context, location string) (string, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := s.repo.do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		if resp.Header.Get("Location") != "" {
+			u, err := uploadLocation(req, resp)
+			if err != nil {
+				return "", 0, err
+			}
+			location = u.String()
+		}
+		offset, err := parseUploadRange(resp.Header.Get("Range"))
+		if err != nil {
+			return "", 0, err
+		}
+		return location, offset, nil
+	case http.StatusNotFound:
+		return "", 0, fmt.Errorf("upload session %s: %w", location, errdef.ErrNotFound)
+	default:
+		return "", 0, errutil.ParseErrorResponse(resp)
+	}
+}
+
+// uploadLocation returns the location of the upload session in the response
+// to req.
+func uploadLocation(req *http.Request, resp *http.Response) (*url.URL, error) {
+	location, err := resp.Location()
+	if err != nil {
+		return nil, err
+	}
+	// work-around solution for https://github.com/oras-project/oras-go/issues/177
+	// For some registries, if the port 443 is explicitly set to the hostname
+	// like registry.wabbit-networks.io:443/myrepo, blob push will fail since
+	// the hostname of the Location header in the response is set to
+	// registry.wabbit-networks.io instead of registry.wabbit-networks.io:443.
+	reqHostname := req.URL.Hostname()
+	reqPort := req.URL.Port()
+	locationHostname := location.Hostname()
+	locationPort := location.Port()
+	// if location port 443 is missing, add it back
+	if reqPort == "443" && locationHostname == reqHostname && locationPort == "" {
+		location.Host = locationHostname + ":" + reqPort
+	}
+	return location, nil
+}
+
+// parseUploadRange parses the Range header of an upload session in the form
+// of "0-<end>", and returns the number of bytes accepted by the registry.
+func parseUploadRange(rangeHeader string) (int64, error) {
+	rangeValue := strings.TrimPrefix(rangeHeader, "bytes=")
+	start, end, ok := strings.Cut(rangeValue, "-")
+	if !ok || start != "0" {
+		return 0, fmt.Errorf("invalid upload range %q", rangeHeader)
+	}
+	last, err := strconv.ParseInt(end, 10, 64)
+	if err != nil || last < 0 {
+		return 0, fmt.Errorf("invalid upload range %q", rangeHeader)
+	}
+	if last == 0 {
+		// "0-0" is ambiguous as registries report it when nothing has been
+		// uploaded. Resending the first byte is preferred over skipping it.
+		return 0, nil
+	}
+	return last + 1, nil
+}
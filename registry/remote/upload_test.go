@@ -0,0 +1,400 @@
+/*
+Copyright The ORAS Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// chunkedUploadServer is a test server accepting chunked blob uploads.
+type chunkedUploadServer struct {
+	t    *testing.T
+	uuid string
+
+	lock sync.Mutex
+	// data is the content accepted by the upload session.
+	data []byte
+	// committed is the content of the blob after the upload is completed.
+	committed []byte
+	// numPatch is the number of PATCH requests.
+	numPatch int
+	// failPatch, if set, is invoked for each PATCH request and returns the
+	// number of bytes of the chunk to be accepted before dropping the
+	// connection.
+	failPatch func(n int) (accepted int, fail bool)
+	// minChunkLength is reported as the OCI-Chunk-Min-Length header.
+	minChunkLength int
+}
+
+func (s *chunkedUploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	location := "/v2/test/blobs/uploads/" + s.uuid
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/test/blobs/uploads/":
+		w.Header().Set("Location", location)
+		if s.minChunkLength > 0 {
+			w.Header().Set(headerOCIChunkMinLength, strconv.Itoa(s.minChunkLength))
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPatch && r.URL.Path == location:
+		s.numPatch++
+		if contentType := r.Header.Get("Content-Type"); contentType != "application/octet-stream" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "%d-%d", &start, &end); err != nil || start != len(s.data) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		chunk, err := io.ReadAll(r.Body)
+		if err != nil || len(chunk) != end-start+1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if s.failPatch != nil {
+			if accepted, fail := s.failPatch(s.numPatch); fail {
+				// simulate a dropped connection
+				s.data = append(s.data, chunk[:min(accepted, len(chunk))]...)
+				conn, _, err := http.NewResponseController(w).Hijack()
+				if err != nil {
+					s.t.Errorf("failed to hijack connection: %v", err)
+					return
+				}
+				conn.Close()
+				return
+			}
+		}
+		s.data = append(s.data, chunk...)
+		w.Header().Set("Location", location)
+		w.Header().Set("Range", fmt.Sprintf("0-%d", len(s.data)-1))
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodGet && r.URL.Path == location:
+		w.Header().Set("Location", location)
+		end := len(s.data) - 1
+		if end < 0 {
+			end = 0
+		}
+		w.Header().Set("Range", fmt.Sprintf("0-%d", end))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.URL.Path == location:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data := append(s.data, body...)
+		if r.URL.Query().Get("digest") != digest.FromBytes(data).String() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.committed = data
+		w.WriteHeader(http.StatusCreated)
+	default:
+		s.t.Errorf("unexpected access: %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newChunkedUploadTestRepository(t *testing.T, s *chunkedUploadServer) *Repository {
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	uri, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("invalid test http server: %v", err)
+	}
+	repo, err := NewRepository(uri.Host + "/test")
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+	repo.PlainHTTP = true
+	return repo
+}
+
+func Test_BlobStore_Push_Chunked(t *testing.T) {
+	blob := []byte("hello world, this is a chunked upload")
+	blobDesc := ocispec.Descriptor{
+		MediaType: "test",
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+	server := &chunkedUploadServer{
+		t:    t,
+		uuid: "4fd53bc9-565d-4527-ab80-3e051ac4880c",
+	}
+	repo := newChunkedUploadTestRepository(t, server)
+	repo.BlobUploadChunkSize = 8
+	var sessions []BlobUploadSession
+	repo.HandleBlobUploadSession = func(session BlobUploadSession) {
+		sessions = append(sessions, session)
+	}
+	ctx := context.Background()
+
+	if err := repo.Blobs().Push(ctx, blobDesc, bytes.NewReader(blob)); err != nil {
+		t.Fatalf("Blobs.Push() error = %v", err)
+	}
+	if !bytes.Equal(server.committed, blob) {
+		t.Errorf("Blobs.Push() = %q, want %q", server.committed, blob)
+	}
+	if got, want := server.numPatch, 5; got != want {
+		t.Errorf("count(PATCH) = %d, want %d", got, want)
+	}
+	if got, want := len(sessions), 6; got != want {
+		t.Fatalf("count(HandleBlobUploadSession()) = %d, want %d", got, want)
+	}
+	for i, session := range sessions {
+		if want := min(int64(i*8), blobDesc.Size); session.Offset != want {
+			t.Errorf("BlobUploadSession.Offset = %d, want %d", session.Offset, want)
+		}
+		if !strings.HasSuffix(session.Location, server.uuid) {
+			t.Errorf("BlobUploadSession.Location = %s, want suffix %s", session.Location, server.uuid)
+		}
+	}
+}
+
+func Test_BlobStore_Push_Chunked_MinChunkLength(t *testing.T) {
+	blob := []byte("hello world, this is a chunked upload")
+	blobDesc := ocispec.Descriptor{
+		MediaType: "test",
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+	server := &chunkedUploadServer{
+		t:              t,
+		uuid:           "4fd53bc9-565d-4527-ab80-3e051ac4880c",
+		minChunkLength: 16,
+	}
+	repo := newChunkedUploadTestRepository(t, server)
+	repo.BlobUploadChunkSize = 4
+	ctx := context.Background()
+
+	if err := repo.Push(ctx, blobDesc, bytes.NewReader(blob)); err != nil {
+		t.Fatalf("Repository.Push() error = %v", err)
+	}
+	if !bytes.Equal(server.committed, blob) {
+		t.Errorf("Repository.Push() = %q, want %q", server.committed, blob)
+	}
+	if got, want := server.numPatch, 3; got != want {
+		t.Errorf("count(PATCH) = %d, want %d", got, want)
+	}
+}
+
+func Test_BlobStore_Push_Chunked_Resume(t *testing.T) {
+	blob := []byte("hello world, this is a chunked upload")
+	blobDesc := ocispec.Descriptor{
+		MediaType: "test",
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+	server := &chunkedUploadServer{
+		t:    t,
+		uuid: "4fd53bc9-565d-4527-ab80-3e051ac4880c",
+		failPatch: func(n int) (int, bool) {
+			// the 2nd request partially fails
+			return 3, n == 2
+		},
+	}
+	repo := newChunkedUploadTestRepository(t, server)
+	repo.BlobUploadChunkSize = 8
+	ctx := context.Background()
+
+	if err := repo.Push(ctx, blobDesc, bytes.NewReader(blob)); err != nil {
+		t.Fatalf("Repository.Push() error = %v", err)
+	}
+	if !bytes.Equal(server.committed, blob) {
+		t.Errorf("Repository.Push() = %q, want %q", server.committed, blob)
+	}
+	if got, want := server.numPatch, 6; got != want {
+		t.Errorf("count(PATCH) = %d, want %d", got, want)
+	}
+}
+
+func Test_BlobStore_Push_Chunked_Failed(t *testing.T) {
+	blob := []byte("hello world, this is a chunked upload")
+	blobDesc := ocispec.Descriptor{
+		MediaType: "test",
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+	server := &chunkedUploadServer{
+		t:    t,
+		uuid: "4fd53bc9-565d-4527-ab80-3e051ac4880c",
+		failPatch: func(n int) (int, bool) {
+			return 0, n > 1
+		},
+	}
+	repo := newChunkedUploadTestRepository(t, server)
+	repo.BlobUploadChunkSize = 8
+	ctx := context.Background()
+
+	if err := repo.Push(ctx, blobDesc, bytes.NewReader(blob)); err == nil {
+		t.Fatal("Repository.Push() error = nil, wantErr true")
+	}
+	if got, want := server.numPatch, 1+1+maxBlobUploadChunkRetries; got != want {
+		t.Errorf("count(PATCH) = %d, want %d", got, want)
+	}
+	if server.committed != nil {
+		t.Errorf("Repository.Push() = %q, want nil", server.committed)
+	}
+}
+
+func TestRepository_ResumeBlobUpload(t *testing.T) {
+	blob := []byte("hello world, this is a chunked upload")
+	blobDesc := ocispec.Descriptor{
+		MediaType: "test",
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+	tests := []struct {
+		name    string
+		content func() io.Reader
+	}{
+		{
+			name: "seekable content",
+			content: func() io.Reader {
+				return bytes.NewReader(blob)
+			},
+		},
+		{
+			name: "non-seekable content",
+			content: func() io.Reader {
+				return io.MultiReader(bytes.NewReader(blob))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failing := true
+			server := &chunkedUploadServer{
+				t:    t,
+				uuid: "4fd53bc9-565d-4527-ab80-3e051ac4880c",
+				failPatch: func(n int) (int, bool) {
+					return 0, failing && n > 2
+				},
+			}
+			repo := newChunkedUploadTestRepository(t, server)
+			repo.BlobUploadChunkSize = 8
+			var persisted []byte
+			repo.HandleBlobUploadSession = func(session BlobUploadSession) {
+				var err error
+				if persisted, err = json.Marshal(session); err != nil {
+					t.Errorf("failed to persist upload session: %v", err)
+				}
+			}
+			ctx := context.Background()
+
+			if err := repo.Push(ctx, blobDesc, tt.content()); err == nil {
+				t.Fatal("Repository.Push() error = nil, wantErr true")
+			}
+
+			// resume the upload with a new repository
+			var session BlobUploadSession
+			if err := json.Unmarshal(persisted, &session); err != nil {
+				t.Fatalf("failed to load upload session: %v", err)
+			}
+			if got, want := session.Offset, int64(16); got != want {
+				t.Errorf("BlobUploadSession.Offset = %d, want %d", got, want)
+			}
+			failing = false
+			resumed, err := NewRepository(repo.Reference.String())
+			if err != nil {
+				t.Fatalf("NewRepository() error = %v", err)
+			}
+			resumed.PlainHTTP = true
+			if err := resumed.ResumeBlobUpload(ctx, session, tt.content()); err != nil {
+				t.Fatalf("Repository.ResumeBlobUpload() error = %v", err)
+			}
+			if !bytes.Equal(server.committed, blob) {
+				t.Errorf("Repository.ResumeBlobUpload() = %q, want %q", server.committed, blob)
+			}
+		})
+	}
+}
+
+func TestRepository_ResumeBlobUpload_NotFound(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+	uri, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("invalid test http server: %v", err)
+	}
+	repo, err := NewRepository(uri.Host + "/test")
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+	repo.PlainHTTP = true
+
+	session := BlobUploadSession{
+		Location: ts.URL + "/v2/test/blobs/uploads/unknown",
+		Descriptor: ocispec.Descriptor{
+			MediaType: "test",
+			Digest:    digest.FromBytes([]byte("foo")),
+			Size:      3,
+		},
+	}
+	err = repo.ResumeBlobUpload(context.Background(), session, strings.NewReader("foo"))
+	if !errors.Is(err, errdef.ErrNotFound) {
+		t.Errorf("Repository.ResumeBlobUpload() error = %v, wantErr %v", err, errdef.ErrNotFound)
+	}
+}
+
+func Test_parseUploadRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    int64
+		wantErr bool
+	}{
+		{name: "nothing uploaded", header: "0-0", want: 0},
+		{name: "partially uploaded", header: "0-99", want: 100},
+		{name: "with unit", header: "bytes=0-99", want: 100},
+		{name: "empty", header: "", wantErr: true},
+		{name: "non-zero start", header: "10-99", wantErr: true},
+		{name: "invalid end", header: "0-abc", wantErr: true},
+		{name: "negative end", header: "0--1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUploadRange(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUploadRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseUploadRange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}